@@ -0,0 +1,47 @@
+// Command breachbuild converts plain list of breached passwords, one per line,
+// into set file loaded by auth service from BREACHED_PASSWORDS_FILE.
+//
+//	go run ./cmd/breachbuild -in rockyou.txt -out breached-passwords.bin
+package main
+
+import (
+	"flag"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/breach"
+	"io"
+	"log"
+	"os"
+)
+
+func main() {
+	in := flag.String("in", "", "plain password list, standard input when empty")
+	out := flag.String("out", "breached-passwords.bin", "set file to write")
+	flag.Parse()
+
+	var r io.Reader = os.Stdin
+	if *in != "" {
+		f, err := os.Open(*in)
+		if err != nil {
+			log.Fatalf("failed to open password list due to: %s", err.Error())
+		}
+		defer f.Close()
+		r = f
+	}
+
+	set, err := breach.Build(r)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		log.Fatalf("failed to create set file due to: %s", err.Error())
+	}
+	_, err = set.WriteTo(f)
+	if err == nil {
+		err = f.Close()
+	}
+	if err != nil {
+		log.Fatalf("failed to write set file due to: %s", err.Error())
+	}
+	log.Printf("%d breached passwords written to %s", set.Len(), *out)
+}
@@ -1,40 +1,35 @@
 package main
 
 import (
+	"context"
 	"github.com/gin-gonic/gin"
+	"github.com/go-webauthn/webauthn/webauthn"
 	"github.com/kamva/mgm/v3"
-	amqp "github.com/rabbitmq/amqp091-go"
 	_ "github.com/ttodoshi/code-typing-auth-service/docs"
 	"github.com/ttodoshi/code-typing-auth-service/internal/adapters/handler/http"
 	"github.com/ttodoshi/code-typing-auth-service/internal/adapters/handler/http/api"
+	"github.com/ttodoshi/code-typing-auth-service/internal/adapters/idp/oauth"
 	"github.com/ttodoshi/code-typing-auth-service/internal/adapters/mq/rabbitmq"
+	"github.com/ttodoshi/code-typing-auth-service/internal/adapters/repository/memory"
 	"github.com/ttodoshi/code-typing-auth-service/internal/adapters/repository/mongodb"
+	"github.com/ttodoshi/code-typing-auth-service/internal/config"
 	"github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
 	"github.com/ttodoshi/code-typing-auth-service/internal/core/servises"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/breach"
 	"github.com/ttodoshi/code-typing-auth-service/pkg/broker"
 	"github.com/ttodoshi/code-typing-auth-service/pkg/discovery"
-	"github.com/ttodoshi/code-typing-auth-service/pkg/env"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/encryption"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/jwt"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/lifecycle"
 	"github.com/ttodoshi/code-typing-auth-service/pkg/logging"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/password"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
-	"os"
-	"strconv"
+	"time"
 )
 
-const (
-	Dev  = "dev"
-	Prod = "prod"
-)
-
-func init() {
-	env.LoadEnvVariables()
-	if os.Getenv("PROFILE") == Prod {
-		gin.SetMode(gin.ReleaseMode)
-	}
-	discovery.InitServiceDiscovery()
-}
-
 //	@title		Auth Service API
 //	@version	1.0
 
@@ -43,57 +38,430 @@ func init() {
 func main() {
 	log := logging.GetLogger()
 
-	initDatabase(log)
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("invalid configuration:\n%s", err.Error())
+	}
+	logging.SetLevel(cfg.LogLevel)
+	log.Debugf("configuration loaded:\n%s", cfg)
+	if cfg.Profile == config.Prod {
+		gin.SetMode(gin.ReleaseMode)
+	}
 
-	channel := broker.InitMessageBroker()
-	defer broker.Close()
+	cipher := encryption.NewCipher(cfg.EncryptionKey)
+	jwtIssuer := initJWTIssuer(log, cfg)
+	hasher := initPasswordHasher(cfg.Password)
+
+	// background tasks are stopped first, then subsystems in reverse order: consul registration, server, rabbitmq, mongo
+	lc := lifecycle.New(log)
+	initDatabase(log, lc, cfg)
+	signingKeyService := initSigningKeyService(log, lc, cfg.JWT, jwtIssuer, cipher)
+	revocationService := initRevocationService(log, lc, cfg.JWT, jwtIssuer)
+	rateLimitService := initRateLimitService(log, cfg.RateLimit)
+
+	publisher := broker.NewPublisher(cfg.RabbitMQ.URL, rabbitmq.DeclareExchanges)
+	lc.OnStop("rabbitmq", func(context.Context) error {
+		return publisher.Close()
+	})
+	outboxService := initOutboxService(log, lc, cfg.Outbox, rabbitmq.NewEventPublisher(publisher, log))
+	transactor := mongodb.NewTransactor(context.Background(), log)
+	accountService := initAccountService(log, lc, cfg.Account, transactor, outboxService, revocationService)
 
 	r := gin.Default()
-	router := initRouter(log, channel)
+	err = r.SetTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		log.Fatalf("failed to parse TRUSTED_PROXIES due to: %s", err.Error())
+	}
+	dataExportService := initDataExportService(log, lc, cfg, jwtIssuer)
+	router := initRouter(log, cfg, jwtIssuer, hasher, cipher, transactor, outboxService, signingKeyService, revocationService, rateLimitService, accountService, dataExportService)
 	router.InitRoutes(r)
 
-	log.Fatalf("error while running server due to: %s", r.Run())
+	server := http.NewServer(cfg.Port, r, log)
+	lc.OnStop("http server", server.Shutdown)
+	// registered after server, so instance leaves consul before it stops accepting connections
+	initServiceDiscovery(lc, cfg)
+	serverErr := make(chan error, 1)
+	go func() {
+		err := server.Run()
+		if err != nil {
+			log.Errorf("error while running server due to: %s", err.Error())
+		}
+		serverErr <- err
+		lc.Shutdown()
+	}()
+
+	lc.Wait()
+	log.Info("shutting down")
+	err = lc.Stop(cfg.ShutdownTimeout)
+	if err != nil {
+		log.Fatalf("shutdown failed due to: %s", err.Error())
+	}
+	// server returns once it is shut down, failed server makes process exit with error
+	if err = <-serverErr; err != nil {
+		log.Fatalf("server failed due to: %s", err.Error())
+	}
+	log.Info("shutdown completed")
 }
 
-func initDatabase(log logging.Logger) {
-	err := mgm.SetDefaultConfig(nil, "auth", options.Client().ApplyURI(os.Getenv("DB_URL")))
+// initJWTIssuer creates issuer shared by all services with keys from files, keys stored in database are applied later
+func initJWTIssuer(log logging.Logger, cfg config.Config) *jwt.Issuer {
+	jwtIssuer := jwt.NewIssuer(jwt.Config{
+		SecretKey:                 cfg.JWT.SecretKey,
+		LegacyKeyEnabled:          cfg.JWT.LegacyKeyEnabled,
+		Issuer:                    cfg.JWT.Issuer,
+		Audience:                  cfg.JWT.Audience,
+		AccessTokenExp:            cfg.Tokens.AccessTokenExp,
+		RefreshTokenExp:           cfg.Tokens.RefreshTokenExp,
+		EmailVerificationTokenExp: cfg.Tokens.EmailVerificationTokenExp,
+		PasswordResetTokenExp:     cfg.Tokens.PasswordResetTokenExp,
+		MFATokenExp:               cfg.Tokens.MFATokenExp,
+		OAuthStateTokenExp:        cfg.Tokens.OAuthStateTokenExp,
+		IDTokenExp:                cfg.Tokens.IDTokenExp,
+		DataExportTokenExp:        cfg.Tokens.DataExportTokenExp,
+	})
+	err := jwtIssuer.InitSigningKeys(cfg.JWT.PrivateKeyFile, cfg.JWT.KeyID, cfg.JWT.VerificationKeyFiles)
+	if err != nil {
+		log.Fatalf("failed to load signing keys due to: %s", err.Error())
+	}
+	return jwtIssuer
+}
+
+func initDatabase(log logging.Logger, lc *lifecycle.Lifecycle, cfg config.Config) {
+	err := mgm.SetDefaultConfig(nil, "auth", options.Client().ApplyURI(cfg.Database.URL))
 
 	if err != nil {
 		log.Fatal("failed connect to database")
 	}
+	lc.OnStop("mongo", func(ctx context.Context) error {
+		_, client, _, err := mgm.DefaultConfigs()
+		if err != nil {
+			return err
+		}
+		return client.Disconnect(ctx)
+	})
+
+	createExpirationIndex(log, &domain.RefreshToken{}, "updated_at", cfg.Tokens.RefreshTokenExp)
+	createExpirationIndex(log, &domain.EmailVerificationToken{}, "created_at", cfg.Tokens.EmailVerificationTokenExp)
+	createExpirationIndex(log, &domain.EmailChangeToken{}, "created_at", cfg.Tokens.EmailVerificationTokenExp)
+	createExpirationIndex(log, &domain.PasswordResetToken{}, "created_at", cfg.Tokens.PasswordResetTokenExp)
+	createExpirationIndex(log, &domain.PasskeySession{}, "created_at", cfg.Tokens.PasskeySessionExp)
+	createExpirationIndex(log, &domain.AuthorizationCode{}, "created_at", cfg.Tokens.AuthorizationCodeExp)
+	createExpirationIndex(log, &domain.RevokedAccessToken{}, "created_at", cfg.Tokens.AccessTokenExp)
+	createExpirationIndex(log, &domain.DataExport{}, "created_at", cfg.Tokens.DataExportExp)
+	createExpirationIndex(log, &domain.OutboxEvent{}, "delivered_at", cfg.Outbox.Retention)
+	createExpirationIndex(log, &domain.OutboxEvent{}, "failed_at", cfg.Outbox.FailedRetention)
+	createUniqueIndex(log, &domain.Identity{}, "provider", "subject")
+	createUniqueIndex(log, &domain.OAuthClient{}, "client_id")
+	createUniqueIndex(log, &domain.SigningKey{}, "key_id")
+	createUniqueIndex(log, &domain.OutboxLease{}, "name")
+}
 
+func createExpirationIndex(log logging.Logger, model mgm.Model, field string, exp time.Duration) {
 	collection := mgm.CollectionByName(
-		mgm.CollName(&domain.RefreshToken{}),
+		mgm.CollName(model),
 	)
-	refreshTokenExp, err := strconv.Atoi(os.Getenv("REFRESH_TOKEN_EXP"))
+	expirationIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: field, Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(int32(exp.Seconds())),
+	}
+	_, err := collection.Indexes().CreateOne(mgm.Ctx(), expirationIndex)
+
 	if err != nil {
-		log.Fatal("failed to parse refresh token expiration")
+		log.Fatal(err.Error())
 	}
-	refreshTokenExpirationIndex := mongo.IndexModel{
-		Keys:    bson.D{{"updated_at", 1}},
-		Options: options.Index().SetExpireAfterSeconds(int32(refreshTokenExp)),
+}
+
+func createUniqueIndex(log logging.Logger, model mgm.Model, fields ...string) {
+	collection := mgm.CollectionByName(
+		mgm.CollName(model),
+	)
+	keys := bson.D{}
+	for _, field := range fields {
+		keys = append(keys, bson.E{Key: field, Value: 1})
+	}
+	uniqueIndex := mongo.IndexModel{
+		Keys:    keys,
+		Options: options.Index().SetUnique(true),
 	}
-	_, err = collection.Indexes().CreateOne(mgm.Ctx(), refreshTokenExpirationIndex)
+	_, err := collection.Indexes().CreateOne(mgm.Ctx(), uniqueIndex)
 
 	if err != nil {
 		log.Fatal(err.Error())
 	}
 }
 
-func initRouter(log logging.Logger, channel *amqp.Channel) *http.Router {
+// initSigningKeyService applies keys stored in database and starts their periodic reload and rotation
+func initSigningKeyService(log logging.Logger, lc *lifecycle.Lifecycle, cfg config.JWTConfig, jwtIssuer *jwt.Issuer, cipher *encryption.Cipher) ports.SigningKeyService {
+	signingKeyService := servises.NewSigningKeyService(
+		cfg.KeyAlgorithm, cfg.KeyRotationInterval,
+		jwtIssuer, cipher,
+		mongodb.NewSigningKeyRepository(),
+		log,
+	)
+	err := signingKeyService.LoadSigningKeys(context.Background())
+	if err != nil {
+		log.Fatalf("failed to load signing keys due to: %s", err.Error())
+	}
+	lc.Go(func(ctx context.Context) {
+		signingKeyService.RunKeyRotation(ctx, cfg.KeysReloadInterval)
+	})
+	return signingKeyService
+}
+
+// initRevocationService starts periodic loading of access token revocations made by all instances
+func initRevocationService(log logging.Logger, lc *lifecycle.Lifecycle, cfg config.JWTConfig, jwtIssuer *jwt.Issuer) ports.RevocationService {
+	revocationService := servises.NewRevocationService(
+		jwtIssuer,
+		mongodb.NewRevokedAccessTokenRepository(),
+		log,
+	)
+	lc.Go(func(ctx context.Context) {
+		revocationService.RunRevocationSync(ctx, cfg.RevocationSync)
+	})
+	return revocationService
+}
+
+// initRateLimitService chooses store of rate limit buckets, mongo store shares limits between replicas
+func initRateLimitService(log logging.Logger, cfg config.RateLimitConfig) ports.RateLimitService {
+	var store ports.RateLimitStore
+	switch cfg.Store {
+	case "mongo":
+		createUniqueIndex(log, &domain.RateLimitBucket{}, "key")
+		createExpirationIndex(log, &domain.RateLimitBucket{}, "updated_at", cfg.BucketExp)
+		store = mongodb.NewRateLimitStore()
+	default:
+		store = memory.NewRateLimitStore()
+	}
+	return servises.NewRateLimitService(store, log)
+}
+
+// initOutboxService starts relay publishing events saved to outbox by other services
+func initOutboxService(log logging.Logger, lc *lifecycle.Lifecycle, cfg config.OutboxConfig, eventPublisher ports.EventPublisher) ports.OutboxService {
+	outboxService := servises.NewOutboxService(
+		cfg.BatchSize, cfg.RetryDelay, cfg.MaxRetryDelay, cfg.MaxAttempts,
+		mongodb.NewOutboxRepository(), eventPublisher,
+		log,
+	)
+	lc.Go(func(ctx context.Context) {
+		outboxService.RunOutboxRelay(ctx, cfg.RelayInterval)
+	})
+	return outboxService
+}
+
+// initAccountService starts periodic purge of accounts with passed deletion grace period
+func initAccountService(log logging.Logger, lc *lifecycle.Lifecycle, cfg config.AccountConfig, transactor ports.Transactor, eventDispatcher ports.EventDispatcher, revocationService ports.RevocationService) ports.AccountService {
+	accountService := servises.NewAccountService(
+		cfg.DeletionGracePeriod,
+		mongodb.NewUserRepository(), mongodb.NewRefreshTokenRepository(),
+		mongodb.NewIdentityRepository(), mongodb.NewPasskeyCredentialRepository(),
+		mongodb.NewDataExportRepository(), mongodb.NewEmailVerificationTokenRepository(),
+		mongodb.NewEmailChangeTokenRepository(), mongodb.NewPasswordResetTokenRepository(),
+		revocationService, transactor, eventDispatcher,
+		log,
+	)
+	lc.Go(func(ctx context.Context) {
+		accountService.RunAccountPurge(ctx, cfg.PurgeInterval)
+	})
+	return accountService
+}
+
+// initDataExportService starts worker generating requested archives of personal data
+func initDataExportService(log logging.Logger, lc *lifecycle.Lifecycle, cfg config.Config, jwtIssuer *jwt.Issuer) ports.DataExportService {
+	dataExportService := servises.NewDataExportService(
+		cfg.OIDC.Issuer, jwtIssuer,
+		mongodb.NewDataExportRepository(), mongodb.NewUserRepository(), mongodb.NewRefreshTokenRepository(),
+		mongodb.NewIdentityRepository(), mongodb.NewPasskeyCredentialRepository(),
+		log,
+	)
+	lc.Go(func(ctx context.Context) {
+		dataExportService.RunDataExportWorker(ctx, cfg.Account.DataExportInterval)
+	})
+	return dataExportService
+}
+
+func initRouter(log logging.Logger, cfg config.Config, jwtIssuer *jwt.Issuer, hasher password.Hasher, cipher *encryption.Cipher, transactor ports.Transactor, eventDispatcher ports.EventDispatcher, signingKeyService ports.SigningKeyService, revocationService ports.RevocationService, rateLimitService ports.RateLimitService, accountService ports.AccountService, dataExportService ports.DataExportService) *http.Router {
 	refreshTokenRepository := mongodb.NewRefreshTokenRepository()
 	userRepository := mongodb.NewUserRepository()
+	verificationTokenRepository := mongodb.NewEmailVerificationTokenRepository()
+	changeTokenRepository := mongodb.NewEmailChangeTokenRepository()
+	resetTokenRepository := mongodb.NewPasswordResetTokenRepository()
+	passkeyCredentialRepository := mongodb.NewPasskeyCredentialRepository()
+	passkeySessionRepository := mongodb.NewPasskeySessionRepository()
+	identityRepository := mongodb.NewIdentityRepository()
+	oauthClientRepository := mongodb.NewOAuthClientRepository()
+	authorizationCodeRepository := mongodb.NewAuthorizationCodeRepository()
 
-	eventDispatcher := rabbitmq.NewEventDispatcher(channel, log)
+	breachedPasswords := initBreachedPasswords(log, cfg.Password.BreachedPasswordsFile)
 	authService := servises.NewAuthService(
+		servises.LockoutPolicy{
+			BaseDelay: cfg.Lockout.BaseDelay,
+			MaxDelay:  cfg.Lockout.MaxDelay,
+			Threshold: cfg.Lockout.Threshold,
+			Duration:  cfg.Lockout.Duration,
+			Window:    cfg.Lockout.FailureWindow,
+		},
+		jwtIssuer, hasher, cipher,
 		userRepository, refreshTokenRepository,
-		eventDispatcher,
+		verificationTokenRepository, resetTokenRepository,
+		breachedPasswords, revocationService, transactor, eventDispatcher,
 		log,
 	)
-	return http.NewRouter(
+	userService := servises.NewUserService(
+		cfg.Account.NicknameChangeCooldown,
+		jwtIssuer, hasher,
+		userRepository, refreshTokenRepository, changeTokenRepository,
+		breachedPasswords, revocationService, transactor, eventDispatcher,
 		log,
+	)
+	mfaService := servises.NewMFAService(
+		cipher,
+		userRepository, refreshTokenRepository,
+		log,
+	)
+	sessionService := servises.NewSessionService(
+		refreshTokenRepository,
+		log,
+	)
+	passkeyService := servises.NewPasskeyService(
+		initWebAuthn(log, cfg.WebAuthn), jwtIssuer,
+		userRepository, passkeyCredentialRepository, passkeySessionRepository,
+		refreshTokenRepository, transactor, eventDispatcher,
+		log,
+	)
+	oauthService := servises.NewOAuthService(
+		initIdentityProviders(cfg.OAuth), jwtIssuer,
+		userRepository, identityRepository,
+		refreshTokenRepository, transactor, eventDispatcher,
+		log,
+	)
+	oidcService := servises.NewOIDCService(
+		cfg.OIDC.Issuer, cfg.Tokens.AuthorizationCodeExp, jwtIssuer,
+		oauthClientRepository, authorizationCodeRepository,
+		userRepository, refreshTokenRepository,
+		revocationService,
+		log,
+	)
+	oauthClientService := servises.NewOAuthClientService(
+		oauthClientRepository,
+		log,
+	)
+	return http.NewRouter(
+		cfg.AdminAPIKey,
+		cfg.AllowedOrigins,
+		http.RateLimitPolicies{
+			LoginPerIP:           cfg.RateLimit.LoginPerIP,
+			LoginPerAccount:      cfg.RateLimit.LoginPerAccount,
+			RegistrationPerIP:    cfg.RateLimit.RegistrationPerIP,
+			RegistrationPerEmail: cfg.RateLimit.RegistrationPerEmail,
+		},
+		log, jwtIssuer, revocationService, rateLimitService,
 		api.NewAuthHandler(
-			authService, log,
+			cfg.CookieHost, cfg.Tokens.RefreshTokenExp, authService, log,
+		),
+		api.NewUserHandler(
+			userService, log,
+		),
+		api.NewAccountHandler(
+			cfg.CookieHost, accountService, log,
+		),
+		api.NewDataExportHandler(
+			dataExportService, log,
+		),
+		api.NewMFAHandler(
+			mfaService, log,
+		),
+		api.NewSessionHandler(
+			sessionService, log,
+		),
+		api.NewPasskeyHandler(
+			cfg.CookieHost, cfg.Tokens.RefreshTokenExp, passkeyService, log,
+		),
+		api.NewOAuthHandler(
+			cfg.CookieHost, cfg.Tokens.RefreshTokenExp, cfg.Tokens.OAuthStateTokenExp,
+			cfg.OAuth.SuccessRedirectURL, oauthService, log,
+		),
+		api.NewOIDCHandler(
+			cfg.OIDC.LoginURL, oidcService, log,
+		),
+		api.NewOAuthClientHandler(
+			oauthClientService, log,
+		),
+		api.NewSigningKeyHandler(
+			signingKeyService, log,
 		),
 	)
 }
+
+// initServiceDiscovery registers service in consul, it is deregistered on stop before hooks registered earlier are run
+func initServiceDiscovery(lc *lifecycle.Lifecycle, cfg config.Config) {
+	registration := discovery.Register(discovery.Config{
+		Address:        cfg.Consul.Host,
+		ServiceName:    cfg.Consul.ServiceName,
+		ServiceAddress: cfg.Consul.ServiceAddress,
+		ServicePort:    cfg.Port,
+		Tags:           cfg.Consul.Tags,
+	})
+	lc.OnStop("consul registration", func(context.Context) error {
+		return registration.Deregister()
+	})
+}
+
+// initPasswordHasher chooses hasher of new passwords, passwords hashed otherwise are rehashed on login
+func initPasswordHasher(cfg config.PasswordConfig) password.Hasher {
+	if cfg.HashAlgorithm == "argon2id" {
+		return password.Argon2idHasher{
+			Memory:      cfg.Argon2Memory,
+			Iterations:  cfg.Argon2Iterations,
+			Parallelism: cfg.Argon2Parallelism,
+			SaltLength:  16,
+			KeyLength:   32,
+		}
+	}
+	return password.BcryptHasher{Cost: cfg.BcryptCost}
+}
+
+// initBreachedPasswords loads set built by cmd/breachbuild, screening is off when path is empty
+func initBreachedPasswords(log logging.Logger, path string) ports.BreachedPasswordChecker {
+	if path == "" {
+		log.Warn("BREACHED_PASSWORDS_FILE is not set, breached passwords are accepted")
+		return breach.NewSet()
+	}
+	set, err := breach.Load(path)
+	if err != nil {
+		log.Fatalf("failed to load breached passwords due to: %s", err.Error())
+	}
+	log.Infof("%d breached passwords loaded", set.Len())
+	return set
+}
+
+func initWebAuthn(log logging.Logger, cfg config.WebAuthnConfig) *webauthn.WebAuthn {
+	webAuthn, err := webauthn.New(&webauthn.Config{
+		RPID:          cfg.RPID,
+		RPDisplayName: cfg.RPDisplayName,
+		RPOrigins:     cfg.RPOrigins,
+	})
+	if err != nil {
+		log.Fatalf("failed to configure webauthn due to: %s", err.Error())
+	}
+	return webAuthn
+}
+
+// initIdentityProviders creates providers listed in OAUTH_PROVIDERS
+func initIdentityProviders(cfg config.OAuthConfig) []ports.IdentityProvider {
+	var providers []ports.IdentityProvider
+	for _, name := range cfg.Providers {
+		providerConfig := cfg.ProviderConfigs[name]
+		providers = append(providers, oauth.NewProvider(oauth.Config{
+			Name:         name,
+			ClientID:     providerConfig.ClientID,
+			ClientSecret: providerConfig.ClientSecret,
+			RedirectURL:  providerConfig.RedirectURL,
+			Scopes:       providerConfig.Scopes,
+			Issuer:       providerConfig.Issuer,
+			AuthURL:      providerConfig.AuthURL,
+			TokenURL:     providerConfig.TokenURL,
+			UserInfoURL:  providerConfig.UserInfoURL,
+		}))
+	}
+	return providers
+}
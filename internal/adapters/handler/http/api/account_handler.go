@@ -0,0 +1,63 @@
+package api
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports/dto"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/logging"
+	"time"
+)
+
+type AccountHandler struct {
+	cookieHost string
+	svc        ports.AccountService
+	log        logging.Logger
+}
+
+func NewAccountHandler(cookieHost string, svc ports.AccountService, log logging.Logger) *AccountHandler {
+	return &AccountHandler{
+		cookieHost: cookieHost,
+		svc:        svc,
+		log:        log,
+	}
+}
+
+// DeleteAccount godoc
+//
+//	@Summary		Delete account
+//	@Description	Schedule deletion of current user account, login before purge date restores it
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header		string						true	"Bearer access token"
+//	@Param			request			body		dto.DeleteAccountRequestDto	true	"Current password, may be empty for accounts without password"
+//	@Success		202				{object}	dto.AccountDeletionResponseDto
+//	@Header			202				{string}	Set-Cookie	"refreshToken"
+//	@Router			/auth/account [delete]
+func (h *AccountHandler) DeleteAccount(c *gin.Context) {
+	h.log.Debug("received delete account request")
+
+	var deleteAccountRequestDto dto.DeleteAccountRequestDto
+	if err := c.ShouldBindJSON(&deleteAccountRequestDto); err != nil {
+		h.log.Warn("error in request body")
+		err = c.Error(
+			fmt.Errorf("error in request body: %w", ports.BadRequestError),
+		)
+		return
+	}
+	claims := c.GetStringMap(ClaimsKey)
+	// auth_time is time of password, passkey or OAuth login, unlike iat it does not change on refresh
+	authTime, _ := claims["auth_time"].(float64)
+
+	accountDeletionResponseDto, err := h.svc.DeleteAccount(
+		c.Request.Context(), c.GetString(UserIDKey), time.Unix(int64(authTime), 0), deleteAccountRequestDto,
+	)
+	if err != nil {
+		err = c.Error(err)
+		return
+	}
+
+	c.SetCookie("refreshToken", "", -1, "/", h.cookieHost, false, true)
+	c.JSON(202, accountDeletionResponseDto)
+}
@@ -5,24 +5,24 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
 	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports/dto"
-	"github.com/ttodoshi/code-typing-auth-service/pkg/jwt"
 	"github.com/ttodoshi/code-typing-auth-service/pkg/logging"
-	"os"
-)
-
-var (
-	cookieHost = os.Getenv("COOKIE_HOST")
+	"strings"
+	"time"
 )
 
 type AuthHandler struct {
-	svc ports.AuthService
-	log logging.Logger
+	cookieHost      string
+	refreshTokenExp time.Duration
+	svc             ports.AuthService
+	log             logging.Logger
 }
 
-func NewAuthHandler(svc ports.AuthService, log logging.Logger) *AuthHandler {
+func NewAuthHandler(cookieHost string, refreshTokenExp time.Duration, svc ports.AuthService, log logging.Logger) *AuthHandler {
 	return &AuthHandler{
-		svc: svc,
-		log: log,
+		cookieHost:      cookieHost,
+		refreshTokenExp: refreshTokenExp,
+		svc:             svc,
+		log:             log,
 	}
 }
 
@@ -50,13 +50,13 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	access, refresh, err := h.svc.Register(registerRequestDto, sessionCookie)
+	access, refresh, err := h.svc.Register(c.Request.Context(), registerRequestDto, sessionCookie, deviceOf(c))
 	if err != nil {
 		err = c.Error(err)
 		return
 	}
 
-	c.SetCookie("refreshToken", refresh, jwt.RefreshTokenExp, "/", cookieHost, false, true)
+	c.SetCookie("refreshToken", refresh, int(h.refreshTokenExp.Seconds()), "/", h.cookieHost, false, true)
 	c.Data(201, "text/html; charset=utf-8", []byte(access))
 }
 
@@ -69,6 +69,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 //	@Produce		plain
 //	@Param			request	body		dto.LoginRequestDto	true	"Login request"
 //	@Success		200		{object}	string
+//	@Success		202		{object}	dto.MFAChallengeResponseDto
 //	@Header			200		{string}	Set-Cookie	"refreshToken"
 //	@Router			/auth/login [post]
 func (h *AuthHandler) Login(c *gin.Context) {
@@ -84,13 +85,51 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	access, refresh, err := h.svc.Login(loginRequestDto, sessionCookie)
+	access, refresh, mfaToken, err := h.svc.Login(c.Request.Context(), loginRequestDto, sessionCookie, deviceOf(c))
 	if err != nil {
 		err = c.Error(err)
 		return
 	}
+	if mfaToken != "" {
+		c.JSON(202, dto.MFAChallengeResponseDto{MFAToken: mfaToken})
+		return
+	}
 
-	c.SetCookie("refreshToken", refresh, jwt.RefreshTokenExp, "/", cookieHost, false, true)
+	c.SetCookie("refreshToken", refresh, int(h.refreshTokenExp.Seconds()), "/", h.cookieHost, false, true)
+	c.Data(200, "text/html; charset=utf-8", []byte(access))
+}
+
+// LoginMFA godoc
+//
+//	@Summary		Login second step
+//	@Description	Complete login with TOTP or recovery code, mfa token and TOTP code are accepted once, wrong codes count as failed logins
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		plain
+//	@Param			request	body		dto.MFALoginRequestDto	true	"Two-factor login request"
+//	@Success		200		{object}	string
+//	@Header			200		{string}	Set-Cookie	"refreshToken"
+//	@Router			/auth/login/2fa [post]
+func (h *AuthHandler) LoginMFA(c *gin.Context) {
+	h.log.Debug("received two-factor login request")
+
+	sessionCookie, err := c.Cookie("SESSION")
+	var mfaLoginRequestDto dto.MFALoginRequestDto
+	if err = c.ShouldBindJSON(&mfaLoginRequestDto); err != nil {
+		h.log.Warn("error in request body")
+		err = c.Error(
+			fmt.Errorf("error in request body: %w", ports.BadRequestError),
+		)
+		return
+	}
+
+	access, refresh, err := h.svc.LoginMFA(c.Request.Context(), mfaLoginRequestDto, sessionCookie, deviceOf(c))
+	if err != nil {
+		err = c.Error(err)
+		return
+	}
+
+	c.SetCookie("refreshToken", refresh, int(h.refreshTokenExp.Seconds()), "/", h.cookieHost, false, true)
 	c.Data(200, "text/html; charset=utf-8", []byte(access))
 }
 
@@ -117,13 +156,13 @@ func (h *AuthHandler) Refresh(c *gin.Context) {
 		return
 	}
 
-	access, refresh, err := h.svc.Refresh(refreshTokenCookie)
+	access, refresh, err := h.svc.Refresh(c.Request.Context(), refreshTokenCookie, deviceOf(c))
 	if err != nil {
 		err = c.Error(err)
 		return
 	}
 
-	c.SetCookie("refreshToken", refresh, jwt.RefreshTokenExp, "/", cookieHost, false, true)
+	c.SetCookie("refreshToken", refresh, int(h.refreshTokenExp.Seconds()), "/", h.cookieHost, false, true)
 	c.Data(200, "text/html; charset=utf-8", []byte(access))
 }
 
@@ -134,7 +173,8 @@ func (h *AuthHandler) Refresh(c *gin.Context) {
 //	@Tags			auth
 //	@Accept			json
 //	@Produce		json
-//	@Param			Cookie	header	string	true	"refreshToken"	default(refreshToken=)
+//	@Param			Cookie			header	string	true	"refreshToken"	default(refreshToken=)
+//	@Param			Authorization	header	string	false	"access token to revoke"	default(Bearer )
 //	@Success		204
 //	@Header			204	{string}	Set-Cookie	"refreshToken"
 //	@Router			/auth/logout [delete]
@@ -150,8 +190,140 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 		return
 	}
 
-	h.svc.Logout(refreshTokenCookie)
+	accessToken, _ := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	h.svc.Logout(c.Request.Context(), refreshTokenCookie, accessToken)
+
+	c.SetCookie("refreshToken", "", -1, "/", h.cookieHost, false, true)
+	c.Status(204)
+}
+
+// VerifyEmail godoc
+//
+//	@Summary		Verify email
+//	@Description	Verify email with token from verification letter
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body	dto.VerifyEmailRequestDto	true	"Verify email request"
+//	@Success		204
+//	@Router			/auth/verify-email [post]
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	h.log.Debug("received verify email request")
+
+	var verifyEmailRequestDto dto.VerifyEmailRequestDto
+	if err := c.ShouldBindJSON(&verifyEmailRequestDto); err != nil {
+		h.log.Warn("error in request body")
+		err = c.Error(
+			fmt.Errorf("error in request body: %w", ports.BadRequestError),
+		)
+		return
+	}
+
+	err := h.svc.VerifyEmail(c.Request.Context(), verifyEmailRequestDto.Token)
+	if err != nil {
+		err = c.Error(err)
+		return
+	}
+
+	c.Status(204)
+}
+
+// ResendVerificationEmail godoc
+//
+//	@Summary		Resend verification email
+//	@Description	Resend verification email, previously sent links stop working. Response is the same for unknown and verified emails
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body	dto.ResendVerificationEmailRequestDto	true	"Resend verification email request"
+//	@Success		204
+//	@Router			/auth/verify-email/resend [post]
+func (h *AuthHandler) ResendVerificationEmail(c *gin.Context) {
+	h.log.Debug("received resend verification email request")
+
+	var resendRequestDto dto.ResendVerificationEmailRequestDto
+	if err := c.ShouldBindJSON(&resendRequestDto); err != nil {
+		h.log.Warn("error in request body")
+		err = c.Error(
+			fmt.Errorf("error in request body: %w", ports.BadRequestError),
+		)
+		return
+	}
+
+	err := h.svc.ResendVerificationEmail(c.Request.Context(), resendRequestDto.Email)
+	if err != nil {
+		err = c.Error(err)
+		return
+	}
 
-	c.SetCookie("refreshToken", "", -1, "/", cookieHost, false, true)
 	c.Status(204)
 }
+
+// ForgotPassword godoc
+//
+//	@Summary		Forgot password
+//	@Description	Request password reset letter
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body	dto.ForgotPasswordRequestDto	true	"Forgot password request"
+//	@Success		204
+//	@Router			/auth/password/forgot [post]
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	h.log.Debug("received forgot password request")
+
+	var forgotPasswordRequestDto dto.ForgotPasswordRequestDto
+	if err := c.ShouldBindJSON(&forgotPasswordRequestDto); err != nil {
+		h.log.Warn("error in request body")
+		err = c.Error(
+			fmt.Errorf("error in request body: %w", ports.BadRequestError),
+		)
+		return
+	}
+
+	err := h.svc.ForgotPassword(c.Request.Context(), forgotPasswordRequestDto.Email)
+	if err != nil {
+		err = c.Error(err)
+		return
+	}
+
+	c.Status(204)
+}
+
+// ResetPassword godoc
+//
+//	@Summary		Reset password
+//	@Description	Set new password with token from reset letter, all sessions are terminated
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body	dto.ResetPasswordRequestDto	true	"Reset password request"
+//	@Success		204
+//	@Router			/auth/password/reset [post]
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	h.log.Debug("received reset password request")
+
+	var resetPasswordRequestDto dto.ResetPasswordRequestDto
+	if err := c.ShouldBindJSON(&resetPasswordRequestDto); err != nil {
+		h.log.Warn("error in request body")
+		err = c.Error(
+			fmt.Errorf("error in request body: %w", ports.BadRequestError),
+		)
+		return
+	}
+
+	err := h.svc.ResetPassword(c.Request.Context(), resetPasswordRequestDto)
+	if err != nil {
+		err = c.Error(err)
+		return
+	}
+
+	c.Status(204)
+}
+
+func deviceOf(c *gin.Context) dto.DeviceDto {
+	return dto.DeviceDto{
+		UserAgent: c.Request.UserAgent(),
+		IP:        c.ClientIP(),
+	}
+}
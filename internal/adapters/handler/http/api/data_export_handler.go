@@ -0,0 +1,94 @@
+package api
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/logging"
+)
+
+type DataExportHandler struct {
+	svc ports.DataExportService
+	log logging.Logger
+}
+
+func NewDataExportHandler(svc ports.DataExportService, log logging.Logger) *DataExportHandler {
+	return &DataExportHandler{
+		svc: svc,
+		log: log,
+	}
+}
+
+// RequestDataExport godoc
+//
+//	@Summary		Request data export
+//	@Description	Start generation of JSON archive of personal data of current user
+//	@Tags			users
+//	@Produce		json
+//	@Param			Authorization	header		string	true	"Bearer access token"
+//	@Success		202				{object}	dto.DataExportResponseDto
+//	@Router			/auth/me/export [post]
+func (h *DataExportHandler) RequestDataExport(c *gin.Context) {
+	h.log.Debug("received request data export request")
+
+	dataExportResponseDto, err := h.svc.RequestDataExport(c.Request.Context(), c.GetString(UserIDKey))
+	if err != nil {
+		err = c.Error(err)
+		return
+	}
+
+	c.JSON(202, dataExportResponseDto)
+}
+
+// GetDataExport godoc
+//
+//	@Summary		Get data export
+//	@Description	Get status of data export, download link is returned once archive is ready
+//	@Tags			users
+//	@Produce		json
+//	@Param			Authorization	header		string	true	"Bearer access token"
+//	@Param			id				path		string	true	"Export ID"
+//	@Success		200				{object}	dto.DataExportResponseDto
+//	@Router			/auth/me/export/{id} [get]
+func (h *DataExportHandler) GetDataExport(c *gin.Context) {
+	h.log.Debug("received get data export request")
+
+	dataExportResponseDto, err := h.svc.GetDataExport(c.Request.Context(), c.GetString(UserIDKey), c.Param("id"))
+	if err != nil {
+		err = c.Error(err)
+		return
+	}
+
+	c.JSON(200, dataExportResponseDto)
+}
+
+// DownloadDataExport godoc
+//
+//	@Summary		Download data export
+//	@Description	Download JSON archive of personal data by link from data export
+//	@Tags			users
+//	@Produce		json
+//	@Param			token	query		string	true	"Download token"
+//	@Success		200		{object}	dto.DataExportArchiveDto
+//	@Router			/auth/export/download [get]
+func (h *DataExportHandler) DownloadDataExport(c *gin.Context) {
+	h.log.Debug("received download data export request")
+
+	downloadToken := c.Query("token")
+	if downloadToken == "" {
+		h.log.Warn("error while getting download token")
+		_ = c.Error(
+			fmt.Errorf("download token is required: %w", ports.BadRequestError),
+		)
+		return
+	}
+
+	archive, err := h.svc.DownloadDataExport(c.Request.Context(), downloadToken)
+	if err != nil {
+		err = c.Error(err)
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="personal-data.json"`)
+	c.Data(200, "application/json", archive)
+}
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports/dto"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/logging"
+)
+
+type MFAHandler struct {
+	svc ports.MFAService
+	log logging.Logger
+}
+
+func NewMFAHandler(svc ports.MFAService, log logging.Logger) *MFAHandler {
+	return &MFAHandler{
+		svc: svc,
+		log: log,
+	}
+}
+
+// EnrollTOTP godoc
+//
+//	@Summary		Enroll TOTP
+//	@Description	Generate TOTP secret, it must be confirmed with a code to enable two-factor authentication
+//	@Tags			2fa
+//	@Accept			json
+//	@Produce		json
+//	@Param			Cookie	header		string	true	"refreshToken"	default(refreshToken=)
+//	@Success		200		{object}	dto.TOTPEnrollResponseDto
+//	@Router			/auth/2fa/totp/enroll [post]
+func (h *MFAHandler) EnrollTOTP(c *gin.Context) {
+	h.log.Debug("received enroll totp request")
+
+	refreshTokenCookie, err := c.Cookie("refreshToken")
+	if err != nil || refreshTokenCookie == "" {
+		h.log.Warn("error while getting refresh token cookie")
+		err = c.Error(
+			fmt.Errorf("error while getting refresh token cookie: %w", ports.UnauthorizedError),
+		)
+		return
+	}
+
+	enrollResponseDto, err := h.svc.EnrollTOTP(c.Request.Context(), refreshTokenCookie)
+	if err != nil {
+		err = c.Error(err)
+		return
+	}
+
+	c.JSON(200, enrollResponseDto)
+}
+
+// ConfirmTOTP godoc
+//
+//	@Summary		Confirm TOTP
+//	@Description	Enable two-factor authentication with the first code, returns recovery codes
+//	@Tags			2fa
+//	@Accept			json
+//	@Produce		json
+//	@Param			Cookie	header		string					true	"refreshToken"	default(refreshToken=)
+//	@Param			request	body		dto.TOTPCodeRequestDto	true	"TOTP code"
+//	@Success		200		{object}	dto.RecoveryCodesResponseDto
+//	@Router			/auth/2fa/totp/confirm [post]
+func (h *MFAHandler) ConfirmTOTP(c *gin.Context) {
+	h.log.Debug("received confirm totp request")
+
+	refreshTokenCookie, codeRequestDto, ok := h.bindCodeRequest(c)
+	if !ok {
+		return
+	}
+
+	recoveryCodes, err := h.svc.ConfirmTOTP(c.Request.Context(), refreshTokenCookie, codeRequestDto.Code)
+	if err != nil {
+		err = c.Error(err)
+		return
+	}
+
+	c.JSON(200, dto.RecoveryCodesResponseDto{RecoveryCodes: recoveryCodes})
+}
+
+// DisableTOTP godoc
+//
+//	@Summary		Disable TOTP
+//	@Description	Disable two-factor authentication with TOTP or recovery code
+//	@Tags			2fa
+//	@Accept			json
+//	@Produce		json
+//	@Param			Cookie	header	string					true	"refreshToken"	default(refreshToken=)
+//	@Param			request	body	dto.TOTPCodeRequestDto	true	"TOTP or recovery code"
+//	@Success		204
+//	@Router			/auth/2fa/totp/disable [post]
+func (h *MFAHandler) DisableTOTP(c *gin.Context) {
+	h.log.Debug("received disable totp request")
+
+	refreshTokenCookie, codeRequestDto, ok := h.bindCodeRequest(c)
+	if !ok {
+		return
+	}
+
+	err := h.svc.DisableTOTP(c.Request.Context(), refreshTokenCookie, codeRequestDto.Code)
+	if err != nil {
+		err = c.Error(err)
+		return
+	}
+
+	c.Status(204)
+}
+
+// RegenerateRecoveryCodes godoc
+//
+//	@Summary		Regenerate recovery codes
+//	@Description	Replace all recovery codes with new ones
+//	@Tags			2fa
+//	@Accept			json
+//	@Produce		json
+//	@Param			Cookie	header		string					true	"refreshToken"	default(refreshToken=)
+//	@Param			request	body		dto.TOTPCodeRequestDto	true	"TOTP or recovery code"
+//	@Success		200		{object}	dto.RecoveryCodesResponseDto
+//	@Router			/auth/2fa/recovery-codes [post]
+func (h *MFAHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	h.log.Debug("received regenerate recovery codes request")
+
+	refreshTokenCookie, codeRequestDto, ok := h.bindCodeRequest(c)
+	if !ok {
+		return
+	}
+
+	recoveryCodes, err := h.svc.RegenerateRecoveryCodes(c.Request.Context(), refreshTokenCookie, codeRequestDto.Code)
+	if err != nil {
+		err = c.Error(err)
+		return
+	}
+
+	c.JSON(200, dto.RecoveryCodesResponseDto{RecoveryCodes: recoveryCodes})
+}
+
+func (h *MFAHandler) bindCodeRequest(c *gin.Context) (refreshTokenCookie string, codeRequestDto dto.TOTPCodeRequestDto, ok bool) {
+	refreshTokenCookie, err := c.Cookie("refreshToken")
+	if err != nil || refreshTokenCookie == "" {
+		h.log.Warn("error while getting refresh token cookie")
+		err = c.Error(
+			fmt.Errorf("error while getting refresh token cookie: %w", ports.UnauthorizedError),
+		)
+		return
+	}
+	if err = c.ShouldBindJSON(&codeRequestDto); err != nil {
+		h.log.Warn("error in request body")
+		err = c.Error(
+			fmt.Errorf("error in request body: %w", ports.BadRequestError),
+		)
+		return
+	}
+	return refreshTokenCookie, codeRequestDto, true
+}
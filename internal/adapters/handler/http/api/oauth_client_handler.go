@@ -0,0 +1,95 @@
+package api
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports/dto"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/logging"
+)
+
+type OAuthClientHandler struct {
+	svc ports.OAuthClientService
+	log logging.Logger
+}
+
+func NewOAuthClientHandler(svc ports.OAuthClientService, log logging.Logger) *OAuthClientHandler {
+	return &OAuthClientHandler{
+		svc: svc,
+		log: log,
+	}
+}
+
+// CreateOAuthClient godoc
+//
+//	@Summary		Register OAuth client
+//	@Description	Register client application, secret is returned only once
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			X-API-Key	header		string							true	"Admin API key"
+//	@Param			request		body		dto.CreateOAuthClientRequestDto	true	"Create client request"
+//	@Success		201			{object}	dto.OAuthClientResponseDto
+//	@Router			/admin/oauth-clients [post]
+func (h *OAuthClientHandler) CreateOAuthClient(c *gin.Context) {
+	h.log.Debug("received create oauth client request")
+
+	var createOAuthClientRequestDto dto.CreateOAuthClientRequestDto
+	if err := c.ShouldBindJSON(&createOAuthClientRequestDto); err != nil {
+		h.log.Warn("error in request body")
+		err = c.Error(
+			fmt.Errorf("error in request body: %w", ports.BadRequestError),
+		)
+		return
+	}
+
+	clientResponseDto, err := h.svc.CreateOAuthClient(c.Request.Context(), createOAuthClientRequestDto)
+	if err != nil {
+		err = c.Error(err)
+		return
+	}
+
+	c.JSON(201, clientResponseDto)
+}
+
+// GetOAuthClients godoc
+//
+//	@Summary		Get OAuth clients
+//	@Description	Get registered client applications
+//	@Tags			admin
+//	@Produce		json
+//	@Param			X-API-Key	header		string	true	"Admin API key"
+//	@Success		200			{array}		dto.OAuthClientResponseDto
+//	@Router			/admin/oauth-clients [get]
+func (h *OAuthClientHandler) GetOAuthClients(c *gin.Context) {
+	h.log.Debug("received get oauth clients request")
+
+	clients, err := h.svc.GetOAuthClients(c.Request.Context())
+	if err != nil {
+		err = c.Error(err)
+		return
+	}
+
+	c.JSON(200, clients)
+}
+
+// DeleteOAuthClient godoc
+//
+//	@Summary		Delete OAuth client
+//	@Description	Delete client application, issued tokens stay valid until expiration
+//	@Tags			admin
+//	@Param			X-API-Key	header	string	true	"Admin API key"
+//	@Param			clientID	path	string	true	"Client ID"
+//	@Success		204
+//	@Router			/admin/oauth-clients/{clientID} [delete]
+func (h *OAuthClientHandler) DeleteOAuthClient(c *gin.Context) {
+	h.log.Debug("received delete oauth client request")
+
+	err := h.svc.DeleteOAuthClient(c.Request.Context(), c.Param("clientID"))
+	if err != nil {
+		err = c.Error(err)
+		return
+	}
+
+	c.Status(204)
+}
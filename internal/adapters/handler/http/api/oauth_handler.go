@@ -0,0 +1,114 @@
+package api
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports/dto"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/logging"
+	"time"
+)
+
+const oauthStateCookiePath = "/api/v1/auth/oauth"
+
+type OAuthHandler struct {
+	cookieHost         string
+	refreshTokenExp    time.Duration
+	oauthStateTokenExp time.Duration
+	successRedirectURL string
+	svc                ports.OAuthService
+	log                logging.Logger
+}
+
+func NewOAuthHandler(cookieHost string, refreshTokenExp time.Duration, oauthStateTokenExp time.Duration, successRedirectURL string, svc ports.OAuthService, log logging.Logger) *OAuthHandler {
+	return &OAuthHandler{
+		cookieHost:         cookieHost,
+		refreshTokenExp:    refreshTokenExp,
+		oauthStateTokenExp: oauthStateTokenExp,
+		successRedirectURL: successRedirectURL,
+		svc:                svc,
+		log:                log,
+	}
+}
+
+// BeginOAuthLogin godoc
+//
+//	@Summary		Begin social login
+//	@Description	Redirect to identity provider authorization page
+//	@Tags			oauth
+//	@Param			provider	path	string	true	"Identity provider name"
+//	@Success		302
+//	@Header			302	{string}	Set-Cookie	"oauthState"
+//	@Router			/auth/oauth/{provider} [get]
+func (h *OAuthHandler) BeginOAuthLogin(c *gin.Context) {
+	h.log.Debug("received begin oauth login request")
+
+	authURL, stateToken, err := h.svc.BeginOAuthLogin(c.Param("provider"))
+	if err != nil {
+		err = c.Error(err)
+		return
+	}
+
+	c.SetCookie("oauthState", stateToken, int(h.oauthStateTokenExp.Seconds()), oauthStateCookiePath, h.cookieHost, false, true)
+	c.Redirect(302, authURL)
+}
+
+// FinishOAuthLogin godoc
+//
+//	@Summary		Finish social login
+//	@Description	Callback called by identity provider, creates account on first login
+//	@Tags			oauth
+//	@Produce		plain
+//	@Param			provider	path		string	true	"Identity provider name"
+//	@Param			code		query		string	false	"Authorization code"
+//	@Param			state		query		string	false	"State"
+//	@Param			Cookie		header		string	true	"oauthState"	default(oauthState=)
+//	@Success		200			{object}	string
+//	@Success		302
+//	@Header			200			{string}	Set-Cookie	"refreshToken"
+//	@Router			/auth/oauth/{provider}/callback [get]
+func (h *OAuthHandler) FinishOAuthLogin(c *gin.Context) {
+	h.log.Debug("received finish oauth login request")
+
+	sessionCookie, err := c.Cookie("SESSION")
+	stateCookie, err := c.Cookie("oauthState")
+	if err != nil || stateCookie == "" {
+		h.log.Warn("error while getting oauth state cookie")
+		err = c.Error(
+			fmt.Errorf("error while getting oauth state cookie: %w", ports.BadRequestError),
+		)
+		return
+	}
+	var oauthCallbackDto dto.OAuthCallbackDto
+	if err = c.ShouldBindUri(&oauthCallbackDto); err != nil {
+		h.log.Warn("error in request path")
+		err = c.Error(
+			fmt.Errorf("error in request path: %w", ports.BadRequestError),
+		)
+		return
+	}
+	if err = c.ShouldBindQuery(&oauthCallbackDto); err != nil {
+		h.log.Warn("error in request query")
+		err = c.Error(
+			fmt.Errorf("error in request query: %w", ports.BadRequestError),
+		)
+		return
+	}
+
+	// state is single-use
+	c.SetCookie("oauthState", "", -1, oauthStateCookiePath, h.cookieHost, false, true)
+
+	access, refresh, err := h.svc.FinishOAuthLogin(c.Request.Context(), oauthCallbackDto, stateCookie, sessionCookie, deviceOf(c))
+	if err != nil {
+		err = c.Error(err)
+		return
+	}
+
+	c.SetCookie("refreshToken", refresh, int(h.refreshTokenExp.Seconds()), "/", h.cookieHost, false, true)
+	if h.successRedirectURL != "" {
+		// browser lands on frontend, which obtains access token by refresh
+		c.Redirect(302, h.successRedirectURL)
+		return
+	}
+	c.Data(200, "text/html; charset=utf-8", []byte(access))
+}
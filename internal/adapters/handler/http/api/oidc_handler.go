@@ -0,0 +1,207 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports/dto"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/logging"
+	"net/url"
+	"strings"
+)
+
+type OIDCHandler struct {
+	loginURL string
+	svc      ports.OIDCService
+	log      logging.Logger
+}
+
+func NewOIDCHandler(loginURL string, svc ports.OIDCService, log logging.Logger) *OIDCHandler {
+	return &OIDCHandler{
+		loginURL: loginURL,
+		svc:      svc,
+		log:      log,
+	}
+}
+
+// GetOpenIDConfiguration serves provider metadata, it lives outside of API base path next to issuer
+func (h *OIDCHandler) GetOpenIDConfiguration(c *gin.Context) {
+	h.log.Debug("received openid configuration request")
+
+	c.JSON(200, h.svc.GetOpenIDConfiguration())
+}
+
+// GetJSONWebKeySet serves public keys for verification of issued tokens
+func (h *OIDCHandler) GetJSONWebKeySet(c *gin.Context) {
+	h.log.Debug("received jwks request")
+
+	// consumers cache keys, so new key must be published before it signs anything
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(200, h.svc.GetJSONWebKeySet())
+}
+
+// Authorize godoc
+//
+//	@Summary		Authorization endpoint
+//	@Description	Authorization code flow with PKCE, not logged in user is sent to login page
+//	@Tags			oidc
+//	@Param			response_type			query	string	true	"code"
+//	@Param			client_id				query	string	true	"Client ID"
+//	@Param			redirect_uri			query	string	false	"Registered redirect URI"
+//	@Param			scope					query	string	false	"Space separated scopes"
+//	@Param			state					query	string	false	"State"
+//	@Param			nonce					query	string	false	"Nonce"
+//	@Param			code_challenge			query	string	true	"PKCE code challenge"
+//	@Param			code_challenge_method	query	string	true	"S256"
+//	@Param			Cookie					header	string	false	"refreshToken"	default(refreshToken=)
+//	@Success		302
+//	@Router			/oauth/authorize [get]
+func (h *OIDCHandler) Authorize(c *gin.Context) {
+	h.log.Debug("received authorize request")
+
+	refreshTokenCookie, _ := c.Cookie("refreshToken")
+	var authorizationRequestDto dto.AuthorizationRequestDto
+	if err := c.ShouldBindQuery(&authorizationRequestDto); err != nil {
+		h.log.Warn("error in request query")
+		err = c.Error(
+			fmt.Errorf("error in request query: %w", ports.BadRequestError),
+		)
+		return
+	}
+
+	redirectURL, err := h.svc.Authorize(c.Request.Context(), refreshTokenCookie, authorizationRequestDto)
+	if errors.Is(err, ports.UnauthorizedError) && h.loginURL != "" {
+		// login page returns user to the same authorization request
+		returnTo := h.svc.GetOpenIDConfiguration().AuthorizationEndpoint + "?" + c.Request.URL.RawQuery
+		c.Redirect(302, h.loginURL+"?return_to="+url.QueryEscape(returnTo))
+		return
+	}
+	if err != nil {
+		err = c.Error(err)
+		return
+	}
+
+	c.Redirect(302, redirectURL)
+}
+
+// Token godoc
+//
+//	@Summary		Token endpoint
+//	@Description	Exchange authorization code for tokens, client authenticates with basic auth or form fields
+//	@Tags			oidc
+//	@Accept			x-www-form-urlencoded
+//	@Produce		json
+//	@Param			grant_type		formData	string	true	"authorization_code"
+//	@Param			code			formData	string	true	"Authorization code"
+//	@Param			redirect_uri	formData	string	false	"Redirect URI from authorization request"
+//	@Param			code_verifier	formData	string	true	"PKCE code verifier"
+//	@Param			client_id		formData	string	false	"Client ID"
+//	@Param			client_secret	formData	string	false	"Client secret"
+//	@Success		200				{object}	dto.TokenResponseDto
+//	@Failure		400				{object}	dto.OAuthErrorResponseDto
+//	@Failure		401				{object}	dto.OAuthErrorResponseDto
+//	@Router			/oauth/token [post]
+func (h *OIDCHandler) Token(c *gin.Context) {
+	h.log.Debug("received token request")
+
+	var tokenRequestDto dto.TokenRequestDto
+	if err := c.ShouldBind(&tokenRequestDto); err != nil {
+		h.log.Warn("error in request body")
+		err = c.Error(
+			ports.NewOAuthError("invalid_request", "error in request body", ports.BadRequestError),
+		)
+		return
+	}
+	if clientID, clientSecret, ok := c.Request.BasicAuth(); ok {
+		// credentials are form-urlencoded before being put into header
+		tokenRequestDto.ClientID, _ = url.QueryUnescape(clientID)
+		tokenRequestDto.ClientSecret, _ = url.QueryUnescape(clientSecret)
+	}
+
+	tokenResponseDto, err := h.svc.Token(c.Request.Context(), tokenRequestDto)
+	if err != nil {
+		err = c.Error(err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+	c.JSON(200, tokenResponseDto)
+}
+
+// Introspect godoc
+//
+//	@Summary		Token introspection endpoint
+//	@Description	Tell whether access or refresh token is active, for confidential clients authenticated by client_secret_basic or client_secret_post
+//	@Tags			oidc
+//	@Accept			x-www-form-urlencoded
+//	@Produce		json
+//	@Param			token			formData	string	true	"Access or refresh token"
+//	@Param			token_type_hint	formData	string	false	"access_token or refresh_token"
+//	@Param			client_id		formData	string	false	"Client ID"
+//	@Param			client_secret	formData	string	false	"Client secret"
+//	@Success		200				{object}	dto.IntrospectionResponseDto
+//	@Failure		401				{object}	dto.OAuthErrorResponseDto
+//	@Router			/auth/introspect [post]
+func (h *OIDCHandler) Introspect(c *gin.Context) {
+	h.log.Debug("received introspection request")
+
+	var introspectionRequestDto dto.IntrospectionRequestDto
+	if err := c.ShouldBind(&introspectionRequestDto); err != nil {
+		h.log.Warn("error in request body")
+		err = c.Error(
+			ports.NewOAuthError("invalid_request", "error in request body", ports.BadRequestError),
+		)
+		return
+	}
+	if clientID, clientSecret, ok := c.Request.BasicAuth(); ok {
+		introspectionRequestDto.ClientID, _ = url.QueryUnescape(clientID)
+		introspectionRequestDto.ClientSecret, _ = url.QueryUnescape(clientSecret)
+	}
+
+	introspectionResponseDto, err := h.svc.Introspect(c.Request.Context(), introspectionRequestDto)
+	if err != nil {
+		err = c.Error(err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(200, introspectionResponseDto)
+}
+
+// UserInfo godoc
+//
+//	@Summary		UserInfo endpoint
+//	@Description	Claims about user allowed by access token scopes
+//	@Tags			oidc
+//	@Produce		json
+//	@Param			Authorization	header		string	true	"Bearer access token"
+//	@Success		200				{object}	dto.UserInfoResponseDto
+//	@Failure		401				{object}	dto.OAuthErrorResponseDto
+//	@Router			/oauth/userinfo [get]
+func (h *OIDCHandler) UserInfo(c *gin.Context) {
+	h.log.Debug("received userinfo request")
+
+	accessToken, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || accessToken == "" {
+		h.log.Warn("error while getting access token")
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		_ = c.Error(
+			ports.NewOAuthError("invalid_token", "bearer access token is required", ports.UnauthorizedError),
+		)
+		return
+	}
+
+	userInfoResponseDto, err := h.svc.UserInfo(c.Request.Context(), accessToken)
+	if err != nil {
+		var oauthError *ports.OAuthError
+		if errors.As(err, &oauthError) {
+			c.Header("WWW-Authenticate", fmt.Sprintf(`Bearer error="%s"`, oauthError.Code))
+		}
+		err = c.Error(err)
+		return
+	}
+
+	c.JSON(200, userInfoResponseDto)
+}
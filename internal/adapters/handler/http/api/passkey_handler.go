@@ -0,0 +1,151 @@
+package api
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/logging"
+	"time"
+)
+
+type PasskeyHandler struct {
+	cookieHost      string
+	refreshTokenExp time.Duration
+	svc             ports.PasskeyService
+	log             logging.Logger
+}
+
+func NewPasskeyHandler(cookieHost string, refreshTokenExp time.Duration, svc ports.PasskeyService, log logging.Logger) *PasskeyHandler {
+	return &PasskeyHandler{
+		cookieHost:      cookieHost,
+		refreshTokenExp: refreshTokenExp,
+		svc:             svc,
+		log:             log,
+	}
+}
+
+// BeginPasskeyRegistration godoc
+//
+//	@Summary		Begin passkey registration
+//	@Description	Get options for navigator.credentials.create()
+//	@Tags			passkeys
+//	@Accept			json
+//	@Produce		json
+//	@Param			Cookie	header		string	true	"refreshToken"	default(refreshToken=)
+//	@Success		200		{object}	object
+//	@Router			/auth/passkeys/registration/begin [post]
+func (h *PasskeyHandler) BeginPasskeyRegistration(c *gin.Context) {
+	h.log.Debug("received begin passkey registration request")
+
+	refreshTokenCookie, err := c.Cookie("refreshToken")
+	if err != nil || refreshTokenCookie == "" {
+		h.log.Warn("error while getting refresh token cookie")
+		err = c.Error(
+			fmt.Errorf("error while getting refresh token cookie: %w", ports.UnauthorizedError),
+		)
+		return
+	}
+
+	creation, err := h.svc.BeginPasskeyRegistration(c.Request.Context(), refreshTokenCookie)
+	if err != nil {
+		err = c.Error(err)
+		return
+	}
+
+	c.JSON(200, creation)
+}
+
+// FinishPasskeyRegistration godoc
+//
+//	@Summary		Finish passkey registration
+//	@Description	Save credential created by authenticator
+//	@Tags			passkeys
+//	@Accept			json
+//	@Produce		json
+//	@Param			Cookie	header	string	true	"refreshToken"	default(refreshToken=)
+//	@Param			request	body	object	true	"PublicKeyCredential returned by navigator.credentials.create()"
+//	@Success		201
+//	@Router			/auth/passkeys/registration/finish [post]
+func (h *PasskeyHandler) FinishPasskeyRegistration(c *gin.Context) {
+	h.log.Debug("received finish passkey registration request")
+
+	refreshTokenCookie, err := c.Cookie("refreshToken")
+	if err != nil || refreshTokenCookie == "" {
+		h.log.Warn("error while getting refresh token cookie")
+		err = c.Error(
+			fmt.Errorf("error while getting refresh token cookie: %w", ports.UnauthorizedError),
+		)
+		return
+	}
+	body, err := c.GetRawData()
+	if err != nil {
+		h.log.Warn("error in request body")
+		err = c.Error(
+			fmt.Errorf("error in request body: %w", ports.BadRequestError),
+		)
+		return
+	}
+
+	err = h.svc.FinishPasskeyRegistration(c.Request.Context(), refreshTokenCookie, body)
+	if err != nil {
+		err = c.Error(err)
+		return
+	}
+
+	c.Status(201)
+}
+
+// BeginPasskeyLogin godoc
+//
+//	@Summary		Begin passkey login
+//	@Description	Get options for navigator.credentials.get()
+//	@Tags			passkeys
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	object
+//	@Router			/auth/passkeys/login/begin [post]
+func (h *PasskeyHandler) BeginPasskeyLogin(c *gin.Context) {
+	h.log.Debug("received begin passkey login request")
+
+	assertion, err := h.svc.BeginPasskeyLogin(c.Request.Context())
+	if err != nil {
+		err = c.Error(err)
+		return
+	}
+
+	c.JSON(200, assertion)
+}
+
+// FinishPasskeyLogin godoc
+//
+//	@Summary		Finish passkey login
+//	@Description	Login with assertion signed by authenticator
+//	@Tags			passkeys
+//	@Accept			json
+//	@Produce		plain
+//	@Param			request	body		object	true	"PublicKeyCredential returned by navigator.credentials.get()"
+//	@Success		200		{object}	string
+//	@Header			200		{string}	Set-Cookie	"refreshToken"
+//	@Router			/auth/passkeys/login/finish [post]
+func (h *PasskeyHandler) FinishPasskeyLogin(c *gin.Context) {
+	h.log.Debug("received finish passkey login request")
+
+	sessionCookie, err := c.Cookie("SESSION")
+	body, err := c.GetRawData()
+	if err != nil {
+		h.log.Warn("error in request body")
+		err = c.Error(
+			fmt.Errorf("error in request body: %w", ports.BadRequestError),
+		)
+		return
+	}
+
+	access, refresh, err := h.svc.FinishPasskeyLogin(c.Request.Context(), body, sessionCookie, deviceOf(c))
+	if err != nil {
+		err = c.Error(err)
+		return
+	}
+
+	c.SetCookie("refreshToken", refresh, int(h.refreshTokenExp.Seconds()), "/", h.cookieHost, false, true)
+	c.Data(200, "text/html; charset=utf-8", []byte(access))
+}
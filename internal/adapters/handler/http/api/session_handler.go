@@ -0,0 +1,109 @@
+package api
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/logging"
+)
+
+type SessionHandler struct {
+	svc ports.SessionService
+	log logging.Logger
+}
+
+func NewSessionHandler(svc ports.SessionService, log logging.Logger) *SessionHandler {
+	return &SessionHandler{
+		svc: svc,
+		log: log,
+	}
+}
+
+// GetSessions godoc
+//
+//	@Summary		Get sessions
+//	@Description	Get devices where user is logged in, the one of this request is flagged as current
+//	@Tags			sessions
+//	@Produce		json
+//	@Param			Cookie	header		string	true	"refreshToken"	default(refreshToken=)
+//	@Success		200		{array}		dto.SessionResponseDto
+//	@Router			/auth/sessions [get]
+func (h *SessionHandler) GetSessions(c *gin.Context) {
+	h.log.Debug("received get sessions request")
+
+	refreshTokenCookie, ok := h.refreshTokenCookie(c)
+	if !ok {
+		return
+	}
+
+	sessions, err := h.svc.GetSessions(c.Request.Context(), refreshTokenCookie)
+	if err != nil {
+		err = c.Error(err)
+		return
+	}
+
+	c.JSON(200, sessions)
+}
+
+// RevokeSession godoc
+//
+//	@Summary		Revoke session
+//	@Description	Log out on device of the session
+//	@Tags			sessions
+//	@Param			Cookie	header	string	true	"refreshToken"	default(refreshToken=)
+//	@Param			id		path	string	true	"Session ID"
+//	@Success		204
+//	@Router			/auth/sessions/{id} [delete]
+func (h *SessionHandler) RevokeSession(c *gin.Context) {
+	h.log.Debug("received revoke session request")
+
+	refreshTokenCookie, ok := h.refreshTokenCookie(c)
+	if !ok {
+		return
+	}
+
+	err := h.svc.RevokeSession(c.Request.Context(), refreshTokenCookie, c.Param("id"))
+	if err != nil {
+		err = c.Error(err)
+		return
+	}
+
+	c.Status(204)
+}
+
+// RevokeOtherSessions godoc
+//
+//	@Summary		Revoke other sessions
+//	@Description	Log out everywhere except current device
+//	@Tags			sessions
+//	@Param			Cookie	header	string	true	"refreshToken"	default(refreshToken=)
+//	@Success		204
+//	@Router			/auth/sessions [delete]
+func (h *SessionHandler) RevokeOtherSessions(c *gin.Context) {
+	h.log.Debug("received revoke other sessions request")
+
+	refreshTokenCookie, ok := h.refreshTokenCookie(c)
+	if !ok {
+		return
+	}
+
+	err := h.svc.RevokeOtherSessions(c.Request.Context(), refreshTokenCookie)
+	if err != nil {
+		err = c.Error(err)
+		return
+	}
+
+	c.Status(204)
+}
+
+func (h *SessionHandler) refreshTokenCookie(c *gin.Context) (string, bool) {
+	refreshTokenCookie, err := c.Cookie("refreshToken")
+	if err != nil || refreshTokenCookie == "" {
+		h.log.Warn("error while getting refresh token cookie")
+		err = c.Error(
+			fmt.Errorf("error while getting refresh token cookie: %w", ports.UnauthorizedError),
+		)
+		return "", false
+	}
+	return refreshTokenCookie, true
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/logging"
+)
+
+type SigningKeyHandler struct {
+	svc ports.SigningKeyService
+	log logging.Logger
+}
+
+func NewSigningKeyHandler(svc ports.SigningKeyService, log logging.Logger) *SigningKeyHandler {
+	return &SigningKeyHandler{
+		svc: svc,
+		log: log,
+	}
+}
+
+// RotateSigningKeys godoc
+//
+//	@Summary		Rotate signing keys
+//	@Description	Promote published key to sign new tokens, previous key stays in JWKS until tokens it signed expire
+//	@Tags			admin
+//	@Produce		json
+//	@Param			X-API-Key	header		string	true	"Admin API key"
+//	@Success		200			{object}	dto.SigningKeyResponseDto
+//	@Router			/admin/signing-keys/rotate [post]
+func (h *SigningKeyHandler) RotateSigningKeys(c *gin.Context) {
+	h.log.Debug("received rotate signing keys request")
+
+	signingKeyResponseDto, err := h.svc.RotateSigningKeys(c.Request.Context())
+	if err != nil {
+		err = c.Error(err)
+		return
+	}
+
+	c.JSON(200, signingKeyResponseDto)
+}
+
+// GetSigningKeys godoc
+//
+//	@Summary		Get signing keys
+//	@Description	Get metadata of signing keys in all states
+//	@Tags			admin
+//	@Produce		json
+//	@Param			X-API-Key	header		string	true	"Admin API key"
+//	@Success		200			{array}		dto.SigningKeyResponseDto
+//	@Router			/admin/signing-keys [get]
+func (h *SigningKeyHandler) GetSigningKeys(c *gin.Context) {
+	h.log.Debug("received get signing keys request")
+
+	signingKeys, err := h.svc.GetSigningKeys(c.Request.Context())
+	if err != nil {
+		err = c.Error(err)
+		return
+	}
+
+	c.JSON(200, signingKeys)
+}
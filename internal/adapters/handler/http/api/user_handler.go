@@ -0,0 +1,238 @@
+package api
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports/dto"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/logging"
+)
+
+const (
+	// UserIDKey and ClaimsKey hold subject and claims of validated access token in request context
+	UserIDKey = "userID"
+	ClaimsKey = "claims"
+)
+
+type UserHandler struct {
+	svc ports.UserService
+	log logging.Logger
+}
+
+func NewUserHandler(svc ports.UserService, log logging.Logger) *UserHandler {
+	return &UserHandler{
+		svc: svc,
+		log: log,
+	}
+}
+
+// GetMe godoc
+//
+//	@Summary		Get current user
+//	@Description	Get profile of user the access token is issued to
+//	@Tags			users
+//	@Produce		json
+//	@Param			Authorization	header		string	true	"Bearer access token"
+//	@Success		200				{object}	dto.UserResponseDto
+//	@Router			/auth/me [get]
+func (h *UserHandler) GetMe(c *gin.Context) {
+	h.log.Debug("received get me request")
+
+	userResponseDto, err := h.svc.GetUser(c.Request.Context(), c.GetString(UserIDKey))
+	if err != nil {
+		err = c.Error(err)
+		return
+	}
+
+	c.JSON(200, userResponseDto)
+}
+
+// ChangeNickname godoc
+//
+//	@Summary		Change nickname
+//	@Description	Change nickname of current user, it can be changed once per cooldown period
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header		string						true	"Bearer access token"
+//	@Param			request			body		dto.ChangeNicknameRequestDto	true	"New nickname"
+//	@Success		200				{object}	dto.UserResponseDto
+//	@Router			/auth/me/nickname [put]
+func (h *UserHandler) ChangeNickname(c *gin.Context) {
+	h.log.Debug("received change nickname request")
+
+	var changeNicknameRequestDto dto.ChangeNicknameRequestDto
+	if err := c.ShouldBindJSON(&changeNicknameRequestDto); err != nil {
+		h.log.Warn("error in request body")
+		err = c.Error(
+			fmt.Errorf("error in request body: %w", ports.BadRequestError),
+		)
+		return
+	}
+
+	userResponseDto, err := h.svc.ChangeNickname(c.Request.Context(), c.GetString(UserIDKey), changeNicknameRequestDto.Nickname)
+	if err != nil {
+		err = c.Error(err)
+		return
+	}
+
+	c.JSON(200, userResponseDto)
+}
+
+// ChangePassword godoc
+//
+//	@Summary		Change password
+//	@Description	Change password of current user, all sessions except the current one are terminated and access tokens issued before are revoked
+//	@Tags			users
+//	@Accept			json
+//	@Param			Authorization	header	string						true	"Bearer access token"
+//	@Param			Cookie			header	string						false	"refreshToken of session to keep"	default(refreshToken=)
+//	@Param			request			body	dto.ChangePasswordRequestDto	true	"Current and new passwords"
+//	@Success		204
+//	@Router			/auth/me/password [put]
+func (h *UserHandler) ChangePassword(c *gin.Context) {
+	h.log.Debug("received change password request")
+
+	var changePasswordRequestDto dto.ChangePasswordRequestDto
+	if err := c.ShouldBindJSON(&changePasswordRequestDto); err != nil {
+		h.log.Warn("error in request body")
+		err = c.Error(
+			fmt.Errorf("error in request body: %w", ports.BadRequestError),
+		)
+		return
+	}
+	refreshTokenCookie, _ := c.Cookie("refreshToken")
+
+	err := h.svc.ChangePassword(c.Request.Context(), c.GetString(UserIDKey), refreshTokenCookie, changePasswordRequestDto)
+	if err != nil {
+		err = c.Error(err)
+		return
+	}
+
+	c.Status(204)
+}
+
+// ChangeEmail godoc
+//
+//	@Summary		Change email
+//	@Description	Send confirmation letter to new address, email is changed once it is confirmed
+//	@Tags			users
+//	@Accept			json
+//	@Param			Authorization	header	string					true	"Bearer access token"
+//	@Param			request			body	dto.ChangeEmailRequestDto	true	"New email"
+//	@Success		204
+//	@Router			/auth/me/email [put]
+func (h *UserHandler) ChangeEmail(c *gin.Context) {
+	h.log.Debug("received change email request")
+
+	var changeEmailRequestDto dto.ChangeEmailRequestDto
+	if err := c.ShouldBindJSON(&changeEmailRequestDto); err != nil {
+		h.log.Warn("error in request body")
+		err = c.Error(
+			fmt.Errorf("error in request body: %w", ports.BadRequestError),
+		)
+		return
+	}
+
+	err := h.svc.ChangeEmail(c.Request.Context(), c.GetString(UserIDKey), changeEmailRequestDto.Email)
+	if err != nil {
+		err = c.Error(err)
+		return
+	}
+
+	c.Status(204)
+}
+
+// ConfirmEmailChange godoc
+//
+//	@Summary		Confirm email change
+//	@Description	Change email to the address the confirmation token was sent to
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		dto.ConfirmEmailChangeRequestDto	true	"Confirmation token"
+//	@Success		200		{object}	dto.UserResponseDto
+//	@Router			/auth/email/confirm [post]
+func (h *UserHandler) ConfirmEmailChange(c *gin.Context) {
+	h.log.Debug("received confirm email change request")
+
+	var confirmEmailChangeRequestDto dto.ConfirmEmailChangeRequestDto
+	if err := c.ShouldBindJSON(&confirmEmailChangeRequestDto); err != nil {
+		h.log.Warn("error in request body")
+		err = c.Error(
+			fmt.Errorf("error in request body: %w", ports.BadRequestError),
+		)
+		return
+	}
+
+	userResponseDto, err := h.svc.ConfirmEmailChange(c.Request.Context(), confirmEmailChangeRequestDto.Token)
+	if err != nil {
+		err = c.Error(err)
+		return
+	}
+
+	c.JSON(200, userResponseDto)
+}
+
+// BanUser godoc
+//
+//	@Summary		Ban user
+//	@Description	Forbid login of user, end all sessions and revoke issued access tokens
+//	@Tags			admin
+//	@Param			X-API-Key	header	string	true	"Admin API key"
+//	@Param			userID		path	string	true	"User ID"
+//	@Success		204
+//	@Router			/admin/users/{userID}/ban [post]
+func (h *UserHandler) BanUser(c *gin.Context) {
+	h.log.Debug("received ban user request")
+
+	err := h.svc.BanUser(c.Request.Context(), c.Param("userID"))
+	if err != nil {
+		err = c.Error(err)
+		return
+	}
+
+	c.Status(204)
+}
+
+// UnbanUser godoc
+//
+//	@Summary		Unban user
+//	@Description	Allow banned user to login again
+//	@Tags			admin
+//	@Param			X-API-Key	header	string	true	"Admin API key"
+//	@Param			userID		path	string	true	"User ID"
+//	@Success		204
+//	@Router			/admin/users/{userID}/ban [delete]
+func (h *UserHandler) UnbanUser(c *gin.Context) {
+	h.log.Debug("received unban user request")
+
+	err := h.svc.UnbanUser(c.Request.Context(), c.Param("userID"))
+	if err != nil {
+		err = c.Error(err)
+		return
+	}
+
+	c.Status(204)
+}
+
+// UnlockUser godoc
+//
+//	@Summary		Unlock user
+//	@Description	Lift lock set after failed logins and forget failed logins
+//	@Tags			admin
+//	@Param			X-API-Key	header	string	true	"Admin API key"
+//	@Param			userID		path	string	true	"User ID"
+//	@Success		204
+//	@Router			/admin/users/{userID}/lock [delete]
+func (h *UserHandler) UnlockUser(c *gin.Context) {
+	h.log.Debug("received unlock user request")
+
+	err := h.svc.UnlockUser(c.Request.Context(), c.Param("userID"))
+	if err != nil {
+		err = c.Error(err)
+		return
+	}
+
+	c.Status(204)
+}
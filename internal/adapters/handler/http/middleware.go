@@ -1,10 +1,22 @@
 package http
 
 import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/ttodoshi/code-typing-auth-service/internal/adapters/handler/http/api"
 	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports/dto"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/jwt"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/ratelimit"
+	"io"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -31,9 +43,25 @@ func ErrorHandlerMiddleware() gin.HandlerFunc {
 				responseStatus = http.StatusForbidden
 			} else if errors.Is(err, ports.NotFoundError) {
 				responseStatus = http.StatusNotFound
+			} else if errors.Is(err, ports.TooManyRequestsError) {
+				responseStatus = http.StatusTooManyRequests
 			} else {
 				responseStatus = http.StatusInternalServerError
 			}
+			var rateLimitError *ports.RateLimitError
+			if errors.As(err, &rateLimitError) {
+				c.Header("Retry-After", strconv.Itoa(int(math.Ceil(rateLimitError.RetryAfter.Seconds()))))
+			}
+			var oauthError *ports.OAuthError
+			if errors.As(err, &oauthError) {
+				c.Header("Cache-Control", "no-store")
+				c.JSON(responseStatus, dto.OAuthErrorResponseDto{
+					Error:            oauthError.Code,
+					ErrorDescription: oauthError.Description,
+				})
+				c.Abort()
+				return
+			}
 			c.JSON(responseStatus, errorResponse{
 				Timestamp: time.Now(),
 				Status:    responseStatus,
@@ -46,3 +74,104 @@ func ErrorHandlerMiddleware() gin.HandlerFunc {
 		}
 	}
 }
+
+// AuthMiddleware requires valid not revoked bearer access token and puts its subject and claims into context,
+// tokens issued to OAuth clients are rejected, they are accepted by userinfo and introspection endpoints only
+func AuthMiddleware(jwtIssuer *jwt.Issuer, revocationService ports.RevocationService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		accessToken, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || accessToken == "" {
+			c.Header("WWW-Authenticate", "Bearer")
+			_ = c.Error(
+				fmt.Errorf("bearer access token is required: %w", ports.UnauthorizedError),
+			)
+			c.Abort()
+			return
+		}
+		claims, err := jwtIssuer.ParseAccessJWT(accessToken)
+		if err != nil || revocationService.IsAccessTokenRevoked(claims) {
+			c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+			_ = c.Error(
+				fmt.Errorf("access token is invalid: %w", ports.UnauthorizedError),
+			)
+			c.Abort()
+			return
+		}
+		sub, _ := claims["sub"].(string)
+		c.Set(api.UserIDKey, sub)
+		c.Set(api.ClaimsKey, claims)
+		c.Next()
+	}
+}
+
+// AdminMiddleware allows requests with X-API-Key header equal to adminAPIKey, admin API is closed when key is empty
+func AdminMiddleware(adminAPIKey string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		apiKey := c.GetHeader("X-API-Key")
+		if adminAPIKey == "" || subtle.ConstantTimeCompare([]byte(apiKey), []byte(adminAPIKey)) != 1 {
+			_ = c.Error(
+				fmt.Errorf("admin api key is invalid: %w", ports.ForbiddenError),
+			)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RateLimit is token bucket policy applied separately to every key of request, requests with empty key are not limited
+type RateLimit struct {
+	Name   string
+	Policy ratelimit.Policy
+	Key    func(c *gin.Context) string
+}
+
+// RateLimitMiddleware rejects request with 429 when bucket of any of given limits is empty
+func RateLimitMiddleware(rateLimitService ports.RateLimitService, limits ...RateLimit) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, limit := range limits {
+			if !limit.Policy.Enabled() {
+				continue
+			}
+			key := limit.Key(c)
+			if key == "" {
+				continue
+			}
+			err := rateLimitService.Allow(c.Request.Context(), limit.Name+":"+key, limit.Policy)
+			if err != nil {
+				_ = c.Error(err)
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// PerIP limits requests by client address, trusted proxies must be configured for addresses behind proxy
+func PerIP(c *gin.Context) string {
+	return c.ClientIP()
+}
+
+// maxRateLimitedBodySize bounds body read by PerBodyField, bodies of login and registration are far smaller
+const maxRateLimitedBodySize = 64 << 10
+
+// PerBodyField limits requests by string field of JSON body, body is restored for handler.
+// Body larger than maxRateLimitedBodySize is not limited, handler fails to read it as well
+func PerBodyField(field string) func(c *gin.Context) string {
+	return func(c *gin.Context) string {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxRateLimitedBodySize)
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			return ""
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		var fields map[string]interface{}
+		if json.Unmarshal(body, &fields) != nil {
+			return ""
+		}
+		value, _ := fields[field].(string)
+		return strings.ToLower(strings.TrimSpace(value))
+	}
+}
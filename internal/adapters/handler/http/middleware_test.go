@@ -0,0 +1,139 @@
+package http
+
+import (
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/ttodoshi/code-typing-auth-service/internal/adapters/handler/http/api"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports/mocks"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/servises"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/jwt"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/logging/nop"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/password"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuthMiddleware(t *testing.T) {
+	var log = nop.GetLogger()
+	gin.SetMode(gin.TestMode)
+	jwtIssuer := jwt.NewIssuer(jwt.Config{SecretKey: "secret", AccessTokenExp: 5 * time.Minute, LegacyKeyEnabled: true})
+	// mocks
+	userRepo := new(mocks.UserRepository)
+	revokedTokenRepo := new(mocks.RevokedAccessTokenRepository)
+
+	user := domain.User{
+		Nickname: gofakeit.Username(),
+		Email:    gofakeit.Email(),
+	}
+	user.ID = primitive.NewObjectID()
+	userRepo.
+		On("GetUserByID", mock.Anything, user.ID.Hex()).
+		Return(user, nil)
+
+	// router with handlers of user routes only
+	revocationService := servises.NewRevocationService(jwtIssuer, revokedTokenRepo, log)
+	userService := servises.NewUserService(time.Hour, jwtIssuer, password.BcryptHasher{Cost: bcrypt.MinCost}, userRepo, nil, nil, nil, revocationService, nil, nil, log)
+	e := gin.New()
+	NewRouter(
+		"", nil, RateLimitPolicies{}, log, jwtIssuer, revocationService, nil,
+		nil, api.NewUserHandler(userService, log), nil, nil, nil, nil, nil, nil, nil, nil, nil,
+	).InitRoutes(e)
+
+	request := func(method string, path string, accessToken string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(`{"nickname":"nickname"}`))
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("successful request with first-party access token", func(t *testing.T) {
+		accessToken, _ := jwtIssuer.GenerateAccessJWT(user.ID.Hex())
+
+		w := request(http.MethodGet, "/api/v1/auth/me", accessToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), user.Nickname)
+	})
+	t.Run("unsuccessful request due to access token issued to OAuth client", func(t *testing.T) {
+		clientAccessToken, _ := jwtIssuer.GenerateClientAccessJWT(user.ID.Hex(), "results-service", jwt.Claim{Name: "scope", Value: "openid profile"})
+
+		w := request(http.MethodGet, "/api/v1/auth/me", clientAccessToken)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		w = request(http.MethodPut, "/api/v1/auth/me/nickname", clientAccessToken)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+	t.Run("unsuccessful request without access token", func(t *testing.T) {
+		w := request(http.MethodGet, "/api/v1/auth/me", "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+	userRepo.AssertExpectations(t)
+}
+
+func TestCORS(t *testing.T) {
+	var log = nop.GetLogger()
+	gin.SetMode(gin.TestMode)
+
+	// router without handlers, preflight requests are answered by cors middleware
+	e := gin.New()
+	NewRouter(
+		"", []string{"http://localhost:3000"}, RateLimitPolicies{}, log, nil, nil, nil,
+		nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil,
+	).InitRoutes(e)
+
+	preflight := func(origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodOptions, "/api/v1/auth/sessions", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", http.MethodDelete)
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("successful preflight from front-end origin", func(t *testing.T) {
+		w := preflight("http://localhost:3000")
+		assert.Equal(t, "http://localhost:3000", w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+	})
+	t.Run("unsuccessful preflight from another origin", func(t *testing.T) {
+		w := preflight("https://attacker.example")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	})
+}
+
+func TestPerBodyField(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	key := PerBodyField("login")
+
+	newContext := func(body string) *gin.Context {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", strings.NewReader(body))
+		return c
+	}
+
+	t.Run("successful key of body restored for handler", func(t *testing.T) {
+		c := newContext(`{"login":" Nickname "}`)
+
+		assert.Equal(t, "nickname", key(c))
+		body, err := io.ReadAll(c.Request.Body)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"login":" Nickname "}`, string(body))
+	})
+	t.Run("unsuccessful key due to body exceeding limit", func(t *testing.T) {
+		c := newContext(`{"login":"nickname","padding":"` + strings.Repeat("a", maxRateLimitedBodySize) + `"}`)
+
+		assert.Empty(t, key(c))
+		_, err := io.ReadAll(c.Request.Body)
+		assert.Error(t, err)
+	})
+}
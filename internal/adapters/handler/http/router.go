@@ -1,24 +1,69 @@
 package http
 
 import (
+	"expvar"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 	"github.com/ttodoshi/code-typing-auth-service/internal/adapters/handler/http/api"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/jwt"
 	"github.com/ttodoshi/code-typing-auth-service/pkg/logging"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/ratelimit"
 	"net/http"
+	"slices"
 )
 
+// RateLimitPolicies are token bucket policies of public routes, zero policy disables limit
+type RateLimitPolicies struct {
+	LoginPerIP           ratelimit.Policy
+	LoginPerAccount      ratelimit.Policy
+	RegistrationPerIP    ratelimit.Policy
+	RegistrationPerEmail ratelimit.Policy
+}
+
 type Router struct {
-	log logging.Logger
+	adminAPIKey       string
+	allowedOrigins    []string
+	rateLimits        RateLimitPolicies
+	log               logging.Logger
+	jwtIssuer         *jwt.Issuer
+	revocationService ports.RevocationService
+	rateLimitService  ports.RateLimitService
 	*api.AuthHandler
+	*api.UserHandler
+	*api.AccountHandler
+	*api.DataExportHandler
+	*api.MFAHandler
+	*api.SessionHandler
+	*api.PasskeyHandler
+	*api.OAuthHandler
+	*api.OIDCHandler
+	*api.OAuthClientHandler
+	*api.SigningKeyHandler
 }
 
-func NewRouter(log logging.Logger, authHandler *api.AuthHandler) *Router {
+func NewRouter(adminAPIKey string, allowedOrigins []string, rateLimits RateLimitPolicies, log logging.Logger, jwtIssuer *jwt.Issuer, revocationService ports.RevocationService, rateLimitService ports.RateLimitService, authHandler *api.AuthHandler, userHandler *api.UserHandler, accountHandler *api.AccountHandler, dataExportHandler *api.DataExportHandler, mfaHandler *api.MFAHandler, sessionHandler *api.SessionHandler, passkeyHandler *api.PasskeyHandler, oauthHandler *api.OAuthHandler, oidcHandler *api.OIDCHandler, oauthClientHandler *api.OAuthClientHandler, signingKeyHandler *api.SigningKeyHandler) *Router {
 	return &Router{
-		log:         log,
-		AuthHandler: authHandler,
+		adminAPIKey:        adminAPIKey,
+		allowedOrigins:     allowedOrigins,
+		rateLimits:         rateLimits,
+		log:                log,
+		jwtIssuer:          jwtIssuer,
+		revocationService:  revocationService,
+		rateLimitService:   rateLimitService,
+		AuthHandler:        authHandler,
+		UserHandler:        userHandler,
+		AccountHandler:     accountHandler,
+		DataExportHandler:  dataExportHandler,
+		MFAHandler:         mfaHandler,
+		SessionHandler:     sessionHandler,
+		PasskeyHandler:     passkeyHandler,
+		OAuthHandler:       oauthHandler,
+		OIDCHandler:        oidcHandler,
+		OAuthClientHandler: oauthClientHandler,
+		SigningKeyHandler:  signingKeyHandler,
 	}
 }
 
@@ -26,8 +71,9 @@ func (r *Router) InitRoutes(e *gin.Engine) {
 	r.log.Info("initializing error handling middleware")
 	e.Use(ErrorHandlerMiddleware())
 	e.Use(cors.New(cors.Config{
+		// only front-end may send credentials, some routes are authenticated by refresh token cookie alone
 		AllowOriginFunc: func(origin string) bool {
-			return true
+			return slices.Contains(r.allowedOrigins, origin)
 		},
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "PATCH", "OPTIONS", "HEAD"},
 		AllowHeaders:     []string{"Content-Type", "Authorization"},
@@ -44,15 +90,80 @@ func (r *Router) InitRoutes(e *gin.Engine) {
 		ctx.String(http.StatusOK, "OK")
 	})
 
+	// openid connect discovery
+	e.GET("/.well-known/openid-configuration", r.GetOpenIDConfiguration)
+	e.GET("/.well-known/jwks.json", r.GetJSONWebKeySet)
+
 	apiGroup := e.Group("/api")
 
 	v1ApiGroup := apiGroup.Group("/v1")
 
 	v1TextsGroup := v1ApiGroup.Group("/auth")
 	{
-		v1TextsGroup.POST("/registration", r.Register)
-		v1TextsGroup.POST("/login", r.Login)
+		v1TextsGroup.POST("/registration", RateLimitMiddleware(
+			r.rateLimitService,
+			RateLimit{Name: "registration:ip", Policy: r.rateLimits.RegistrationPerIP, Key: PerIP},
+			RateLimit{Name: "registration:email", Policy: r.rateLimits.RegistrationPerEmail, Key: PerBodyField("email")},
+		), r.Register)
+		v1TextsGroup.POST("/login", RateLimitMiddleware(
+			r.rateLimitService,
+			RateLimit{Name: "login:ip", Policy: r.rateLimits.LoginPerIP, Key: PerIP},
+			RateLimit{Name: "login:account", Policy: r.rateLimits.LoginPerAccount, Key: PerBodyField("login")},
+		), r.Login)
+		v1TextsGroup.POST("/login/2fa", RateLimitMiddleware(
+			r.rateLimitService,
+			RateLimit{Name: "login:ip", Policy: r.rateLimits.LoginPerIP, Key: PerIP},
+		), r.LoginMFA)
 		v1TextsGroup.GET("/refresh", r.Refresh)
 		v1TextsGroup.DELETE("/logout", r.Logout)
+		v1TextsGroup.POST("/verify-email", r.VerifyEmail)
+		v1TextsGroup.POST("/verify-email/resend", r.ResendVerificationEmail)
+		v1TextsGroup.POST("/password/forgot", r.ForgotPassword)
+		v1TextsGroup.POST("/password/reset", r.ResetPassword)
+		v1TextsGroup.GET("/me", AuthMiddleware(r.jwtIssuer, r.revocationService), r.GetMe)
+		v1TextsGroup.PUT("/me/nickname", AuthMiddleware(r.jwtIssuer, r.revocationService), r.ChangeNickname)
+		v1TextsGroup.PUT("/me/password", AuthMiddleware(r.jwtIssuer, r.revocationService), r.ChangePassword)
+		v1TextsGroup.PUT("/me/email", AuthMiddleware(r.jwtIssuer, r.revocationService), r.ChangeEmail)
+		v1TextsGroup.POST("/email/confirm", r.ConfirmEmailChange)
+		v1TextsGroup.DELETE("/account", AuthMiddleware(r.jwtIssuer, r.revocationService), r.DeleteAccount)
+		v1TextsGroup.POST("/me/export", AuthMiddleware(r.jwtIssuer, r.revocationService), r.RequestDataExport)
+		v1TextsGroup.GET("/me/export/:id", AuthMiddleware(r.jwtIssuer, r.revocationService), r.GetDataExport)
+		v1TextsGroup.GET("/export/download", r.DownloadDataExport)
+		v1TextsGroup.POST("/introspect", r.Introspect)
+		v1TextsGroup.GET("/sessions", r.GetSessions)
+		v1TextsGroup.DELETE("/sessions", r.RevokeOtherSessions)
+		v1TextsGroup.DELETE("/sessions/:id", r.RevokeSession)
+		v1TextsGroup.POST("/2fa/totp/enroll", r.EnrollTOTP)
+		v1TextsGroup.POST("/2fa/totp/confirm", r.ConfirmTOTP)
+		v1TextsGroup.POST("/2fa/totp/disable", r.DisableTOTP)
+		v1TextsGroup.POST("/2fa/recovery-codes", r.RegenerateRecoveryCodes)
+		v1TextsGroup.POST("/passkeys/registration/begin", r.BeginPasskeyRegistration)
+		v1TextsGroup.POST("/passkeys/registration/finish", r.FinishPasskeyRegistration)
+		v1TextsGroup.POST("/passkeys/login/begin", r.BeginPasskeyLogin)
+		v1TextsGroup.POST("/passkeys/login/finish", r.FinishPasskeyLogin)
+		v1TextsGroup.GET("/oauth/:provider", r.BeginOAuthLogin)
+		v1TextsGroup.GET("/oauth/:provider/callback", r.FinishOAuthLogin)
+	}
+
+	v1OAuthGroup := v1ApiGroup.Group("/oauth")
+	{
+		v1OAuthGroup.GET("/authorize", r.Authorize)
+		v1OAuthGroup.POST("/token", r.Token)
+		v1OAuthGroup.GET("/userinfo", r.UserInfo)
+		v1OAuthGroup.POST("/userinfo", r.UserInfo)
+	}
+
+	v1AdminGroup := v1ApiGroup.Group("/admin", AdminMiddleware(r.adminAPIKey))
+	{
+		v1AdminGroup.POST("/oauth-clients", r.CreateOAuthClient)
+		v1AdminGroup.GET("/oauth-clients", r.GetOAuthClients)
+		v1AdminGroup.DELETE("/oauth-clients/:clientID", r.DeleteOAuthClient)
+		v1AdminGroup.POST("/signing-keys/rotate", r.RotateSigningKeys)
+		v1AdminGroup.GET("/signing-keys", r.GetSigningKeys)
+		v1AdminGroup.POST("/users/:userID/ban", r.BanUser)
+		v1AdminGroup.DELETE("/users/:userID/ban", r.UnbanUser)
+		v1AdminGroup.DELETE("/users/:userID/lock", r.UnlockUser)
+		// metrics of RabbitMQ publisher and runtime
+		v1AdminGroup.GET("/metrics", gin.WrapH(expvar.Handler()))
 	}
 }
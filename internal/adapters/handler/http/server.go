@@ -0,0 +1,39 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/logging"
+	"net/http"
+)
+
+type Server struct {
+	server *http.Server
+	log    logging.Logger
+}
+
+func NewServer(port int, handler http.Handler, log logging.Logger) *Server {
+	return &Server{
+		server: &http.Server{
+			Addr:    fmt.Sprintf(":%d", port),
+			Handler: handler,
+		},
+		log: log,
+	}
+}
+
+// Run blocks until server fails or is shut down, shutdown is not an error
+func (s *Server) Run() error {
+	s.log.Infof("listening on %s", s.server.Addr)
+	err := s.server.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Shutdown stops accepting connections and waits for requests in flight until ctx is done
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
+}
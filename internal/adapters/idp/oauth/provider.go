@@ -0,0 +1,214 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
+	"golang.org/x/oauth2"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const requestTimeout = 10 * time.Second
+
+type Config struct {
+	Name         string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	// Issuer enables OpenID Connect discovery and ID token validation
+	Issuer string
+	// AuthURL, TokenURL and UserInfoURL describe plain OAuth2 providers without discovery
+	AuthURL     string
+	TokenURL    string
+	UserInfoURL string
+}
+
+type Provider struct {
+	cfg    Config
+	client *http.Client
+
+	mu           sync.Mutex
+	oauth2Config *oauth2.Config
+	verifier     *oidc.IDTokenVerifier
+	userInfoURL  string
+}
+
+func NewProvider(cfg Config) ports.IdentityProvider {
+	return &Provider{
+		cfg:    cfg,
+		client: &http.Client{Timeout: requestTimeout},
+	}
+}
+
+func (p *Provider) Name() string {
+	return p.cfg.Name
+}
+
+func (p *Provider) AuthCodeURL(state string, codeVerifier string, nonce string) (string, error) {
+	oauth2Config, err := p.configure()
+	if err != nil {
+		return "", err
+	}
+
+	options := []oauth2.AuthCodeOption{oauth2.S256ChallengeOption(codeVerifier)}
+	if p.verifier != nil {
+		options = append(options, oidc.Nonce(nonce))
+	}
+	return oauth2Config.AuthCodeURL(state, options...), nil
+}
+
+func (p *Provider) Exchange(ctx context.Context, code string, codeVerifier string, nonce string) (profile domain.ExternalProfile, err error) {
+	oauth2Config, err := p.configure()
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(oidc.ClientContext(ctx, p.client), requestTimeout)
+	defer cancel()
+
+	token, err := oauth2Config.Exchange(ctx, code, oauth2.VerifierOption(codeVerifier))
+	if err != nil {
+		err = fmt.Errorf("code exchange failed: %v", err)
+		return
+	}
+
+	if p.verifier != nil {
+		return p.profileFromIDToken(ctx, token, nonce)
+	}
+	return p.profileFromUserInfo(ctx, oauth2Config, token)
+}
+
+// configure resolves provider metadata lazily, so unavailable provider does not prevent service start
+func (p *Provider) configure() (*oauth2.Config, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.oauth2Config != nil {
+		return p.oauth2Config, nil
+	}
+
+	endpoint := oauth2.Endpoint{
+		AuthURL:  p.cfg.AuthURL,
+		TokenURL: p.cfg.TokenURL,
+	}
+	p.userInfoURL = p.cfg.UserInfoURL
+	if p.cfg.Issuer != "" {
+		// key set of the provider keeps this context for its whole lifetime
+		provider, err := oidc.NewProvider(oidc.ClientContext(context.Background(), p.client), p.cfg.Issuer)
+		if err != nil {
+			return nil, fmt.Errorf("provider discovery failed: %v", err)
+		}
+		endpoint = provider.Endpoint()
+		p.verifier = provider.Verifier(&oidc.Config{ClientID: p.cfg.ClientID})
+		if p.userInfoURL == "" {
+			p.userInfoURL = provider.UserInfoEndpoint()
+		}
+	}
+
+	p.oauth2Config = &oauth2.Config{
+		ClientID:     p.cfg.ClientID,
+		ClientSecret: p.cfg.ClientSecret,
+		Endpoint:     endpoint,
+		RedirectURL:  p.cfg.RedirectURL,
+		Scopes:       p.cfg.Scopes,
+	}
+	return p.oauth2Config, nil
+}
+
+func (p *Provider) profileFromIDToken(ctx context.Context, token *oauth2.Token, nonce string) (profile domain.ExternalProfile, err error) {
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		err = fmt.Errorf("id token is missing in token response")
+		return
+	}
+	idToken, err := p.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		err = fmt.Errorf("id token verification failed: %v", err)
+		return
+	}
+	if idToken.Nonce != nonce {
+		err = fmt.Errorf("id token nonce mismatch")
+		return
+	}
+
+	var claims map[string]interface{}
+	err = idToken.Claims(&claims)
+	if err != nil {
+		err = fmt.Errorf("id token claims decoding failed: %v", err)
+		return
+	}
+	return p.profile(idToken.Subject, claims), nil
+}
+
+func (p *Provider) profileFromUserInfo(ctx context.Context, oauth2Config *oauth2.Config, token *oauth2.Token) (profile domain.ExternalProfile, err error) {
+	if p.userInfoURL == "" {
+		err = fmt.Errorf("userinfo url is not configured")
+		return
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, p.userInfoURL, nil)
+	if err != nil {
+		return
+	}
+	request.Header.Set("Accept", "application/json")
+
+	response, err := oauth2Config.Client(ctx, token).Do(request)
+	if err != nil {
+		err = fmt.Errorf("userinfo request failed: %v", err)
+		return
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		err = fmt.Errorf("userinfo request failed with status %d", response.StatusCode)
+		return
+	}
+
+	var claims map[string]interface{}
+	decoder := json.NewDecoder(response.Body)
+	decoder.UseNumber()
+	err = decoder.Decode(&claims)
+	if err != nil {
+		err = fmt.Errorf("userinfo decoding failed: %v", err)
+		return
+	}
+
+	subject := stringClaim(claims, "sub", "id")
+	if subject == "" {
+		err = fmt.Errorf("userinfo has no subject")
+		return
+	}
+	return p.profile(subject, claims), nil
+}
+
+func (p *Provider) profile(subject string, claims map[string]interface{}) domain.ExternalProfile {
+	emailVerified, _ := claims["email_verified"].(bool)
+	if value, ok := claims["email_verified"].(string); ok {
+		emailVerified = value == "true"
+	}
+	return domain.ExternalProfile{
+		Provider:      p.cfg.Name,
+		Subject:       subject,
+		Email:         stringClaim(claims, "email"),
+		EmailVerified: emailVerified,
+		Nickname:      stringClaim(claims, "preferred_username", "nickname", "login", "name"),
+	}
+}
+
+func stringClaim(claims map[string]interface{}, names ...string) string {
+	for _, name := range names {
+		switch value := claims[name].(type) {
+		case string:
+			if value != "" {
+				return value
+			}
+		case json.Number:
+			return value.String()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,194 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+const testClientID = "code-typing"
+
+// standInProvider is local OpenID Connect provider which accepts any login
+type standInProvider struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	// issued authorization codes with their PKCE challenges and nonces
+	codes map[string][2]string
+}
+
+func newStandInProvider() *standInProvider {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	p := &standInProvider{
+		key:   key,
+		codes: map[string][2]string{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("/jwks", p.jwks)
+	mux.HandleFunc("/token", p.token)
+	mux.HandleFunc("/userinfo", p.userInfo)
+	p.server = httptest.NewServer(mux)
+	return p
+}
+
+// authorize emulates user consent on authorization page
+func (p *standInProvider) authorize(authURL string) (code string, state string) {
+	query, _ := url.Parse(authURL)
+	raw := make([]byte, 16)
+	_, _ = rand.Read(raw)
+	code = base64.RawURLEncoding.EncodeToString(raw)
+	p.codes[code] = [2]string{query.Query().Get("code_challenge"), query.Query().Get("nonce")}
+	return code, query.Query().Get("state")
+}
+
+func (p *standInProvider) discovery(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, map[string]any{
+		"issuer":                                p.server.URL,
+		"authorization_endpoint":                p.server.URL + "/authorize",
+		"token_endpoint":                        p.server.URL + "/token",
+		"userinfo_endpoint":                     p.server.URL + "/userinfo",
+		"jwks_uri":                              p.server.URL + "/jwks",
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+func (p *standInProvider) jwks(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"alg": "RS256",
+			"use": "sig",
+			"kid": "stand-in",
+			"n":   base64.RawURLEncoding.EncodeToString(p.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(p.key.E)).Bytes()),
+		}},
+	})
+}
+
+func (p *standInProvider) token(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	issued, ok := p.codes[r.PostForm.Get("code")]
+	delete(p.codes, r.PostForm.Get("code"))
+	challenge := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(challenge[:]) != issued[0] {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":                p.server.URL,
+		"aud":                testClientID,
+		"sub":                "42",
+		"email":              "octocat@example.com",
+		"email_verified":     true,
+		"preferred_username": "octocat",
+		"nonce":              issued[1],
+		"iat":                time.Now().Unix(),
+		"exp":                time.Now().Add(time.Minute).Unix(),
+	})
+	idToken.Header["kid"] = "stand-in"
+	signedIDToken, _ := idToken.SignedString(p.key)
+	writeJSON(w, map[string]any{
+		"access_token": "stand-in-access-token",
+		"token_type":   "Bearer",
+		"expires_in":   60,
+		"id_token":     signedIDToken,
+	})
+}
+
+// userInfo answers like GitHub, which has numeric ids and no email verification flag
+func (p *standInProvider) userInfo(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer stand-in-access-token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	writeJSON(w, map[string]any{
+		"id":    42,
+		"login": "octocat",
+		"email": "octocat@example.com",
+	})
+}
+
+func writeJSON(w http.ResponseWriter, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func TestOpenIDConnectProvider(t *testing.T) {
+	standIn := newStandInProvider()
+	defer standIn.server.Close()
+
+	provider := NewProvider(Config{
+		Name:        "stand-in",
+		ClientID:    testClientID,
+		RedirectURL: "http://localhost:8090/api/v1/auth/oauth/stand-in/callback",
+		Scopes:      []string{"openid", "email", "profile"},
+		Issuer:      standIn.server.URL,
+	})
+
+	t.Run("successful login with validated id token", func(t *testing.T) {
+		authURL, err := provider.AuthCodeURL("state", "verifierverifierverifierverifierverifierveri", "nonce")
+		assert.NoError(t, err)
+		code, state := standIn.authorize(authURL)
+		assert.Equal(t, "state", state)
+
+		profile, err := provider.Exchange(context.Background(), code, "verifierverifierverifierverifierverifierveri", "nonce")
+		assert.NoError(t, err)
+		assert.Equal(t, "42", profile.Subject)
+		assert.Equal(t, "octocat", profile.Nickname)
+		assert.True(t, profile.EmailVerified)
+	})
+	t.Run("unsuccessful login due to wrong code verifier", func(t *testing.T) {
+		authURL, _ := provider.AuthCodeURL("state", "verifierverifierverifierverifierverifierveri", "nonce")
+		code, _ := standIn.authorize(authURL)
+
+		_, err := provider.Exchange(context.Background(), code, "anotheranotheranotheranotheranotheranotherano", "nonce")
+		assert.Error(t, err)
+	})
+	t.Run("unsuccessful login due to nonce mismatch", func(t *testing.T) {
+		authURL, _ := provider.AuthCodeURL("state", "verifierverifierverifierverifierverifierveri", "nonce")
+		code, _ := standIn.authorize(authURL)
+
+		_, err := provider.Exchange(context.Background(), code, "verifierverifierverifierverifierverifierveri", "replayed")
+		assert.Error(t, err)
+	})
+}
+
+func TestOAuth2Provider(t *testing.T) {
+	standIn := newStandInProvider()
+	defer standIn.server.Close()
+
+	provider := NewProvider(Config{
+		Name:        "stand-in",
+		ClientID:    testClientID,
+		RedirectURL: "http://localhost:8090/api/v1/auth/oauth/stand-in/callback",
+		AuthURL:     standIn.server.URL + "/authorize",
+		TokenURL:    standIn.server.URL + "/token",
+		UserInfoURL: standIn.server.URL + "/userinfo",
+	})
+
+	t.Run("successful login with userinfo", func(t *testing.T) {
+		authURL, err := provider.AuthCodeURL("state", "verifierverifierverifierverifierverifierveri", "nonce")
+		assert.NoError(t, err)
+		code, _ := standIn.authorize(authURL)
+
+		profile, err := provider.Exchange(context.Background(), code, "verifierverifierverifierverifierverifierveri", "nonce")
+		assert.NoError(t, err)
+		assert.Equal(t, "42", profile.Subject)
+		assert.Equal(t, "octocat", profile.Nickname)
+		assert.False(t, profile.EmailVerified)
+	})
+}
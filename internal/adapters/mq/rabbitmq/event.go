@@ -2,19 +2,19 @@ package rabbitmq
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	amqp "github.com/rabbitmq/amqp091-go"
 	"github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
 	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/broker"
 	"github.com/ttodoshi/code-typing-auth-service/pkg/logging"
+	"slices"
 	"time"
 )
 
-type EventDispatcher struct {
-	channel *amqp.Channel
-	log     logging.Logger
-}
-
-func NewEventDispatcher(channel *amqp.Channel, log logging.Logger) ports.EventDispatcher {
+// DeclareExchanges is topology of events, it is declared again on every reconnection
+func DeclareExchanges(channel *amqp.Channel) error {
 	for _, exchange := range ports.Exchanges {
 		err := channel.ExchangeDeclare(
 			exchange,
@@ -26,34 +26,47 @@ func NewEventDispatcher(channel *amqp.Channel, log logging.Logger) ports.EventDi
 			nil,
 		)
 		if err != nil {
-			log.Fatalf("failed to declare exchange due to: %s", err.Error())
+			return fmt.Errorf("failed to declare exchange '%s': %w", exchange, err)
 		}
 	}
-	return &EventDispatcher{
-		channel: channel,
-		log:     log,
+	return nil
+}
+
+type EventPublisher struct {
+	publisher *broker.Publisher
+	log       logging.Logger
+}
+
+func NewEventPublisher(publisher *broker.Publisher, log logging.Logger) ports.EventPublisher {
+	return &EventPublisher{
+		publisher: publisher,
+		log:       log,
 	}
 }
 
-func (r *EventDispatcher) Dispatch(event domain.Event) {
+func (r *EventPublisher) Publish(ctx context.Context, event domain.Event) error {
 	ctx, cancel := context.WithTimeout(
-		context.Background(), 5*time.Second,
+		ctx, 5*time.Second,
 	)
 	defer cancel()
 
-	err := r.channel.PublishWithContext(
+	err := r.publisher.Publish(
 		ctx,
 		event.Exchange,
 		"",
-		false,
-		false,
 		amqp.Publishing{
-			ContentType: "application/json",
-			Body:        event.Body,
+			ContentType:  "application/json",
+			DeliveryMode: amqp.Persistent,
+			MessageId:    event.ID,
+			Body:         event.Body,
 		},
 	)
+	if errors.Is(err, broker.ErrReturned) && slices.Contains(ports.OptionalExchanges, event.Exchange) {
+		r.log.Warnf("event '%s' dropped, no queue is bound to exchange '%s'", event.ID, event.Exchange)
+		return nil
+	}
 	if err != nil {
-		r.log.Warnf(`event not dispatched due to error: %v`, err)
-		return
+		return fmt.Errorf(`event not published due to error: %w`, err)
 	}
+	return nil
 }
@@ -0,0 +1,54 @@
+package memory
+
+import (
+	"context"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/ratelimit"
+	"sync"
+	"time"
+)
+
+// pruneInterval is how often buckets which are full again are forgotten
+const pruneInterval = time.Minute
+
+type rateLimitBucket struct {
+	ratelimit.Bucket
+	fullAt time.Time
+}
+
+// RateLimitStore keeps buckets in process, limits are not shared between replicas
+type RateLimitStore struct {
+	mu       sync.Mutex
+	buckets  map[string]rateLimitBucket
+	prunedAt time.Time
+}
+
+func NewRateLimitStore() ports.RateLimitStore {
+	return &RateLimitStore{
+		buckets:  make(map[string]rateLimitBucket),
+		prunedAt: time.Now(),
+	}
+}
+
+func (s *RateLimitStore) Take(_ context.Context, key string, policy ratelimit.Policy) (time.Duration, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.prunedAt) >= pruneInterval {
+		for k, bucket := range s.buckets {
+			if !bucket.fullAt.After(now) {
+				delete(s.buckets, k)
+			}
+		}
+		s.prunedAt = now
+	}
+
+	bucket, retryAfter := policy.Take(s.buckets[key].Bucket, now)
+	s.buckets[key] = rateLimitBucket{
+		Bucket: bucket,
+		fullAt: policy.FullAt(bucket),
+	}
+	return retryAfter, nil
+}
@@ -0,0 +1,40 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"github.com/kamva/mgm/v3"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type AuthorizationCodeRepository struct {
+}
+
+func NewAuthorizationCodeRepository() ports.AuthorizationCodeRepository {
+	return &AuthorizationCodeRepository{}
+}
+
+func (r *AuthorizationCodeRepository) CreateAuthorizationCode(ctx context.Context, authorizationCode domain.AuthorizationCode) (ID string, err error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	err = mgm.Coll(&authorizationCode).CreateWithCtx(ctx, &authorizationCode)
+	if err != nil {
+		err = fmt.Errorf(`authorization code not created due to error: %v`, err)
+		return
+	}
+	return authorizationCode.ID.Hex(), nil
+}
+
+func (r *AuthorizationCodeRepository) TakeAuthorizationCode(ctx context.Context, code string) (authorizationCode domain.AuthorizationCode, err error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	err = mgm.Coll(&authorizationCode).FindOneAndDelete(ctx, bson.M{"code": code}).Decode(&authorizationCode)
+	if err != nil {
+		return authorizationCode, fmt.Errorf("authorization code not found")
+	}
+	return authorizationCode, nil
+}
@@ -0,0 +1,13 @@
+package mongodb
+
+import (
+	"context"
+	"time"
+)
+
+// queryTimeout limits every repository operation, request context may end it earlier
+const queryTimeout = 5 * time.Second
+
+func withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, queryTimeout)
+}
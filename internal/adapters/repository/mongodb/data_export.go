@@ -0,0 +1,102 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/kamva/mgm/v3"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+type DataExportRepository struct {
+}
+
+func NewDataExportRepository() ports.DataExportRepository {
+	return &DataExportRepository{}
+}
+
+func (r *DataExportRepository) GetDataExport(ctx context.Context, ID string) (dataExport domain.DataExport, err error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	err = mgm.Coll(&dataExport).FindByIDWithCtx(ctx, ID, &dataExport)
+	if err != nil {
+		return dataExport, fmt.Errorf("data export by ID '%s' not found", ID)
+	}
+	return dataExport, nil
+}
+
+func (r *DataExportRepository) CreateDataExport(ctx context.Context, dataExport domain.DataExport) (ID string, err error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	err = mgm.Coll(&dataExport).CreateWithCtx(ctx, &dataExport)
+	if err != nil {
+		err = fmt.Errorf(`data export not created due to error: %v`, err)
+		return
+	}
+	return dataExport.ID.Hex(), nil
+}
+
+func (r *DataExportRepository) UpdateDataExport(ctx context.Context, dataExport domain.DataExport) (domain.DataExport, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	err := mgm.Coll(&dataExport).UpdateWithCtx(ctx, &dataExport)
+	if err != nil {
+		return dataExport, fmt.Errorf(`data export not updated due to error: %v`, err)
+	}
+	return dataExport, nil
+}
+
+func (r *DataExportRepository) ClaimPendingDataExport(ctx context.Context, claimedUntil time.Time) (dataExport domain.DataExport, claimed bool, err error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	now := time.Now().UTC()
+	err = mgm.Coll(&dataExport).FindOneAndUpdate(
+		ctx,
+		bson.M{
+			"status": domain.DataExportPending,
+			"$or": bson.A{
+				bson.M{"claimed_until": bson.M{"$exists": false}},
+				bson.M{"claimed_until": bson.M{"$lt": now}},
+			},
+		},
+		bson.M{"$set": bson.M{
+			"claimed_until": claimedUntil,
+			"updated_at":    now,
+		}},
+		options.FindOneAndUpdate().
+			SetSort(bson.D{{Key: "created_at", Value: 1}}).
+			SetReturnDocument(options.After),
+	).Decode(&dataExport)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return dataExport, false, nil
+	}
+	if err != nil {
+		return dataExport, false, fmt.Errorf(`data export not claimed due to error: %v`, err)
+	}
+	return dataExport, true, nil
+}
+
+func (r *DataExportRepository) DeleteUserDataExports(ctx context.Context, userID string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	user, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return fmt.Errorf("invalid user ID '%s'", userID)
+	}
+	_, err = mgm.Coll(&domain.DataExport{}).DeleteMany(ctx, bson.M{"user": user})
+	if err != nil {
+		return fmt.Errorf(`data exports not deleted due to error: %v`, err)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"github.com/kamva/mgm/v3"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type EmailChangeTokenRepository struct {
+}
+
+func NewEmailChangeTokenRepository() ports.EmailChangeTokenRepository {
+	return &EmailChangeTokenRepository{}
+}
+
+func (r *EmailChangeTokenRepository) TakeEmailChangeToken(ctx context.Context, token string) (changeToken domain.EmailChangeToken, err error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	err = mgm.Coll(&changeToken).FindOneAndDelete(ctx, bson.M{"token": token}).Decode(&changeToken)
+	if err != nil {
+		return changeToken, fmt.Errorf("email change token not found")
+	}
+	return changeToken, nil
+}
+
+func (r *EmailChangeTokenRepository) CreateEmailChangeToken(ctx context.Context, changeToken domain.EmailChangeToken) (ID string, err error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	err = mgm.Coll(&changeToken).CreateWithCtx(ctx, &changeToken)
+	if err != nil {
+		err = fmt.Errorf(`email change token not created due to error: %v`, err)
+		return
+	}
+	return changeToken.ID.Hex(), nil
+}
+
+func (r *EmailChangeTokenRepository) DeleteUserEmailChangeTokens(ctx context.Context, userID string) (err error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	user, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return fmt.Errorf("invalid user ID '%s'", userID)
+	}
+	_, err = mgm.Coll(&domain.EmailChangeToken{}).DeleteMany(ctx, bson.M{"user": user})
+	if err != nil {
+		return fmt.Errorf(`email change tokens not deleted due to error: %v`, err)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"github.com/kamva/mgm/v3"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type EmailVerificationTokenRepository struct {
+}
+
+func NewEmailVerificationTokenRepository() ports.EmailVerificationTokenRepository {
+	return &EmailVerificationTokenRepository{}
+}
+
+func (r *EmailVerificationTokenRepository) GetEmailVerificationToken(ctx context.Context, token string) (verificationToken domain.EmailVerificationToken, err error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	err = mgm.Coll(&verificationToken).FirstWithCtx(ctx, bson.M{"token": token}, &verificationToken)
+	if err != nil {
+		return verificationToken, fmt.Errorf("email verification token not found")
+	}
+	return verificationToken, nil
+}
+
+func (r *EmailVerificationTokenRepository) CreateEmailVerificationToken(ctx context.Context, verificationToken domain.EmailVerificationToken) (ID string, err error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	err = mgm.Coll(&verificationToken).CreateWithCtx(ctx, &verificationToken)
+	if err != nil {
+		err = fmt.Errorf(`email verification token not created due to error: %v`, err)
+		return
+	}
+	return verificationToken.ID.Hex(), nil
+}
+
+func (r *EmailVerificationTokenRepository) DeleteUserEmailVerificationTokens(ctx context.Context, userID string) (err error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	user, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return fmt.Errorf("invalid user ID '%s'", userID)
+	}
+	_, err = mgm.Coll(&domain.EmailVerificationToken{}).DeleteMany(ctx, bson.M{"user": user})
+	if err != nil {
+		return fmt.Errorf(`email verification tokens not deleted due to error: %v`, err)
+	}
+	return nil
+}
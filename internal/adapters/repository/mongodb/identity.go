@@ -0,0 +1,71 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"github.com/kamva/mgm/v3"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type IdentityRepository struct {
+}
+
+func NewIdentityRepository() ports.IdentityRepository {
+	return &IdentityRepository{}
+}
+
+func (r *IdentityRepository) GetIdentity(ctx context.Context, provider string, subject string) (identity domain.Identity, err error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	err = mgm.Coll(&identity).FirstWithCtx(ctx, bson.M{"provider": provider, "subject": subject}, &identity)
+	if err != nil {
+		return identity, fmt.Errorf("identity '%s' of provider '%s' not found", subject, provider)
+	}
+	return identity, nil
+}
+
+func (r *IdentityRepository) GetUserIdentities(ctx context.Context, userID string) (identities []domain.Identity, err error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	user, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return identities, fmt.Errorf("invalid user ID '%s'", userID)
+	}
+	err = mgm.Coll(&domain.Identity{}).SimpleFindWithCtx(ctx, &identities, bson.M{"user": user})
+	if err != nil {
+		return identities, fmt.Errorf(`identities not found due to error: %v`, err)
+	}
+	return identities, nil
+}
+
+func (r *IdentityRepository) CreateIdentity(ctx context.Context, identity domain.Identity) (ID string, err error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	err = mgm.Coll(&identity).CreateWithCtx(ctx, &identity)
+	if err != nil {
+		err = fmt.Errorf(`identity not created due to error: %v`, err)
+		return
+	}
+	return identity.ID.Hex(), nil
+}
+
+func (r *IdentityRepository) DeleteUserIdentities(ctx context.Context, userID string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	user, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return fmt.Errorf("invalid user ID '%s'", userID)
+	}
+	_, err = mgm.Coll(&domain.Identity{}).DeleteMany(ctx, bson.M{"user": user})
+	if err != nil {
+		return fmt.Errorf(`identities not deleted due to error: %v`, err)
+	}
+	return nil
+}
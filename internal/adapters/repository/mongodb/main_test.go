@@ -0,0 +1,38 @@
+package mongodb
+
+import (
+	"github.com/kamva/mgm/v3"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"os"
+	"testing"
+)
+
+// testDatabaseURL points repository tests to real database, tests are skipped when it is not set
+const testDatabaseURL = "MONGODB_TEST_URL"
+
+func TestMain(m *testing.M) {
+	url := os.Getenv(testDatabaseURL)
+	if url != "" {
+		err := mgm.SetDefaultConfig(nil, "auth_test", options.Client().ApplyURI(url))
+		if err != nil {
+			panic(err)
+		}
+	}
+	os.Exit(m.Run())
+}
+
+// setupDatabase skips test without database and drops data left by previous tests
+func setupDatabase(t *testing.T) {
+	t.Helper()
+	if os.Getenv(testDatabaseURL) == "" {
+		t.Skipf("%s is not set", testDatabaseURL)
+	}
+	_, _, db, err := mgm.DefaultConfigs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Drop(mgm.Ctx())
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,65 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"github.com/kamva/mgm/v3"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type OAuthClientRepository struct {
+}
+
+func NewOAuthClientRepository() ports.OAuthClientRepository {
+	return &OAuthClientRepository{}
+}
+
+func (r *OAuthClientRepository) GetOAuthClient(ctx context.Context, clientID string) (client domain.OAuthClient, err error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	err = mgm.Coll(&client).FirstWithCtx(ctx, bson.M{"client_id": clientID}, &client)
+	if err != nil {
+		return client, fmt.Errorf("oauth client '%s' not found", clientID)
+	}
+	return client, nil
+}
+
+func (r *OAuthClientRepository) GetOAuthClients(ctx context.Context) (clients []domain.OAuthClient, err error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	err = mgm.Coll(&domain.OAuthClient{}).SimpleFindWithCtx(ctx, &clients, bson.M{})
+	if err != nil {
+		return clients, fmt.Errorf(`oauth clients not found due to error: %v`, err)
+	}
+	return clients, nil
+}
+
+func (r *OAuthClientRepository) CreateOAuthClient(ctx context.Context, client domain.OAuthClient) (ID string, err error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	err = mgm.Coll(&client).CreateWithCtx(ctx, &client)
+	if err != nil {
+		err = fmt.Errorf(`oauth client not created due to error: %v`, err)
+		return
+	}
+	return client.ID.Hex(), nil
+}
+
+func (r *OAuthClientRepository) DeleteOAuthClient(ctx context.Context, clientID string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	result, err := mgm.Coll(&domain.OAuthClient{}).DeleteOne(ctx, bson.M{"client_id": clientID})
+	if err != nil {
+		return fmt.Errorf(`oauth client not deleted due to error: %v`, err)
+	}
+	if result.DeletedCount == 0 {
+		return fmt.Errorf("oauth client '%s' not found", clientID)
+	}
+	return nil
+}
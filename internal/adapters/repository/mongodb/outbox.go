@@ -0,0 +1,120 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"github.com/kamva/mgm/v3"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+const outboxLeaseName = "outbox-relay"
+
+type OutboxRepository struct {
+}
+
+func NewOutboxRepository() ports.OutboxRepository {
+	return &OutboxRepository{}
+}
+
+func (r *OutboxRepository) CreateOutboxEvent(ctx context.Context, event domain.OutboxEvent) (ID string, err error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	err = mgm.Coll(&event).CreateWithCtx(ctx, &event)
+	if err != nil {
+		err = fmt.Errorf(`outbox event not created due to error: %v`, err)
+		return
+	}
+	return event.ID.Hex(), nil
+}
+
+func (r *OutboxRepository) GetDueOutboxEvents(ctx context.Context, now time.Time, limit int) (events []domain.OutboxEvent, err error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	// only the oldest pending event of key may be published, events without key are not ordered
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"delivered_at": bson.M{"$exists": false},
+			"failed_at":    bson.M{"$exists": false},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id": bson.M{
+				"exchange": "$exchange",
+				"key": bson.M{"$cond": bson.A{
+					bson.M{"$eq": bson.A{bson.M{"$ifNull": bson.A{"$key", ""}}, ""}},
+					"$_id",
+					"$key",
+				}},
+			},
+			"event": bson.M{"$first": "$$ROOT"},
+		}}},
+		{{Key: "$replaceRoot", Value: bson.M{"newRoot": "$event"}}},
+		{{Key: "$match", Value: bson.M{"$or": bson.A{
+			bson.M{"next_attempt_at": bson.M{"$exists": false}},
+			bson.M{"next_attempt_at": bson.M{"$lte": now}},
+		}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "next_attempt_at", Value: 1}, {Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$limit", Value: limit}},
+	}
+	cursor, err := mgm.Coll(&domain.OutboxEvent{}).Aggregate(ctx, pipeline)
+	if err != nil {
+		return events, fmt.Errorf(`outbox events not found due to error: %v`, err)
+	}
+	err = cursor.All(ctx, &events)
+	if err != nil {
+		return events, fmt.Errorf(`outbox events not decoded due to error: %v`, err)
+	}
+	return events, nil
+}
+
+func (r *OutboxRepository) UpdateOutboxEvent(ctx context.Context, event domain.OutboxEvent) (domain.OutboxEvent, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	err := mgm.Coll(&event).UpdateWithCtx(ctx, &event)
+	if err != nil {
+		return event, fmt.Errorf(`outbox event not updated due to error: %v`, err)
+	}
+	return event, nil
+}
+
+func (r *OutboxRepository) AcquireOutboxLease(ctx context.Context, owner string, until time.Time) (bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	now := time.Now().UTC()
+	// upsert of lease held by another owner violates unique name
+	_, err := mgm.Coll(&domain.OutboxLease{}).UpdateOne(
+		ctx,
+		bson.M{
+			"name": outboxLeaseName,
+			"$or": bson.A{
+				bson.M{"owner": owner},
+				bson.M{"expires_at": bson.M{"$lt": now}},
+			},
+		},
+		bson.M{
+			"$set": bson.M{
+				"owner":      owner,
+				"expires_at": until,
+				"updated_at": now,
+			},
+			"$setOnInsert": bson.M{"created_at": now},
+		},
+		options.Update().SetUpsert(true),
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf(`outbox lease not acquired due to error: %v`, err)
+	}
+	return true, nil
+}
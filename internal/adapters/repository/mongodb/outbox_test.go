@@ -0,0 +1,49 @@
+package mongodb
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+	"testing"
+	"time"
+)
+
+func TestGetDueOutboxEvents(t *testing.T) {
+	setupDatabase(t)
+	outboxRepo := &OutboxRepository{}
+	now := time.Now()
+
+	create := func(event domain.OutboxEvent) {
+		t.Helper()
+		event.Exchange = "auth-exchange"
+		_, err := outboxRepo.CreateOutboxEvent(context.Background(), event)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	create(domain.OutboxEvent{Key: "user1", Body: []byte("waiting"), Attempts: 1, NextAttemptAt: now.Add(time.Minute)})
+	create(domain.OutboxEvent{Key: "user1", Body: []byte("held")})
+	create(domain.OutboxEvent{Key: "user2", Body: []byte("failed"), Attempts: 3, FailedAt: now})
+	create(domain.OutboxEvent{Key: "user2", Body: []byte("after failed")})
+	create(domain.OutboxEvent{Key: "user3", Body: []byte("delivered"), DeliveredAt: now})
+	create(domain.OutboxEvent{Key: "user3", Body: []byte("after delivered")})
+	create(domain.OutboxEvent{Key: "user4", Body: []byte("retried"), Attempts: 1, NextAttemptAt: now.Add(-time.Second)})
+	create(domain.OutboxEvent{Body: []byte("first without key")})
+	create(domain.OutboxEvent{Body: []byte("second without key")})
+
+	t.Run("successful get of due events of every key", func(t *testing.T) {
+		events, err := outboxRepo.GetDueOutboxEvents(context.Background(), now, 10)
+		assert.NoError(t, err)
+
+		var bodies []string
+		for _, event := range events {
+			bodies = append(bodies, string(event.Body))
+		}
+		assert.Equal(t, []string{"after failed", "after delivered", "first without key", "second without key", "retried"}, bodies)
+	})
+	t.Run("successful get of limited batch", func(t *testing.T) {
+		events, err := outboxRepo.GetDueOutboxEvents(context.Background(), now, 2)
+		assert.NoError(t, err)
+		assert.Len(t, events, 2)
+	})
+}
@@ -0,0 +1,82 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"github.com/kamva/mgm/v3"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type PasskeyCredentialRepository struct {
+}
+
+func NewPasskeyCredentialRepository() ports.PasskeyCredentialRepository {
+	return &PasskeyCredentialRepository{}
+}
+
+func (r *PasskeyCredentialRepository) GetPasskeyCredential(ctx context.Context, credentialID []byte) (credential domain.PasskeyCredential, err error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	err = mgm.Coll(&credential).FirstWithCtx(ctx, bson.M{"credential_id": credentialID}, &credential)
+	if err != nil {
+		return credential, fmt.Errorf("passkey credential not found")
+	}
+	return credential, nil
+}
+
+func (r *PasskeyCredentialRepository) GetUserPasskeyCredentials(ctx context.Context, userID string) (credentials []domain.PasskeyCredential, err error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	user, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return credentials, fmt.Errorf("invalid user ID '%s'", userID)
+	}
+	err = mgm.Coll(&domain.PasskeyCredential{}).SimpleFindWithCtx(ctx, &credentials, bson.M{"user": user})
+	if err != nil {
+		return credentials, fmt.Errorf(`passkey credentials not found due to error: %v`, err)
+	}
+	return credentials, nil
+}
+
+func (r *PasskeyCredentialRepository) CreatePasskeyCredential(ctx context.Context, credential domain.PasskeyCredential) (ID string, err error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	err = mgm.Coll(&credential).CreateWithCtx(ctx, &credential)
+	if err != nil {
+		err = fmt.Errorf(`passkey credential not created due to error: %v`, err)
+		return
+	}
+	return credential.ID.Hex(), nil
+}
+
+func (r *PasskeyCredentialRepository) UpdatePasskeyCredential(ctx context.Context, credential domain.PasskeyCredential) (domain.PasskeyCredential, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	err := mgm.Coll(&credential).UpdateWithCtx(ctx, &credential)
+	if err != nil {
+		return credential, fmt.Errorf(`passkey credential not updated due to error: %v`, err)
+	}
+	return credential, nil
+}
+
+func (r *PasskeyCredentialRepository) DeleteUserPasskeyCredentials(ctx context.Context, userID string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	user, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return fmt.Errorf("invalid user ID '%s'", userID)
+	}
+	_, err = mgm.Coll(&domain.PasskeyCredential{}).DeleteMany(ctx, bson.M{"user": user})
+	if err != nil {
+		return fmt.Errorf(`passkey credentials not deleted due to error: %v`, err)
+	}
+	return nil
+}
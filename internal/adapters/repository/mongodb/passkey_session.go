@@ -0,0 +1,40 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"github.com/kamva/mgm/v3"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type PasskeySessionRepository struct {
+}
+
+func NewPasskeySessionRepository() ports.PasskeySessionRepository {
+	return &PasskeySessionRepository{}
+}
+
+func (r *PasskeySessionRepository) CreatePasskeySession(ctx context.Context, session domain.PasskeySession) (ID string, err error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	err = mgm.Coll(&session).CreateWithCtx(ctx, &session)
+	if err != nil {
+		err = fmt.Errorf(`passkey session not created due to error: %v`, err)
+		return
+	}
+	return session.ID.Hex(), nil
+}
+
+func (r *PasskeySessionRepository) TakePasskeySession(ctx context.Context, challenge string) (session domain.PasskeySession, err error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	err = mgm.Coll(&session).FindOneAndDelete(ctx, bson.M{"challenge": challenge}).Decode(&session)
+	if err != nil {
+		return session, fmt.Errorf("passkey session '%s' not found", challenge)
+	}
+	return session, nil
+}
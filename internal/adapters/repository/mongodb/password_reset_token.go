@@ -0,0 +1,56 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"github.com/kamva/mgm/v3"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type PasswordResetTokenRepository struct {
+}
+
+func NewPasswordResetTokenRepository() ports.PasswordResetTokenRepository {
+	return &PasswordResetTokenRepository{}
+}
+
+func (r *PasswordResetTokenRepository) TakePasswordResetToken(ctx context.Context, token string) (resetToken domain.PasswordResetToken, err error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	err = mgm.Coll(&resetToken).FindOneAndDelete(ctx, bson.M{"token": token}).Decode(&resetToken)
+	if err != nil {
+		return resetToken, fmt.Errorf("password reset token not found")
+	}
+	return resetToken, nil
+}
+
+func (r *PasswordResetTokenRepository) CreatePasswordResetToken(ctx context.Context, resetToken domain.PasswordResetToken) (ID string, err error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	err = mgm.Coll(&resetToken).CreateWithCtx(ctx, &resetToken)
+	if err != nil {
+		err = fmt.Errorf(`password reset token not created due to error: %v`, err)
+		return
+	}
+	return resetToken.ID.Hex(), nil
+}
+
+func (r *PasswordResetTokenRepository) DeleteUserPasswordResetTokens(ctx context.Context, userID string) (err error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	user, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return fmt.Errorf("invalid user ID '%s'", userID)
+	}
+	_, err = mgm.Coll(&domain.PasswordResetToken{}).DeleteMany(ctx, bson.M{"user": user})
+	if err != nil {
+		return fmt.Errorf(`password reset tokens not deleted due to error: %v`, err)
+	}
+	return nil
+}
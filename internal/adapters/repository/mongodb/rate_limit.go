@@ -0,0 +1,97 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/kamva/mgm/v3"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/ratelimit"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"time"
+)
+
+// takeAttempts is how many times bucket changed concurrently by another request is read again
+const takeAttempts = 5
+
+type RateLimitStore struct {
+}
+
+func NewRateLimitStore() ports.RateLimitStore {
+	return &RateLimitStore{}
+}
+
+func (s *RateLimitStore) Take(ctx context.Context, key string, policy ratelimit.Policy) (time.Duration, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	for i := 0; i < takeAttempts; i++ {
+		retryAfter, err := s.take(ctx, key, policy)
+		if err == nil {
+			return retryAfter, nil
+		}
+		if !errors.Is(err, errBucketChanged) {
+			return 0, err
+		}
+	}
+	return 0, fmt.Errorf("rate limit bucket '%s' is changed concurrently", key)
+}
+
+var errBucketChanged = errors.New("bucket changed")
+
+// take updates bucket only if it was not changed since it was read
+func (s *RateLimitStore) take(ctx context.Context, key string, policy ratelimit.Policy) (time.Duration, error) {
+	// mongo stores time with millisecond precision, so compared time must be truncated too
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	coll := mgm.Coll(&domain.RateLimitBucket{})
+
+	var stored domain.RateLimitBucket
+	err := coll.FirstWithCtx(ctx, bson.M{"key": key}, &stored)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		bucket, retryAfter := policy.Take(ratelimit.Bucket{}, now)
+		stored = domain.RateLimitBucket{
+			Key:    key,
+			Tokens: bucket.Tokens,
+		}
+		stored.ID = primitive.NewObjectID()
+		stored.CreatedAt = now
+		stored.UpdatedAt = now
+		_, err = coll.InsertOne(ctx, &stored)
+		if mongo.IsDuplicateKeyError(err) {
+			return 0, errBucketChanged
+		}
+		if err != nil {
+			return 0, fmt.Errorf(`rate limit bucket not created due to error: %v`, err)
+		}
+		return retryAfter, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf(`rate limit bucket not found due to error: %v`, err)
+	}
+
+	bucket, retryAfter := policy.Take(ratelimit.Bucket{
+		Tokens:    stored.Tokens,
+		UpdatedAt: stored.UpdatedAt,
+	}, now)
+	result, err := coll.UpdateOne(
+		ctx,
+		bson.M{
+			"key":        key,
+			"updated_at": stored.UpdatedAt,
+		},
+		bson.M{"$set": bson.M{
+			"tokens":     bucket.Tokens,
+			"updated_at": now,
+		}},
+	)
+	if err != nil {
+		return 0, fmt.Errorf(`rate limit bucket not updated due to error: %v`, err)
+	}
+	if result.MatchedCount == 0 {
+		return 0, errBucketChanged
+	}
+	return retryAfter, nil
+}
@@ -1,11 +1,15 @@
 package mongodb
 
 import (
+	"context"
 	"fmt"
 	"github.com/kamva/mgm/v3"
 	"github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
 	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
 )
 
 type RefreshTokenRepository struct {
@@ -15,16 +19,68 @@ func NewRefreshTokenRepository() ports.RefreshTokenRepository {
 	return &RefreshTokenRepository{}
 }
 
-func (r *RefreshTokenRepository) GetRefreshToken(token string) (refreshToken domain.RefreshToken, err error) {
-	err = mgm.Coll(&refreshToken).First(bson.M{"token": token}, &refreshToken)
+func (r *RefreshTokenRepository) GetRefreshToken(ctx context.Context, token string) (refreshToken domain.RefreshToken, err error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	err = mgm.Coll(&refreshToken).FirstWithCtx(ctx, bson.M{"token": token, "rotated_at": bson.M{"$exists": false}}, &refreshToken)
 	if err != nil {
 		return refreshToken, fmt.Errorf("refresh token '%s' not found", token)
 	}
 	return refreshToken, nil
 }
 
-func (r *RefreshTokenRepository) CreateRefreshToken(refreshToken domain.RefreshToken) (ID string, err error) {
-	err = mgm.Coll(&refreshToken).Create(&refreshToken)
+func (r *RefreshTokenRepository) GetRotatedRefreshToken(ctx context.Context, token string) (refreshToken domain.RefreshToken, err error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	err = mgm.Coll(&refreshToken).FirstWithCtx(ctx, bson.M{"token": token, "rotated_at": bson.M{"$exists": true}}, &refreshToken)
+	if err != nil {
+		return refreshToken, fmt.Errorf("rotated refresh token '%s' not found", token)
+	}
+	return refreshToken, nil
+}
+
+func (r *RefreshTokenRepository) GetUserRefreshTokens(ctx context.Context, userID string) (refreshTokens []domain.RefreshToken, err error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	user, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return refreshTokens, fmt.Errorf("invalid user ID '%s'", userID)
+	}
+	err = mgm.Coll(&domain.RefreshToken{}).SimpleFindWithCtx(ctx, &refreshTokens, bson.M{"user": user, "rotated_at": bson.M{"$exists": false}})
+	if err != nil {
+		return refreshTokens, fmt.Errorf(`tokens not found due to error: %v`, err)
+	}
+	return refreshTokens, nil
+}
+
+func (r *RefreshTokenRepository) GetUserRotatedRefreshTokens(ctx context.Context, userID string) (refreshTokens []domain.RefreshToken, err error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	user, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return refreshTokens, fmt.Errorf("invalid user ID '%s'", userID)
+	}
+	err = mgm.Coll(&domain.RefreshToken{}).SimpleFindWithCtx(
+		ctx,
+		&refreshTokens,
+		bson.M{"user": user, "rotated_at": bson.M{"$exists": true}},
+		options.Find().SetSort(bson.D{{Key: "rotated_at", Value: 1}}),
+	)
+	if err != nil {
+		return refreshTokens, fmt.Errorf(`rotated tokens not found due to error: %v`, err)
+	}
+	return refreshTokens, nil
+}
+
+func (r *RefreshTokenRepository) CreateRefreshToken(ctx context.Context, refreshToken domain.RefreshToken) (ID string, err error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	err = mgm.Coll(&refreshToken).CreateWithCtx(ctx, &refreshToken)
 	if err != nil {
 		err = fmt.Errorf(`token not created due to error: %v`, err)
 		return
@@ -32,25 +88,96 @@ func (r *RefreshTokenRepository) CreateRefreshToken(refreshToken domain.RefreshT
 	return refreshToken.ID.Hex(), nil
 }
 
-func (r *RefreshTokenRepository) UpdateRefreshToken(oldRefreshToken, newRefreshToken string) (refreshToken domain.RefreshToken, err error) {
-	refreshToken, _ = r.GetRefreshToken(oldRefreshToken)
-	refreshToken.Token = newRefreshToken
-	err = mgm.Coll(&refreshToken).Update(&refreshToken)
+func (r *RefreshTokenRepository) RotateRefreshToken(ctx context.Context, oldRefreshToken string, newRefreshToken domain.RefreshToken) (refreshToken domain.RefreshToken, err error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var oldToken domain.RefreshToken
+	err = mgm.Coll(&oldToken).FindOneAndUpdate(
+		ctx,
+		bson.M{"token": oldRefreshToken, "rotated_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"rotated_at": time.Now().UTC()}},
+	).Decode(&oldToken)
 	if err != nil {
-		return refreshToken, fmt.Errorf(`token not updated due to error: %v`, err)
+		return refreshToken, fmt.Errorf("refresh token '%s' not found", oldRefreshToken)
+	}
+	// tokens issued before families appeared start their own one
+	if oldToken.Family == "" {
+		oldToken.Family = oldToken.ID.Hex()
+		_, err = mgm.Coll(&oldToken).UpdateByID(ctx, oldToken.ID, bson.M{"$set": bson.M{"family": oldToken.Family}})
+		if err != nil {
+			return refreshToken, fmt.Errorf(`token not updated due to error: %v`, err)
+		}
+	}
+	if oldToken.StartedAt.IsZero() {
+		oldToken.StartedAt = oldToken.CreatedAt
+	}
+
+	refreshToken = newRefreshToken
+	refreshToken.User = oldToken.User
+	refreshToken.Family = oldToken.Family
+	refreshToken.Generation = oldToken.Generation + 1
+	refreshToken.StartedAt = oldToken.StartedAt
+	err = mgm.Coll(&refreshToken).CreateWithCtx(ctx, &refreshToken)
+	if err != nil {
+		return refreshToken, fmt.Errorf(`token not created due to error: %v`, err)
 	}
 	return refreshToken, nil
 }
 
-func (r *RefreshTokenRepository) DeleteRefreshToken(token string) (err error) {
+func (r *RefreshTokenRepository) DeleteRefreshToken(ctx context.Context, token string) (err error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
 	var refreshToken domain.RefreshToken
-	err = mgm.Coll(&refreshToken).First(bson.M{"token": token}, &refreshToken)
+	err = mgm.Coll(&refreshToken).FirstWithCtx(ctx, bson.M{"token": token}, &refreshToken)
 	if err != nil {
 		return fmt.Errorf("refresh token '%s' not found", token)
 	}
-	err = mgm.Coll(&refreshToken).Delete(&refreshToken)
+	err = mgm.Coll(&refreshToken).DeleteWithCtx(ctx, &refreshToken)
 	if err != nil {
 		return fmt.Errorf(`token not deleted due to error: %v`, err)
 	}
 	return nil
 }
+
+func (r *RefreshTokenRepository) DeleteRefreshTokenFamily(ctx context.Context, family string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := mgm.Coll(&domain.RefreshToken{}).DeleteMany(ctx, bson.M{"family": family})
+	if err != nil {
+		return fmt.Errorf(`tokens not deleted due to error: %v`, err)
+	}
+	return nil
+}
+
+func (r *RefreshTokenRepository) DeleteOtherUserRefreshTokens(ctx context.Context, userID string, exceptFamily string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	user, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return fmt.Errorf("invalid user ID '%s'", userID)
+	}
+	_, err = mgm.Coll(&domain.RefreshToken{}).DeleteMany(ctx, bson.M{"user": user, "family": bson.M{"$ne": exceptFamily}})
+	if err != nil {
+		return fmt.Errorf(`tokens not deleted due to error: %v`, err)
+	}
+	return nil
+}
+
+func (r *RefreshTokenRepository) DeleteUserRefreshTokens(ctx context.Context, userID string) (err error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	user, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return fmt.Errorf("invalid user ID '%s'", userID)
+	}
+	_, err = mgm.Coll(&domain.RefreshToken{}).DeleteMany(ctx, bson.M{"user": user})
+	if err != nil {
+		return fmt.Errorf(`tokens not deleted due to error: %v`, err)
+	}
+	return nil
+}
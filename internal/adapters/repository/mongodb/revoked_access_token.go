@@ -0,0 +1,41 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"github.com/kamva/mgm/v3"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
+	"go.mongodb.org/mongo-driver/bson"
+	"time"
+)
+
+type RevokedAccessTokenRepository struct {
+}
+
+func NewRevokedAccessTokenRepository() ports.RevokedAccessTokenRepository {
+	return &RevokedAccessTokenRepository{}
+}
+
+func (r *RevokedAccessTokenRepository) GetRevokedAccessTokens(ctx context.Context, since time.Time) (revokedTokens []domain.RevokedAccessToken, err error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	err = mgm.Coll(&domain.RevokedAccessToken{}).SimpleFindWithCtx(ctx, &revokedTokens, bson.M{"created_at": bson.M{"$gt": since}})
+	if err != nil {
+		return revokedTokens, fmt.Errorf(`revoked tokens not found due to error: %v`, err)
+	}
+	return revokedTokens, nil
+}
+
+func (r *RevokedAccessTokenRepository) CreateRevokedAccessToken(ctx context.Context, revokedToken domain.RevokedAccessToken) (ID string, err error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	err = mgm.Coll(&revokedToken).CreateWithCtx(ctx, &revokedToken)
+	if err != nil {
+		err = fmt.Errorf(`revoked token not created due to error: %v`, err)
+		return
+	}
+	return revokedToken.ID.Hex(), nil
+}
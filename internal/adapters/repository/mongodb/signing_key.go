@@ -0,0 +1,111 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"github.com/kamva/mgm/v3"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/jwt"
+	"go.mongodb.org/mongo-driver/bson"
+	"time"
+)
+
+type SigningKeyRepository struct {
+}
+
+func NewSigningKeyRepository() ports.SigningKeyRepository {
+	return &SigningKeyRepository{}
+}
+
+func (r *SigningKeyRepository) GetSigningKeys(ctx context.Context) (keys []domain.SigningKey, err error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	err = mgm.Coll(&domain.SigningKey{}).SimpleFindWithCtx(ctx, &keys, bson.M{})
+	if err != nil {
+		return keys, fmt.Errorf(`signing keys not found due to error: %v`, err)
+	}
+	return keys, nil
+}
+
+func (r *SigningKeyRepository) CreateSigningKey(ctx context.Context, key domain.SigningKey) (ID string, err error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	err = mgm.Coll(&key).CreateWithCtx(ctx, &key)
+	if err != nil {
+		err = fmt.Errorf(`signing key not created due to error: %v`, err)
+		return
+	}
+	return key.ID.Hex(), nil
+}
+
+func (r *SigningKeyRepository) ActivateSigningKey(ctx context.Context, keyID string, activatedAt time.Time) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	result, err := mgm.Coll(&domain.SigningKey{}).UpdateOne(
+		ctx,
+		bson.M{
+			"key_id":       keyID,
+			"state":        string(jwt.KeyVerifyOnly),
+			"activated_at": bson.M{"$exists": false},
+		},
+		bson.M{"$set": bson.M{
+			"state":        string(jwt.KeyActive),
+			"activated_at": activatedAt,
+			"updated_at":   time.Now().UTC(),
+		}},
+	)
+	if err != nil {
+		return fmt.Errorf(`signing key not activated due to error: %v`, err)
+	}
+	if result.ModifiedCount == 0 {
+		return fmt.Errorf("signing key '%s' has already been activated", keyID)
+	}
+	return nil
+}
+
+func (r *SigningKeyRepository) DeactivateSigningKeys(ctx context.Context, exceptKeyID string, retireAt time.Time) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := mgm.Coll(&domain.SigningKey{}).UpdateMany(
+		ctx,
+		bson.M{
+			"key_id": bson.M{"$ne": exceptKeyID},
+			"state":  string(jwt.KeyActive),
+		},
+		bson.M{"$set": bson.M{
+			"state":      string(jwt.KeyVerifyOnly),
+			"retire_at":  retireAt,
+			"updated_at": time.Now().UTC(),
+		}},
+	)
+	if err != nil {
+		return fmt.Errorf(`signing keys not deactivated due to error: %v`, err)
+	}
+	return nil
+}
+
+func (r *SigningKeyRepository) RetireSigningKeys(ctx context.Context, now time.Time) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := mgm.Coll(&domain.SigningKey{}).UpdateMany(
+		ctx,
+		bson.M{
+			"state":     string(jwt.KeyVerifyOnly),
+			"retire_at": bson.M{"$lte": now},
+		},
+		bson.M{
+			"$set":   bson.M{"state": string(jwt.KeyRetired), "updated_at": time.Now().UTC()},
+			"$unset": bson.M{"private_key": ""},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf(`signing keys not retired due to error: %v`, err)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"github.com/kamva/mgm/v3"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/logging"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type Transactor struct {
+	// standalone server has no transactions, calls are made one by one there
+	supported bool
+}
+
+// NewTransactor asks database whether it supports transactions, ctx bounds the request together with query timeout
+func NewTransactor(ctx context.Context, log logging.Logger) ports.Transactor {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, _, db, err := mgm.DefaultConfigs()
+	if err != nil {
+		log.Fatalf("failed to get database due to: %s", err.Error())
+	}
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+	err = db.RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello)
+	if err != nil {
+		log.Fatalf("failed to get database topology due to: %s", err.Error())
+	}
+	supported := hello.SetName != "" || hello.Msg == "isdbgrid"
+	if !supported {
+		log.Warn("database is standalone server, events are not saved in the same transaction as state changes")
+	}
+	return &Transactor{supported: supported}
+}
+
+func (t *Transactor) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if !t.supported {
+		return fn(ctx)
+	}
+	_, client, _, err := mgm.DefaultConfigs()
+	if err != nil {
+		return err
+	}
+	session, err := client.StartSession()
+	if err != nil {
+		return fmt.Errorf(`session not started due to error: %v`, err)
+	}
+	defer session.EndSession(ctx)
+
+	// fn is run again when transaction fails with transient error
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+	return err
+}
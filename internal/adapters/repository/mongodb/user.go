@@ -1,11 +1,17 @@
 package mongodb
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/kamva/mgm/v3"
 	"github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
 	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
 )
 
 type UserRepository struct {
@@ -15,34 +21,378 @@ func NewUserRepository() ports.UserRepository {
 	return &UserRepository{}
 }
 
-func (r *UserRepository) GetUserByID(ID string) (user domain.User, err error) {
-	err = mgm.Coll(&user).FindByID(ID, &user)
+func (r *UserRepository) GetUserByID(ctx context.Context, ID string) (user domain.User, err error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	err = mgm.Coll(&user).FindByIDWithCtx(ctx, ID, &user)
 	if err != nil {
 		return user, fmt.Errorf("user by ID '%s' not found", ID)
 	}
 	return user, nil
 }
 
-func (r *UserRepository) GetUserByNickname(nickname string) (user domain.User, err error) {
-	err = mgm.Coll(&user).First(bson.M{"nickname": nickname}, &user)
+func (r *UserRepository) GetUserByNickname(ctx context.Context, nickname string) (user domain.User, err error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	err = mgm.Coll(&user).FirstWithCtx(ctx, bson.M{"nickname": nickname}, &user)
 	if err != nil {
 		return user, fmt.Errorf("user by nickname '%s' not found", nickname)
 	}
 	return user, nil
 }
 
-func (r *UserRepository) GetUserByEmail(email string) (user domain.User, err error) {
-	err = mgm.Coll(&user).First(bson.M{"email": email}, &user)
+func (r *UserRepository) GetUserByEmail(ctx context.Context, email string) (user domain.User, err error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	err = mgm.Coll(&user).FirstWithCtx(ctx, bson.M{"email": email}, &user)
 	if err != nil {
 		return user, fmt.Errorf("user by email '%s' not found", email)
 	}
 	return user, nil
 }
 
-func (r *UserRepository) SaveUser(user domain.User) (domain.User, error) {
-	err := mgm.Coll(&user).Create(&user)
+func (r *UserRepository) SaveUser(ctx context.Context, user domain.User) (domain.User, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	err := mgm.Coll(&user).CreateWithCtx(ctx, &user)
 	if err != nil {
 		return user, fmt.Errorf(`user not created due to error: %v`, err)
 	}
 	return user, nil
 }
+
+func (r *UserRepository) ChangeNickname(ctx context.Context, ID string, nickname string, changedAt time.Time) (domain.User, error) {
+	return setUserFields(ctx, ID, bson.M{"nickname": nickname, "nickname_changed_at": changedAt})
+}
+
+func (r *UserRepository) ChangeEmail(ctx context.Context, ID string, email string) (domain.User, error) {
+	return setUserFields(ctx, ID, bson.M{"email": email, "email_verified": true})
+}
+
+func (r *UserRepository) SetEmailVerified(ctx context.Context, ID string) error {
+	_, err := setUserFields(ctx, ID, bson.M{"email_verified": true})
+	return err
+}
+
+func (r *UserRepository) SetPassword(ctx context.Context, ID string, password string) error {
+	_, err := setUserFields(ctx, ID, bson.M{"password": password})
+	return err
+}
+
+func (r *UserRepository) SetBanned(ctx context.Context, ID string, banned bool) error {
+	_, err := setUserFields(ctx, ID, bson.M{"banned": banned})
+	return err
+}
+
+func (r *UserRepository) MarkUserDeleted(ctx context.Context, ID string, deletedAt time.Time) error {
+	_, err := setUserFields(ctx, ID, bson.M{"deleted_at": deletedAt})
+	return err
+}
+
+func (r *UserRepository) SetTOTPSecret(ctx context.Context, ID string, encryptedSecret string) error {
+	_, err := setUserFields(ctx, ID, bson.M{"totp_secret": encryptedSecret})
+	return err
+}
+
+func (r *UserRepository) EnableTOTP(ctx context.Context, ID string, hashedRecoveryCodes []string) error {
+	_, err := setUserFields(ctx, ID, bson.M{"totp_enabled": true, "recovery_codes": hashedRecoveryCodes})
+	return err
+}
+
+func (r *UserRepository) SetRecoveryCodes(ctx context.Context, ID string, hashedRecoveryCodes []string) error {
+	_, err := setUserFields(ctx, ID, bson.M{"recovery_codes": hashedRecoveryCodes})
+	return err
+}
+
+// setUserFields sets only given fields of user, so fields updated atomically by concurrent requests are not overwritten
+func setUserFields(ctx context.Context, ID string, fields bson.M) (user domain.User, err error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	userID, err := primitive.ObjectIDFromHex(ID)
+	if err != nil {
+		return user, fmt.Errorf("invalid user ID '%s'", ID)
+	}
+	fields["updated_at"] = time.Now().UTC()
+	err = mgm.Coll(&user).FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": userID},
+		bson.M{"$set": fields},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return user, fmt.Errorf("user by ID '%s' not found", ID)
+	}
+	if err != nil {
+		return user, fmt.Errorf(`user '%s' not updated due to error: %v`, ID, err)
+	}
+	return user, nil
+}
+
+func (r *UserRepository) GetUsersDeletedBefore(ctx context.Context, deletedBefore time.Time) (users []domain.User, err error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	err = mgm.Coll(&domain.User{}).SimpleFindWithCtx(ctx, &users, bson.M{"deleted_at": bson.M{"$lte": deletedBefore}})
+	if err != nil {
+		return users, fmt.Errorf(`deleted users not found due to error: %v`, err)
+	}
+	return users, nil
+}
+
+func (r *UserRepository) RestoreUser(ctx context.Context, ID string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	userID, err := primitive.ObjectIDFromHex(ID)
+	if err != nil {
+		return fmt.Errorf("invalid user ID '%s'", ID)
+	}
+	// zero deleted_at is omitted by UpdateUser, so field is removed explicitly
+	result, err := mgm.Coll(&domain.User{}).UpdateOne(
+		ctx,
+		bson.M{"_id": userID},
+		bson.M{
+			"$set":   bson.M{"updated_at": time.Now().UTC()},
+			"$unset": bson.M{"deleted_at": ""},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf(`user not restored due to error: %v`, err)
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("user by ID '%s' not found", ID)
+	}
+	return nil
+}
+
+func (r *UserRepository) DeleteUser(ctx context.Context, ID string, deletedBefore time.Time) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	userID, err := primitive.ObjectIDFromHex(ID)
+	if err != nil {
+		return fmt.Errorf("invalid user ID '%s'", ID)
+	}
+	result, err := mgm.Coll(&domain.User{}).DeleteOne(ctx, bson.M{"_id": userID, "deleted_at": bson.M{"$lte": deletedBefore}})
+	if err != nil {
+		return fmt.Errorf(`user not deleted due to error: %v`, err)
+	}
+	if result.DeletedCount == 0 {
+		return fmt.Errorf("user by ID '%s' not found or restored", ID)
+	}
+	return nil
+}
+
+func (r *UserRepository) RecordFailedLogin(ctx context.Context, ID string, failedAt time.Time, forgetBefore time.Time) (user domain.User, err error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	userID, err := primitive.ObjectIDFromHex(ID)
+	if err != nil {
+		return user, fmt.Errorf("invalid user ID '%s'", ID)
+	}
+	// counter is restarted when previous failure is forgotten
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"failed_logins": bson.M{"$cond": bson.A{
+				bson.M{"$lt": bson.A{"$last_failed_login_at", forgetBefore}},
+				1,
+				bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$failed_logins", 0}}, 1}},
+			}},
+			"last_failed_login_at": failedAt,
+			"updated_at":           time.Now().UTC(),
+		}}},
+	}
+	err = mgm.Coll(&user).FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": userID},
+		update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&user)
+	if err != nil {
+		return user, fmt.Errorf(`failed login of user '%s' not recorded due to error: %v`, ID, err)
+	}
+	return user, nil
+}
+
+func (r *UserRepository) LockUser(ctx context.Context, ID string, lockedAt time.Time, lockedUntil time.Time) (user domain.User, locked bool, err error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	userID, err := primitive.ObjectIDFromHex(ID)
+	if err != nil {
+		return user, false, fmt.Errorf("invalid user ID '%s'", ID)
+	}
+	// lock is matched by filter, so only one of concurrent failed logins locks account
+	err = mgm.Coll(&user).FindOneAndUpdate(
+		ctx,
+		bson.M{
+			"_id": userID,
+			"$or": bson.A{
+				bson.M{"locked_until": bson.M{"$exists": false}},
+				bson.M{"locked_until": bson.M{"$lte": lockedAt}},
+			},
+		},
+		bson.M{
+			"$set": bson.M{
+				"locked_until": lockedUntil,
+				"updated_at":   time.Now().UTC(),
+			},
+			"$unset": bson.M{"failed_logins": ""},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return user, false, nil
+	}
+	if err != nil {
+		return user, false, fmt.Errorf(`user '%s' not locked due to error: %v`, ID, err)
+	}
+	return user, true, nil
+}
+
+func (r *UserRepository) ResetFailedLogins(ctx context.Context, ID string) (user domain.User, err error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	userID, err := primitive.ObjectIDFromHex(ID)
+	if err != nil {
+		return user, fmt.Errorf("invalid user ID '%s'", ID)
+	}
+	// fields are removed rather than zeroed, as they are omitted when empty
+	err = mgm.Coll(&user).FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": userID},
+		bson.M{
+			"$set": bson.M{"updated_at": time.Now().UTC()},
+			"$unset": bson.M{
+				"failed_logins":        "",
+				"last_failed_login_at": "",
+				"locked_until":         "",
+			},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&user)
+	if err != nil {
+		return user, fmt.Errorf(`failed logins of user '%s' not reset due to error: %v`, ID, err)
+	}
+	return user, nil
+}
+
+func (r *UserRepository) ConsumeRecoveryCode(ctx context.Context, ID string, hashedCode string) (bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	userID, err := primitive.ObjectIDFromHex(ID)
+	if err != nil {
+		return false, fmt.Errorf("invalid user ID '%s'", ID)
+	}
+	// code is matched by filter, so only one of concurrent requests with the same code modifies user
+	result, err := mgm.Coll(&domain.User{}).UpdateOne(
+		ctx,
+		bson.M{"_id": userID, "recovery_codes": hashedCode},
+		bson.M{
+			"$pull": bson.M{"recovery_codes": hashedCode},
+			"$set":  bson.M{"updated_at": time.Now().UTC()},
+		},
+	)
+	if err != nil {
+		return false, fmt.Errorf(`recovery code of user '%s' not consumed due to error: %v`, ID, err)
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+func (r *UserRepository) ConsumeTOTPStep(ctx context.Context, ID string, step int64) (bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	userID, err := primitive.ObjectIDFromHex(ID)
+	if err != nil {
+		return false, fmt.Errorf("invalid user ID '%s'", ID)
+	}
+	// step is matched by filter, so only one of concurrent requests with codes of the step modifies user
+	result, err := mgm.Coll(&domain.User{}).UpdateOne(
+		ctx,
+		bson.M{"_id": userID, "totp_last_step": bson.M{"$not": bson.M{"$gte": step}}},
+		bson.M{"$set": bson.M{"totp_last_step": step, "updated_at": time.Now().UTC()}},
+	)
+	if err != nil {
+		return false, fmt.Errorf(`totp step of user '%s' not consumed due to error: %v`, ID, err)
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+func (r *UserRepository) SetMFATokenID(ctx context.Context, ID string, mfaTokenID string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	userID, err := primitive.ObjectIDFromHex(ID)
+	if err != nil {
+		return fmt.Errorf("invalid user ID '%s'", ID)
+	}
+	result, err := mgm.Coll(&domain.User{}).UpdateOne(
+		ctx,
+		bson.M{"_id": userID},
+		bson.M{"$set": bson.M{"mfa_token_id": mfaTokenID, "updated_at": time.Now().UTC()}},
+	)
+	if err != nil {
+		return fmt.Errorf(`mfa token of user '%s' not set due to error: %v`, ID, err)
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("user by ID '%s' not found", ID)
+	}
+	return nil
+}
+
+func (r *UserRepository) ConsumeMFATokenID(ctx context.Context, ID string, mfaTokenID string) (bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	userID, err := primitive.ObjectIDFromHex(ID)
+	if err != nil {
+		return false, fmt.Errorf("invalid user ID '%s'", ID)
+	}
+	// token is matched by filter, so only one of concurrent requests with the same token modifies user
+	result, err := mgm.Coll(&domain.User{}).UpdateOne(
+		ctx,
+		bson.M{"_id": userID, "mfa_token_id": mfaTokenID},
+		bson.M{
+			"$unset": bson.M{"mfa_token_id": ""},
+			"$set":   bson.M{"updated_at": time.Now().UTC()},
+		},
+	)
+	if err != nil {
+		return false, fmt.Errorf(`mfa token of user '%s' not consumed due to error: %v`, ID, err)
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+func (r *UserRepository) DisableTOTP(ctx context.Context, ID string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	userID, err := primitive.ObjectIDFromHex(ID)
+	if err != nil {
+		return fmt.Errorf("invalid user ID '%s'", ID)
+	}
+	result, err := mgm.Coll(&domain.User{}).UpdateOne(
+		ctx,
+		bson.M{"_id": userID},
+		bson.M{
+			"$set":   bson.M{"totp_enabled": false, "updated_at": time.Now().UTC()},
+			"$unset": bson.M{"totp_secret": "", "recovery_codes": ""},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf(`two-factor authentication of user '%s' not disabled due to error: %v`, ID, err)
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("user by ID '%s' not found", ID)
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package mongodb
+
+import (
+	"context"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+	"testing"
+	"time"
+)
+
+func saveTestUser(t *testing.T, userRepo *UserRepository, user domain.User) domain.User {
+	t.Helper()
+	user.Nickname = gofakeit.Username()
+	user.Email = gofakeit.Email()
+	user, err := userRepo.SaveUser(context.Background(), user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func TestRestoreUser(t *testing.T) {
+	setupDatabase(t)
+	userRepo := &UserRepository{}
+	user := saveTestUser(t, userRepo, domain.User{
+		DeletedAt: time.Now(),
+	})
+
+	err := userRepo.RestoreUser(context.Background(), user.ID.Hex())
+	assert.NoError(t, err)
+
+	stored, err := userRepo.GetUserByID(context.Background(), user.ID.Hex())
+	assert.NoError(t, err)
+	assert.True(t, stored.DeletedAt.IsZero())
+	deletedUsers, err := userRepo.GetUsersDeletedBefore(context.Background(), time.Now())
+	assert.NoError(t, err)
+	assert.Empty(t, deletedUsers)
+}
+
+func TestSetBanned(t *testing.T) {
+	setupDatabase(t)
+	userRepo := &UserRepository{}
+	user := saveTestUser(t, userRepo, domain.User{})
+
+	// failed login is recorded after user was loaded, ban must not overwrite it
+	_, err := userRepo.RecordFailedLogin(context.Background(), user.ID.Hex(), time.Now(), time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	err = userRepo.SetBanned(context.Background(), user.ID.Hex(), true)
+	assert.NoError(t, err)
+
+	stored, err := userRepo.GetUserByID(context.Background(), user.ID.Hex())
+	assert.NoError(t, err)
+	assert.True(t, stored.Banned)
+	assert.Equal(t, 1, stored.FailedLogins)
+}
+
+func TestConsumeRecoveryCode(t *testing.T) {
+	setupDatabase(t)
+	userRepo := &UserRepository{}
+	user := saveTestUser(t, userRepo, domain.User{
+		TOTPEnabled:   true,
+		RecoveryCodes: []string{"first", "second"},
+	})
+
+	t.Run("successful consumption", func(t *testing.T) {
+		consumed, err := userRepo.ConsumeRecoveryCode(context.Background(), user.ID.Hex(), "first")
+		assert.NoError(t, err)
+		assert.True(t, consumed)
+
+		stored, err := userRepo.GetUserByID(context.Background(), user.ID.Hex())
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"second"}, stored.RecoveryCodes)
+	})
+	t.Run("unsuccessful consumption due to already consumed code", func(t *testing.T) {
+		consumed, err := userRepo.ConsumeRecoveryCode(context.Background(), user.ID.Hex(), "first")
+		assert.NoError(t, err)
+		assert.False(t, consumed)
+	})
+}
+
+func TestConsumeTOTPStep(t *testing.T) {
+	setupDatabase(t)
+	userRepo := &UserRepository{}
+	user := saveTestUser(t, userRepo, domain.User{
+		TOTPEnabled: true,
+	})
+
+	t.Run("successful consumption", func(t *testing.T) {
+		consumed, err := userRepo.ConsumeTOTPStep(context.Background(), user.ID.Hex(), 100)
+		assert.NoError(t, err)
+		assert.True(t, consumed)
+	})
+	t.Run("unsuccessful consumption due to already consumed step", func(t *testing.T) {
+		consumed, err := userRepo.ConsumeTOTPStep(context.Background(), user.ID.Hex(), 100)
+		assert.NoError(t, err)
+		assert.False(t, consumed)
+	})
+	t.Run("unsuccessful consumption due to earlier step", func(t *testing.T) {
+		consumed, err := userRepo.ConsumeTOTPStep(context.Background(), user.ID.Hex(), 99)
+		assert.NoError(t, err)
+		assert.False(t, consumed)
+	})
+}
+
+func TestConsumeMFATokenID(t *testing.T) {
+	setupDatabase(t)
+	userRepo := &UserRepository{}
+	user := saveTestUser(t, userRepo, domain.User{
+		TOTPEnabled: true,
+	})
+	err := userRepo.SetMFATokenID(context.Background(), user.ID.Hex(), "first")
+	assert.NoError(t, err)
+	err = userRepo.SetMFATokenID(context.Background(), user.ID.Hex(), "second")
+	assert.NoError(t, err)
+
+	t.Run("unsuccessful consumption due to replaced token", func(t *testing.T) {
+		consumed, err := userRepo.ConsumeMFATokenID(context.Background(), user.ID.Hex(), "first")
+		assert.NoError(t, err)
+		assert.False(t, consumed)
+	})
+	t.Run("successful consumption", func(t *testing.T) {
+		consumed, err := userRepo.ConsumeMFATokenID(context.Background(), user.ID.Hex(), "second")
+		assert.NoError(t, err)
+		assert.True(t, consumed)
+	})
+	t.Run("unsuccessful consumption due to already consumed token", func(t *testing.T) {
+		consumed, err := userRepo.ConsumeMFATokenID(context.Background(), user.ID.Hex(), "second")
+		assert.NoError(t, err)
+		assert.False(t, consumed)
+	})
+}
+
+func TestDisableTOTP(t *testing.T) {
+	setupDatabase(t)
+	userRepo := &UserRepository{}
+	user := saveTestUser(t, userRepo, domain.User{
+		TOTPEnabled:   true,
+		TOTPSecret:    "secret",
+		RecoveryCodes: []string{"first"},
+	})
+
+	err := userRepo.DisableTOTP(context.Background(), user.ID.Hex())
+	assert.NoError(t, err)
+
+	stored, err := userRepo.GetUserByID(context.Background(), user.ID.Hex())
+	assert.NoError(t, err)
+	assert.False(t, stored.TOTPEnabled)
+	assert.Empty(t, stored.TOTPSecret)
+	assert.Empty(t, stored.RecoveryCodes)
+}
+
+func TestLockUser(t *testing.T) {
+	setupDatabase(t)
+	userRepo := &UserRepository{}
+	user := saveTestUser(t, userRepo, domain.User{})
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		_, err := userRepo.RecordFailedLogin(context.Background(), user.ID.Hex(), now, now.Add(-time.Hour))
+		assert.NoError(t, err)
+	}
+
+	t.Run("successful lock", func(t *testing.T) {
+		locked, ok, err := userRepo.LockUser(context.Background(), user.ID.Hex(), now, now.Add(time.Hour))
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Zero(t, locked.FailedLogins)
+		assert.WithinDuration(t, now.Add(time.Hour), locked.LockedUntil, time.Second)
+	})
+	t.Run("unsuccessful lock due to account locked concurrently", func(t *testing.T) {
+		_, ok, err := userRepo.LockUser(context.Background(), user.ID.Hex(), now, now.Add(2*time.Hour))
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+}
+
+func TestResetFailedLogins(t *testing.T) {
+	setupDatabase(t)
+	userRepo := &UserRepository{}
+	now := time.Now()
+	user := saveTestUser(t, userRepo, domain.User{
+		FailedLogins:      5,
+		LastFailedLoginAt: now,
+		LockedUntil:       now.Add(time.Hour),
+	})
+
+	_, err := userRepo.ResetFailedLogins(context.Background(), user.ID.Hex())
+	assert.NoError(t, err)
+
+	stored, err := userRepo.GetUserByID(context.Background(), user.ID.Hex())
+	assert.NoError(t, err)
+	assert.Zero(t, stored.FailedLogins)
+	assert.True(t, stored.LastFailedLoginAt.IsZero())
+	assert.True(t, stored.LockedUntil.IsZero())
+
+	// login failed after reset is counted from one
+	stored, err = userRepo.RecordFailedLogin(context.Background(), user.ID.Hex(), now, now.Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, stored.FailedLogins)
+}
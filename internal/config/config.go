@@ -0,0 +1,257 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/ratelimit"
+	"golang.org/x/crypto/bcrypt"
+	"slices"
+	"time"
+)
+
+const (
+	Dev  = "dev"
+	Prod = "prod"
+)
+
+// Config is configuration of the service, every value is read from variable named in env tag,
+// nested sections group values in YAML file only
+type Config struct {
+	Profile        string   `env:"PROFILE" yaml:"profile" default:"dev"`
+	Port           int      `env:"PORT" yaml:"port" default:"8090"`
+	LogLevel       string   `env:"LOG_LEVEL" yaml:"log_level" default:"debug"`
+	CookieHost     string   `env:"COOKIE_HOST" yaml:"cookie_host" default:"localhost"`
+	TrustedProxies []string `env:"TRUSTED_PROXIES" yaml:"trusted_proxies"`
+	AllowedOrigins []string `env:"CORS_ALLOWED_ORIGINS" yaml:"cors_allowed_origins"`
+	// time to drain requests and close connections after SIGTERM
+	ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT" yaml:"shutdown_timeout" default:"30"`
+	EncryptionKey   string        `env:"ENCRYPTION_KEY" yaml:"encryption_key" secret:"true"`
+	// empty key closes admin API
+	AdminAPIKey string `env:"ADMIN_API_KEY" yaml:"admin_api_key" secret:"true"`
+
+	Database  DatabaseConfig  `yaml:"database"`
+	Consul    ConsulConfig    `yaml:"consul"`
+	RabbitMQ  RabbitMQConfig  `yaml:"rabbitmq"`
+	Outbox    OutboxConfig    `yaml:"outbox"`
+	Tokens    TokensConfig    `yaml:"tokens"`
+	JWT       JWTConfig       `yaml:"jwt"`
+	Account   AccountConfig   `yaml:"account"`
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+	Lockout   LockoutConfig   `yaml:"lockout"`
+	Password  PasswordConfig  `yaml:"password"`
+	WebAuthn  WebAuthnConfig  `yaml:"webauthn"`
+	OAuth     OAuthConfig     `yaml:"oauth"`
+	OIDC      OIDCConfig      `yaml:"oidc"`
+}
+
+type DatabaseConfig struct {
+	URL string `env:"DB_URL" yaml:"url" secret:"true"`
+}
+
+type ConsulConfig struct {
+	Host           string   `env:"CONSUL_HOST" yaml:"host" default:"localhost:8500"`
+	ServiceName    string   `env:"CONSUL_SERVICE_NAME" yaml:"service_name" default:"auth-service"`
+	ServiceAddress string   `env:"CONSUL_SERVICE_ADDRESS" yaml:"service_address" default:"localhost"`
+	Tags           []string `env:"CONSUL_TAGS" yaml:"tags"`
+}
+
+type RabbitMQConfig struct {
+	URL string `env:"RABBITMQ_URL" yaml:"url" secret:"true"`
+}
+
+// OutboxConfig defines relay of events saved to outbox, failed publication is retried with doubling delay
+type OutboxConfig struct {
+	RelayInterval time.Duration `env:"OUTBOX_RELAY_INTERVAL" yaml:"relay_interval" default:"1"`
+	BatchSize     int           `env:"OUTBOX_BATCH_SIZE" yaml:"batch_size" default:"100"`
+	RetryDelay    time.Duration `env:"OUTBOX_RETRY_DELAY" yaml:"retry_delay" default:"1"`
+	MaxRetryDelay time.Duration `env:"OUTBOX_MAX_RETRY_DELAY" yaml:"max_retry_delay" default:"300"`
+	// event is marked failed and not published again after MaxAttempts failed publications
+	MaxAttempts int `env:"OUTBOX_MAX_ATTEMPTS" yaml:"max_attempts" default:"50"`
+	// delivered and failed events are kept for investigation
+	Retention       time.Duration `env:"OUTBOX_RETENTION" yaml:"retention" default:"86400"`
+	FailedRetention time.Duration `env:"OUTBOX_FAILED_RETENTION" yaml:"failed_retention" default:"604800"`
+}
+
+// TokensConfig defines lifetimes of tokens and records, durations are written in seconds or as "15m"
+type TokensConfig struct {
+	AccessTokenExp            time.Duration `env:"ACCESS_TOKEN_EXP" yaml:"access_token_exp" default:"300"`
+	RefreshTokenExp           time.Duration `env:"REFRESH_TOKEN_EXP" yaml:"refresh_token_exp" default:"1209600"`
+	EmailVerificationTokenExp time.Duration `env:"EMAIL_VERIFICATION_TOKEN_EXP" yaml:"email_verification_token_exp" default:"86400"`
+	PasswordResetTokenExp     time.Duration `env:"PASSWORD_RESET_TOKEN_EXP" yaml:"password_reset_token_exp" default:"3600"`
+	MFATokenExp               time.Duration `env:"MFA_TOKEN_EXP" yaml:"mfa_token_exp" default:"300"`
+	OAuthStateTokenExp        time.Duration `env:"OAUTH_STATE_TOKEN_EXP" yaml:"oauth_state_token_exp" default:"600"`
+	IDTokenExp                time.Duration `env:"ID_TOKEN_EXP" yaml:"id_token_exp" default:"300"`
+	DataExportExp             time.Duration `env:"DATA_EXPORT_EXP" yaml:"data_export_exp" default:"86400"`
+	DataExportTokenExp        time.Duration `env:"DATA_EXPORT_TOKEN_EXP" yaml:"data_export_token_exp" default:"3600"`
+	AuthorizationCodeExp      time.Duration `env:"AUTHORIZATION_CODE_EXP" yaml:"authorization_code_exp" default:"60"`
+	PasskeySessionExp         time.Duration `env:"PASSKEY_SESSION_EXP" yaml:"passkey_session_exp" default:"300"`
+}
+
+type JWTConfig struct {
+	// HS256 key, tokens without kid are verified with it while LegacyKeyEnabled
+	SecretKey            string        `env:"SECRET_KEY" yaml:"secret_key" secret:"true"`
+	LegacyKeyEnabled     bool          `env:"JWT_LEGACY_KEY_ENABLED" yaml:"legacy_key_enabled" default:"true"`
+	PrivateKeyFile       string        `env:"JWT_PRIVATE_KEY_FILE" yaml:"private_key_file"`
+	KeyID                string        `env:"JWT_KEY_ID" yaml:"key_id"`
+	VerificationKeyFiles []string      `env:"JWT_VERIFICATION_KEY_FILES" yaml:"verification_key_files"`
+	KeyAlgorithm         string        `env:"JWT_KEY_ALGORITHM" yaml:"key_algorithm" default:"EdDSA"`
+	KeyRotationInterval  time.Duration `env:"JWT_KEY_ROTATION_INTERVAL" yaml:"key_rotation_interval" default:"2592000"`
+	KeysReloadInterval   time.Duration `env:"JWT_KEYS_RELOAD_INTERVAL" yaml:"keys_reload_interval" default:"60"`
+	Issuer               string        `env:"JWT_ISSUER" yaml:"issuer"`
+	Audience             string        `env:"JWT_AUDIENCE" yaml:"audience"`
+	RevocationSync       time.Duration `env:"REVOCATION_SYNC_INTERVAL" yaml:"revocation_sync_interval" default:"5"`
+}
+
+type AccountConfig struct {
+	NicknameChangeCooldown time.Duration `env:"NICKNAME_CHANGE_COOLDOWN" yaml:"nickname_change_cooldown" default:"2592000"`
+	DeletionGracePeriod    time.Duration `env:"ACCOUNT_DELETION_GRACE_PERIOD" yaml:"deletion_grace_period" default:"2592000"`
+	PurgeInterval          time.Duration `env:"ACCOUNT_PURGE_INTERVAL" yaml:"purge_interval" default:"3600"`
+	DataExportInterval     time.Duration `env:"DATA_EXPORT_INTERVAL" yaml:"data_export_interval" default:"5"`
+}
+
+type RateLimitConfig struct {
+	Store                string           `env:"RATE_LIMIT_STORE" yaml:"store" default:"memory"`
+	BucketExp            time.Duration    `env:"RATE_LIMIT_BUCKET_EXP" yaml:"bucket_exp" default:"3600"`
+	LoginPerIP           ratelimit.Policy `env:"RATE_LIMIT_LOGIN_PER_IP" yaml:"login_per_ip" default:"20/60"`
+	LoginPerAccount      ratelimit.Policy `env:"RATE_LIMIT_LOGIN_PER_ACCOUNT" yaml:"login_per_account" default:"5/300"`
+	RegistrationPerIP    ratelimit.Policy `env:"RATE_LIMIT_REGISTRATION_PER_IP" yaml:"registration_per_ip" default:"5/3600"`
+	RegistrationPerEmail ratelimit.Policy `env:"RATE_LIMIT_REGISTRATION_PER_EMAIL" yaml:"registration_per_email" default:"3/3600"`
+}
+
+type LockoutConfig struct {
+	BaseDelay     time.Duration `env:"LOGIN_BACKOFF_BASE_DELAY" yaml:"backoff_base_delay" default:"1"`
+	MaxDelay      time.Duration `env:"LOGIN_BACKOFF_MAX_DELAY" yaml:"backoff_max_delay" default:"60"`
+	Threshold     int           `env:"LOGIN_LOCKOUT_THRESHOLD" yaml:"lockout_threshold" default:"10"`
+	Duration      time.Duration `env:"LOGIN_LOCKOUT_DURATION" yaml:"lockout_duration" default:"900"`
+	FailureWindow time.Duration `env:"LOGIN_FAILURE_WINDOW" yaml:"failure_window" default:"3600"`
+}
+
+type PasswordConfig struct {
+	HashAlgorithm     string `env:"PASSWORD_HASH_ALGORITHM" yaml:"hash_algorithm" default:"argon2id"`
+	BcryptCost        int    `env:"PASSWORD_BCRYPT_COST" yaml:"bcrypt_cost" default:"10"`
+	Argon2Memory      uint32 `env:"PASSWORD_ARGON2_MEMORY" yaml:"argon2_memory" default:"65536"`
+	Argon2Iterations  uint32 `env:"PASSWORD_ARGON2_ITERATIONS" yaml:"argon2_iterations" default:"3"`
+	Argon2Parallelism uint8  `env:"PASSWORD_ARGON2_PARALLELISM" yaml:"argon2_parallelism" default:"2"`
+	// empty disables breached password screening
+	BreachedPasswordsFile string `env:"BREACHED_PASSWORDS_FILE" yaml:"breached_passwords_file"`
+}
+
+type WebAuthnConfig struct {
+	RPID          string   `env:"WEBAUTHN_RP_ID" yaml:"rp_id" default:"localhost"`
+	RPDisplayName string   `env:"WEBAUTHN_RP_DISPLAY_NAME" yaml:"rp_display_name" default:"Code Typing"`
+	RPOrigins     []string `env:"WEBAUTHN_RP_ORIGINS" yaml:"rp_origins"`
+}
+
+type OAuthConfig struct {
+	Providers          []string `env:"OAUTH_PROVIDERS" yaml:"providers"`
+	SuccessRedirectURL string   `env:"OAUTH_SUCCESS_REDIRECT_URL" yaml:"success_redirect_url"`
+	// ProviderConfigs are read for every listed provider from OAUTH_<NAME>_* variables or section named after provider
+	ProviderConfigs map[string]OAuthProviderConfig `yaml:"-"`
+}
+
+type OAuthProviderConfig struct {
+	ClientID     string   `env:"CLIENT_ID" yaml:"client_id"`
+	ClientSecret string   `env:"CLIENT_SECRET" yaml:"client_secret" secret:"true"`
+	RedirectURL  string   `env:"REDIRECT_URL" yaml:"redirect_url"`
+	Scopes       []string `env:"SCOPES" yaml:"scopes"`
+	Issuer       string   `env:"ISSUER" yaml:"issuer"`
+	AuthURL      string   `env:"AUTH_URL" yaml:"auth_url"`
+	TokenURL     string   `env:"TOKEN_URL" yaml:"token_url"`
+	UserInfoURL  string   `env:"USERINFO_URL" yaml:"userinfo_url"`
+}
+
+type OIDCConfig struct {
+	// also base of data export download links
+	Issuer   string `env:"OIDC_ISSUER" yaml:"issuer" default:"http://localhost:8090"`
+	LoginURL string `env:"OIDC_LOGIN_URL" yaml:"login_url"`
+}
+
+// Validate reports every invalid value, not only the first one
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(slices.Contains([]string{Dev, Prod}, c.Profile), "PROFILE must be one of %s, %s", Dev, Prod)
+	check(slices.Contains([]string{"trace", "debug", "info", "warn", "error"}, c.LogLevel), "LOG_LEVEL must be one of trace, debug, info, warn, error")
+	check(c.Port > 0 && c.Port < 1<<16, "PORT must be between 1 and 65535")
+	check(c.ShutdownTimeout > 0, "SHUTDOWN_TIMEOUT must be positive")
+	check(c.EncryptionKey != "", "ENCRYPTION_KEY is required")
+	check(c.Database.URL != "", "DB_URL is required")
+	check(c.RabbitMQ.URL != "", "RABBITMQ_URL is required")
+	check(c.Consul.ServiceName != "", "CONSUL_SERVICE_NAME is required")
+
+	// lifetimes are also used as expiration of mongo indexes, which are whole seconds
+	check(c.Tokens.AccessTokenExp >= time.Second, "ACCESS_TOKEN_EXP must be at least 1 second")
+	check(c.Tokens.RefreshTokenExp >= time.Second, "REFRESH_TOKEN_EXP must be at least 1 second")
+	check(c.Tokens.EmailVerificationTokenExp >= time.Second, "EMAIL_VERIFICATION_TOKEN_EXP must be at least 1 second")
+	check(c.Tokens.PasswordResetTokenExp >= time.Second, "PASSWORD_RESET_TOKEN_EXP must be at least 1 second")
+	check(c.Tokens.MFATokenExp >= time.Second, "MFA_TOKEN_EXP must be at least 1 second")
+	check(c.Tokens.OAuthStateTokenExp >= time.Second, "OAUTH_STATE_TOKEN_EXP must be at least 1 second")
+	check(c.Tokens.IDTokenExp >= time.Second, "ID_TOKEN_EXP must be at least 1 second")
+	check(c.Tokens.DataExportExp >= time.Second, "DATA_EXPORT_EXP must be at least 1 second")
+	check(c.Tokens.DataExportTokenExp >= time.Second, "DATA_EXPORT_TOKEN_EXP must be at least 1 second")
+	check(c.Tokens.AuthorizationCodeExp >= time.Second, "AUTHORIZATION_CODE_EXP must be at least 1 second")
+	check(c.Tokens.PasskeySessionExp >= time.Second, "PASSKEY_SESSION_EXP must be at least 1 second")
+
+	check(c.JWT.SecretKey != "" || !c.JWT.LegacyKeyEnabled, "SECRET_KEY is required while JWT_LEGACY_KEY_ENABLED")
+	check(slices.Contains([]string{"RS256", "EdDSA"}, c.JWT.KeyAlgorithm), "JWT_KEY_ALGORITHM must be one of RS256, EdDSA")
+	check(c.JWT.KeyRotationInterval >= 0, "JWT_KEY_ROTATION_INTERVAL must not be negative")
+	check(c.JWT.KeysReloadInterval > 0, "JWT_KEYS_RELOAD_INTERVAL must be positive")
+	check(c.JWT.RevocationSync > 0, "REVOCATION_SYNC_INTERVAL must be positive")
+
+	check(c.Account.NicknameChangeCooldown >= 0, "NICKNAME_CHANGE_COOLDOWN must not be negative")
+	check(c.Account.DeletionGracePeriod >= 0, "ACCOUNT_DELETION_GRACE_PERIOD must not be negative")
+	check(c.Account.PurgeInterval > 0, "ACCOUNT_PURGE_INTERVAL must be positive")
+	check(c.Account.DataExportInterval > 0, "DATA_EXPORT_INTERVAL must be positive")
+
+	check(slices.Contains([]string{"memory", "mongo"}, c.RateLimit.Store), "RATE_LIMIT_STORE must be one of memory, mongo")
+	check(c.RateLimit.BucketExp >= time.Second, "RATE_LIMIT_BUCKET_EXP must be at least 1 second")
+	// bucket removed before it is full again would reset limit
+	maxPeriod := max(
+		c.RateLimit.LoginPerIP.Period, c.RateLimit.LoginPerAccount.Period,
+		c.RateLimit.RegistrationPerIP.Period, c.RateLimit.RegistrationPerEmail.Period,
+	)
+	check(maxPeriod <= c.RateLimit.BucketExp, "RATE_LIMIT_BUCKET_EXP must not be shorter than period of any rate limit")
+
+	check(c.Outbox.RelayInterval > 0, "OUTBOX_RELAY_INTERVAL must be positive")
+	check(c.Outbox.BatchSize > 0, "OUTBOX_BATCH_SIZE must be positive")
+	check(c.Outbox.RetryDelay > 0, "OUTBOX_RETRY_DELAY must be positive")
+	check(c.Outbox.MaxRetryDelay >= c.Outbox.RetryDelay, "OUTBOX_MAX_RETRY_DELAY must not be shorter than OUTBOX_RETRY_DELAY")
+	check(c.Outbox.MaxAttempts > 0, "OUTBOX_MAX_ATTEMPTS must be positive")
+	check(c.Outbox.Retention >= time.Second, "OUTBOX_RETENTION must be at least 1 second")
+	check(c.Outbox.FailedRetention >= time.Second, "OUTBOX_FAILED_RETENTION must be at least 1 second")
+
+	check(c.Lockout.BaseDelay >= 0, "LOGIN_BACKOFF_BASE_DELAY must not be negative")
+	check(c.Lockout.MaxDelay >= 0, "LOGIN_BACKOFF_MAX_DELAY must not be negative")
+	check(c.Lockout.Threshold >= 0, "LOGIN_LOCKOUT_THRESHOLD must not be negative")
+	check(c.Lockout.Duration >= 0, "LOGIN_LOCKOUT_DURATION must not be negative")
+	check(c.Lockout.FailureWindow >= 0, "LOGIN_FAILURE_WINDOW must not be negative")
+
+	switch c.Password.HashAlgorithm {
+	case "bcrypt":
+		check(c.Password.BcryptCost >= bcrypt.MinCost && c.Password.BcryptCost <= bcrypt.MaxCost, "PASSWORD_BCRYPT_COST must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	case "argon2id":
+		check(c.Password.Argon2Memory > 0, "PASSWORD_ARGON2_MEMORY must be positive")
+		check(c.Password.Argon2Iterations > 0, "PASSWORD_ARGON2_ITERATIONS must be positive")
+		check(c.Password.Argon2Parallelism > 0, "PASSWORD_ARGON2_PARALLELISM must be positive")
+	default:
+		check(false, "PASSWORD_HASH_ALGORITHM must be one of argon2id, bcrypt")
+	}
+
+	check(c.WebAuthn.RPID != "", "WEBAUTHN_RP_ID is required")
+	check(len(c.WebAuthn.RPOrigins) > 0, "WEBAUTHN_RP_ORIGINS is required")
+
+	for _, name := range c.OAuth.Providers {
+		provider := c.OAuth.ProviderConfigs[name]
+		prefix := oauthProviderPrefix(name)
+		check(provider.RedirectURL != "", "%sREDIRECT_URL is required", prefix)
+		check(provider.Issuer != "" || provider.AuthURL != "" && provider.TokenURL != "", "%sISSUER or %sAUTH_URL and %sTOKEN_URL are required", prefix, prefix, prefix)
+	}
+
+	check(c.OIDC.Issuer != "", "OIDC_ISSUER is required")
+	return errors.Join(errs...)
+}
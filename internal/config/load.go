@@ -0,0 +1,206 @@
+package config
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FileEnv names variable with path to optional YAML file
+const FileEnv = "CONFIG_FILE"
+
+const redacted = "******"
+
+// Load reads configuration from defaults, YAML file, .env file and environment, each overriding the previous one.
+// Values which could not be parsed are reported together with ones failing validation
+func Load() (cfg Config, err error) {
+	// variables already set in environment are not overridden by .env
+	_ = godotenv.Load()
+
+	var file map[string]interface{}
+	if path := os.Getenv(FileEnv); path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return cfg, fmt.Errorf("failed to read %s '%s': %w", FileEnv, path, err)
+		}
+		err = yaml.Unmarshal(content, &file)
+		if err != nil {
+			return cfg, fmt.Errorf("failed to parse %s '%s': %w", FileEnv, path, err)
+		}
+	}
+
+	var errs []error
+	errs = append(errs, load(reflect.ValueOf(&cfg).Elem(), "", file)...)
+
+	oauthSection, _ := file["oauth"].(map[string]interface{})
+	cfg.OAuth.ProviderConfigs = make(map[string]OAuthProviderConfig, len(cfg.OAuth.Providers))
+	for _, name := range cfg.OAuth.Providers {
+		var provider OAuthProviderConfig
+		providerSection, _ := oauthSection[name].(map[string]interface{})
+		errs = append(errs, load(reflect.ValueOf(&provider).Elem(), oauthProviderPrefix(name), providerSection)...)
+		cfg.OAuth.ProviderConfigs[name] = provider
+	}
+
+	errs = append(errs, cfg.Validate())
+	return cfg, errors.Join(errs...)
+}
+
+func oauthProviderPrefix(name string) string {
+	return "OAUTH_" + strings.ToUpper(name) + "_"
+}
+
+// load fills fields tagged with env, nested structs are sections of YAML file
+func load(v reflect.Value, prefix string, section map[string]interface{}) (errs []error) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, ok := field.Tag.Lookup("env")
+		if !ok {
+			if field.Type.Kind() == reflect.Struct {
+				nested, _ := section[field.Tag.Get("yaml")].(map[string]interface{})
+				errs = append(errs, load(v.Field(i), prefix, nested)...)
+			}
+			continue
+		}
+		name = prefix + name
+
+		raw, set := field.Tag.Lookup("default")
+		if value, ok := section[field.Tag.Get("yaml")]; ok {
+			raw, set = yamlString(value), true
+		}
+		if value, ok := os.LookupEnv(name); ok {
+			raw, set = value, true
+		}
+		if !set {
+			continue
+		}
+		err := setValue(v.Field(i), raw)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			// default keeps validation from reporting the same variable again
+			if def, ok := field.Tag.Lookup("default"); ok {
+				_ = setValue(v.Field(i), def)
+			}
+		}
+	}
+	return
+}
+
+// yamlString brings YAML value to the form of variable, so both are parsed the same way
+func yamlString(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case []interface{}:
+		items := make([]string, 0, len(value))
+		for _, item := range value {
+			items = append(items, fmt.Sprint(item))
+		}
+		return strings.Join(items, ",")
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func setValue(v reflect.Value, raw string) error {
+	if unmarshaler, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return unmarshaler.UnmarshalText([]byte(raw))
+	}
+	if v.Type() == durationType {
+		d, err := parseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid integer '%s'", raw)
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid unsigned integer '%s'", raw)
+		}
+		v.SetUint(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean '%s'", raw)
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		// comma separated list, empty items are skipped
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// parseDuration reads plain number as seconds, so existing values stay valid, or Go duration such as "15m"
+func parseDuration(raw string) (time.Duration, error) {
+	if seconds, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration '%s', must be seconds or value like 15m", raw)
+	}
+	return d, nil
+}
+
+// String lists every value by its variable name with secrets redacted, so configuration may be logged
+func (c Config) String() string {
+	var b strings.Builder
+	write(&b, reflect.ValueOf(c), "")
+	for _, name := range c.OAuth.Providers {
+		write(&b, reflect.ValueOf(c.OAuth.ProviderConfigs[name]), oauthProviderPrefix(name))
+	}
+	return b.String()
+}
+
+func write(b *strings.Builder, v reflect.Value, prefix string) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, ok := field.Tag.Lookup("env")
+		if !ok {
+			if field.Type.Kind() == reflect.Struct {
+				write(b, v.Field(i), prefix)
+			}
+			continue
+		}
+		value := fmt.Sprint(v.Field(i).Interface())
+		if slice, ok := v.Field(i).Interface().([]string); ok {
+			value = strings.Join(slice, ",")
+		}
+		if field.Tag.Get("secret") == "true" && value != "" {
+			// empty secret is shown, so missing one is noticed
+			value = redacted
+		}
+		fmt.Fprintf(b, "%s%s=%s\n", prefix, name, value)
+	}
+}
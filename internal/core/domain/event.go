@@ -1,6 +1,10 @@
 package domain
 
 type Event struct {
+	// ID is set by outbox and sent as message ID, so consumers can skip event published twice
+	ID       string
 	Exchange string
-	Body     []byte
+	// Key orders events, events of the same key are published in order they were dispatched
+	Key  string
+	Body []byte
 }
@@ -3,17 +3,181 @@ package domain
 import (
 	"github.com/kamva/mgm/v3"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
 )
 
 type User struct {
 	mgm.DefaultModel `bson:",inline"`
-	Nickname         string `bson:"nickname"`
-	Email            string `bson:"email"`
-	Password         string `bson:"password"`
+	Nickname         string   `bson:"nickname"`
+	Email            string   `bson:"email"`
+	EmailVerified    bool     `bson:"email_verified"`
+	Password         string   `bson:"password"`
+	TOTPEnabled      bool     `bson:"totp_enabled"`
+	TOTPSecret       string   `bson:"totp_secret,omitempty"`
+	RecoveryCodes    []string `bson:"recovery_codes,omitempty"`
+	Banned           bool     `bson:"banned"`
+	// TOTPLastStep is time step of the last accepted code, codes of it and earlier steps are not accepted again
+	TOTPLastStep int64 `bson:"totp_last_step,omitempty"`
+	// MFATokenID is jti of the mfa token issued by the last password login, only it may finish login once
+	MFATokenID string `bson:"mfa_token_id,omitempty"`
+	// NicknameChangedAt is time of the last nickname change, zero for nicknames chosen at registration
+	NicknameChangedAt time.Time `bson:"nickname_changed_at,omitempty"`
+	// DeletedAt is set when user asks to delete account, account is purged after grace period unless user logs in
+	DeletedAt time.Time `bson:"deleted_at,omitempty"`
+	// FailedLogins counts wrong passwords since the last successful login, LockedUntil is set when it reaches lockout threshold
+	FailedLogins      int       `bson:"failed_logins,omitempty"`
+	LastFailedLoginAt time.Time `bson:"last_failed_login_at,omitempty"`
+	LockedUntil       time.Time `bson:"locked_until,omitempty"`
 }
 
 type RefreshToken struct {
 	mgm.DefaultModel `bson:",inline"`
 	User             primitive.ObjectID `bson:"user"`
 	Token            string             `bson:"token"`
+	// Family is shared by all tokens rotated from the same login, Generation counts rotations
+	Family     string `bson:"family"`
+	Generation int    `bson:"generation"`
+	// RotatedAt is set when token is exchanged, rotated tokens are kept to detect their reuse
+	RotatedAt time.Time `bson:"rotated_at,omitempty"`
+	// device of the session, family is its ID
+	UserAgent  string    `bson:"user_agent,omitempty"`
+	IP         string    `bson:"ip,omitempty"`
+	StartedAt  time.Time `bson:"started_at,omitempty"`
+	LastUsedAt time.Time `bson:"last_used_at,omitempty"`
+}
+
+type EmailVerificationToken struct {
+	mgm.DefaultModel `bson:",inline"`
+	User             primitive.ObjectID `bson:"user"`
+	Email            string             `bson:"email"`
+	Token            string             `bson:"token"`
+}
+
+// EmailChangeToken confirms new email of user, it is kept apart from verification tokens of registered email,
+// so neither flow invalidates tokens of the other
+type EmailChangeToken struct {
+	mgm.DefaultModel `bson:",inline"`
+	User             primitive.ObjectID `bson:"user"`
+	Email            string             `bson:"email"`
+	Token            string             `bson:"token"`
+}
+
+type PasswordResetToken struct {
+	mgm.DefaultModel `bson:",inline"`
+	User             primitive.ObjectID `bson:"user"`
+	Token            string             `bson:"token"`
+}
+
+type PasskeyCredential struct {
+	mgm.DefaultModel `bson:",inline"`
+	User             primitive.ObjectID `bson:"user"`
+	CredentialID     []byte             `bson:"credential_id"`
+	PublicKey        []byte             `bson:"public_key"`
+	AttestationType  string             `bson:"attestation_type"`
+	Transports       []string           `bson:"transports,omitempty"`
+	Flags            byte               `bson:"flags"`
+	AAGUID           []byte             `bson:"aaguid"`
+	SignCount        uint32             `bson:"sign_count"`
+}
+
+type PasskeySession struct {
+	mgm.DefaultModel `bson:",inline"`
+	Challenge        string `bson:"challenge"`
+	Data             []byte `bson:"data"`
+}
+
+type Identity struct {
+	mgm.DefaultModel `bson:",inline"`
+	User             primitive.ObjectID `bson:"user"`
+	Provider         string             `bson:"provider"`
+	Subject          string             `bson:"subject"`
+	Email            string             `bson:"email,omitempty"`
+}
+
+type OAuthClient struct {
+	mgm.DefaultModel `bson:",inline"`
+	ClientID         string   `bson:"client_id"`
+	SecretHash       string   `bson:"secret_hash,omitempty"`
+	Name             string   `bson:"name"`
+	RedirectURIs     []string `bson:"redirect_uris"`
+	Scopes           []string `bson:"scopes"`
+}
+
+type AuthorizationCode struct {
+	mgm.DefaultModel `bson:",inline"`
+	Code             string             `bson:"code"`
+	Client           string             `bson:"client"`
+	User             primitive.ObjectID `bson:"user"`
+	RedirectURI      string             `bson:"redirect_uri"`
+	Scope            string             `bson:"scope"`
+	CodeChallenge    string             `bson:"code_challenge"`
+	Nonce            string             `bson:"nonce,omitempty"`
+}
+
+type SigningKey struct {
+	mgm.DefaultModel `bson:",inline"`
+	KeyID            string `bson:"key_id"`
+	Algorithm        string `bson:"algorithm"`
+	State            string `bson:"state"`
+	// DER encoded public key
+	PublicKey []byte `bson:"public_key"`
+	// PKCS#8 private key encrypted with ENCRYPTION_KEY, erased when key is retired
+	PrivateKey  string    `bson:"private_key,omitempty"`
+	ActivatedAt time.Time `bson:"activated_at,omitempty"`
+	RetireAt    time.Time `bson:"retire_at,omitempty"`
+}
+
+// RevokedAccessToken denies access token with given jti, or all access tokens of user issued before its creation when jti is empty
+type RevokedAccessToken struct {
+	mgm.DefaultModel `bson:",inline"`
+	JTI              string             `bson:"jti,omitempty"`
+	User             primitive.ObjectID `bson:"user"`
+}
+
+const (
+	DataExportPending = "pending"
+	DataExportReady   = "ready"
+	DataExportFailed  = "failed"
+)
+
+// DataExport is archive of personal data of user, it is generated in background and removed after DATA_EXPORT_EXP
+type DataExport struct {
+	mgm.DefaultModel `bson:",inline"`
+	User             primitive.ObjectID `bson:"user"`
+	Status           string             `bson:"status"`
+	// ClaimedUntil is set by worker generating pending export, export is claimed again once worker stopped before it passed
+	ClaimedUntil time.Time `bson:"claimed_until,omitempty"`
+	// Data is JSON archive, set once export is ready
+	Data []byte `bson:"data,omitempty"`
+}
+
+// RateLimitBucket is token bucket shared by all instances, UpdatedAt is time of its last refill
+type RateLimitBucket struct {
+	mgm.DefaultModel `bson:",inline"`
+	Key              string  `bson:"key"`
+	Tokens           float64 `bson:"tokens"`
+}
+
+// OutboxEvent is event saved together with state change it describes, relay publishes it until it is delivered
+type OutboxEvent struct {
+	mgm.DefaultModel `bson:",inline"`
+	Exchange         string `bson:"exchange"`
+	Key              string `bson:"key,omitempty"`
+	Body             []byte `bson:"body"`
+	// Attempts counts failed publications, next one is not made before NextAttemptAt
+	Attempts      int       `bson:"attempts,omitempty"`
+	NextAttemptAt time.Time `bson:"next_attempt_at,omitempty"`
+	LastError     string    `bson:"last_error,omitempty"`
+	// DeliveredAt is set once event is published, delivered events are removed after OUTBOX_RETENTION
+	DeliveredAt time.Time `bson:"delivered_at,omitempty"`
+	// FailedAt is set once OUTBOX_MAX_ATTEMPTS publications failed, failed events are removed after OUTBOX_FAILED_RETENTION
+	FailedAt time.Time `bson:"failed_at,omitempty"`
+}
+
+// OutboxLease lets only one instance relay outbox events, so events of the same key are not published concurrently
+type OutboxLease struct {
+	mgm.DefaultModel `bson:",inline"`
+	Name             string    `bson:"name"`
+	Owner            string    `bson:"owner"`
+	ExpiresAt        time.Time `bson:"expires_at"`
 }
@@ -0,0 +1,10 @@
+package domain
+
+// ExternalProfile is user info confirmed by external identity provider
+type ExternalProfile struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Nickname      string
+}
@@ -19,6 +19,23 @@ type LogoutRequestDto struct {
 	RefreshToken string
 }
 
+type VerifyEmailRequestDto struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ResendVerificationEmailRequestDto struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ForgotPasswordRequestDto struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequestDto struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=8"`
+}
+
 type AuthResponseDto struct {
 	Access  string `json:"access,omitempty"`
 	Refresh string `json:"refresh,omitempty"`
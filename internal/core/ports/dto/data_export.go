@@ -0,0 +1,70 @@
+package dto
+
+import "time"
+
+type DataExportResponseDto struct {
+	ID          string    `json:"id"`
+	Status      string    `json:"status"`
+	CreatedAt   time.Time `json:"created_at"`
+	DownloadURL string    `json:"download_url,omitempty"`
+}
+
+// DataExportArchiveDto is everything the service stores about user, secrets such as password hash are left out.
+// Failed logins are stored only as counter of the profile, so there is no history of them to export
+type DataExportArchiveDto struct {
+	ExportedAt time.Time          `json:"exported_at"`
+	Profile    ProfileExportDto   `json:"profile"`
+	Sessions   []SessionExportDto `json:"sessions"`
+	// LoginHistory lists refreshes of sessions kept for detection of token reuse, they expire with REFRESH_TOKEN_EXP
+	LoginHistory []LoginExportDto    `json:"login_history"`
+	Identities   []IdentityExportDto `json:"identities"`
+	Passkeys     []PasskeyExportDto  `json:"passkeys"`
+}
+
+type ProfileExportDto struct {
+	ID                string     `json:"id"`
+	Nickname          string     `json:"nickname"`
+	Email             string     `json:"email"`
+	EmailVerified     bool       `json:"email_verified"`
+	HasPassword       bool       `json:"has_password"`
+	TOTPEnabled       bool       `json:"totp_enabled"`
+	Banned            bool       `json:"banned"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+	NicknameChangedAt *time.Time `json:"nickname_changed_at,omitempty"`
+	DeletedAt         *time.Time `json:"deleted_at,omitempty"`
+	FailedLogins      int        `json:"failed_logins"`
+	LastFailedLoginAt *time.Time `json:"last_failed_login_at,omitempty"`
+	LockedUntil       *time.Time `json:"locked_until,omitempty"`
+}
+
+type SessionExportDto struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	StartedAt  time.Time `json:"started_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+}
+
+// LoginExportDto is refresh token of session issued to device at IssuedAt and exchanged for the next one at RotatedAt
+type LoginExportDto struct {
+	Session   string    `json:"session"`
+	UserAgent string    `json:"user_agent"`
+	IP        string    `json:"ip"`
+	IssuedAt  time.Time `json:"issued_at"`
+	RotatedAt time.Time `json:"rotated_at"`
+}
+
+type IdentityExportDto struct {
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"subject"`
+	Email     string    `json:"email,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type PasskeyExportDto struct {
+	CredentialID string    `json:"credential_id"`
+	Transports   []string  `json:"transports,omitempty"`
+	SignCount    uint32    `json:"sign_count"`
+	CreatedAt    time.Time `json:"created_at"`
+}
@@ -0,0 +1,23 @@
+package dto
+
+type MFALoginRequestDto struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+type MFAChallengeResponseDto struct {
+	MFAToken string `json:"mfa_token"`
+}
+
+type TOTPEnrollResponseDto struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+type TOTPCodeRequestDto struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type RecoveryCodesResponseDto struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
@@ -0,0 +1,9 @@
+package dto
+
+type OAuthCallbackDto struct {
+	Provider         string `uri:"provider" binding:"required"`
+	Code             string `form:"code"`
+	State            string `form:"state"`
+	Error            string `form:"error"`
+	ErrorDescription string `form:"error_description"`
+}
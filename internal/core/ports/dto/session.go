@@ -0,0 +1,18 @@
+package dto
+
+import "time"
+
+// DeviceDto describes client which starts or continues session
+type DeviceDto struct {
+	UserAgent string
+	IP        string
+}
+
+type SessionResponseDto struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	Current    bool      `json:"current"`
+}
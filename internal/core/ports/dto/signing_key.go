@@ -0,0 +1,12 @@
+package dto
+
+import "time"
+
+type SigningKeyResponseDto struct {
+	KeyID       string     `json:"kid"`
+	Algorithm   string     `json:"alg"`
+	State       string     `json:"state"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ActivatedAt *time.Time `json:"activated_at,omitempty"`
+	RetireAt    *time.Time `json:"retire_at,omitempty"`
+}
@@ -0,0 +1,37 @@
+package dto
+
+import "time"
+
+type UserResponseDto struct {
+	ID            string    `json:"id"`
+	Nickname      string    `json:"nickname"`
+	Email         string    `json:"email"`
+	EmailVerified bool      `json:"email_verified"`
+	TOTPEnabled   bool      `json:"totp_enabled"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type ChangeNicknameRequestDto struct {
+	Nickname string `json:"nickname" binding:"required"`
+}
+
+type ChangePasswordRequestDto struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=8"`
+}
+
+type ChangeEmailRequestDto struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ConfirmEmailChangeRequestDto struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type DeleteAccountRequestDto struct {
+	Password string `json:"password"`
+}
+
+type AccountDeletionResponseDto struct {
+	PurgeAt time.Time `json:"purge_at"`
+}
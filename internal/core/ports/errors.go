@@ -1,11 +1,59 @@
 package ports
 
-import "errors"
+import (
+	"errors"
+	"time"
+)
 
 var (
-	BadRequestError     = errors.New("bad request")
-	UnauthorizedError   = errors.New("unauthorized")
-	ForbiddenError      = errors.New("forbidden")
-	NotFoundError       = errors.New("not found")
-	InternalServerError = errors.New("internal server error")
+	BadRequestError      = errors.New("bad request")
+	UnauthorizedError    = errors.New("unauthorized")
+	ForbiddenError       = errors.New("forbidden")
+	NotFoundError        = errors.New("not found")
+	TooManyRequestsError = errors.New("too many requests")
+	InternalServerError  = errors.New("internal server error")
 )
+
+// OAuthError is rendered in RFC 6749 format, wrapped error defines response status
+type OAuthError struct {
+	Code        string
+	Description string
+	Err         error
+}
+
+func NewOAuthError(code string, description string, err error) *OAuthError {
+	return &OAuthError{
+		Code:        code,
+		Description: description,
+		Err:         err,
+	}
+}
+
+func (e *OAuthError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+func (e *OAuthError) Unwrap() error {
+	return e.Err
+}
+
+// RateLimitError tells client when request can be retried
+type RateLimitError struct {
+	RetryAfter time.Duration
+	Err        error
+}
+
+func NewRateLimitError(retryAfter time.Duration, err error) *RateLimitError {
+	return &RateLimitError{
+		RetryAfter: retryAfter,
+		Err:        err,
+	}
+}
+
+func (e *RateLimitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,85 @@
+// Code generated by mockery v2.39.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	domain "github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// AuthorizationCodeRepository is an autogenerated mock type for the AuthorizationCodeRepository type
+type AuthorizationCodeRepository struct {
+	mock.Mock
+}
+
+// CreateAuthorizationCode provides a mock function with given fields: ctx, authorizationCode
+func (_m *AuthorizationCodeRepository) CreateAuthorizationCode(ctx context.Context, authorizationCode domain.AuthorizationCode) (string, error) {
+	ret := _m.Called(ctx, authorizationCode)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateAuthorizationCode")
+	}
+
+	var r0 string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.AuthorizationCode) (string, error)); ok {
+		return rf(ctx, authorizationCode)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, domain.AuthorizationCode) string); ok {
+		r0 = rf(ctx, authorizationCode)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, domain.AuthorizationCode) error); ok {
+		r1 = rf(ctx, authorizationCode)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// TakeAuthorizationCode provides a mock function with given fields: ctx, code
+func (_m *AuthorizationCodeRepository) TakeAuthorizationCode(ctx context.Context, code string) (domain.AuthorizationCode, error) {
+	ret := _m.Called(ctx, code)
+
+	if len(ret) == 0 {
+		panic("no return value specified for TakeAuthorizationCode")
+	}
+
+	var r0 domain.AuthorizationCode
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (domain.AuthorizationCode, error)); ok {
+		return rf(ctx, code)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) domain.AuthorizationCode); ok {
+		r0 = rf(ctx, code)
+	} else {
+		r0 = ret.Get(0).(domain.AuthorizationCode)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, code)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewAuthorizationCodeRepository creates a new instance of AuthorizationCodeRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewAuthorizationCodeRepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *AuthorizationCodeRepository {
+	mock := &AuthorizationCodeRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,42 @@
+// Code generated by mockery v2.39.1. DO NOT EDIT.
+
+package mocks
+
+import mock "github.com/stretchr/testify/mock"
+
+// BreachedPasswordChecker is an autogenerated mock type for the BreachedPasswordChecker type
+type BreachedPasswordChecker struct {
+	mock.Mock
+}
+
+// IsBreached provides a mock function with given fields: password
+func (_m *BreachedPasswordChecker) IsBreached(password string) bool {
+	ret := _m.Called(password)
+
+	if len(ret) == 0 {
+		panic("no return value specified for IsBreached")
+	}
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(string) bool); ok {
+		r0 = rf(password)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
+
+// NewBreachedPasswordChecker creates a new instance of BreachedPasswordChecker. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewBreachedPasswordChecker(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *BreachedPasswordChecker {
+	mock := &BreachedPasswordChecker{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
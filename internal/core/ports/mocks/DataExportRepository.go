@@ -0,0 +1,167 @@
+// Code generated by mockery v2.39.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	domain "github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+	time "time"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// DataExportRepository is an autogenerated mock type for the DataExportRepository type
+type DataExportRepository struct {
+	mock.Mock
+}
+
+// ClaimPendingDataExport provides a mock function with given fields: ctx, claimedUntil
+func (_m *DataExportRepository) ClaimPendingDataExport(ctx context.Context, claimedUntil time.Time) (domain.DataExport, bool, error) {
+	ret := _m.Called(ctx, claimedUntil)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ClaimPendingDataExport")
+	}
+
+	var r0 domain.DataExport
+	var r1 bool
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) (domain.DataExport, bool, error)); ok {
+		return rf(ctx, claimedUntil)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) domain.DataExport); ok {
+		r0 = rf(ctx, claimedUntil)
+	} else {
+		r0 = ret.Get(0).(domain.DataExport)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time) bool); ok {
+		r1 = rf(ctx, claimedUntil)
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, time.Time) error); ok {
+		r2 = rf(ctx, claimedUntil)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// CreateDataExport provides a mock function with given fields: ctx, dataExport
+func (_m *DataExportRepository) CreateDataExport(ctx context.Context, dataExport domain.DataExport) (string, error) {
+	ret := _m.Called(ctx, dataExport)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateDataExport")
+	}
+
+	var r0 string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.DataExport) (string, error)); ok {
+		return rf(ctx, dataExport)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, domain.DataExport) string); ok {
+		r0 = rf(ctx, dataExport)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, domain.DataExport) error); ok {
+		r1 = rf(ctx, dataExport)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeleteUserDataExports provides a mock function with given fields: ctx, userID
+func (_m *DataExportRepository) DeleteUserDataExports(ctx context.Context, userID string) error {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteUserDataExports")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetDataExport provides a mock function with given fields: ctx, ID
+func (_m *DataExportRepository) GetDataExport(ctx context.Context, ID string) (domain.DataExport, error) {
+	ret := _m.Called(ctx, ID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetDataExport")
+	}
+
+	var r0 domain.DataExport
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (domain.DataExport, error)); ok {
+		return rf(ctx, ID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) domain.DataExport); ok {
+		r0 = rf(ctx, ID)
+	} else {
+		r0 = ret.Get(0).(domain.DataExport)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, ID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpdateDataExport provides a mock function with given fields: ctx, dataExport
+func (_m *DataExportRepository) UpdateDataExport(ctx context.Context, dataExport domain.DataExport) (domain.DataExport, error) {
+	ret := _m.Called(ctx, dataExport)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateDataExport")
+	}
+
+	var r0 domain.DataExport
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.DataExport) (domain.DataExport, error)); ok {
+		return rf(ctx, dataExport)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, domain.DataExport) domain.DataExport); ok {
+		r0 = rf(ctx, dataExport)
+	} else {
+		r0 = ret.Get(0).(domain.DataExport)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, domain.DataExport) error); ok {
+		r1 = rf(ctx, dataExport)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewDataExportRepository creates a new instance of DataExportRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewDataExportRepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *DataExportRepository {
+	mock := &DataExportRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,103 @@
+// Code generated by mockery v2.39.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	domain "github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// EmailChangeTokenRepository is an autogenerated mock type for the EmailChangeTokenRepository type
+type EmailChangeTokenRepository struct {
+	mock.Mock
+}
+
+// CreateEmailChangeToken provides a mock function with given fields: ctx, changeToken
+func (_m *EmailChangeTokenRepository) CreateEmailChangeToken(ctx context.Context, changeToken domain.EmailChangeToken) (string, error) {
+	ret := _m.Called(ctx, changeToken)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateEmailChangeToken")
+	}
+
+	var r0 string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.EmailChangeToken) (string, error)); ok {
+		return rf(ctx, changeToken)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, domain.EmailChangeToken) string); ok {
+		r0 = rf(ctx, changeToken)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, domain.EmailChangeToken) error); ok {
+		r1 = rf(ctx, changeToken)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeleteUserEmailChangeTokens provides a mock function with given fields: ctx, userID
+func (_m *EmailChangeTokenRepository) DeleteUserEmailChangeTokens(ctx context.Context, userID string) error {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteUserEmailChangeTokens")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// TakeEmailChangeToken provides a mock function with given fields: ctx, token
+func (_m *EmailChangeTokenRepository) TakeEmailChangeToken(ctx context.Context, token string) (domain.EmailChangeToken, error) {
+	ret := _m.Called(ctx, token)
+
+	if len(ret) == 0 {
+		panic("no return value specified for TakeEmailChangeToken")
+	}
+
+	var r0 domain.EmailChangeToken
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (domain.EmailChangeToken, error)); ok {
+		return rf(ctx, token)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) domain.EmailChangeToken); ok {
+		r0 = rf(ctx, token)
+	} else {
+		r0 = ret.Get(0).(domain.EmailChangeToken)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, token)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewEmailChangeTokenRepository creates a new instance of EmailChangeTokenRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewEmailChangeTokenRepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *EmailChangeTokenRepository {
+	mock := &EmailChangeTokenRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,103 @@
+// Code generated by mockery v2.39.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	domain "github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// EmailVerificationTokenRepository is an autogenerated mock type for the EmailVerificationTokenRepository type
+type EmailVerificationTokenRepository struct {
+	mock.Mock
+}
+
+// CreateEmailVerificationToken provides a mock function with given fields: ctx, verificationToken
+func (_m *EmailVerificationTokenRepository) CreateEmailVerificationToken(ctx context.Context, verificationToken domain.EmailVerificationToken) (string, error) {
+	ret := _m.Called(ctx, verificationToken)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateEmailVerificationToken")
+	}
+
+	var r0 string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.EmailVerificationToken) (string, error)); ok {
+		return rf(ctx, verificationToken)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, domain.EmailVerificationToken) string); ok {
+		r0 = rf(ctx, verificationToken)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, domain.EmailVerificationToken) error); ok {
+		r1 = rf(ctx, verificationToken)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeleteUserEmailVerificationTokens provides a mock function with given fields: ctx, userID
+func (_m *EmailVerificationTokenRepository) DeleteUserEmailVerificationTokens(ctx context.Context, userID string) error {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteUserEmailVerificationTokens")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetEmailVerificationToken provides a mock function with given fields: ctx, token
+func (_m *EmailVerificationTokenRepository) GetEmailVerificationToken(ctx context.Context, token string) (domain.EmailVerificationToken, error) {
+	ret := _m.Called(ctx, token)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetEmailVerificationToken")
+	}
+
+	var r0 domain.EmailVerificationToken
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (domain.EmailVerificationToken, error)); ok {
+		return rf(ctx, token)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) domain.EmailVerificationToken); ok {
+		r0 = rf(ctx, token)
+	} else {
+		r0 = ret.Get(0).(domain.EmailVerificationToken)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, token)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewEmailVerificationTokenRepository creates a new instance of EmailVerificationTokenRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewEmailVerificationTokenRepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *EmailVerificationTokenRepository {
+	mock := &EmailVerificationTokenRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
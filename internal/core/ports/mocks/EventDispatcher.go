@@ -3,6 +3,7 @@
 package mocks
 
 import (
+	context "context"
 	domain "github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
 
 	mock "github.com/stretchr/testify/mock"
@@ -13,9 +14,22 @@ type EventDispatcher struct {
 	mock.Mock
 }
 
-// Dispatch provides a mock function with given fields: event
-func (_m *EventDispatcher) Dispatch(event domain.Event) {
-	_m.Called(event)
+// Dispatch provides a mock function with given fields: ctx, event
+func (_m *EventDispatcher) Dispatch(ctx context.Context, event domain.Event) error {
+	ret := _m.Called(ctx, event)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Dispatch")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.Event) error); ok {
+		r0 = rf(ctx, event)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
 }
 
 // NewEventDispatcher creates a new instance of EventDispatcher. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
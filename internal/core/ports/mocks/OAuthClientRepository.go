@@ -0,0 +1,133 @@
+// Code generated by mockery v2.39.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	domain "github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// OAuthClientRepository is an autogenerated mock type for the OAuthClientRepository type
+type OAuthClientRepository struct {
+	mock.Mock
+}
+
+// CreateOAuthClient provides a mock function with given fields: ctx, client
+func (_m *OAuthClientRepository) CreateOAuthClient(ctx context.Context, client domain.OAuthClient) (string, error) {
+	ret := _m.Called(ctx, client)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateOAuthClient")
+	}
+
+	var r0 string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.OAuthClient) (string, error)); ok {
+		return rf(ctx, client)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, domain.OAuthClient) string); ok {
+		r0 = rf(ctx, client)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, domain.OAuthClient) error); ok {
+		r1 = rf(ctx, client)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeleteOAuthClient provides a mock function with given fields: ctx, clientID
+func (_m *OAuthClientRepository) DeleteOAuthClient(ctx context.Context, clientID string) error {
+	ret := _m.Called(ctx, clientID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteOAuthClient")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, clientID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetOAuthClient provides a mock function with given fields: ctx, clientID
+func (_m *OAuthClientRepository) GetOAuthClient(ctx context.Context, clientID string) (domain.OAuthClient, error) {
+	ret := _m.Called(ctx, clientID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetOAuthClient")
+	}
+
+	var r0 domain.OAuthClient
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (domain.OAuthClient, error)); ok {
+		return rf(ctx, clientID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) domain.OAuthClient); ok {
+		r0 = rf(ctx, clientID)
+	} else {
+		r0 = ret.Get(0).(domain.OAuthClient)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, clientID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetOAuthClients provides a mock function with given fields: ctx
+func (_m *OAuthClientRepository) GetOAuthClients(ctx context.Context) ([]domain.OAuthClient, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetOAuthClients")
+	}
+
+	var r0 []domain.OAuthClient
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) ([]domain.OAuthClient, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) []domain.OAuthClient); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.OAuthClient)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewOAuthClientRepository creates a new instance of OAuthClientRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewOAuthClientRepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *OAuthClientRepository {
+	mock := &OAuthClientRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,144 @@
+// Code generated by mockery v2.39.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	domain "github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+	time "time"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// OutboxRepository is an autogenerated mock type for the OutboxRepository type
+type OutboxRepository struct {
+	mock.Mock
+}
+
+// AcquireOutboxLease provides a mock function with given fields: ctx, owner, until
+func (_m *OutboxRepository) AcquireOutboxLease(ctx context.Context, owner string, until time.Time) (bool, error) {
+	ret := _m.Called(ctx, owner, until)
+
+	if len(ret) == 0 {
+		panic("no return value specified for AcquireOutboxLease")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time) (bool, error)); ok {
+		return rf(ctx, owner, until)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time) bool); ok {
+		r0 = rf(ctx, owner, until)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, time.Time) error); ok {
+		r1 = rf(ctx, owner, until)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CreateOutboxEvent provides a mock function with given fields: ctx, event
+func (_m *OutboxRepository) CreateOutboxEvent(ctx context.Context, event domain.OutboxEvent) (string, error) {
+	ret := _m.Called(ctx, event)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateOutboxEvent")
+	}
+
+	var r0 string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.OutboxEvent) (string, error)); ok {
+		return rf(ctx, event)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, domain.OutboxEvent) string); ok {
+		r0 = rf(ctx, event)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, domain.OutboxEvent) error); ok {
+		r1 = rf(ctx, event)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetDueOutboxEvents provides a mock function with given fields: ctx, now, limit
+func (_m *OutboxRepository) GetDueOutboxEvents(ctx context.Context, now time.Time, limit int) ([]domain.OutboxEvent, error) {
+	ret := _m.Called(ctx, now, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetDueOutboxEvents")
+	}
+
+	var r0 []domain.OutboxEvent
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time, int) ([]domain.OutboxEvent, error)); ok {
+		return rf(ctx, now, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time, int) []domain.OutboxEvent); ok {
+		r0 = rf(ctx, now, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.OutboxEvent)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time, int) error); ok {
+		r1 = rf(ctx, now, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpdateOutboxEvent provides a mock function with given fields: ctx, event
+func (_m *OutboxRepository) UpdateOutboxEvent(ctx context.Context, event domain.OutboxEvent) (domain.OutboxEvent, error) {
+	ret := _m.Called(ctx, event)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateOutboxEvent")
+	}
+
+	var r0 domain.OutboxEvent
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.OutboxEvent) (domain.OutboxEvent, error)); ok {
+		return rf(ctx, event)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, domain.OutboxEvent) domain.OutboxEvent); ok {
+		r0 = rf(ctx, event)
+	} else {
+		r0 = ret.Get(0).(domain.OutboxEvent)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, domain.OutboxEvent) error); ok {
+		r1 = rf(ctx, event)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewOutboxRepository creates a new instance of OutboxRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewOutboxRepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *OutboxRepository {
+	mock := &OutboxRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,161 @@
+// Code generated by mockery v2.39.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	domain "github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// PasskeyCredentialRepository is an autogenerated mock type for the PasskeyCredentialRepository type
+type PasskeyCredentialRepository struct {
+	mock.Mock
+}
+
+// CreatePasskeyCredential provides a mock function with given fields: ctx, credential
+func (_m *PasskeyCredentialRepository) CreatePasskeyCredential(ctx context.Context, credential domain.PasskeyCredential) (string, error) {
+	ret := _m.Called(ctx, credential)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreatePasskeyCredential")
+	}
+
+	var r0 string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.PasskeyCredential) (string, error)); ok {
+		return rf(ctx, credential)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, domain.PasskeyCredential) string); ok {
+		r0 = rf(ctx, credential)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, domain.PasskeyCredential) error); ok {
+		r1 = rf(ctx, credential)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeleteUserPasskeyCredentials provides a mock function with given fields: ctx, userID
+func (_m *PasskeyCredentialRepository) DeleteUserPasskeyCredentials(ctx context.Context, userID string) error {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteUserPasskeyCredentials")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetPasskeyCredential provides a mock function with given fields: ctx, credentialID
+func (_m *PasskeyCredentialRepository) GetPasskeyCredential(ctx context.Context, credentialID []byte) (domain.PasskeyCredential, error) {
+	ret := _m.Called(ctx, credentialID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetPasskeyCredential")
+	}
+
+	var r0 domain.PasskeyCredential
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []byte) (domain.PasskeyCredential, error)); ok {
+		return rf(ctx, credentialID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []byte) domain.PasskeyCredential); ok {
+		r0 = rf(ctx, credentialID)
+	} else {
+		r0 = ret.Get(0).(domain.PasskeyCredential)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []byte) error); ok {
+		r1 = rf(ctx, credentialID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetUserPasskeyCredentials provides a mock function with given fields: ctx, userID
+func (_m *PasskeyCredentialRepository) GetUserPasskeyCredentials(ctx context.Context, userID string) ([]domain.PasskeyCredential, error) {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetUserPasskeyCredentials")
+	}
+
+	var r0 []domain.PasskeyCredential
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) ([]domain.PasskeyCredential, error)); ok {
+		return rf(ctx, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) []domain.PasskeyCredential); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.PasskeyCredential)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpdatePasskeyCredential provides a mock function with given fields: ctx, credential
+func (_m *PasskeyCredentialRepository) UpdatePasskeyCredential(ctx context.Context, credential domain.PasskeyCredential) (domain.PasskeyCredential, error) {
+	ret := _m.Called(ctx, credential)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdatePasskeyCredential")
+	}
+
+	var r0 domain.PasskeyCredential
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.PasskeyCredential) (domain.PasskeyCredential, error)); ok {
+		return rf(ctx, credential)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, domain.PasskeyCredential) domain.PasskeyCredential); ok {
+		r0 = rf(ctx, credential)
+	} else {
+		r0 = ret.Get(0).(domain.PasskeyCredential)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, domain.PasskeyCredential) error); ok {
+		r1 = rf(ctx, credential)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewPasskeyCredentialRepository creates a new instance of PasskeyCredentialRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewPasskeyCredentialRepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *PasskeyCredentialRepository {
+	mock := &PasskeyCredentialRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
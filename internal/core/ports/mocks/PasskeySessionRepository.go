@@ -0,0 +1,85 @@
+// Code generated by mockery v2.39.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	domain "github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// PasskeySessionRepository is an autogenerated mock type for the PasskeySessionRepository type
+type PasskeySessionRepository struct {
+	mock.Mock
+}
+
+// CreatePasskeySession provides a mock function with given fields: ctx, session
+func (_m *PasskeySessionRepository) CreatePasskeySession(ctx context.Context, session domain.PasskeySession) (string, error) {
+	ret := _m.Called(ctx, session)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreatePasskeySession")
+	}
+
+	var r0 string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.PasskeySession) (string, error)); ok {
+		return rf(ctx, session)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, domain.PasskeySession) string); ok {
+		r0 = rf(ctx, session)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, domain.PasskeySession) error); ok {
+		r1 = rf(ctx, session)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// TakePasskeySession provides a mock function with given fields: ctx, challenge
+func (_m *PasskeySessionRepository) TakePasskeySession(ctx context.Context, challenge string) (domain.PasskeySession, error) {
+	ret := _m.Called(ctx, challenge)
+
+	if len(ret) == 0 {
+		panic("no return value specified for TakePasskeySession")
+	}
+
+	var r0 domain.PasskeySession
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (domain.PasskeySession, error)); ok {
+		return rf(ctx, challenge)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) domain.PasskeySession); ok {
+		r0 = rf(ctx, challenge)
+	} else {
+		r0 = ret.Get(0).(domain.PasskeySession)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, challenge)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewPasskeySessionRepository creates a new instance of PasskeySessionRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewPasskeySessionRepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *PasskeySessionRepository {
+	mock := &PasskeySessionRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
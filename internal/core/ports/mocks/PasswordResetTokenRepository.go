@@ -0,0 +1,103 @@
+// Code generated by mockery v2.39.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	domain "github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// PasswordResetTokenRepository is an autogenerated mock type for the PasswordResetTokenRepository type
+type PasswordResetTokenRepository struct {
+	mock.Mock
+}
+
+// CreatePasswordResetToken provides a mock function with given fields: ctx, resetToken
+func (_m *PasswordResetTokenRepository) CreatePasswordResetToken(ctx context.Context, resetToken domain.PasswordResetToken) (string, error) {
+	ret := _m.Called(ctx, resetToken)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreatePasswordResetToken")
+	}
+
+	var r0 string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.PasswordResetToken) (string, error)); ok {
+		return rf(ctx, resetToken)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, domain.PasswordResetToken) string); ok {
+		r0 = rf(ctx, resetToken)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, domain.PasswordResetToken) error); ok {
+		r1 = rf(ctx, resetToken)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeleteUserPasswordResetTokens provides a mock function with given fields: ctx, userID
+func (_m *PasswordResetTokenRepository) DeleteUserPasswordResetTokens(ctx context.Context, userID string) error {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteUserPasswordResetTokens")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// TakePasswordResetToken provides a mock function with given fields: ctx, token
+func (_m *PasswordResetTokenRepository) TakePasswordResetToken(ctx context.Context, token string) (domain.PasswordResetToken, error) {
+	ret := _m.Called(ctx, token)
+
+	if len(ret) == 0 {
+		panic("no return value specified for TakePasswordResetToken")
+	}
+
+	var r0 domain.PasswordResetToken
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (domain.PasswordResetToken, error)); ok {
+		return rf(ctx, token)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) domain.PasswordResetToken); ok {
+		r0 = rf(ctx, token)
+	} else {
+		r0 = ret.Get(0).(domain.PasswordResetToken)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, token)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewPasswordResetTokenRepository creates a new instance of PasswordResetTokenRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewPasswordResetTokenRepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *PasswordResetTokenRepository {
+	mock := &PasswordResetTokenRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
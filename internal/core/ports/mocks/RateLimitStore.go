@@ -0,0 +1,58 @@
+// Code generated by mockery v2.39.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	ratelimit "github.com/ttodoshi/code-typing-auth-service/pkg/ratelimit"
+	time "time"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// RateLimitStore is an autogenerated mock type for the RateLimitStore type
+type RateLimitStore struct {
+	mock.Mock
+}
+
+// Take provides a mock function with given fields: ctx, key, policy
+func (_m *RateLimitStore) Take(ctx context.Context, key string, policy ratelimit.Policy) (time.Duration, error) {
+	ret := _m.Called(ctx, key, policy)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Take")
+	}
+
+	var r0 time.Duration
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, ratelimit.Policy) (time.Duration, error)); ok {
+		return rf(ctx, key, policy)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, ratelimit.Policy) time.Duration); ok {
+		r0 = rf(ctx, key, policy)
+	} else {
+		r0 = ret.Get(0).(time.Duration)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, ratelimit.Policy) error); ok {
+		r1 = rf(ctx, key, policy)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewRateLimitStore creates a new instance of RateLimitStore. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewRateLimitStore(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *RateLimitStore {
+	mock := &RateLimitStore{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -3,6 +3,7 @@
 package mocks
 
 import (
+	context "context"
 	domain "github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
 
 	mock "github.com/stretchr/testify/mock"
@@ -13,9 +14,9 @@ type RefreshTokenRepository struct {
 	mock.Mock
 }
 
-// CreateRefreshToken provides a mock function with given fields: refreshToken
-func (_m *RefreshTokenRepository) CreateRefreshToken(refreshToken domain.RefreshToken) (string, error) {
-	ret := _m.Called(refreshToken)
+// CreateRefreshToken provides a mock function with given fields: ctx, refreshToken
+func (_m *RefreshTokenRepository) CreateRefreshToken(ctx context.Context, refreshToken domain.RefreshToken) (string, error) {
+	ret := _m.Called(ctx, refreshToken)
 
 	if len(ret) == 0 {
 		panic("no return value specified for CreateRefreshToken")
@@ -23,17 +24,17 @@ func (_m *RefreshTokenRepository) CreateRefreshToken(refreshToken domain.Refresh
 
 	var r0 string
 	var r1 error
-	if rf, ok := ret.Get(0).(func(domain.RefreshToken) (string, error)); ok {
-		return rf(refreshToken)
+	if rf, ok := ret.Get(0).(func(context.Context, domain.RefreshToken) (string, error)); ok {
+		return rf(ctx, refreshToken)
 	}
-	if rf, ok := ret.Get(0).(func(domain.RefreshToken) string); ok {
-		r0 = rf(refreshToken)
+	if rf, ok := ret.Get(0).(func(context.Context, domain.RefreshToken) string); ok {
+		r0 = rf(ctx, refreshToken)
 	} else {
 		r0 = ret.Get(0).(string)
 	}
 
-	if rf, ok := ret.Get(1).(func(domain.RefreshToken) error); ok {
-		r1 = rf(refreshToken)
+	if rf, ok := ret.Get(1).(func(context.Context, domain.RefreshToken) error); ok {
+		r1 = rf(ctx, refreshToken)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -41,17 +42,35 @@ func (_m *RefreshTokenRepository) CreateRefreshToken(refreshToken domain.Refresh
 	return r0, r1
 }
 
-// DeleteRefreshToken provides a mock function with given fields: refreshToken
-func (_m *RefreshTokenRepository) DeleteRefreshToken(refreshToken string) error {
-	ret := _m.Called(refreshToken)
+// DeleteOtherUserRefreshTokens provides a mock function with given fields: ctx, userID, exceptFamily
+func (_m *RefreshTokenRepository) DeleteOtherUserRefreshTokens(ctx context.Context, userID string, exceptFamily string) error {
+	ret := _m.Called(ctx, userID, exceptFamily)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteOtherUserRefreshTokens")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, userID, exceptFamily)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteRefreshToken provides a mock function with given fields: ctx, refreshToken
+func (_m *RefreshTokenRepository) DeleteRefreshToken(ctx context.Context, refreshToken string) error {
+	ret := _m.Called(ctx, refreshToken)
 
 	if len(ret) == 0 {
 		panic("no return value specified for DeleteRefreshToken")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(string) error); ok {
-		r0 = rf(refreshToken)
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, refreshToken)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -59,9 +78,45 @@ func (_m *RefreshTokenRepository) DeleteRefreshToken(refreshToken string) error
 	return r0
 }
 
-// GetRefreshToken provides a mock function with given fields: refreshToken
-func (_m *RefreshTokenRepository) GetRefreshToken(refreshToken string) (domain.RefreshToken, error) {
-	ret := _m.Called(refreshToken)
+// DeleteRefreshTokenFamily provides a mock function with given fields: ctx, family
+func (_m *RefreshTokenRepository) DeleteRefreshTokenFamily(ctx context.Context, family string) error {
+	ret := _m.Called(ctx, family)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteRefreshTokenFamily")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, family)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteUserRefreshTokens provides a mock function with given fields: ctx, userID
+func (_m *RefreshTokenRepository) DeleteUserRefreshTokens(ctx context.Context, userID string) error {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteUserRefreshTokens")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetRefreshToken provides a mock function with given fields: ctx, refreshToken
+func (_m *RefreshTokenRepository) GetRefreshToken(ctx context.Context, refreshToken string) (domain.RefreshToken, error) {
+	ret := _m.Called(ctx, refreshToken)
 
 	if len(ret) == 0 {
 		panic("no return value specified for GetRefreshToken")
@@ -69,17 +124,105 @@ func (_m *RefreshTokenRepository) GetRefreshToken(refreshToken string) (domain.R
 
 	var r0 domain.RefreshToken
 	var r1 error
-	if rf, ok := ret.Get(0).(func(string) (domain.RefreshToken, error)); ok {
-		return rf(refreshToken)
+	if rf, ok := ret.Get(0).(func(context.Context, string) (domain.RefreshToken, error)); ok {
+		return rf(ctx, refreshToken)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) domain.RefreshToken); ok {
+		r0 = rf(ctx, refreshToken)
+	} else {
+		r0 = ret.Get(0).(domain.RefreshToken)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, refreshToken)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetRotatedRefreshToken provides a mock function with given fields: ctx, refreshToken
+func (_m *RefreshTokenRepository) GetRotatedRefreshToken(ctx context.Context, refreshToken string) (domain.RefreshToken, error) {
+	ret := _m.Called(ctx, refreshToken)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetRotatedRefreshToken")
+	}
+
+	var r0 domain.RefreshToken
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (domain.RefreshToken, error)); ok {
+		return rf(ctx, refreshToken)
 	}
-	if rf, ok := ret.Get(0).(func(string) domain.RefreshToken); ok {
-		r0 = rf(refreshToken)
+	if rf, ok := ret.Get(0).(func(context.Context, string) domain.RefreshToken); ok {
+		r0 = rf(ctx, refreshToken)
 	} else {
 		r0 = ret.Get(0).(domain.RefreshToken)
 	}
 
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(refreshToken)
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, refreshToken)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetUserRefreshTokens provides a mock function with given fields: ctx, userID
+func (_m *RefreshTokenRepository) GetUserRefreshTokens(ctx context.Context, userID string) ([]domain.RefreshToken, error) {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetUserRefreshTokens")
+	}
+
+	var r0 []domain.RefreshToken
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) ([]domain.RefreshToken, error)); ok {
+		return rf(ctx, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) []domain.RefreshToken); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.RefreshToken)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetUserRotatedRefreshTokens provides a mock function with given fields: ctx, userID
+func (_m *RefreshTokenRepository) GetUserRotatedRefreshTokens(ctx context.Context, userID string) ([]domain.RefreshToken, error) {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetUserRotatedRefreshTokens")
+	}
+
+	var r0 []domain.RefreshToken
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) ([]domain.RefreshToken, error)); ok {
+		return rf(ctx, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) []domain.RefreshToken); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.RefreshToken)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, userID)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -87,27 +230,27 @@ func (_m *RefreshTokenRepository) GetRefreshToken(refreshToken string) (domain.R
 	return r0, r1
 }
 
-// UpdateRefreshToken provides a mock function with given fields: oldRefreshToken, newRefreshToken
-func (_m *RefreshTokenRepository) UpdateRefreshToken(oldRefreshToken string, newRefreshToken string) (domain.RefreshToken, error) {
-	ret := _m.Called(oldRefreshToken, newRefreshToken)
+// RotateRefreshToken provides a mock function with given fields: ctx, oldRefreshToken, newRefreshToken
+func (_m *RefreshTokenRepository) RotateRefreshToken(ctx context.Context, oldRefreshToken string, newRefreshToken domain.RefreshToken) (domain.RefreshToken, error) {
+	ret := _m.Called(ctx, oldRefreshToken, newRefreshToken)
 
 	if len(ret) == 0 {
-		panic("no return value specified for UpdateRefreshToken")
+		panic("no return value specified for RotateRefreshToken")
 	}
 
 	var r0 domain.RefreshToken
 	var r1 error
-	if rf, ok := ret.Get(0).(func(string, string) (domain.RefreshToken, error)); ok {
-		return rf(oldRefreshToken, newRefreshToken)
+	if rf, ok := ret.Get(0).(func(context.Context, string, domain.RefreshToken) (domain.RefreshToken, error)); ok {
+		return rf(ctx, oldRefreshToken, newRefreshToken)
 	}
-	if rf, ok := ret.Get(0).(func(string, string) domain.RefreshToken); ok {
-		r0 = rf(oldRefreshToken, newRefreshToken)
+	if rf, ok := ret.Get(0).(func(context.Context, string, domain.RefreshToken) domain.RefreshToken); ok {
+		r0 = rf(ctx, oldRefreshToken, newRefreshToken)
 	} else {
 		r0 = ret.Get(0).(domain.RefreshToken)
 	}
 
-	if rf, ok := ret.Get(1).(func(string, string) error); ok {
-		r1 = rf(oldRefreshToken, newRefreshToken)
+	if rf, ok := ret.Get(1).(func(context.Context, string, domain.RefreshToken) error); ok {
+		r1 = rf(ctx, oldRefreshToken, newRefreshToken)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -0,0 +1,88 @@
+// Code generated by mockery v2.39.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	domain "github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+	time "time"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// RevokedAccessTokenRepository is an autogenerated mock type for the RevokedAccessTokenRepository type
+type RevokedAccessTokenRepository struct {
+	mock.Mock
+}
+
+// CreateRevokedAccessToken provides a mock function with given fields: ctx, revokedToken
+func (_m *RevokedAccessTokenRepository) CreateRevokedAccessToken(ctx context.Context, revokedToken domain.RevokedAccessToken) (string, error) {
+	ret := _m.Called(ctx, revokedToken)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateRevokedAccessToken")
+	}
+
+	var r0 string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.RevokedAccessToken) (string, error)); ok {
+		return rf(ctx, revokedToken)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, domain.RevokedAccessToken) string); ok {
+		r0 = rf(ctx, revokedToken)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, domain.RevokedAccessToken) error); ok {
+		r1 = rf(ctx, revokedToken)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetRevokedAccessTokens provides a mock function with given fields: ctx, since
+func (_m *RevokedAccessTokenRepository) GetRevokedAccessTokens(ctx context.Context, since time.Time) ([]domain.RevokedAccessToken, error) {
+	ret := _m.Called(ctx, since)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetRevokedAccessTokens")
+	}
+
+	var r0 []domain.RevokedAccessToken
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) ([]domain.RevokedAccessToken, error)); ok {
+		return rf(ctx, since)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) []domain.RevokedAccessToken); ok {
+		r0 = rf(ctx, since)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.RevokedAccessToken)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time) error); ok {
+		r1 = rf(ctx, since)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewRevokedAccessTokenRepository creates a new instance of RevokedAccessTokenRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewRevokedAccessTokenRepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *RevokedAccessTokenRepository {
+	mock := &RevokedAccessTokenRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,142 @@
+// Code generated by mockery v2.39.1. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+	domain "github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+	time "time"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// SigningKeyRepository is an autogenerated mock type for the SigningKeyRepository type
+type SigningKeyRepository struct {
+	mock.Mock
+}
+
+// ActivateSigningKey provides a mock function with given fields: ctx, keyID, activatedAt
+func (_m *SigningKeyRepository) ActivateSigningKey(ctx context.Context, keyID string, activatedAt time.Time) error {
+	ret := _m.Called(ctx, keyID, activatedAt)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ActivateSigningKey")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time) error); ok {
+		r0 = rf(ctx, keyID, activatedAt)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CreateSigningKey provides a mock function with given fields: ctx, key
+func (_m *SigningKeyRepository) CreateSigningKey(ctx context.Context, key domain.SigningKey) (string, error) {
+	ret := _m.Called(ctx, key)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateSigningKey")
+	}
+
+	var r0 string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, domain.SigningKey) (string, error)); ok {
+		return rf(ctx, key)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, domain.SigningKey) string); ok {
+		r0 = rf(ctx, key)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, domain.SigningKey) error); ok {
+		r1 = rf(ctx, key)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeactivateSigningKeys provides a mock function with given fields: ctx, exceptKeyID, retireAt
+func (_m *SigningKeyRepository) DeactivateSigningKeys(ctx context.Context, exceptKeyID string, retireAt time.Time) error {
+	ret := _m.Called(ctx, exceptKeyID, retireAt)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeactivateSigningKeys")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time) error); ok {
+		r0 = rf(ctx, exceptKeyID, retireAt)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetSigningKeys provides a mock function with given fields: ctx
+func (_m *SigningKeyRepository) GetSigningKeys(ctx context.Context) ([]domain.SigningKey, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetSigningKeys")
+	}
+
+	var r0 []domain.SigningKey
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) ([]domain.SigningKey, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) []domain.SigningKey); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.SigningKey)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RetireSigningKeys provides a mock function with given fields: ctx, now
+func (_m *SigningKeyRepository) RetireSigningKeys(ctx context.Context, now time.Time) error {
+	ret := _m.Called(ctx, now)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RetireSigningKeys")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) error); ok {
+		r0 = rf(ctx, now)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// NewSigningKeyRepository creates a new instance of SigningKeyRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewSigningKeyRepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *SigningKeyRepository {
+	mock := &SigningKeyRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -3,7 +3,9 @@
 package mocks
 
 import (
+	context "context"
 	domain "github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+	time "time"
 
 	mock "github.com/stretchr/testify/mock"
 )
@@ -13,9 +15,203 @@ type UserRepository struct {
 	mock.Mock
 }
 
-// GetUserByEmail provides a mock function with given fields: email
-func (_m *UserRepository) GetUserByEmail(email string) (domain.User, error) {
-	ret := _m.Called(email)
+// ChangeEmail provides a mock function with given fields: ctx, ID, email
+func (_m *UserRepository) ChangeEmail(ctx context.Context, ID string, email string) (domain.User, error) {
+	ret := _m.Called(ctx, ID, email)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ChangeEmail")
+	}
+
+	var r0 domain.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) (domain.User, error)); ok {
+		return rf(ctx, ID, email)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) domain.User); ok {
+		r0 = rf(ctx, ID, email)
+	} else {
+		r0 = ret.Get(0).(domain.User)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, ID, email)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ChangeNickname provides a mock function with given fields: ctx, ID, nickname, changedAt
+func (_m *UserRepository) ChangeNickname(ctx context.Context, ID string, nickname string, changedAt time.Time) (domain.User, error) {
+	ret := _m.Called(ctx, ID, nickname, changedAt)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ChangeNickname")
+	}
+
+	var r0 domain.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, time.Time) (domain.User, error)); ok {
+		return rf(ctx, ID, nickname, changedAt)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, time.Time) domain.User); ok {
+		r0 = rf(ctx, ID, nickname, changedAt)
+	} else {
+		r0 = ret.Get(0).(domain.User)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, time.Time) error); ok {
+		r1 = rf(ctx, ID, nickname, changedAt)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ConsumeMFATokenID provides a mock function with given fields: ctx, ID, mfaTokenID
+func (_m *UserRepository) ConsumeMFATokenID(ctx context.Context, ID string, mfaTokenID string) (bool, error) {
+	ret := _m.Called(ctx, ID, mfaTokenID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ConsumeMFATokenID")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) (bool, error)); ok {
+		return rf(ctx, ID, mfaTokenID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) bool); ok {
+		r0 = rf(ctx, ID, mfaTokenID)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, ID, mfaTokenID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ConsumeRecoveryCode provides a mock function with given fields: ctx, ID, hashedCode
+func (_m *UserRepository) ConsumeRecoveryCode(ctx context.Context, ID string, hashedCode string) (bool, error) {
+	ret := _m.Called(ctx, ID, hashedCode)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ConsumeRecoveryCode")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) (bool, error)); ok {
+		return rf(ctx, ID, hashedCode)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) bool); ok {
+		r0 = rf(ctx, ID, hashedCode)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, ID, hashedCode)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ConsumeTOTPStep provides a mock function with given fields: ctx, ID, step
+func (_m *UserRepository) ConsumeTOTPStep(ctx context.Context, ID string, step int64) (bool, error) {
+	ret := _m.Called(ctx, ID, step)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ConsumeTOTPStep")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, int64) (bool, error)); ok {
+		return rf(ctx, ID, step)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, int64) bool); ok {
+		r0 = rf(ctx, ID, step)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, int64) error); ok {
+		r1 = rf(ctx, ID, step)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeleteUser provides a mock function with given fields: ctx, ID, deletedBefore
+func (_m *UserRepository) DeleteUser(ctx context.Context, ID string, deletedBefore time.Time) error {
+	ret := _m.Called(ctx, ID, deletedBefore)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteUser")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time) error); ok {
+		r0 = rf(ctx, ID, deletedBefore)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DisableTOTP provides a mock function with given fields: ctx, ID
+func (_m *UserRepository) DisableTOTP(ctx context.Context, ID string) error {
+	ret := _m.Called(ctx, ID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DisableTOTP")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, ID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// EnableTOTP provides a mock function with given fields: ctx, ID, hashedRecoveryCodes
+func (_m *UserRepository) EnableTOTP(ctx context.Context, ID string, hashedRecoveryCodes []string) error {
+	ret := _m.Called(ctx, ID, hashedRecoveryCodes)
+
+	if len(ret) == 0 {
+		panic("no return value specified for EnableTOTP")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, []string) error); ok {
+		r0 = rf(ctx, ID, hashedRecoveryCodes)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetUserByEmail provides a mock function with given fields: ctx, email
+func (_m *UserRepository) GetUserByEmail(ctx context.Context, email string) (domain.User, error) {
+	ret := _m.Called(ctx, email)
 
 	if len(ret) == 0 {
 		panic("no return value specified for GetUserByEmail")
@@ -23,17 +219,17 @@ func (_m *UserRepository) GetUserByEmail(email string) (domain.User, error) {
 
 	var r0 domain.User
 	var r1 error
-	if rf, ok := ret.Get(0).(func(string) (domain.User, error)); ok {
-		return rf(email)
+	if rf, ok := ret.Get(0).(func(context.Context, string) (domain.User, error)); ok {
+		return rf(ctx, email)
 	}
-	if rf, ok := ret.Get(0).(func(string) domain.User); ok {
-		r0 = rf(email)
+	if rf, ok := ret.Get(0).(func(context.Context, string) domain.User); ok {
+		r0 = rf(ctx, email)
 	} else {
 		r0 = ret.Get(0).(domain.User)
 	}
 
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(email)
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, email)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -41,9 +237,9 @@ func (_m *UserRepository) GetUserByEmail(email string) (domain.User, error) {
 	return r0, r1
 }
 
-// GetUserByID provides a mock function with given fields: ID
-func (_m *UserRepository) GetUserByID(ID string) (domain.User, error) {
-	ret := _m.Called(ID)
+// GetUserByID provides a mock function with given fields: ctx, ID
+func (_m *UserRepository) GetUserByID(ctx context.Context, ID string) (domain.User, error) {
+	ret := _m.Called(ctx, ID)
 
 	if len(ret) == 0 {
 		panic("no return value specified for GetUserByID")
@@ -51,17 +247,17 @@ func (_m *UserRepository) GetUserByID(ID string) (domain.User, error) {
 
 	var r0 domain.User
 	var r1 error
-	if rf, ok := ret.Get(0).(func(string) (domain.User, error)); ok {
-		return rf(ID)
+	if rf, ok := ret.Get(0).(func(context.Context, string) (domain.User, error)); ok {
+		return rf(ctx, ID)
 	}
-	if rf, ok := ret.Get(0).(func(string) domain.User); ok {
-		r0 = rf(ID)
+	if rf, ok := ret.Get(0).(func(context.Context, string) domain.User); ok {
+		r0 = rf(ctx, ID)
 	} else {
 		r0 = ret.Get(0).(domain.User)
 	}
 
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(ID)
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, ID)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -69,9 +265,9 @@ func (_m *UserRepository) GetUserByID(ID string) (domain.User, error) {
 	return r0, r1
 }
 
-// GetUserByNickname provides a mock function with given fields: nickname
-func (_m *UserRepository) GetUserByNickname(nickname string) (domain.User, error) {
-	ret := _m.Called(nickname)
+// GetUserByNickname provides a mock function with given fields: ctx, nickname
+func (_m *UserRepository) GetUserByNickname(ctx context.Context, nickname string) (domain.User, error) {
+	ret := _m.Called(ctx, nickname)
 
 	if len(ret) == 0 {
 		panic("no return value specified for GetUserByNickname")
@@ -79,17 +275,47 @@ func (_m *UserRepository) GetUserByNickname(nickname string) (domain.User, error
 
 	var r0 domain.User
 	var r1 error
-	if rf, ok := ret.Get(0).(func(string) (domain.User, error)); ok {
-		return rf(nickname)
+	if rf, ok := ret.Get(0).(func(context.Context, string) (domain.User, error)); ok {
+		return rf(ctx, nickname)
 	}
-	if rf, ok := ret.Get(0).(func(string) domain.User); ok {
-		r0 = rf(nickname)
+	if rf, ok := ret.Get(0).(func(context.Context, string) domain.User); ok {
+		r0 = rf(ctx, nickname)
 	} else {
 		r0 = ret.Get(0).(domain.User)
 	}
 
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(nickname)
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, nickname)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetUsersDeletedBefore provides a mock function with given fields: ctx, deletedBefore
+func (_m *UserRepository) GetUsersDeletedBefore(ctx context.Context, deletedBefore time.Time) ([]domain.User, error) {
+	ret := _m.Called(ctx, deletedBefore)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetUsersDeletedBefore")
+	}
+
+	var r0 []domain.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) ([]domain.User, error)); ok {
+		return rf(ctx, deletedBefore)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) []domain.User); ok {
+		r0 = rf(ctx, deletedBefore)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.User)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time) error); ok {
+		r1 = rf(ctx, deletedBefore)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -97,9 +323,136 @@ func (_m *UserRepository) GetUserByNickname(nickname string) (domain.User, error
 	return r0, r1
 }
 
-// SaveUser provides a mock function with given fields: user
-func (_m *UserRepository) SaveUser(user domain.User) (domain.User, error) {
-	ret := _m.Called(user)
+// LockUser provides a mock function with given fields: ctx, ID, lockedAt, lockedUntil
+func (_m *UserRepository) LockUser(ctx context.Context, ID string, lockedAt time.Time, lockedUntil time.Time) (domain.User, bool, error) {
+	ret := _m.Called(ctx, ID, lockedAt, lockedUntil)
+
+	if len(ret) == 0 {
+		panic("no return value specified for LockUser")
+	}
+
+	var r0 domain.User
+	var r1 bool
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time, time.Time) (domain.User, bool, error)); ok {
+		return rf(ctx, ID, lockedAt, lockedUntil)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time, time.Time) domain.User); ok {
+		r0 = rf(ctx, ID, lockedAt, lockedUntil)
+	} else {
+		r0 = ret.Get(0).(domain.User)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, time.Time, time.Time) bool); ok {
+		r1 = rf(ctx, ID, lockedAt, lockedUntil)
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, string, time.Time, time.Time) error); ok {
+		r2 = rf(ctx, ID, lockedAt, lockedUntil)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// MarkUserDeleted provides a mock function with given fields: ctx, ID, deletedAt
+func (_m *UserRepository) MarkUserDeleted(ctx context.Context, ID string, deletedAt time.Time) error {
+	ret := _m.Called(ctx, ID, deletedAt)
+
+	if len(ret) == 0 {
+		panic("no return value specified for MarkUserDeleted")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time) error); ok {
+		r0 = rf(ctx, ID, deletedAt)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RecordFailedLogin provides a mock function with given fields: ctx, ID, failedAt, forgetBefore
+func (_m *UserRepository) RecordFailedLogin(ctx context.Context, ID string, failedAt time.Time, forgetBefore time.Time) (domain.User, error) {
+	ret := _m.Called(ctx, ID, failedAt, forgetBefore)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RecordFailedLogin")
+	}
+
+	var r0 domain.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time, time.Time) (domain.User, error)); ok {
+		return rf(ctx, ID, failedAt, forgetBefore)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time, time.Time) domain.User); ok {
+		r0 = rf(ctx, ID, failedAt, forgetBefore)
+	} else {
+		r0 = ret.Get(0).(domain.User)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, time.Time, time.Time) error); ok {
+		r1 = rf(ctx, ID, failedAt, forgetBefore)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ResetFailedLogins provides a mock function with given fields: ctx, ID
+func (_m *UserRepository) ResetFailedLogins(ctx context.Context, ID string) (domain.User, error) {
+	ret := _m.Called(ctx, ID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ResetFailedLogins")
+	}
+
+	var r0 domain.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (domain.User, error)); ok {
+		return rf(ctx, ID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) domain.User); ok {
+		r0 = rf(ctx, ID)
+	} else {
+		r0 = ret.Get(0).(domain.User)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, ID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RestoreUser provides a mock function with given fields: ctx, ID
+func (_m *UserRepository) RestoreUser(ctx context.Context, ID string) error {
+	ret := _m.Called(ctx, ID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RestoreUser")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, ID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SaveUser provides a mock function with given fields: ctx, user
+func (_m *UserRepository) SaveUser(ctx context.Context, user domain.User) (domain.User, error) {
+	ret := _m.Called(ctx, user)
 
 	if len(ret) == 0 {
 		panic("no return value specified for SaveUser")
@@ -107,17 +460,17 @@ func (_m *UserRepository) SaveUser(user domain.User) (domain.User, error) {
 
 	var r0 domain.User
 	var r1 error
-	if rf, ok := ret.Get(0).(func(domain.User) (domain.User, error)); ok {
-		return rf(user)
+	if rf, ok := ret.Get(0).(func(context.Context, domain.User) (domain.User, error)); ok {
+		return rf(ctx, user)
 	}
-	if rf, ok := ret.Get(0).(func(domain.User) domain.User); ok {
-		r0 = rf(user)
+	if rf, ok := ret.Get(0).(func(context.Context, domain.User) domain.User); ok {
+		r0 = rf(ctx, user)
 	} else {
 		r0 = ret.Get(0).(domain.User)
 	}
 
-	if rf, ok := ret.Get(1).(func(domain.User) error); ok {
-		r1 = rf(user)
+	if rf, ok := ret.Get(1).(func(context.Context, domain.User) error); ok {
+		r1 = rf(ctx, user)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -125,6 +478,114 @@ func (_m *UserRepository) SaveUser(user domain.User) (domain.User, error) {
 	return r0, r1
 }
 
+// SetBanned provides a mock function with given fields: ctx, ID, banned
+func (_m *UserRepository) SetBanned(ctx context.Context, ID string, banned bool) error {
+	ret := _m.Called(ctx, ID, banned)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetBanned")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, bool) error); ok {
+		r0 = rf(ctx, ID, banned)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetEmailVerified provides a mock function with given fields: ctx, ID
+func (_m *UserRepository) SetEmailVerified(ctx context.Context, ID string) error {
+	ret := _m.Called(ctx, ID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetEmailVerified")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, ID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetMFATokenID provides a mock function with given fields: ctx, ID, mfaTokenID
+func (_m *UserRepository) SetMFATokenID(ctx context.Context, ID string, mfaTokenID string) error {
+	ret := _m.Called(ctx, ID, mfaTokenID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetMFATokenID")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, ID, mfaTokenID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetPassword provides a mock function with given fields: ctx, ID, password
+func (_m *UserRepository) SetPassword(ctx context.Context, ID string, password string) error {
+	ret := _m.Called(ctx, ID, password)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetPassword")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, ID, password)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetRecoveryCodes provides a mock function with given fields: ctx, ID, hashedRecoveryCodes
+func (_m *UserRepository) SetRecoveryCodes(ctx context.Context, ID string, hashedRecoveryCodes []string) error {
+	ret := _m.Called(ctx, ID, hashedRecoveryCodes)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetRecoveryCodes")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, []string) error); ok {
+		r0 = rf(ctx, ID, hashedRecoveryCodes)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetTOTPSecret provides a mock function with given fields: ctx, ID, encryptedSecret
+func (_m *UserRepository) SetTOTPSecret(ctx context.Context, ID string, encryptedSecret string) error {
+	ret := _m.Called(ctx, ID, encryptedSecret)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetTOTPSecret")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, ID, encryptedSecret)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // NewUserRepository creates a new instance of UserRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewUserRepository(t interface {
@@ -1,40 +1,350 @@
 package ports
 
 import (
+	"context"
+	"github.com/go-webauthn/webauthn/protocol"
 	"github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
 	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports/dto"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/jwt"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/ratelimit"
+	"time"
 )
 
 type AuthService interface {
-	Register(registerRequestDto dto.RegisterRequestDto, session string) (access string, refresh string, err error)
-	Login(loginRequestDto dto.LoginRequestDto, session string) (access string, refresh string, err error)
-	Refresh(oldRefreshToken string) (access string, refresh string, err error)
-	Logout(refreshToken string)
+	Register(ctx context.Context, registerRequestDto dto.RegisterRequestDto, session string, device dto.DeviceDto) (access string, refresh string, err error)
+	Login(ctx context.Context, loginRequestDto dto.LoginRequestDto, session string, device dto.DeviceDto) (access string, refresh string, mfaToken string, err error)
+	LoginMFA(ctx context.Context, mfaLoginRequestDto dto.MFALoginRequestDto, session string, device dto.DeviceDto) (access string, refresh string, err error)
+	Refresh(ctx context.Context, oldRefreshToken string, device dto.DeviceDto) (access string, refresh string, err error)
+	// Logout ends session of refresh token and revokes access token when it is given
+	Logout(ctx context.Context, refreshToken string, accessToken string)
+	VerifyEmail(ctx context.Context, verificationToken string) error
+	ResendVerificationEmail(ctx context.Context, email string) error
+	ForgotPassword(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, resetPasswordRequestDto dto.ResetPasswordRequestDto) error
+}
+
+type RateLimitService interface {
+	// Allow takes token from bucket of key, RateLimitError is returned when bucket is empty
+	Allow(ctx context.Context, key string, policy ratelimit.Policy) error
+}
+
+type UserService interface {
+	GetUser(ctx context.Context, userID string) (dto.UserResponseDto, error)
+	ChangeNickname(ctx context.Context, userID string, nickname string) (dto.UserResponseDto, error)
+	// ChangePassword ends all sessions except the one of given refresh token
+	ChangePassword(ctx context.Context, userID string, refreshToken string, changePasswordRequestDto dto.ChangePasswordRequestDto) error
+	// ChangeEmail sends confirmation to new address, email is changed by ConfirmEmailChange
+	ChangeEmail(ctx context.Context, userID string, email string) error
+	ConfirmEmailChange(ctx context.Context, confirmationToken string) (dto.UserResponseDto, error)
+	// BanUser forbids login and revokes all tokens of user
+	BanUser(ctx context.Context, userID string) error
+	UnbanUser(ctx context.Context, userID string) error
+	// UnlockUser lifts lock and forgets failed logins
+	UnlockUser(ctx context.Context, userID string) error
+}
+
+type RevocationService interface {
+	RevokeAccessToken(ctx context.Context, accessToken string) error
+	RevokeUserAccessTokens(ctx context.Context, userID string) error
+	// IsAccessTokenRevoked checks claims of already validated access token against in-process cache of revocations
+	IsAccessTokenRevoked(claims map[string]interface{}) bool
+	// RunRevocationSync loads revocations made by other instances every interval until ctx is done
+	RunRevocationSync(ctx context.Context, interval time.Duration)
+}
+
+// OutboxService dispatches events into outbox, they are published by relay
+type OutboxService interface {
+	EventDispatcher
+	// RunOutboxRelay publishes pending events every interval until ctx is done
+	RunOutboxRelay(ctx context.Context, interval time.Duration)
+}
+
+type AccountService interface {
+	// DeleteAccount requires current password or, for accounts without one, login moments ago,
+	// authenticatedAt is auth_time of access token, refresh keeps it
+	DeleteAccount(ctx context.Context, userID string, authenticatedAt time.Time, deleteAccountRequestDto dto.DeleteAccountRequestDto) (dto.AccountDeletionResponseDto, error)
+	// RunAccountPurge hard-deletes accounts with passed grace period every interval until ctx is done
+	RunAccountPurge(ctx context.Context, interval time.Duration)
+}
+
+type DataExportService interface {
+	// RequestDataExport starts generation of archive of personal data of user
+	RequestDataExport(ctx context.Context, userID string) (dto.DataExportResponseDto, error)
+	// GetDataExport returns export status and, once it is ready, download link that expires
+	GetDataExport(ctx context.Context, userID string, exportID string) (dto.DataExportResponseDto, error)
+	DownloadDataExport(ctx context.Context, downloadToken string) ([]byte, error)
+	// RunDataExportWorker generates pending exports every interval until ctx is done
+	RunDataExportWorker(ctx context.Context, interval time.Duration)
+}
+
+type SessionService interface {
+	GetSessions(ctx context.Context, refreshToken string) ([]dto.SessionResponseDto, error)
+	RevokeSession(ctx context.Context, refreshToken string, sessionID string) error
+	// RevokeOtherSessions logs out everywhere except the session of given refresh token
+	RevokeOtherSessions(ctx context.Context, refreshToken string) error
+}
+
+type MFAService interface {
+	EnrollTOTP(ctx context.Context, refreshToken string) (dto.TOTPEnrollResponseDto, error)
+	ConfirmTOTP(ctx context.Context, refreshToken string, code string) (recoveryCodes []string, err error)
+	DisableTOTP(ctx context.Context, refreshToken string, code string) error
+	RegenerateRecoveryCodes(ctx context.Context, refreshToken string, code string) (recoveryCodes []string, err error)
+}
+
+type PasskeyService interface {
+	BeginPasskeyRegistration(ctx context.Context, refreshToken string) (*protocol.CredentialCreation, error)
+	FinishPasskeyRegistration(ctx context.Context, refreshToken string, credentialCreationResponse []byte) error
+	BeginPasskeyLogin(ctx context.Context) (*protocol.CredentialAssertion, error)
+	FinishPasskeyLogin(ctx context.Context, credentialAssertionResponse []byte, session string, device dto.DeviceDto) (access string, refresh string, err error)
+}
+
+type OAuthService interface {
+	BeginOAuthLogin(provider string) (authURL string, stateToken string, err error)
+	FinishOAuthLogin(ctx context.Context, oauthCallbackDto dto.OAuthCallbackDto, stateToken string, session string, device dto.DeviceDto) (access string, refresh string, err error)
+}
+
+type OIDCService interface {
+	GetOpenIDConfiguration() dto.OpenIDConfigurationDto
+	GetJSONWebKeySet() jwt.JSONWebKeySet
+	// Authorize returns redirect to client with authorization code or with error which may be shown to client
+	Authorize(ctx context.Context, refreshToken string, authorizationRequestDto dto.AuthorizationRequestDto) (redirectURL string, err error)
+	Token(ctx context.Context, tokenRequestDto dto.TokenRequestDto) (dto.TokenResponseDto, error)
+	UserInfo(ctx context.Context, accessToken string) (dto.UserInfoResponseDto, error)
+	// Introspect tells confidential clients whether access or refresh token is active, as described in RFC 7662
+	Introspect(ctx context.Context, introspectionRequestDto dto.IntrospectionRequestDto) (dto.IntrospectionResponseDto, error)
+}
+
+type OAuthClientService interface {
+	CreateOAuthClient(ctx context.Context, createOAuthClientRequestDto dto.CreateOAuthClientRequestDto) (dto.OAuthClientResponseDto, error)
+	GetOAuthClients(ctx context.Context) ([]dto.OAuthClientResponseDto, error)
+	DeleteOAuthClient(ctx context.Context, clientID string) error
+}
+
+type SigningKeyService interface {
+	// LoadSigningKeys applies keys stored in database, configured keys stay in use until the first rotation
+	LoadSigningKeys(ctx context.Context) error
+	RotateSigningKeys(ctx context.Context) (dto.SigningKeyResponseDto, error)
+	GetSigningKeys(ctx context.Context) ([]dto.SigningKeyResponseDto, error)
+	// RunKeyRotation reloads keys every interval, retires expired ones and rotates active key when it gets too old, until ctx is done
+	RunKeyRotation(ctx context.Context, interval time.Duration)
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.39.1 --name=BreachedPasswordChecker
+type BreachedPasswordChecker interface {
+	// IsBreached looks password up in local copy of breach corpora, no external API is called
+	IsBreached(password string) bool
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.39.1 --name=IdentityProvider
+type IdentityProvider interface {
+	Name() string
+	AuthCodeURL(state string, codeVerifier string, nonce string) (string, error)
+	Exchange(ctx context.Context, code string, codeVerifier string, nonce string) (domain.ExternalProfile, error)
 }
 
 //go:generate go run github.com/vektra/mockery/v2@v2.39.1 --name=RefreshTokenRepository
 type RefreshTokenRepository interface {
-	GetRefreshToken(refreshToken string) (domain.RefreshToken, error)
-	CreateRefreshToken(refreshToken domain.RefreshToken) (string, error)
-	UpdateRefreshToken(oldRefreshToken, newRefreshToken string) (domain.RefreshToken, error)
-	DeleteRefreshToken(refreshToken string) error
+	// GetRefreshToken returns token which has not been rotated yet
+	GetRefreshToken(ctx context.Context, refreshToken string) (domain.RefreshToken, error)
+	GetRotatedRefreshToken(ctx context.Context, refreshToken string) (domain.RefreshToken, error)
+	// GetUserRefreshTokens returns current tokens of user sessions, one per family
+	GetUserRefreshTokens(ctx context.Context, userID string) ([]domain.RefreshToken, error)
+	// GetUserRotatedRefreshTokens returns tokens already exchanged by user sessions, oldest rotation first
+	GetUserRotatedRefreshTokens(ctx context.Context, userID string) ([]domain.RefreshToken, error)
+	CreateRefreshToken(ctx context.Context, refreshToken domain.RefreshToken) (string, error)
+	// RotateRefreshToken marks old token as rotated and creates the next generation of its family
+	// with token and device of newRefreshToken, fails if old token has already been rotated.
+	// Both writes belong together, so callers run it within transaction
+	RotateRefreshToken(ctx context.Context, oldRefreshToken string, newRefreshToken domain.RefreshToken) (domain.RefreshToken, error)
+	DeleteRefreshToken(ctx context.Context, refreshToken string) error
+	DeleteRefreshTokenFamily(ctx context.Context, family string) error
+	DeleteOtherUserRefreshTokens(ctx context.Context, userID string, exceptFamily string) error
+	DeleteUserRefreshTokens(ctx context.Context, userID string) error
 }
 
 //go:generate go run github.com/vektra/mockery/v2@v2.39.1 --name=UserRepository
 type UserRepository interface {
-	GetUserByID(ID string) (domain.User, error)
-	GetUserByNickname(nickname string) (domain.User, error)
-	GetUserByEmail(email string) (domain.User, error)
-	SaveUser(user domain.User) (domain.User, error)
+	GetUserByID(ctx context.Context, ID string) (domain.User, error)
+	GetUserByNickname(ctx context.Context, nickname string) (domain.User, error)
+	GetUserByEmail(ctx context.Context, email string) (domain.User, error)
+	SaveUser(ctx context.Context, user domain.User) (domain.User, error)
+	// ChangeNickname, ChangeEmail and the other setters update only their fields of user,
+	// so they do not overwrite fields updated atomically by concurrent requests
+	ChangeNickname(ctx context.Context, ID string, nickname string, changedAt time.Time) (domain.User, error)
+	// ChangeEmail sets confirmed email, so it is verified
+	ChangeEmail(ctx context.Context, ID string, email string) (domain.User, error)
+	SetEmailVerified(ctx context.Context, ID string) error
+	SetPassword(ctx context.Context, ID string, password string) error
+	SetBanned(ctx context.Context, ID string, banned bool) error
+	// MarkUserDeleted starts grace period of account deletion, RestoreUser cancels it
+	MarkUserDeleted(ctx context.Context, ID string, deletedAt time.Time) error
+	// SetTOTPSecret sets secret of pending enrollment, EnableTOTP completes it with recovery codes
+	SetTOTPSecret(ctx context.Context, ID string, encryptedSecret string) error
+	EnableTOTP(ctx context.Context, ID string, hashedRecoveryCodes []string) error
+	SetRecoveryCodes(ctx context.Context, ID string, hashedRecoveryCodes []string) error
+	GetUsersDeletedBefore(ctx context.Context, deletedBefore time.Time) ([]domain.User, error)
+	// RestoreUser cancels deletion of account during grace period
+	RestoreUser(ctx context.Context, ID string) error
+	// DeleteUser deletes user whose account deletion was requested before deletedBefore, fails if it was restored since
+	DeleteUser(ctx context.Context, ID string, deletedBefore time.Time) error
+	// RecordFailedLogin atomically counts failed login, failures made before forgetBefore are not counted
+	RecordFailedLogin(ctx context.Context, ID string, failedAt time.Time, forgetBefore time.Time) (domain.User, error)
+	// LockUser atomically locks account until lockedUntil and restarts failed logins counter,
+	// false is returned when account is already locked at lockedAt
+	LockUser(ctx context.Context, ID string, lockedAt time.Time, lockedUntil time.Time) (domain.User, bool, error)
+	// ResetFailedLogins atomically removes failed logins counter and lock of account
+	ResetFailedLogins(ctx context.Context, ID string) (domain.User, error)
+	// ConsumeRecoveryCode atomically removes hashed recovery code, false is returned when user has no such code
+	ConsumeRecoveryCode(ctx context.Context, ID string, hashedCode string) (bool, error)
+	// ConsumeTOTPStep atomically remembers time step of accepted code, false is returned when code of the step or a later one was accepted
+	ConsumeTOTPStep(ctx context.Context, ID string, step int64) (bool, error)
+	// SetMFATokenID replaces jti of mfa token which may finish login of user
+	SetMFATokenID(ctx context.Context, ID string, mfaTokenID string) error
+	// ConsumeMFATokenID atomically removes jti of mfa token, false is returned when the token is not the current one or was used
+	ConsumeMFATokenID(ctx context.Context, ID string, mfaTokenID string) (bool, error)
+	// DisableTOTP removes totp secret and recovery codes of user
+	DisableTOTP(ctx context.Context, ID string) error
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.39.1 --name=EmailVerificationTokenRepository
+type EmailVerificationTokenRepository interface {
+	GetEmailVerificationToken(ctx context.Context, token string) (domain.EmailVerificationToken, error)
+	CreateEmailVerificationToken(ctx context.Context, verificationToken domain.EmailVerificationToken) (string, error)
+	DeleteUserEmailVerificationTokens(ctx context.Context, userID string) error
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.39.1 --name=EmailChangeTokenRepository
+type EmailChangeTokenRepository interface {
+	// TakeEmailChangeToken returns token and deletes it, so every token changes email only once
+	TakeEmailChangeToken(ctx context.Context, token string) (domain.EmailChangeToken, error)
+	CreateEmailChangeToken(ctx context.Context, changeToken domain.EmailChangeToken) (string, error)
+	DeleteUserEmailChangeTokens(ctx context.Context, userID string) error
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.39.1 --name=PasswordResetTokenRepository
+type PasswordResetTokenRepository interface {
+	// TakePasswordResetToken returns token and deletes it, so every token resets password only once
+	TakePasswordResetToken(ctx context.Context, token string) (domain.PasswordResetToken, error)
+	CreatePasswordResetToken(ctx context.Context, resetToken domain.PasswordResetToken) (string, error)
+	DeleteUserPasswordResetTokens(ctx context.Context, userID string) error
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.39.1 --name=PasskeyCredentialRepository
+type PasskeyCredentialRepository interface {
+	GetPasskeyCredential(ctx context.Context, credentialID []byte) (domain.PasskeyCredential, error)
+	GetUserPasskeyCredentials(ctx context.Context, userID string) ([]domain.PasskeyCredential, error)
+	CreatePasskeyCredential(ctx context.Context, credential domain.PasskeyCredential) (string, error)
+	UpdatePasskeyCredential(ctx context.Context, credential domain.PasskeyCredential) (domain.PasskeyCredential, error)
+	DeleteUserPasskeyCredentials(ctx context.Context, userID string) error
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.39.1 --name=PasskeySessionRepository
+type PasskeySessionRepository interface {
+	CreatePasskeySession(ctx context.Context, session domain.PasskeySession) (string, error)
+	// TakePasskeySession returns session and deletes it, so every ceremony can be finished only once
+	TakePasskeySession(ctx context.Context, challenge string) (domain.PasskeySession, error)
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.39.1 --name=IdentityRepository
+type IdentityRepository interface {
+	GetIdentity(ctx context.Context, provider string, subject string) (domain.Identity, error)
+	GetUserIdentities(ctx context.Context, userID string) ([]domain.Identity, error)
+	CreateIdentity(ctx context.Context, identity domain.Identity) (string, error)
+	DeleteUserIdentities(ctx context.Context, userID string) error
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.39.1 --name=OAuthClientRepository
+type OAuthClientRepository interface {
+	GetOAuthClient(ctx context.Context, clientID string) (domain.OAuthClient, error)
+	GetOAuthClients(ctx context.Context) ([]domain.OAuthClient, error)
+	CreateOAuthClient(ctx context.Context, client domain.OAuthClient) (string, error)
+	DeleteOAuthClient(ctx context.Context, clientID string) error
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.39.1 --name=AuthorizationCodeRepository
+type AuthorizationCodeRepository interface {
+	CreateAuthorizationCode(ctx context.Context, authorizationCode domain.AuthorizationCode) (string, error)
+	// TakeAuthorizationCode returns code and deletes it, so every code can be exchanged only once
+	TakeAuthorizationCode(ctx context.Context, code string) (domain.AuthorizationCode, error)
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.39.1 --name=SigningKeyRepository
+type SigningKeyRepository interface {
+	GetSigningKeys(ctx context.Context) ([]domain.SigningKey, error)
+	CreateSigningKey(ctx context.Context, key domain.SigningKey) (string, error)
+	// ActivateSigningKey promotes key which has never been active, so only one of concurrent rotations succeeds
+	ActivateSigningKey(ctx context.Context, keyID string, activatedAt time.Time) error
+	// DeactivateSigningKeys moves active keys except given one to verify-only state until retireAt
+	DeactivateSigningKeys(ctx context.Context, exceptKeyID string, retireAt time.Time) error
+	// RetireSigningKeys retires verify-only keys with passed retirement time and erases their private parts
+	RetireSigningKeys(ctx context.Context, now time.Time) error
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.39.1 --name=RevokedAccessTokenRepository
+type RevokedAccessTokenRepository interface {
+	// GetRevokedAccessTokens returns revocations created after given time
+	GetRevokedAccessTokens(ctx context.Context, since time.Time) ([]domain.RevokedAccessToken, error)
+	CreateRevokedAccessToken(ctx context.Context, revokedToken domain.RevokedAccessToken) (string, error)
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.39.1 --name=RateLimitStore
+type RateLimitStore interface {
+	// Take takes token from bucket of key, positive retryAfter means bucket is empty
+	Take(ctx context.Context, key string, policy ratelimit.Policy) (retryAfter time.Duration, err error)
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.39.1 --name=DataExportRepository
+type DataExportRepository interface {
+	GetDataExport(ctx context.Context, ID string) (domain.DataExport, error)
+	CreateDataExport(ctx context.Context, dataExport domain.DataExport) (string, error)
+	UpdateDataExport(ctx context.Context, dataExport domain.DataExport) (domain.DataExport, error)
+	// ClaimPendingDataExport atomically claims the oldest pending export not claimed by another worker
+	// until claimedUntil, false is returned when there is none
+	ClaimPendingDataExport(ctx context.Context, claimedUntil time.Time) (domain.DataExport, bool, error)
+	DeleteUserDataExports(ctx context.Context, userID string) error
 }
 
 const (
-	AuthExchange = "auth-exchange"
+	AuthExchange              = "auth-exchange"
+	EmailVerificationExchange = "email-verification-exchange"
+	PasswordResetExchange     = "password-reset-exchange"
+	TokenReuseExchange        = "token-reuse-exchange"
+	EmailChangeExchange       = "email-change-exchange"
+	UserUpdatedExchange       = "user-updated-exchange"
+	UserDeletedExchange       = "user-deleted-exchange"
+	AccountLockedExchange     = "account-locked-exchange"
 )
 
-var Exchanges = []string{AuthExchange}
+var Exchanges = []string{AuthExchange, EmailVerificationExchange, PasswordResetExchange, TokenReuseExchange, EmailChangeExchange, UserUpdatedExchange, UserDeletedExchange, AccountLockedExchange}
+
+// OptionalExchanges carry notifications nobody may subscribe to, their events are dropped when no queue is bound,
+// events of other exchanges are retried until queue is bound, since mail and deletion of user data depend on them
+var OptionalExchanges = []string{TokenReuseExchange, UserUpdatedExchange, AccountLockedExchange}
 
 //go:generate go run github.com/vektra/mockery/v2@v2.39.1 --name=EventDispatcher
 type EventDispatcher interface {
-	Dispatch(event domain.Event)
+	// Dispatch saves event within transaction of ctx, if there is one, so it is not lost when state change is committed
+	Dispatch(ctx context.Context, event domain.Event) error
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.39.1 --name=EventPublisher
+type EventPublisher interface {
+	Publish(ctx context.Context, event domain.Event) error
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.39.1 --name=OutboxRepository
+type OutboxRepository interface {
+	CreateOutboxEvent(ctx context.Context, event domain.OutboxEvent) (string, error)
+	// GetDueOutboxEvents returns up to limit events which are the oldest undelivered and not failed events
+	// of their exchange and key and whose next attempt is due at now, events never attempted come first
+	GetDueOutboxEvents(ctx context.Context, now time.Time, limit int) ([]domain.OutboxEvent, error)
+	UpdateOutboxEvent(ctx context.Context, event domain.OutboxEvent) (domain.OutboxEvent, error)
+	// AcquireOutboxLease extends lease held by owner or takes expired one, false is returned when another owner holds it
+	AcquireOutboxLease(ctx context.Context, owner string, until time.Time) (bool, error)
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.39.1 --name=Transactor
+type Transactor interface {
+	// WithinTransaction commits repository calls made by fn with its ctx together, nothing is committed when fn fails
+	WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error
 }
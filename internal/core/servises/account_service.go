@@ -0,0 +1,159 @@
+package servises
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports/dto"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/logging"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/password"
+	"time"
+)
+
+// reauthenticationWindow is how recent login must be to delete account without password
+const reauthenticationWindow = 5 * time.Minute
+
+type AccountService struct {
+	gracePeriod           time.Duration
+	userRepo              ports.UserRepository
+	tokenRepo             ports.RefreshTokenRepository
+	identityRepo          ports.IdentityRepository
+	credentialRepo        ports.PasskeyCredentialRepository
+	dataExportRepo        ports.DataExportRepository
+	verificationTokenRepo ports.EmailVerificationTokenRepository
+	changeTokenRepo       ports.EmailChangeTokenRepository
+	resetTokenRepo        ports.PasswordResetTokenRepository
+	revocationService     ports.RevocationService
+	transactor            ports.Transactor
+	eventDispatcher       ports.EventDispatcher
+	log                   logging.Logger
+}
+
+func NewAccountService(gracePeriod time.Duration, userRepo ports.UserRepository, tokenRepo ports.RefreshTokenRepository, identityRepo ports.IdentityRepository, credentialRepo ports.PasskeyCredentialRepository, dataExportRepo ports.DataExportRepository, verificationTokenRepo ports.EmailVerificationTokenRepository, changeTokenRepo ports.EmailChangeTokenRepository, resetTokenRepo ports.PasswordResetTokenRepository, revocationService ports.RevocationService, transactor ports.Transactor, eventDispatcher ports.EventDispatcher, log logging.Logger) ports.AccountService {
+	return &AccountService{
+		gracePeriod:           gracePeriod,
+		userRepo:              userRepo,
+		tokenRepo:             tokenRepo,
+		identityRepo:          identityRepo,
+		credentialRepo:        credentialRepo,
+		dataExportRepo:        dataExportRepo,
+		verificationTokenRepo: verificationTokenRepo,
+		changeTokenRepo:       changeTokenRepo,
+		resetTokenRepo:        resetTokenRepo,
+		revocationService:     revocationService,
+		transactor:            transactor,
+		eventDispatcher:       eventDispatcher,
+		log:                   log,
+	}
+}
+
+func (s *AccountService) DeleteAccount(ctx context.Context, userID string, authenticatedAt time.Time, deleteAccountRequestDto dto.DeleteAccountRequestDto) (dto.AccountDeletionResponseDto, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return dto.AccountDeletionResponseDto{}, fmt.Errorf("user not found: %w", ports.NotFoundError)
+	}
+	if user.Password != "" {
+		err = password.VerifyPassword(user.Password, deleteAccountRequestDto.Password)
+		if err != nil {
+			return dto.AccountDeletionResponseDto{}, fmt.Errorf("password does not match: %w", ports.BadRequestError)
+		}
+	} else if time.Since(authenticatedAt) > reauthenticationWindow {
+		return dto.AccountDeletionResponseDto{}, fmt.Errorf("login again to delete account: %w", ports.UnauthorizedError)
+	}
+
+	if user.DeletedAt.IsZero() {
+		user.DeletedAt = time.Now()
+		err = s.userRepo.MarkUserDeleted(ctx, userID, user.DeletedAt)
+		if err != nil {
+			s.log.Warnf("user not updated due to error: %v", err)
+			return dto.AccountDeletionResponseDto{}, fmt.Errorf(`updating user error: %w`, ports.InternalServerError)
+		}
+	}
+
+	err = s.tokenRepo.DeleteUserRefreshTokens(ctx, userID)
+	if err != nil {
+		s.log.Warnf("refresh tokens delete error: %v", err)
+	}
+	err = s.revocationService.RevokeUserAccessTokens(ctx, userID)
+	if err != nil {
+		s.log.Warnf("access tokens revoke error: %v", err)
+	}
+	return dto.AccountDeletionResponseDto{
+		PurgeAt: user.DeletedAt.Add(s.gracePeriod),
+	}, nil
+}
+
+func (s *AccountService) RunAccountPurge(ctx context.Context, interval time.Duration) {
+	for {
+		s.purgeDeletedAccounts(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+func (s *AccountService) purgeDeletedAccounts(ctx context.Context, now time.Time) {
+	deletedBefore := now.Add(-s.gracePeriod)
+	users, err := s.userRepo.GetUsersDeletedBefore(ctx, deletedBefore)
+	if err != nil {
+		s.log.Warnf("deleted users not loaded due to error: %v", err)
+		return
+	}
+	for _, user := range users {
+		err = s.purgeAccount(ctx, user, deletedBefore)
+		if err != nil {
+			s.log.Errorf("account '%s' not purged due to error: %v", user.ID.Hex(), err)
+		}
+	}
+}
+
+// purgeAccount removes everything stored about user, user is deleted first and only if it is still deleted before
+// deletedBefore, so account restored by login after it was loaded is kept and no event is sent about it.
+// Event is saved in the same transaction, so account is not purged without consumers being told
+func (s *AccountService) purgeAccount(ctx context.Context, user domain.User, deletedBefore time.Time) error {
+	userID := user.ID.Hex()
+	body, err := json.Marshal(
+		map[string]interface{}{
+			"userID": userID,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf(`error marshaling event body: %w`, ports.InternalServerError)
+	}
+
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		err := s.userRepo.DeleteUser(ctx, userID, deletedBefore)
+		if err != nil {
+			return err
+		}
+		deletions := []func(ctx context.Context, userID string) error{
+			s.tokenRepo.DeleteUserRefreshTokens,
+			s.identityRepo.DeleteUserIdentities,
+			s.credentialRepo.DeleteUserPasskeyCredentials,
+			s.dataExportRepo.DeleteUserDataExports,
+			s.verificationTokenRepo.DeleteUserEmailVerificationTokens,
+			s.changeTokenRepo.DeleteUserEmailChangeTokens,
+			s.resetTokenRepo.DeleteUserPasswordResetTokens,
+		}
+		for _, deletion := range deletions {
+			err := deletion(ctx, userID)
+			if err != nil {
+				return err
+			}
+		}
+		return s.eventDispatcher.Dispatch(ctx, domain.Event{
+			Exchange: ports.UserDeletedExchange,
+			Key:      userID,
+			Body:     body,
+		})
+	})
+	if err != nil {
+		return err
+	}
+	s.log.Infof("account '%s' purged", userID)
+	return nil
+}
@@ -0,0 +1,214 @@
+package servises
+
+import (
+	"context"
+	"fmt"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports/dto"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports/mocks"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/logging/nop"
+	. "github.com/ttodoshi/code-typing-auth-service/pkg/password"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"testing"
+	"time"
+)
+
+func TestDeleteAccount(t *testing.T) {
+	var log = nop.GetLogger()
+	jwtIssuer := newIssuer()
+	gracePeriod := 24 * time.Hour
+	// mocks
+	userRepo := new(mocks.UserRepository)
+	tokenRepo := new(mocks.RefreshTokenRepository)
+	identityRepo := new(mocks.IdentityRepository)
+	credentialRepo := new(mocks.PasskeyCredentialRepository)
+	dataExportRepo := new(mocks.DataExportRepository)
+	verificationTokenRepo := new(mocks.EmailVerificationTokenRepository)
+	changeTokenRepo := new(mocks.EmailChangeTokenRepository)
+	resetTokenRepo := new(mocks.PasswordResetTokenRepository)
+	revokedTokenRepo := new(mocks.RevokedAccessTokenRepository)
+	eventDispatcher := new(mocks.EventDispatcher)
+
+	password := gofakeit.Password(true, true, true, true, false, 8)
+	hashPassword, err := HashPassword(password)
+	assert.NoError(t, err)
+	user := domain.User{
+		Nickname: gofakeit.Username(),
+		Email:    gofakeit.Email(),
+		Password: hashPassword,
+	}
+	user.ID = primitive.NewObjectID()
+	passwordlessUser := domain.User{
+		Nickname: gofakeit.Username(),
+	}
+	passwordlessUser.ID = primitive.NewObjectID()
+
+	// in-memory storage behind repository mock
+	users := map[string]domain.User{
+		user.ID.Hex():             user,
+		passwordlessUser.ID.Hex(): passwordlessUser,
+	}
+
+	userRepo.
+		On("GetUserByID", mock.Anything, mock.AnythingOfType("string")).
+		Return(func(_ context.Context, ID string) (domain.User, error) {
+			u, ok := users[ID]
+			if !ok {
+				return u, fmt.Errorf("")
+			}
+			return u, nil
+		})
+	userRepo.
+		On("GetUserByNickname", mock.Anything, user.Nickname).
+		Return(func(_ context.Context, nickname string) (domain.User, error) {
+			return users[user.ID.Hex()], nil
+		})
+	userRepo.
+		On("MarkUserDeleted", mock.Anything, mock.AnythingOfType("string"), mock.AnythingOfType("time.Time")).
+		Return(func(_ context.Context, ID string, deletedAt time.Time) error {
+			u := users[ID]
+			u.DeletedAt = deletedAt
+			users[ID] = u
+			return nil
+		})
+	userRepo.
+		On("RestoreUser", mock.Anything, mock.AnythingOfType("string")).
+		Return(func(_ context.Context, ID string) error {
+			u := users[ID]
+			u.DeletedAt = time.Time{}
+			users[ID] = u
+			return nil
+		})
+	userRepo.
+		On("GetUsersDeletedBefore", mock.Anything, mock.AnythingOfType("time.Time")).
+		Return(func(_ context.Context, deletedBefore time.Time) ([]domain.User, error) {
+			var deletedUsers []domain.User
+			for _, u := range users {
+				if !u.DeletedAt.IsZero() && !u.DeletedAt.After(deletedBefore) {
+					deletedUsers = append(deletedUsers, u)
+				}
+			}
+			return deletedUsers, nil
+		})
+	userRepo.
+		On("DeleteUser", mock.Anything, mock.AnythingOfType("string"), mock.AnythingOfType("time.Time")).
+		Return(func(_ context.Context, ID string, deletedBefore time.Time) error {
+			u, ok := users[ID]
+			if !ok || u.DeletedAt.IsZero() || u.DeletedAt.After(deletedBefore) {
+				return fmt.Errorf("")
+			}
+			delete(users, ID)
+			return nil
+		})
+	tokenRepo.
+		On("DeleteUserRefreshTokens", mock.Anything, mock.AnythingOfType("string")).
+		Return(nil)
+	tokenRepo.
+		On("CreateRefreshToken", mock.Anything, mock.Anything).
+		Return(gofakeit.UUID(), nil)
+	identityRepo.
+		On("DeleteUserIdentities", mock.Anything, mock.AnythingOfType("string")).
+		Return(nil)
+	credentialRepo.
+		On("DeleteUserPasskeyCredentials", mock.Anything, mock.AnythingOfType("string")).
+		Return(nil)
+	dataExportRepo.
+		On("DeleteUserDataExports", mock.Anything, mock.AnythingOfType("string")).
+		Return(nil)
+	verificationTokenRepo.
+		On("DeleteUserEmailVerificationTokens", mock.Anything, mock.AnythingOfType("string")).
+		Return(nil)
+	changeTokenRepo.
+		On("DeleteUserEmailChangeTokens", mock.Anything, mock.AnythingOfType("string")).
+		Return(nil)
+	resetTokenRepo.
+		On("DeleteUserPasswordResetTokens", mock.Anything, mock.AnythingOfType("string")).
+		Return(nil)
+	revokedTokenRepo.
+		On("CreateRevokedAccessToken", mock.Anything, mock.AnythingOfType("domain.RevokedAccessToken")).
+		Return(primitive.NewObjectID().Hex(), nil)
+	eventDispatcher.
+		On("Dispatch", mock.Anything, mock.MatchedBy(func(event domain.Event) bool {
+			return event.Exchange == ports.UserDeletedExchange
+		})).
+		Return(nil).
+		Once()
+	eventDispatcher.
+		On("Dispatch", mock.Anything, mock.MatchedBy(func(event domain.Event) bool {
+			return event.Exchange == ports.UserDeletedExchange
+		})).
+		Return(fmt.Errorf("outbox is not available"))
+
+	// service
+	revocationService := NewRevocationService(jwtIssuer, revokedTokenRepo, log)
+	accountService := NewAccountService(
+		gracePeriod,
+		userRepo, tokenRepo, identityRepo, credentialRepo,
+		dataExportRepo, verificationTokenRepo, changeTokenRepo, resetTokenRepo,
+		revocationService, newTransactor(), eventDispatcher,
+		log,
+	).(*AccountService)
+	authService := NewAuthService(LockoutPolicy{}, jwtIssuer, newHasher(), newCipher(), userRepo, tokenRepo, nil, nil, nil, revocationService, newTransactor(), eventDispatcher, log)
+
+	t.Run("unsuccessful deletion due to wrong password", func(t *testing.T) {
+		_, err = accountService.DeleteAccount(context.Background(), user.ID.Hex(), time.Now(), dto.DeleteAccountRequestDto{Password: "wrong_password"})
+		assert.Error(t, err)
+		assert.True(t, users[user.ID.Hex()].DeletedAt.IsZero())
+	})
+	t.Run("unsuccessful deletion of passwordless account due to old access token", func(t *testing.T) {
+		_, err = accountService.DeleteAccount(context.Background(), passwordlessUser.ID.Hex(), time.Now().Add(-time.Hour), dto.DeleteAccountRequestDto{})
+		assert.Error(t, err)
+		assert.True(t, users[passwordlessUser.ID.Hex()].DeletedAt.IsZero())
+	})
+	t.Run("successful deletion and restoration by login", func(t *testing.T) {
+		accountDeletionResponseDto, err := accountService.DeleteAccount(context.Background(), user.ID.Hex(), time.Now(), dto.DeleteAccountRequestDto{Password: password})
+		assert.NoError(t, err)
+		assert.WithinDuration(t, time.Now().Add(gracePeriod), accountDeletionResponseDto.PurgeAt, time.Minute)
+
+		_, _, _, err = authService.Login(context.Background(), dto.LoginRequestDto{Login: user.Nickname, Password: password}, "", dto.DeviceDto{})
+		assert.NoError(t, err)
+		assert.True(t, users[user.ID.Hex()].DeletedAt.IsZero())
+	})
+	t.Run("successful purge after grace period", func(t *testing.T) {
+		_, err = accountService.DeleteAccount(context.Background(), user.ID.Hex(), time.Now(), dto.DeleteAccountRequestDto{Password: password})
+		assert.NoError(t, err)
+
+		accountService.purgeDeletedAccounts(context.Background(), time.Now())
+		assert.Contains(t, users, user.ID.Hex())
+
+		accountService.purgeDeletedAccounts(context.Background(), time.Now().Add(gracePeriod))
+		assert.NotContains(t, users, user.ID.Hex())
+		assert.Contains(t, users, passwordlessUser.ID.Hex())
+	})
+	t.Run("unsuccessful purge due to account restored after it was loaded", func(t *testing.T) {
+		deletedUser := passwordlessUser
+		deletedUser.DeletedAt = time.Now().Add(-2 * gracePeriod)
+
+		err := accountService.purgeAccount(context.Background(), deletedUser, time.Now().Add(-gracePeriod))
+		assert.Error(t, err)
+		assert.Contains(t, users, passwordlessUser.ID.Hex())
+		eventDispatcher.AssertNumberOfCalls(t, "Dispatch", 1)
+	})
+	t.Run("unsuccessful purge due to event not saved", func(t *testing.T) {
+		deletedUser := passwordlessUser
+		deletedUser.DeletedAt = time.Now().Add(-2 * gracePeriod)
+		users[deletedUser.ID.Hex()] = deletedUser
+
+		err := accountService.purgeAccount(context.Background(), deletedUser, time.Now().Add(-gracePeriod))
+		assert.Error(t, err)
+	})
+	userRepo.AssertExpectations(t)
+	tokenRepo.AssertExpectations(t)
+	identityRepo.AssertExpectations(t)
+	credentialRepo.AssertExpectations(t)
+	dataExportRepo.AssertExpectations(t)
+	verificationTokenRepo.AssertExpectations(t)
+	changeTokenRepo.AssertExpectations(t)
+	resetTokenRepo.AssertExpectations(t)
+	revokedTokenRepo.AssertExpectations(t)
+	eventDispatcher.AssertExpectations(t)
+}
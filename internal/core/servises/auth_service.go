@@ -1,83 +1,98 @@
 package servises
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/jinzhu/copier"
 	"github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
 	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
 	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports/dto"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/digest"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/encryption"
 	"github.com/ttodoshi/code-typing-auth-service/pkg/jwt"
 	"github.com/ttodoshi/code-typing-auth-service/pkg/logging"
 	"github.com/ttodoshi/code-typing-auth-service/pkg/password"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+const (
+	emailVerificationPurpose = "email_verification"
+	passwordResetPurpose     = "password_reset"
 )
 
 type AuthService struct {
-	userRepo        ports.UserRepository
-	tokenRepo       ports.RefreshTokenRepository
-	eventDispatcher ports.EventDispatcher
-	log             logging.Logger
+	lockoutPolicy         LockoutPolicy
+	hasher                password.Hasher
+	cipher                *encryption.Cipher
+	userRepo              ports.UserRepository
+	verificationTokenRepo ports.EmailVerificationTokenRepository
+	resetTokenRepo        ports.PasswordResetTokenRepository
+	breachedPasswords     ports.BreachedPasswordChecker
+	revocationService     ports.RevocationService
+	*sessionIssuer
 }
 
-func NewAuthService(userRepo ports.UserRepository, tokenRepo ports.RefreshTokenRepository, resultsMigrator ports.EventDispatcher, log logging.Logger) ports.AuthService {
+func NewAuthService(lockoutPolicy LockoutPolicy, jwtIssuer *jwt.Issuer, hasher password.Hasher, cipher *encryption.Cipher, userRepo ports.UserRepository, tokenRepo ports.RefreshTokenRepository, verificationTokenRepo ports.EmailVerificationTokenRepository, resetTokenRepo ports.PasswordResetTokenRepository, breachedPasswords ports.BreachedPasswordChecker, revocationService ports.RevocationService, transactor ports.Transactor, resultsMigrator ports.EventDispatcher, log logging.Logger) ports.AuthService {
 	return &AuthService{
-		userRepo:        userRepo,
-		tokenRepo:       tokenRepo,
-		eventDispatcher: resultsMigrator,
-		log:             log,
+		lockoutPolicy:         lockoutPolicy,
+		hasher:                hasher,
+		cipher:                cipher,
+		userRepo:              userRepo,
+		verificationTokenRepo: verificationTokenRepo,
+		resetTokenRepo:        resetTokenRepo,
+		breachedPasswords:     breachedPasswords,
+		revocationService:     revocationService,
+		sessionIssuer:         newSessionIssuer(jwtIssuer, userRepo, tokenRepo, transactor, resultsMigrator, log),
 	}
 }
 
-func (s *AuthService) Register(registerRequestDto dto.RegisterRequestDto, session string) (access string, refresh string, err error) {
+func (s *AuthService) Register(ctx context.Context, registerRequestDto dto.RegisterRequestDto, session string, device dto.DeviceDto) (access string, refresh string, err error) {
 	var user domain.User
 
-	registerRequestDto.Password, err = password.HashPassword(registerRequestDto.Password)
+	err = checkBreachedPassword(s.breachedPasswords, registerRequestDto.Password)
 	if err != nil {
 		return
 	}
 
-	err = copier.Copy(&user, &registerRequestDto)
+	registerRequestDto.Password, err = s.hasher.Hash(registerRequestDto.Password)
 	if err != nil {
-		err = fmt.Errorf(`struct mapping error: %w`, ports.InternalServerError)
 		return
 	}
 
-	user, err = s.saveUser(user)
+	err = copier.Copy(&user, &registerRequestDto)
 	if err != nil {
+		err = fmt.Errorf(`struct mapping error: %w`, ports.InternalServerError)
 		return
 	}
 
-	access, refresh, err = s.generateTokens(user)
+	user, err = s.saveUser(ctx, user)
 	if err != nil {
 		return
 	}
 
-	_, err = s.tokenRepo.CreateRefreshToken(domain.RefreshToken{
-		User:  user.ID,
-		Token: refresh,
-	})
-	if err != nil {
-		err = fmt.Errorf(`creating refresh token error: %w`, ports.InternalServerError)
-		return
+	if verificationErr := s.sendVerificationEmail(ctx, user); verificationErr != nil {
+		s.log.Warnf("verification email not sent due to error: %v", verificationErr)
 	}
-	s.dispatchAuthEvent(session, user)
-	return
+
+	return s.createSession(ctx, user, session, device)
 }
 
-func (s *AuthService) saveUser(user domain.User) (domain.User, error) {
+func (s *AuthService) saveUser(ctx context.Context, user domain.User) (domain.User, error) {
 	var err error
-	_, err = s.userRepo.GetUserByNickname(user.Nickname)
+	_, err = s.userRepo.GetUserByNickname(ctx, user.Nickname)
 	if err == nil {
 		err = fmt.Errorf("nickname already picked: %w", ports.BadRequestError)
 		return domain.User{}, err
 	}
-	_, err = s.userRepo.GetUserByEmail(user.Email)
+	_, err = s.userRepo.GetUserByEmail(ctx, user.Email)
 	if err == nil {
 		err = fmt.Errorf("account with this email already exists: %w", ports.BadRequestError)
 		return domain.User{}, err
 	}
 
-	user, err = s.userRepo.SaveUser(user)
+	user, err = s.userRepo.SaveUser(ctx, user)
 	if err != nil {
 		s.log.Warnf("user not saved due to error: %v", err)
 		err = fmt.Errorf(`saving user error: %w`, ports.InternalServerError)
@@ -86,106 +101,397 @@ func (s *AuthService) saveUser(user domain.User) (domain.User, error) {
 	return user, nil
 }
 
-func (s *AuthService) Login(loginRequestDto dto.LoginRequestDto, session string) (access string, refresh string, err error) {
+func (s *AuthService) Login(ctx context.Context, loginRequestDto dto.LoginRequestDto, session string, device dto.DeviceDto) (access string, refresh string, mfaToken string, err error) {
 	var user domain.User
-	user, err = s.userRepo.GetUserByNickname(loginRequestDto.Login)
+	user, err = s.userRepo.GetUserByNickname(ctx, loginRequestDto.Login)
 	if err != nil {
-		user, err = s.userRepo.GetUserByEmail(loginRequestDto.Login)
+		user, err = s.userRepo.GetUserByEmail(ctx, loginRequestDto.Login)
 		if err != nil {
 			err = fmt.Errorf("user not found: %w", ports.BadRequestError)
 			return
 		}
 	}
 
+	now := time.Now()
+	err = s.checkLockout(user, now)
+	if err != nil {
+		return
+	}
+
 	err = password.VerifyPassword(user.Password, loginRequestDto.Password)
 	if err != nil {
-		return access, refresh, fmt.Errorf(
+		s.recordFailedLogin(ctx, user, now)
+		return access, refresh, mfaToken, fmt.Errorf(
 			"login or password do not match: %w", ports.BadRequestError,
 		)
 	}
+	user = s.rehashPassword(ctx, user, loginRequestDto.Password)
 
-	access, refresh, err = s.generateTokens(user)
-	if err != nil {
+	// failed logins are kept until the second factor is verified, so password does not reset count of wrong codes
+	if user.TOTPEnabled {
+		mfaToken, err = s.generateMFAToken(ctx, user)
 		return
 	}
 
-	_, err = s.tokenRepo.CreateRefreshToken(domain.RefreshToken{
-		User:  user.ID,
-		Token: refresh,
-	})
+	user = s.resetFailedLogins(ctx, user)
+	access, refresh, err = s.createSession(ctx, user, session, device)
+	return
+}
+
+// generateMFAToken issues token finishing login with the second factor, only the last issued token is accepted and only once
+func (s *AuthService) generateMFAToken(ctx context.Context, user domain.User) (string, error) {
+	mfaTokenID := primitive.NewObjectID().Hex()
+	mfaToken, err := s.jwtIssuer.GenerateMFAJWT(
+		user.ID.Hex(),
+		jwt.Claim{
+			Name:  "purpose",
+			Value: mfaPurpose,
+		},
+		jwt.Claim{
+			Name:  "jti",
+			Value: mfaTokenID,
+		},
+	)
 	if err != nil {
-		err = fmt.Errorf(`creating refresh token error: %w`, ports.InternalServerError)
+		return "", fmt.Errorf(`generating mfa token error: %w`, ports.InternalServerError)
 	}
-	s.dispatchAuthEvent(session, user)
-	return
+	err = s.userRepo.SetMFATokenID(ctx, user.ID.Hex(), mfaTokenID)
+	if err != nil {
+		s.log.Warnf("mfa token not saved due to error: %v", err)
+		return "", fmt.Errorf(`saving mfa token error: %w`, ports.InternalServerError)
+	}
+	return mfaToken, nil
 }
 
-func (s *AuthService) dispatchAuthEvent(session string, user domain.User) {
-	if session == "" {
+func (s *AuthService) LoginMFA(ctx context.Context, mfaLoginRequestDto dto.MFALoginRequestDto, session string, device dto.DeviceDto) (access string, refresh string, err error) {
+	claims, err := s.jwtIssuer.ParseJWT(mfaLoginRequestDto.MFAToken)
+	if err != nil || claims["purpose"] != mfaPurpose {
+		err = fmt.Errorf("invalid mfa token: %w", ports.UnauthorizedError)
 		return
 	}
 
-	body, err := json.Marshal(
-		map[string]interface{}{
-			"session": session,
-			"userID":  user.ID.Hex(),
-		},
-	)
+	sub, _ := claims["sub"].(string)
+	mfaTokenID, _ := claims["jti"].(string)
+	user, err := s.userRepo.GetUserByID(ctx, sub)
+	if err != nil || !user.TOTPEnabled || mfaTokenID == "" || user.MFATokenID != mfaTokenID {
+		err = fmt.Errorf("invalid mfa token: %w", ports.UnauthorizedError)
+		return
+	}
+
+	// wrong codes count as failed logins, so second factor is not guessed faster than password
+	now := time.Now()
+	err = s.checkLockout(user, now)
+	if err != nil {
+		return
+	}
+	ok, err := verifySecondFactor(ctx, s.cipher, s.userRepo, user, mfaLoginRequestDto.Code, s.log)
+	if err != nil {
+		return
+	}
+	if !ok {
+		s.recordFailedLogin(ctx, user, now)
+		err = fmt.Errorf("invalid two-factor code: %w", ports.BadRequestError)
+		return
+	}
+
+	consumed, err := s.userRepo.ConsumeMFATokenID(ctx, user.ID.Hex(), mfaTokenID)
 	if err != nil {
-		s.log.Warnf(`error marshaling event body: %v`, err)
+		s.log.Warnf("mfa token not consumed due to error: %v", err)
+		err = fmt.Errorf(`consuming mfa token error: %w`, ports.InternalServerError)
 		return
 	}
+	if !consumed {
+		err = fmt.Errorf("invalid mfa token: %w", ports.UnauthorizedError)
+		return
+	}
+	user = s.resetFailedLogins(ctx, user)
 
-	s.eventDispatcher.Dispatch(domain.Event{
-		Exchange: ports.AuthExchange,
-		Body:     body,
-	})
+	return s.createSession(ctx, user, session, device)
 }
 
-func (s *AuthService) Refresh(oldRefreshToken string) (access string, refresh string, err error) {
-	token, err := s.tokenRepo.GetRefreshToken(oldRefreshToken)
+func (s *AuthService) Refresh(ctx context.Context, oldRefreshToken string, device dto.DeviceDto) (access string, refresh string, err error) {
+	token, err := s.tokenRepo.GetRefreshToken(ctx, oldRefreshToken)
 	if err != nil {
+		s.revokeReusedToken(ctx, oldRefreshToken)
 		err = fmt.Errorf("refresh token not found: %w", ports.UnauthorizedError)
 		return
 	}
 
-	user, _ := s.userRepo.GetUserByID(token.User.Hex())
+	user, _ := s.userRepo.GetUserByID(ctx, token.User.Hex())
+	if user.Banned {
+		err = fmt.Errorf("user is banned: %w", ports.ForbiddenError)
+		return
+	}
 
-	access, refresh, err = s.generateTokens(user)
+	access, refresh, err = s.generateTokens(user, sessionID(token), token.Generation+1, firstNonZero(token.StartedAt, token.CreatedAt))
 	if err != nil {
 		return
 	}
 
-	_, err = s.tokenRepo.UpdateRefreshToken(token.Token, refresh)
+	// old token is not marked rotated unless the next generation is stored
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		_, err := s.tokenRepo.RotateRefreshToken(ctx, token.Token, domain.RefreshToken{
+			Token:      refresh,
+			UserAgent:  device.UserAgent,
+			IP:         device.IP,
+			LastUsedAt: time.Now(),
+		})
+		return err
+	})
 	if err != nil {
+		// token has been rotated by concurrent request with the same token
+		if s.revokeReusedToken(ctx, token.Token) {
+			err = fmt.Errorf("refresh token not found: %w", ports.UnauthorizedError)
+			return
+		}
 		err = fmt.Errorf(`updating refresh token error: %w`, ports.InternalServerError)
 	}
 	return
 }
 
-func (s *AuthService) generateTokens(user domain.User) (accessToken string, refreshToken string, err error) {
-	accessToken, err = jwt.GenerateAccessJWT(
+func (s *AuthService) Logout(ctx context.Context, refreshToken string, accessToken string) {
+	err := s.tokenRepo.DeleteRefreshToken(ctx, refreshToken)
+	if err != nil {
+		s.log.Warnf("refresh token delete error: %v", err)
+	}
+	if accessToken == "" {
+		return
+	}
+	err = s.revocationService.RevokeAccessToken(ctx, accessToken)
+	if err != nil {
+		s.log.Warnf("access token revoke error: %v", err)
+	}
+}
+
+func (s *AuthService) VerifyEmail(ctx context.Context, verificationToken string) error {
+	claims, err := s.jwtIssuer.ParseJWT(verificationToken)
+	if err != nil || claims["purpose"] != emailVerificationPurpose {
+		return fmt.Errorf("invalid verification token: %w", ports.BadRequestError)
+	}
+
+	token, err := s.verificationTokenRepo.GetEmailVerificationToken(
+		ctx,
+		digest.SHA256(verificationToken),
+	)
+	if err != nil || token.User.Hex() != claims["sub"] {
+		return fmt.Errorf("verification token not found: %w", ports.BadRequestError)
+	}
+
+	err = s.verificationTokenRepo.DeleteUserEmailVerificationTokens(ctx, token.User.Hex())
+	if err != nil {
+		s.log.Warnf("email verification tokens delete error: %v", err)
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, token.User.Hex())
+	if err != nil {
+		return fmt.Errorf("user not found: %w", ports.NotFoundError)
+	}
+	if user.Email != token.Email {
+		return fmt.Errorf("verification token was issued for another email: %w", ports.BadRequestError)
+	}
+	if user.EmailVerified {
+		return nil
+	}
+
+	err = s.userRepo.SetEmailVerified(ctx, user.ID.Hex())
+	if err != nil {
+		s.log.Warnf("user not updated due to error: %v", err)
+		return fmt.Errorf(`updating user error: %w`, ports.InternalServerError)
+	}
+	return nil
+}
+
+// ResendVerificationEmail succeeds for any email, response must not reveal whether account exists or is verified
+func (s *AuthService) ResendVerificationEmail(ctx context.Context, email string) error {
+	user, err := s.userRepo.GetUserByEmail(ctx, email)
+	if err != nil {
+		s.log.Debugf("verification email requested for unknown email: %v", err)
+		return nil
+	}
+	if user.EmailVerified {
+		s.log.Debugf("verification email requested for already verified user '%s'", user.ID.Hex())
+		return nil
+	}
+	err = s.sendVerificationEmail(ctx, user)
+	if err != nil {
+		s.log.Warnf("verification email not resent to user '%s' due to error: %v", user.ID.Hex(), err)
+	}
+	return nil
+}
+
+func (s *AuthService) sendVerificationEmail(ctx context.Context, user domain.User) error {
+	verificationToken, err := s.jwtIssuer.GenerateEmailVerificationJWT(
 		user.ID.Hex(),
 		jwt.Claim{
-			Name:  "nickname",
-			Value: user.Nickname,
+			Name:  "purpose",
+			Value: emailVerificationPurpose,
+		},
+		jwt.Claim{
+			Name:  "email",
+			Value: user.Email,
 		},
 	)
 	if err != nil {
-		err = fmt.Errorf(`generating tokens error: %w`, ports.InternalServerError)
-		return
+		return fmt.Errorf(`generating verification token error: %w`, ports.InternalServerError)
 	}
-	refreshToken, err = jwt.GenerateRefreshJWT(user.ID.Hex())
+
+	// previously sent links stop working once a new one is issued
+	err = s.verificationTokenRepo.DeleteUserEmailVerificationTokens(ctx, user.ID.Hex())
 	if err != nil {
-		err = fmt.Errorf(`generating tokens error: %w`, ports.InternalServerError)
-		return
+		s.log.Warnf("email verification tokens delete error: %v", err)
 	}
-	return
+	_, err = s.verificationTokenRepo.CreateEmailVerificationToken(ctx, domain.EmailVerificationToken{
+		User:  user.ID,
+		Email: user.Email,
+		Token: digest.SHA256(verificationToken),
+	})
+	if err != nil {
+		return fmt.Errorf(`creating verification token error: %w`, ports.InternalServerError)
+	}
+
+	body, err := json.Marshal(
+		map[string]interface{}{
+			"userID":   user.ID.Hex(),
+			"nickname": user.Nickname,
+			"email":    user.Email,
+			"token":    verificationToken,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf(`error marshaling event body: %w`, ports.InternalServerError)
+	}
+
+	return s.eventDispatcher.Dispatch(ctx, domain.Event{
+		Exchange: ports.EmailVerificationExchange,
+		Key:      user.ID.Hex(),
+		Body:     body,
+	})
 }
 
-func (s *AuthService) Logout(refreshToken string) {
-	err := s.tokenRepo.DeleteRefreshToken(refreshToken)
+func (s *AuthService) ForgotPassword(ctx context.Context, email string) error {
+	user, err := s.userRepo.GetUserByEmail(ctx, email)
 	if err != nil {
-		s.log.Warnf("refresh token delete error: %v", err)
+		// response must not reveal whether account exists
+		s.log.Debugf("password reset requested for unknown email: %v", err)
+		return nil
+	}
+
+	resetToken, err := s.jwtIssuer.GeneratePasswordResetJWT(
+		user.ID.Hex(),
+		jwt.Claim{
+			Name:  "purpose",
+			Value: passwordResetPurpose,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf(`generating reset token error: %w`, ports.InternalServerError)
+	}
+
+	err = s.resetTokenRepo.DeleteUserPasswordResetTokens(ctx, user.ID.Hex())
+	if err != nil {
+		s.log.Warnf("password reset tokens delete error: %v", err)
+	}
+	_, err = s.resetTokenRepo.CreatePasswordResetToken(ctx, domain.PasswordResetToken{
+		User:  user.ID,
+		Token: digest.SHA256(resetToken),
+	})
+	if err != nil {
+		return fmt.Errorf(`creating reset token error: %w`, ports.InternalServerError)
+	}
+
+	body, err := json.Marshal(
+		map[string]interface{}{
+			"userID":   user.ID.Hex(),
+			"nickname": user.Nickname,
+			"email":    user.Email,
+			"token":    resetToken,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf(`error marshaling event body: %w`, ports.InternalServerError)
+	}
+
+	return s.eventDispatcher.Dispatch(ctx, domain.Event{
+		Exchange: ports.PasswordResetExchange,
+		Key:      user.ID.Hex(),
+		Body:     body,
+	})
+}
+
+func (s *AuthService) ResetPassword(ctx context.Context, resetPasswordRequestDto dto.ResetPasswordRequestDto) error {
+	claims, err := s.jwtIssuer.ParseJWT(resetPasswordRequestDto.Token)
+	if err != nil || claims["purpose"] != passwordResetPurpose {
+		return fmt.Errorf("invalid reset token: %w", ports.BadRequestError)
+	}
+	// checked before token is used up so user can pick another password with the same link
+	err = checkBreachedPassword(s.breachedPasswords, resetPasswordRequestDto.Password)
+	if err != nil {
+		return err
+	}
+
+	// token is used up before password is changed, so concurrent requests cannot both redeem it
+	token, err := s.resetTokenRepo.TakePasswordResetToken(
+		ctx,
+		digest.SHA256(resetPasswordRequestDto.Token),
+	)
+	if err != nil || token.User.Hex() != claims["sub"] {
+		return fmt.Errorf("reset token not found: %w", ports.BadRequestError)
+	}
+
+	err = s.resetTokenRepo.DeleteUserPasswordResetTokens(ctx, token.User.Hex())
+	if err != nil {
+		s.log.Warnf("password reset tokens delete error: %v", err)
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, token.User.Hex())
+	if err != nil {
+		return fmt.Errorf("user not found: %w", ports.NotFoundError)
+	}
+
+	hashedPassword, err := s.hasher.Hash(resetPasswordRequestDto.Password)
+	if err != nil {
+		return fmt.Errorf(`hashing password error: %w`, ports.InternalServerError)
+	}
+	err = s.userRepo.SetPassword(ctx, user.ID.Hex(), hashedPassword)
+	if err != nil {
+		s.log.Warnf("user not updated due to error: %v", err)
+		return fmt.Errorf(`updating user error: %w`, ports.InternalServerError)
+	}
+
+	// whoever knew the old password must not stay logged in
+	err = s.tokenRepo.DeleteUserRefreshTokens(ctx, user.ID.Hex())
+	if err != nil {
+		s.log.Warnf("refresh tokens delete error: %v", err)
+	}
+	err = s.revocationService.RevokeUserAccessTokens(ctx, user.ID.Hex())
+	if err != nil {
+		s.log.Warnf("access tokens revoke error: %v", err)
+	}
+	return nil
+}
+
+// rehashPassword migrates password hashed by outdated algorithm or parameters while plain password is known
+func (s *AuthService) rehashPassword(ctx context.Context, user domain.User, pwd string) domain.User {
+	if !s.hasher.NeedsRehash(user.Password) {
+		return user
+	}
+	hashedPassword, err := s.hasher.Hash(pwd)
+	if err != nil {
+		s.log.Warnf("password of user '%s' not rehashed due to error: %v", user.ID.Hex(), err)
+		return user
+	}
+	err = s.userRepo.SetPassword(ctx, user.ID.Hex(), hashedPassword)
+	if err != nil {
+		s.log.Warnf("user not updated due to error: %v", err)
+		return user
+	}
+	user.Password = hashedPassword
+	return user
+}
+
+func checkBreachedPassword(breachedPasswords ports.BreachedPasswordChecker, pwd string) error {
+	if breachedPasswords.IsBreached(pwd) {
+		return fmt.Errorf("password appears in known data breaches, choose another one: %w", ports.BadRequestError)
 	}
+	return nil
 }
@@ -1,95 +1,138 @@
 package servises
 
 import (
+	"context"
 	"fmt"
 	"github.com/brianvoe/gofakeit/v6"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
 	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports/dto"
 	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports/mocks"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/digest"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/encryption"
 	"github.com/ttodoshi/code-typing-auth-service/pkg/jwt"
 	"github.com/ttodoshi/code-typing-auth-service/pkg/logging/nop"
 	. "github.com/ttodoshi/code-typing-auth-service/pkg/password"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/totp"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
 	"os"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestRegister(t *testing.T) {
 	var log = nop.GetLogger()
+	jwtIssuer := newIssuer()
 	var err error
 	err = os.Setenv("ACCESS_TOKEN_EXP", "300")
 	err = os.Setenv("REFRESH_TOKEN_EXP", "1209600")
 	// mocks
 	userRepo := new(mocks.UserRepository)
 	tokenRepo := new(mocks.RefreshTokenRepository)
+	verificationTokenRepo := new(mocks.EmailVerificationTokenRepository)
+	resetTokenRepo := new(mocks.PasswordResetTokenRepository)
 	eventDispatcher := new(mocks.EventDispatcher)
+	revokedTokenRepo := new(mocks.RevokedAccessTokenRepository)
+	breachedPasswords := new(mocks.BreachedPasswordChecker)
 
 	userRepo.
-		On("GetUserByNickname", "already_taken").
+		On("GetUserByNickname", mock.Anything, "already_taken").
 		Return(domain.User{}, nil)
 	userRepo.
-		On("GetUserByNickname", mock.Anything).
+		On("GetUserByNickname", mock.Anything, mock.Anything).
 		Return(domain.User{}, fmt.Errorf(""))
 	userRepo.
-		On("GetUserByEmail", "already_taken").
+		On("GetUserByEmail", mock.Anything, "already_taken").
 		Return(domain.User{}, nil)
 	userRepo.
-		On("GetUserByEmail", mock.Anything).
+		On("GetUserByEmail", mock.Anything, mock.Anything).
 		Return(domain.User{}, fmt.Errorf(""))
 	userRepo.
-		On("SaveUser", mock.Anything).
+		On("SaveUser", mock.Anything, mock.Anything).
 		Return(domain.User{}, nil)
 	tokenRepo.
-		On("CreateRefreshToken", mock.Anything).
+		On("CreateRefreshToken", mock.Anything, mock.Anything).
+		Return(gofakeit.UUID(), nil)
+	verificationTokenRepo.
+		On("DeleteUserEmailVerificationTokens", mock.Anything, mock.Anything).
+		Return(nil)
+	verificationTokenRepo.
+		On("CreateEmailVerificationToken", mock.Anything, mock.Anything).
 		Return(gofakeit.UUID(), nil)
 	eventDispatcher.
 		On(
 			"Dispatch",
 			mock.Anything,
-		).Return()
+			mock.Anything,
+		).Return(nil)
+	breachedPasswords.
+		On("IsBreached", "password123").
+		Return(true)
+	breachedPasswords.
+		On("IsBreached", mock.AnythingOfType("string")).
+		Return(false)
 
 	// service
-	authService := NewAuthService(userRepo, tokenRepo, eventDispatcher, log)
+	authService := NewAuthService(LockoutPolicy{}, jwtIssuer, newHasher(), newCipher(), userRepo, tokenRepo, verificationTokenRepo, resetTokenRepo, breachedPasswords, NewRevocationService(jwtIssuer, revokedTokenRepo, log), newTransactor(), eventDispatcher, log)
 
 	t.Run("successful registration", func(t *testing.T) {
-		_, _, err = authService.Register(dto.RegisterRequestDto{
+		_, _, err = authService.Register(context.Background(), dto.RegisterRequestDto{
 			Nickname: gofakeit.Username(),
 			Email:    gofakeit.Email(),
 			Password: gofakeit.Password(true, true, true, true, false, 8),
-		}, gofakeit.UUID())
+		}, gofakeit.UUID(), dto.DeviceDto{})
 		assert.NoError(t, err)
 	})
 	t.Run("unsuccessful registration due to nickname already taken", func(t *testing.T) {
-		_, _, err = authService.Register(dto.RegisterRequestDto{
+		_, _, err = authService.Register(context.Background(), dto.RegisterRequestDto{
 			Nickname: "already_taken",
 			Email:    gofakeit.Email(),
 			Password: gofakeit.Password(true, true, true, true, false, 4),
-		}, gofakeit.UUID())
+		}, gofakeit.UUID(), dto.DeviceDto{})
 		assert.Error(t, err)
 	})
 	t.Run("unsuccessful registration due to email already taken", func(t *testing.T) {
-		_, _, err = authService.Register(dto.RegisterRequestDto{
+		_, _, err = authService.Register(context.Background(), dto.RegisterRequestDto{
 			Nickname: gofakeit.Username(),
 			Email:    "already_taken",
 			Password: gofakeit.Password(true, true, true, true, false, 4),
-		}, gofakeit.UUID())
+		}, gofakeit.UUID(), dto.DeviceDto{})
 		assert.Error(t, err)
 	})
+	t.Run("unsuccessful registration due to breached password", func(t *testing.T) {
+		_, _, err = authService.Register(context.Background(), dto.RegisterRequestDto{
+			Nickname: gofakeit.Username(),
+			Email:    gofakeit.Email(),
+			Password: "password123",
+		}, gofakeit.UUID(), dto.DeviceDto{})
+		assert.ErrorIs(t, err, ports.BadRequestError)
+	})
 	userRepo.AssertExpectations(t)
 	eventDispatcher.AssertExpectations(t)
 	tokenRepo.AssertExpectations(t)
+	verificationTokenRepo.AssertExpectations(t)
+	resetTokenRepo.AssertExpectations(t)
+	revokedTokenRepo.AssertExpectations(t)
+	breachedPasswords.AssertExpectations(t)
 }
 
 func TestLogin(t *testing.T) {
 	var log = nop.GetLogger()
+	jwtIssuer := newIssuer()
 	var err error
 	err = os.Setenv("ACCESS_TOKEN_EXP", "300")
 	err = os.Setenv("REFRESH_TOKEN_EXP", "1209600")
 	// mocks
 	userRepo := new(mocks.UserRepository)
 	tokenRepo := new(mocks.RefreshTokenRepository)
+	verificationTokenRepo := new(mocks.EmailVerificationTokenRepository)
+	resetTokenRepo := new(mocks.PasswordResetTokenRepository)
 	eventDispatcher := new(mocks.EventDispatcher)
+	revokedTokenRepo := new(mocks.RevokedAccessTokenRepository)
 
 	password := gofakeit.Password(true, true, true, true, false, 8)
 	hashPassword, err := HashPassword(password)
@@ -99,79 +142,263 @@ func TestLogin(t *testing.T) {
 		Password: hashPassword,
 	}
 	userRepo.
-		On("GetUserByNickname", user.Nickname).
+		On("GetUserByNickname", mock.Anything, user.Nickname).
 		Return(user, nil)
 	userRepo.
-		On("GetUserByEmail", user.Email).
+		On("GetUserByEmail", mock.Anything, user.Email).
 		Return(user, nil)
 	userRepo.
-		On("GetUserByNickname", mock.AnythingOfType("string")).
+		On("GetUserByNickname", mock.Anything, mock.AnythingOfType("string")).
 		Return(domain.User{}, fmt.Errorf(""))
 	userRepo.
-		On("GetUserByEmail", mock.AnythingOfType("string")).
+		On("GetUserByEmail", mock.Anything, mock.AnythingOfType("string")).
 		Return(domain.User{}, fmt.Errorf(""))
 
 	tokenRepo.
-		On("CreateRefreshToken", mock.Anything).
+		On("CreateRefreshToken", mock.Anything, mock.Anything).
 		Return(gofakeit.UUID(), nil)
 	eventDispatcher.
 		On(
 			"Dispatch",
 			mock.Anything,
-		).Return()
+			mock.Anything,
+		).Return(nil)
 
 	// service
-	authService := NewAuthService(userRepo, tokenRepo, eventDispatcher, log)
+	authService := NewAuthService(LockoutPolicy{}, jwtIssuer, newHasher(), newCipher(), userRepo, tokenRepo, verificationTokenRepo, resetTokenRepo, nil, NewRevocationService(jwtIssuer, revokedTokenRepo, log), newTransactor(), eventDispatcher, log)
 
 	t.Run("successful login by nickname", func(t *testing.T) {
-		_, _, err = authService.Login(dto.LoginRequestDto{
+		_, _, _, err = authService.Login(context.Background(), dto.LoginRequestDto{
 			Login:    user.Nickname,
 			Password: password,
-		}, gofakeit.UUID())
+		}, gofakeit.UUID(), dto.DeviceDto{})
 		assert.NoError(t, err)
 	})
 	t.Run("successful login by email", func(t *testing.T) {
-		_, _, err = authService.Login(dto.LoginRequestDto{
+		_, _, _, err = authService.Login(context.Background(), dto.LoginRequestDto{
 			Login:    user.Email,
 			Password: password,
-		}, gofakeit.UUID())
+		}, gofakeit.UUID(), dto.DeviceDto{})
 		assert.NoError(t, err)
 	})
 	t.Run("unsuccessful login due to invalid email", func(t *testing.T) {
-		_, _, err = authService.Login(dto.LoginRequestDto{
+		_, _, _, err = authService.Login(context.Background(), dto.LoginRequestDto{
 			Login:    "invalid_email",
 			Password: password,
-		}, gofakeit.UUID())
+		}, gofakeit.UUID(), dto.DeviceDto{})
 		assert.Error(t, err)
 	})
 	t.Run("unsuccessful login due to invalid nickname", func(t *testing.T) {
-		_, _, err = authService.Login(dto.LoginRequestDto{
+		_, _, _, err = authService.Login(context.Background(), dto.LoginRequestDto{
 			Login:    "invalid_nickname",
 			Password: password,
-		}, gofakeit.UUID())
+		}, gofakeit.UUID(), dto.DeviceDto{})
 		assert.Error(t, err)
 	})
 	t.Run("unsuccessful login due to invalid password", func(t *testing.T) {
-		_, _, err = authService.Login(dto.LoginRequestDto{
+		_, _, _, err = authService.Login(context.Background(), dto.LoginRequestDto{
 			Login:    user.Nickname,
 			Password: "invalid_password",
-		}, gofakeit.UUID())
+		}, gofakeit.UUID(), dto.DeviceDto{})
 		assert.Error(t, err)
 	})
 	userRepo.AssertExpectations(t)
 	eventDispatcher.AssertExpectations(t)
 	tokenRepo.AssertExpectations(t)
+	verificationTokenRepo.AssertExpectations(t)
+	resetTokenRepo.AssertExpectations(t)
+	revokedTokenRepo.AssertExpectations(t)
+}
+
+func TestLoginLockout(t *testing.T) {
+	var log = nop.GetLogger()
+	jwtIssuer := newIssuer()
+	// mocks
+	userRepo := new(mocks.UserRepository)
+	tokenRepo := new(mocks.RefreshTokenRepository)
+	eventDispatcher := new(mocks.EventDispatcher)
+
+	password := gofakeit.Password(true, true, true, true, false, 8)
+	hashPassword, err := HashPassword(password)
+	assert.NoError(t, err)
+	user := domain.User{
+		Nickname: gofakeit.Username(),
+		Email:    gofakeit.Email(),
+		Password: hashPassword,
+	}
+	user.ID = primitive.NewObjectID()
+
+	userRepo.
+		On("GetUserByNickname", mock.Anything, user.Nickname).
+		Return(func(_ context.Context, nickname string) (domain.User, error) {
+			return user, nil
+		})
+	userRepo.
+		On("GetUserByID", mock.Anything, user.ID.Hex()).
+		Return(func(_ context.Context, ID string) (domain.User, error) {
+			return user, nil
+		})
+	userRepo.
+		On("LockUser", mock.Anything, user.ID.Hex(), mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Time")).
+		Return(func(_ context.Context, ID string, lockedAt time.Time, lockedUntil time.Time) (domain.User, bool, error) {
+			if user.LockedUntil.After(lockedAt) {
+				return user, false, nil
+			}
+			user.FailedLogins = 0
+			user.LockedUntil = lockedUntil
+			return user, true, nil
+		})
+	userRepo.
+		On("ResetFailedLogins", mock.Anything, user.ID.Hex()).
+		Return(func(_ context.Context, ID string) (domain.User, error) {
+			user.FailedLogins = 0
+			user.LastFailedLoginAt = time.Time{}
+			user.LockedUntil = time.Time{}
+			return user, nil
+		})
+	userRepo.
+		On("RecordFailedLogin", mock.Anything, user.ID.Hex(), mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Time")).
+		Return(func(_ context.Context, ID string, failedAt time.Time, forgetBefore time.Time) (domain.User, error) {
+			if user.LastFailedLoginAt.Before(forgetBefore) {
+				user.FailedLogins = 0
+			}
+			user.FailedLogins++
+			user.LastFailedLoginAt = failedAt
+			return user, nil
+		})
+	tokenRepo.
+		On("CreateRefreshToken", mock.Anything, mock.Anything).
+		Return(gofakeit.UUID(), nil)
+	eventDispatcher.
+		On("Dispatch", mock.Anything, mock.MatchedBy(func(event domain.Event) bool {
+			return event.Exchange == ports.AccountLockedExchange
+		})).
+		Return(nil).
+		Once()
+	eventDispatcher.
+		On("Dispatch", mock.Anything, mock.Anything).
+		Return(nil)
+
+	// service
+	lockingAuthService := NewAuthService(
+		LockoutPolicy{Threshold: 3, Duration: time.Hour, Window: time.Hour},
+		jwtIssuer, newHasher(), newCipher(), userRepo, tokenRepo, nil, nil, nil, nil, newTransactor(), eventDispatcher, log,
+	)
+	delayingAuthService := NewAuthService(
+		LockoutPolicy{BaseDelay: time.Minute, MaxDelay: time.Hour, Window: time.Hour},
+		jwtIssuer, newHasher(), newCipher(), userRepo, tokenRepo, nil, nil, nil, nil, newTransactor(), eventDispatcher, log,
+	)
+	userService := NewUserService(time.Hour, jwtIssuer, newHasher(), userRepo, nil, nil, nil, nil, newTransactor(), eventDispatcher, log)
+
+	login := func(authService ports.AuthService, password string) error {
+		_, _, _, err := authService.Login(context.Background(), dto.LoginRequestDto{
+			Login:    user.Nickname,
+			Password: password,
+		}, gofakeit.UUID(), dto.DeviceDto{})
+		return err
+	}
+
+	t.Run("successful login resets failed logins", func(t *testing.T) {
+		assert.Error(t, login(lockingAuthService, "invalid_password"))
+		assert.Error(t, login(lockingAuthService, "invalid_password"))
+		assert.Equal(t, 2, user.FailedLogins)
+
+		assert.NoError(t, login(lockingAuthService, password))
+		assert.Equal(t, 0, user.FailedLogins)
+	})
+	t.Run("unsuccessful login due to locked account", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			assert.ErrorIs(t, login(lockingAuthService, "invalid_password"), ports.BadRequestError)
+		}
+		assert.WithinDuration(t, time.Now().Add(time.Hour), user.LockedUntil, time.Minute)
+
+		err = login(lockingAuthService, password)
+		assert.ErrorIs(t, err, ports.TooManyRequestsError)
+		var rateLimitError *ports.RateLimitError
+		assert.ErrorAs(t, err, &rateLimitError)
+		assert.InDelta(t, time.Hour, rateLimitError.RetryAfter, float64(time.Minute))
+	})
+	t.Run("successful login after unlock by admin", func(t *testing.T) {
+		assert.NoError(t, userService.UnlockUser(context.Background(), user.ID.Hex()))
+		assert.NoError(t, login(lockingAuthService, password))
+	})
+	t.Run("unsuccessful login due to backoff delay", func(t *testing.T) {
+		assert.ErrorIs(t, login(delayingAuthService, "invalid_password"), ports.BadRequestError)
+		assert.ErrorIs(t, login(delayingAuthService, password), ports.TooManyRequestsError)
+	})
+	userRepo.AssertExpectations(t)
+	tokenRepo.AssertExpectations(t)
+	eventDispatcher.AssertExpectations(t)
+}
+
+func TestLoginPasswordRehash(t *testing.T) {
+	var log = nop.GetLogger()
+	jwtIssuer := newIssuer()
+	// mocks
+	userRepo := new(mocks.UserRepository)
+	tokenRepo := new(mocks.RefreshTokenRepository)
+	eventDispatcher := new(mocks.EventDispatcher)
+
+	password := gofakeit.Password(true, true, true, true, false, 8)
+	bcryptHash, err := BcryptHasher{Cost: bcrypt.MinCost}.Hash(password)
+	assert.NoError(t, err)
+	user := domain.User{
+		Nickname: gofakeit.Username(),
+		Email:    gofakeit.Email(),
+		Password: bcryptHash,
+	}
+	user.ID = primitive.NewObjectID()
+
+	userRepo.
+		On("GetUserByNickname", mock.Anything, user.Nickname).
+		Return(func(_ context.Context, nickname string) (domain.User, error) {
+			return user, nil
+		})
+	userRepo.
+		On("SetPassword", mock.Anything, user.ID.Hex(), mock.AnythingOfType("string")).
+		Return(func(_ context.Context, _ string, password string) error {
+			user.Password = password
+			return nil
+		}).
+		Once()
+	tokenRepo.
+		On("CreateRefreshToken", mock.Anything, mock.Anything).
+		Return(gofakeit.UUID(), nil)
+	eventDispatcher.
+		On("Dispatch", mock.Anything, mock.Anything).
+		Return(nil)
+
+	// service
+	hasher := Argon2idHasher{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	authService := NewAuthService(LockoutPolicy{}, jwtIssuer, hasher, newCipher(), userRepo, tokenRepo, nil, nil, nil, nil, newTransactor(), eventDispatcher, log)
+
+	t.Run("successful login rehashes outdated password", func(t *testing.T) {
+		_, _, _, err = authService.Login(context.Background(), dto.LoginRequestDto{Login: user.Nickname, Password: password}, gofakeit.UUID(), dto.DeviceDto{})
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(user.Password, "$argon2id$"))
+		assert.NoError(t, VerifyPassword(user.Password, password))
+	})
+	t.Run("successful login keeps up-to-date password", func(t *testing.T) {
+		_, _, _, err = authService.Login(context.Background(), dto.LoginRequestDto{Login: user.Nickname, Password: password}, gofakeit.UUID(), dto.DeviceDto{})
+		assert.NoError(t, err)
+	})
+	userRepo.AssertExpectations(t)
+	tokenRepo.AssertExpectations(t)
 }
 
 func TestRefresh(t *testing.T) {
 	var log = nop.GetLogger()
+	jwtIssuer := newIssuer()
 	var err error
 	err = os.Setenv("ACCESS_TOKEN_EXP", "300")
 	err = os.Setenv("REFRESH_TOKEN_EXP", "1209600")
 	// mocks
 	userRepo := new(mocks.UserRepository)
 	tokenRepo := new(mocks.RefreshTokenRepository)
+	verificationTokenRepo := new(mocks.EmailVerificationTokenRepository)
+	resetTokenRepo := new(mocks.PasswordResetTokenRepository)
 	eventDispatcher := new(mocks.EventDispatcher)
+	revokedTokenRepo := new(mocks.RevokedAccessTokenRepository)
 
 	password := gofakeit.Password(true, true, true, true, false, 8)
 	hashPassword, err := HashPassword(password)
@@ -181,63 +408,676 @@ func TestRefresh(t *testing.T) {
 		Password: hashPassword,
 	}
 
-	refresh, err := jwt.GenerateRefreshJWT(user.ID.Hex())
+	refresh, err := jwtIssuer.GenerateRefreshJWT(user.ID.Hex())
+	loggedInAt := time.Now().Add(-time.Hour)
+
+	// in-memory storage behind repository mock
+	tokens := map[string]domain.RefreshToken{
+		refresh: {User: user.ID, Token: refresh, Family: "family", StartedAt: loggedInAt},
+	}
+
 	tokenRepo.
-		On("GetRefreshToken", refresh).
-		Return(domain.RefreshToken{
-			User:  user.ID,
-			Token: refresh,
-		}, nil)
+		On("GetRefreshToken", mock.Anything, mock.AnythingOfType("string")).
+		Return(func(_ context.Context, refreshToken string) (domain.RefreshToken, error) {
+			token, ok := tokens[refreshToken]
+			if !ok || !token.RotatedAt.IsZero() {
+				return token, fmt.Errorf("")
+			}
+			return token, nil
+		})
+	tokenRepo.
+		On("GetRotatedRefreshToken", mock.Anything, mock.AnythingOfType("string")).
+		Return(func(_ context.Context, refreshToken string) (domain.RefreshToken, error) {
+			token, ok := tokens[refreshToken]
+			if !ok || token.RotatedAt.IsZero() {
+				return token, fmt.Errorf("")
+			}
+			return token, nil
+		})
 	tokenRepo.
-		On("GetRefreshToken", mock.AnythingOfType("string")).
-		Return(domain.RefreshToken{}, fmt.Errorf(""))
+		On("RotateRefreshToken", mock.Anything, mock.AnythingOfType("string"), mock.Anything).
+		Return(func(_ context.Context, oldRefreshToken string, newRefreshToken domain.RefreshToken) (domain.RefreshToken, error) {
+			token := tokens[oldRefreshToken]
+			token.RotatedAt = time.Now()
+			tokens[oldRefreshToken] = token
+			newRefreshToken.User = token.User
+			newRefreshToken.Family = token.Family
+			newRefreshToken.Generation = token.Generation + 1
+			tokens[newRefreshToken.Token] = newRefreshToken
+			return newRefreshToken, nil
+		})
 	tokenRepo.
-		On("UpdateRefreshToken", refresh, mock.Anything).
-		Return(domain.RefreshToken{}, nil)
+		On("DeleteRefreshTokenFamily", mock.Anything, "family").
+		Return(func(_ context.Context, family string) error {
+			for refreshToken, token := range tokens {
+				if token.Family == family {
+					delete(tokens, refreshToken)
+				}
+			}
+			return nil
+		})
+	eventDispatcher.
+		On("Dispatch", mock.Anything, mock.MatchedBy(func(event domain.Event) bool {
+			return event.Exchange == ports.TokenReuseExchange
+		})).
+		Return(nil)
 
 	userRepo.
-		On("GetUserByID", user.ID.Hex()).
+		On("GetUserByID", mock.Anything, user.ID.Hex()).
 		Return(user, nil)
 
 	// service
-	authService := NewAuthService(userRepo, tokenRepo, eventDispatcher, log)
+	authService := NewAuthService(LockoutPolicy{}, jwtIssuer, newHasher(), newCipher(), userRepo, tokenRepo, verificationTokenRepo, resetTokenRepo, nil, NewRevocationService(jwtIssuer, revokedTokenRepo, log), newTransactor(), eventDispatcher, log)
 
+	var rotatedRefresh string
 	t.Run("successful refresh", func(t *testing.T) {
-		_, _, err = authService.Refresh(refresh)
+		var access string
+		access, rotatedRefresh, err = authService.Refresh(context.Background(), refresh, dto.DeviceDto{})
 		assert.NoError(t, err)
+		assert.Equal(t, 1, tokens[rotatedRefresh].Generation)
+
+		// refresh is not a login, access token keeps time of the one the session started with
+		claims, err := jwtIssuer.ParseAccessJWT(access)
+		assert.NoError(t, err)
+		assert.Equal(t, float64(loggedInAt.Unix()), claims["auth_time"])
 	})
 	t.Run("unsuccessful refresh due to invalid refresh token", func(t *testing.T) {
-		_, _, err = authService.Refresh("invalid_refresh_token")
+		_, _, err = authService.Refresh(context.Background(), "invalid_refresh_token", dto.DeviceDto{})
+		assert.Error(t, err)
+	})
+	t.Run("unsuccessful refresh due to reuse of rotated token", func(t *testing.T) {
+		_, _, err = authService.Refresh(context.Background(), refresh, dto.DeviceDto{})
+		assert.Error(t, err)
+
+		// the whole family is revoked, so current token does not work either
+		_, _, err = authService.Refresh(context.Background(), rotatedRefresh, dto.DeviceDto{})
 		assert.Error(t, err)
 	})
 	userRepo.AssertExpectations(t)
 	eventDispatcher.AssertExpectations(t)
 	tokenRepo.AssertExpectations(t)
+	verificationTokenRepo.AssertExpectations(t)
+	resetTokenRepo.AssertExpectations(t)
+	revokedTokenRepo.AssertExpectations(t)
 }
 
 func TestLogout(t *testing.T) {
 	var log = nop.GetLogger()
+	jwtIssuer := newIssuer()
 	// mocks
 	userRepo := new(mocks.UserRepository)
 	tokenRepo := new(mocks.RefreshTokenRepository)
+	verificationTokenRepo := new(mocks.EmailVerificationTokenRepository)
+	resetTokenRepo := new(mocks.PasswordResetTokenRepository)
 	eventDispatcher := new(mocks.EventDispatcher)
+	revokedTokenRepo := new(mocks.RevokedAccessTokenRepository)
 
-	refresh, err := jwt.GenerateRefreshJWT(gofakeit.UUID())
+	userID := primitive.NewObjectID()
+	refresh, err := jwtIssuer.GenerateRefreshJWT(userID.Hex())
+	access, err := jwtIssuer.GenerateAccessJWT(userID.Hex())
+	revokedTokenRepo.
+		On("CreateRevokedAccessToken", mock.Anything, mock.MatchedBy(func(revokedToken domain.RevokedAccessToken) bool {
+			return revokedToken.JTI != "" && revokedToken.User == userID
+		})).
+		Return(primitive.NewObjectID().Hex(), nil)
 	tokenRepo.
-		On("DeleteRefreshToken", refresh).
+		On("DeleteRefreshToken", mock.Anything, refresh).
 		Return(nil)
 	tokenRepo.
-		On("DeleteRefreshToken", mock.AnythingOfType("string")).
+		On("DeleteRefreshToken", mock.Anything, mock.AnythingOfType("string")).
 		Return(fmt.Errorf(""))
 
 	// service
-	authService := NewAuthService(userRepo, tokenRepo, eventDispatcher, log)
+	revocationService := NewRevocationService(jwtIssuer, revokedTokenRepo, log)
+	authService := NewAuthService(LockoutPolicy{}, jwtIssuer, newHasher(), newCipher(), userRepo, tokenRepo, verificationTokenRepo, resetTokenRepo, nil, revocationService, newTransactor(), eventDispatcher, log)
 
 	t.Run("successful logout", func(t *testing.T) {
-		authService.Logout(refresh)
+		authService.Logout(context.Background(), refresh, "")
 		assert.NoError(t, err)
 	})
+	t.Run("successful logout with access token revocation", func(t *testing.T) {
+		authService.Logout(context.Background(), refresh, access)
+
+		claims, err := jwtIssuer.ParseAccessJWT(access)
+		assert.NoError(t, err)
+		assert.True(t, revocationService.IsAccessTokenRevoked(claims))
+	})
 	userRepo.AssertExpectations(t)
 	eventDispatcher.AssertExpectations(t)
 	tokenRepo.AssertExpectations(t)
+	verificationTokenRepo.AssertExpectations(t)
+	resetTokenRepo.AssertExpectations(t)
+	revokedTokenRepo.AssertExpectations(t)
+}
+
+func TestVerifyEmail(t *testing.T) {
+	var log = nop.GetLogger()
+	jwtIssuer := newIssuer()
+	var err error
+	// mocks
+	userRepo := new(mocks.UserRepository)
+	tokenRepo := new(mocks.RefreshTokenRepository)
+	verificationTokenRepo := new(mocks.EmailVerificationTokenRepository)
+	resetTokenRepo := new(mocks.PasswordResetTokenRepository)
+	eventDispatcher := new(mocks.EventDispatcher)
+	revokedTokenRepo := new(mocks.RevokedAccessTokenRepository)
+
+	user := domain.User{
+		Nickname: gofakeit.Username(),
+		Email:    gofakeit.Email(),
+	}
+	user.ID = primitive.NewObjectID()
+
+	verificationToken, err := jwtIssuer.GenerateEmailVerificationJWT(
+		user.ID.Hex(),
+		jwt.Claim{Name: "purpose", Value: "email_verification"},
+		jwt.Claim{Name: "email", Value: user.Email},
+	)
+	foreignToken, err := jwtIssuer.GenerateEmailVerificationJWT(user.ID.Hex())
+	verificationTokenRepo.
+		On("GetEmailVerificationToken", mock.Anything, digest.SHA256(verificationToken)).
+		Return(domain.EmailVerificationToken{
+			User:  user.ID,
+			Email: user.Email,
+			Token: digest.SHA256(verificationToken),
+		}, nil)
+	verificationTokenRepo.
+		On("DeleteUserEmailVerificationTokens", mock.Anything, user.ID.Hex()).
+		Return(nil)
+	userRepo.
+		On("GetUserByID", mock.Anything, user.ID.Hex()).
+		Return(user, nil)
+	userRepo.
+		On("SetEmailVerified", mock.Anything, user.ID.Hex()).
+		Return(nil)
+
+	// service
+	authService := NewAuthService(LockoutPolicy{}, jwtIssuer, newHasher(), newCipher(), userRepo, tokenRepo, verificationTokenRepo, resetTokenRepo, nil, NewRevocationService(jwtIssuer, revokedTokenRepo, log), newTransactor(), eventDispatcher, log)
+
+	t.Run("successful email verification", func(t *testing.T) {
+		err = authService.VerifyEmail(context.Background(), verificationToken)
+		assert.NoError(t, err)
+	})
+	t.Run("unsuccessful email verification due to invalid token", func(t *testing.T) {
+		err = authService.VerifyEmail(context.Background(), "invalid_token")
+		assert.Error(t, err)
+	})
+	t.Run("unsuccessful email verification due to token of another purpose", func(t *testing.T) {
+		err = authService.VerifyEmail(context.Background(), foreignToken)
+		assert.Error(t, err)
+	})
+	userRepo.AssertExpectations(t)
+	eventDispatcher.AssertExpectations(t)
+	tokenRepo.AssertExpectations(t)
+	verificationTokenRepo.AssertExpectations(t)
+	resetTokenRepo.AssertExpectations(t)
+	revokedTokenRepo.AssertExpectations(t)
+}
+
+func TestResendVerificationEmail(t *testing.T) {
+	var log = nop.GetLogger()
+	jwtIssuer := newIssuer()
+	var err error
+	// mocks
+	userRepo := new(mocks.UserRepository)
+	tokenRepo := new(mocks.RefreshTokenRepository)
+	verificationTokenRepo := new(mocks.EmailVerificationTokenRepository)
+	resetTokenRepo := new(mocks.PasswordResetTokenRepository)
+	eventDispatcher := new(mocks.EventDispatcher)
+	revokedTokenRepo := new(mocks.RevokedAccessTokenRepository)
+
+	user := domain.User{
+		Nickname: gofakeit.Username(),
+		Email:    gofakeit.Email(),
+	}
+	verifiedUser := domain.User{
+		Nickname:      gofakeit.Username(),
+		Email:         gofakeit.Email(),
+		EmailVerified: true,
+	}
+	userRepo.
+		On("GetUserByEmail", mock.Anything, user.Email).
+		Return(user, nil)
+	userRepo.
+		On("GetUserByEmail", mock.Anything, verifiedUser.Email).
+		Return(verifiedUser, nil)
+	userRepo.
+		On("GetUserByEmail", mock.Anything, mock.AnythingOfType("string")).
+		Return(domain.User{}, fmt.Errorf(""))
+	verificationTokenRepo.
+		On("DeleteUserEmailVerificationTokens", mock.Anything, user.ID.Hex()).
+		Return(nil)
+	verificationTokenRepo.
+		On("CreateEmailVerificationToken", mock.Anything, mock.Anything).
+		Return(gofakeit.UUID(), nil)
+	eventDispatcher.
+		On(
+			"Dispatch",
+			mock.Anything,
+			mock.MatchedBy(func(event domain.Event) bool {
+				return event.Exchange == ports.EmailVerificationExchange
+			}),
+		).Return(nil)
+
+	// service
+	authService := NewAuthService(LockoutPolicy{}, jwtIssuer, newHasher(), newCipher(), userRepo, tokenRepo, verificationTokenRepo, resetTokenRepo, nil, NewRevocationService(jwtIssuer, revokedTokenRepo, log), newTransactor(), eventDispatcher, log)
+
+	t.Run("successful resend", func(t *testing.T) {
+		err = authService.ResendVerificationEmail(context.Background(), user.Email)
+		assert.NoError(t, err)
+	})
+	t.Run("successful resend to already verified email without letter", func(t *testing.T) {
+		err = authService.ResendVerificationEmail(context.Background(), verifiedUser.Email)
+		assert.NoError(t, err)
+	})
+	t.Run("successful resend to unknown email without letter", func(t *testing.T) {
+		err = authService.ResendVerificationEmail(context.Background(), gofakeit.Email())
+		assert.NoError(t, err)
+	})
+	userRepo.AssertExpectations(t)
+	eventDispatcher.AssertExpectations(t)
+	eventDispatcher.AssertNumberOfCalls(t, "Dispatch", 1)
+	tokenRepo.AssertExpectations(t)
+	verificationTokenRepo.AssertExpectations(t)
+	resetTokenRepo.AssertExpectations(t)
+	revokedTokenRepo.AssertExpectations(t)
+}
+
+func TestForgotPassword(t *testing.T) {
+	var log = nop.GetLogger()
+	jwtIssuer := newIssuer()
+	var err error
+	// mocks
+	userRepo := new(mocks.UserRepository)
+	tokenRepo := new(mocks.RefreshTokenRepository)
+	verificationTokenRepo := new(mocks.EmailVerificationTokenRepository)
+	resetTokenRepo := new(mocks.PasswordResetTokenRepository)
+	eventDispatcher := new(mocks.EventDispatcher)
+	revokedTokenRepo := new(mocks.RevokedAccessTokenRepository)
+
+	user := domain.User{
+		Nickname: gofakeit.Username(),
+		Email:    gofakeit.Email(),
+	}
+	userRepo.
+		On("GetUserByEmail", mock.Anything, user.Email).
+		Return(user, nil)
+	userRepo.
+		On("GetUserByEmail", mock.Anything, mock.AnythingOfType("string")).
+		Return(domain.User{}, fmt.Errorf(""))
+	resetTokenRepo.
+		On("DeleteUserPasswordResetTokens", mock.Anything, user.ID.Hex()).
+		Return(nil)
+	resetTokenRepo.
+		On("CreatePasswordResetToken", mock.Anything, mock.Anything).
+		Return(gofakeit.UUID(), nil).
+		Once()
+	eventDispatcher.
+		On(
+			"Dispatch",
+			mock.Anything,
+			mock.MatchedBy(func(event domain.Event) bool {
+				return event.Exchange == ports.PasswordResetExchange
+			}),
+		).Return(nil).
+		Once()
+
+	// service
+	authService := NewAuthService(LockoutPolicy{}, jwtIssuer, newHasher(), newCipher(), userRepo, tokenRepo, verificationTokenRepo, resetTokenRepo, nil, NewRevocationService(jwtIssuer, revokedTokenRepo, log), newTransactor(), eventDispatcher, log)
+
+	t.Run("successful forgot password request", func(t *testing.T) {
+		err = authService.ForgotPassword(context.Background(), user.Email)
+		assert.NoError(t, err)
+	})
+	t.Run("forgot password request for unknown email does not reveal it", func(t *testing.T) {
+		err = authService.ForgotPassword(context.Background(), gofakeit.Email())
+		assert.NoError(t, err)
+	})
+	userRepo.AssertExpectations(t)
+	eventDispatcher.AssertExpectations(t)
+	tokenRepo.AssertExpectations(t)
+	verificationTokenRepo.AssertExpectations(t)
+	resetTokenRepo.AssertExpectations(t)
+	revokedTokenRepo.AssertExpectations(t)
+}
+
+func TestResetPassword(t *testing.T) {
+	var log = nop.GetLogger()
+	jwtIssuer := newIssuer()
+	var err error
+	// mocks
+	userRepo := new(mocks.UserRepository)
+	tokenRepo := new(mocks.RefreshTokenRepository)
+	verificationTokenRepo := new(mocks.EmailVerificationTokenRepository)
+	resetTokenRepo := new(mocks.PasswordResetTokenRepository)
+	eventDispatcher := new(mocks.EventDispatcher)
+	revokedTokenRepo := new(mocks.RevokedAccessTokenRepository)
+	breachedPasswords := new(mocks.BreachedPasswordChecker)
+
+	user := domain.User{
+		Nickname: gofakeit.Username(),
+		Email:    gofakeit.Email(),
+	}
+	user.ID = primitive.NewObjectID()
+	newPassword := gofakeit.Password(true, true, true, true, false, 8)
+
+	resetToken, err := jwtIssuer.GeneratePasswordResetJWT(
+		user.ID.Hex(),
+		jwt.Claim{Name: "purpose", Value: "password_reset"},
+	)
+	resetTokenRepo.
+		On("TakePasswordResetToken", mock.Anything, digest.SHA256(resetToken)).
+		Return(domain.PasswordResetToken{
+			User:  user.ID,
+			Token: digest.SHA256(resetToken),
+		}, nil).
+		Once()
+	resetTokenRepo.
+		On("TakePasswordResetToken", mock.Anything, mock.AnythingOfType("string")).
+		Return(domain.PasswordResetToken{}, fmt.Errorf(""))
+	resetTokenRepo.
+		On("DeleteUserPasswordResetTokens", mock.Anything, user.ID.Hex()).
+		Return(nil)
+	userRepo.
+		On("GetUserByID", mock.Anything, user.ID.Hex()).
+		Return(user, nil)
+	userRepo.
+		On("SetPassword", mock.Anything, user.ID.Hex(), mock.MatchedBy(func(password string) bool {
+			return VerifyPassword(password, newPassword) == nil
+		})).
+		Return(nil)
+	tokenRepo.
+		On("DeleteUserRefreshTokens", mock.Anything, user.ID.Hex()).
+		Return(nil)
+	revokedTokenRepo.
+		On("CreateRevokedAccessToken", mock.Anything, domain.RevokedAccessToken{User: user.ID}).
+		Return(primitive.NewObjectID().Hex(), nil)
+	breachedPasswords.
+		On("IsBreached", "password123").
+		Return(true)
+	breachedPasswords.
+		On("IsBreached", newPassword).
+		Return(false)
+
+	// service
+	authService := NewAuthService(LockoutPolicy{}, jwtIssuer, newHasher(), newCipher(), userRepo, tokenRepo, verificationTokenRepo, resetTokenRepo, breachedPasswords, NewRevocationService(jwtIssuer, revokedTokenRepo, log), newTransactor(), eventDispatcher, log)
+
+	t.Run("unsuccessful password reset due to breached password", func(t *testing.T) {
+		err = authService.ResetPassword(context.Background(), dto.ResetPasswordRequestDto{
+			Token:    resetToken,
+			Password: "password123",
+		})
+		assert.ErrorIs(t, err, ports.BadRequestError)
+	})
+	t.Run("successful password reset", func(t *testing.T) {
+		err = authService.ResetPassword(context.Background(), dto.ResetPasswordRequestDto{
+			Token:    resetToken,
+			Password: newPassword,
+		})
+		assert.NoError(t, err)
+	})
+	t.Run("unsuccessful password reset due to used token", func(t *testing.T) {
+		err = authService.ResetPassword(context.Background(), dto.ResetPasswordRequestDto{
+			Token:    resetToken,
+			Password: newPassword,
+		})
+		assert.ErrorIs(t, err, ports.BadRequestError)
+	})
+	t.Run("unsuccessful password reset due to invalid token", func(t *testing.T) {
+		err = authService.ResetPassword(context.Background(), dto.ResetPasswordRequestDto{
+			Token:    "invalid_token",
+			Password: newPassword,
+		})
+		assert.Error(t, err)
+	})
+	userRepo.AssertExpectations(t)
+	eventDispatcher.AssertExpectations(t)
+	tokenRepo.AssertExpectations(t)
+	verificationTokenRepo.AssertExpectations(t)
+	resetTokenRepo.AssertExpectations(t)
+	revokedTokenRepo.AssertExpectations(t)
+	breachedPasswords.AssertExpectations(t)
+}
+
+func TestLoginMFA(t *testing.T) {
+	var log = nop.GetLogger()
+	jwtIssuer := newIssuer()
+	var err error
+	// mocks
+	userRepo := new(mocks.UserRepository)
+	tokenRepo := new(mocks.RefreshTokenRepository)
+	verificationTokenRepo := new(mocks.EmailVerificationTokenRepository)
+	resetTokenRepo := new(mocks.PasswordResetTokenRepository)
+	eventDispatcher := new(mocks.EventDispatcher)
+	revokedTokenRepo := new(mocks.RevokedAccessTokenRepository)
+
+	password := gofakeit.Password(true, true, true, true, false, 8)
+	hashPassword, err := HashPassword(password)
+	secret, err := totp.GenerateSecret()
+	encryptedSecret, err := newCipher().Encrypt(secret)
+	user := domain.User{
+		Nickname:      gofakeit.Username(),
+		Email:         gofakeit.Email(),
+		Password:      hashPassword,
+		TOTPEnabled:   true,
+		TOTPSecret:    encryptedSecret,
+		RecoveryCodes: []string{digest.SHA256("abcdefgh")},
+	}
+	user.ID = primitive.NewObjectID()
+	lockedUser := user
+	lockedUser.ID = primitive.NewObjectID()
+	lockedUser.Nickname = gofakeit.Username()
+
+	// in-memory storage behind repository mock
+	users := map[string]domain.User{
+		user.ID.Hex():       user,
+		lockedUser.ID.Hex(): lockedUser,
+	}
+
+	userRepo.
+		On("GetUserByNickname", mock.Anything, mock.AnythingOfType("string")).
+		Return(func(_ context.Context, nickname string) (domain.User, error) {
+			for _, u := range users {
+				if u.Nickname == nickname {
+					return u, nil
+				}
+			}
+			return domain.User{}, fmt.Errorf("")
+		})
+	userRepo.
+		On("GetUserByID", mock.Anything, mock.AnythingOfType("string")).
+		Return(func(_ context.Context, ID string) (domain.User, error) {
+			return users[ID], nil
+		})
+	userRepo.
+		On("SetMFATokenID", mock.Anything, mock.AnythingOfType("string"), mock.AnythingOfType("string")).
+		Return(func(_ context.Context, ID string, mfaTokenID string) error {
+			u := users[ID]
+			u.MFATokenID = mfaTokenID
+			users[ID] = u
+			return nil
+		})
+	userRepo.
+		On("ConsumeMFATokenID", mock.Anything, mock.AnythingOfType("string"), mock.AnythingOfType("string")).
+		Return(func(_ context.Context, ID string, mfaTokenID string) (bool, error) {
+			u := users[ID]
+			if u.MFATokenID != mfaTokenID {
+				return false, nil
+			}
+			u.MFATokenID = ""
+			users[ID] = u
+			return true, nil
+		})
+	userRepo.
+		On("ConsumeTOTPStep", mock.Anything, mock.AnythingOfType("string"), mock.AnythingOfType("int64")).
+		Return(func(_ context.Context, ID string, step int64) (bool, error) {
+			u := users[ID]
+			if u.TOTPLastStep >= step {
+				return false, nil
+			}
+			u.TOTPLastStep = step
+			users[ID] = u
+			return true, nil
+		})
+	userRepo.
+		On("ConsumeRecoveryCode", mock.Anything, user.ID.Hex(), digest.SHA256("abcdefgh")).
+		Return(true, nil).
+		Once()
+	userRepo.
+		On("ConsumeRecoveryCode", mock.Anything, mock.AnythingOfType("string"), mock.AnythingOfType("string")).
+		Return(false, nil)
+	userRepo.
+		On("RecordFailedLogin", mock.Anything, mock.AnythingOfType("string"), mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Time")).
+		Return(func(_ context.Context, ID string, failedAt time.Time, _ time.Time) (domain.User, error) {
+			u := users[ID]
+			u.FailedLogins++
+			u.LastFailedLoginAt = failedAt
+			users[ID] = u
+			return u, nil
+		})
+	userRepo.
+		On("LockUser", mock.Anything, mock.AnythingOfType("string"), mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Time")).
+		Return(func(_ context.Context, ID string, _ time.Time, lockedUntil time.Time) (domain.User, bool, error) {
+			u := users[ID]
+			u.FailedLogins = 0
+			u.LockedUntil = lockedUntil
+			users[ID] = u
+			return u, true, nil
+		})
+	userRepo.
+		On("ResetFailedLogins", mock.Anything, mock.AnythingOfType("string")).
+		Return(func(_ context.Context, ID string) (domain.User, error) {
+			u := users[ID]
+			u.FailedLogins = 0
+			u.LockedUntil = time.Time{}
+			users[ID] = u
+			return u, nil
+		})
+	tokenRepo.
+		On("CreateRefreshToken", mock.Anything, mock.Anything).
+		Return(gofakeit.UUID(), nil)
+	eventDispatcher.
+		On(
+			"Dispatch",
+			mock.Anything,
+			mock.Anything,
+		).Return(nil)
+
+	// service
+	authService := NewAuthService(LockoutPolicy{Threshold: 2, Duration: time.Hour, Window: time.Hour}, jwtIssuer, newHasher(), newCipher(), userRepo, tokenRepo, verificationTokenRepo, resetTokenRepo, nil, NewRevocationService(jwtIssuer, revokedTokenRepo, log), newTransactor(), eventDispatcher, log)
+	login := func(t *testing.T, u domain.User) string {
+		access, _, mfaToken, err := authService.Login(context.Background(), dto.LoginRequestDto{
+			Login:    u.Nickname,
+			Password: password,
+		}, gofakeit.UUID(), dto.DeviceDto{})
+		assert.NoError(t, err)
+		assert.Empty(t, access)
+		assert.NotEmpty(t, mfaToken)
+		return mfaToken
+	}
+
+	var mfaToken, access, code string
+	t.Run("successful login with totp code", func(t *testing.T) {
+		mfaToken = login(t, user)
+		code, _ = totp.GenerateCode(secret, time.Now())
+		access, _, err = authService.LoginMFA(context.Background(), dto.MFALoginRequestDto{
+			MFAToken: mfaToken,
+			Code:     code,
+		}, gofakeit.UUID(), dto.DeviceDto{})
+		assert.NoError(t, err)
+		assert.NotEmpty(t, access)
+	})
+	t.Run("unsuccessful login due to used mfa token", func(t *testing.T) {
+		_, _, err = authService.LoginMFA(context.Background(), dto.MFALoginRequestDto{
+			MFAToken: mfaToken,
+			Code:     "ABCD-EFGH",
+		}, gofakeit.UUID(), dto.DeviceDto{})
+		assert.ErrorIs(t, err, ports.UnauthorizedError)
+	})
+	t.Run("unsuccessful login due to replayed totp code", func(t *testing.T) {
+		_, _, err = authService.LoginMFA(context.Background(), dto.MFALoginRequestDto{
+			MFAToken: login(t, user),
+			Code:     code,
+		}, gofakeit.UUID(), dto.DeviceDto{})
+		assert.ErrorIs(t, err, ports.BadRequestError)
+	})
+	t.Run("successful login with recovery code", func(t *testing.T) {
+		_, _, err = authService.LoginMFA(context.Background(), dto.MFALoginRequestDto{
+			MFAToken: login(t, user),
+			Code:     "ABCD-EFGH",
+		}, gofakeit.UUID(), dto.DeviceDto{})
+		assert.NoError(t, err)
+		assert.Zero(t, users[user.ID.Hex()].FailedLogins)
+	})
+	t.Run("unsuccessful login due to recovery code consumed by concurrent request", func(t *testing.T) {
+		_, _, err = authService.LoginMFA(context.Background(), dto.MFALoginRequestDto{
+			MFAToken: login(t, user),
+			Code:     "abcd efgh",
+		}, gofakeit.UUID(), dto.DeviceDto{})
+		assert.ErrorIs(t, err, ports.BadRequestError)
+	})
+	t.Run("unsuccessful login due to invalid mfa token", func(t *testing.T) {
+		_, _, err = authService.LoginMFA(context.Background(), dto.MFALoginRequestDto{
+			MFAToken: "invalid_mfa_token",
+			Code:     "000000",
+		}, gofakeit.UUID(), dto.DeviceDto{})
+		assert.Error(t, err)
+	})
+	t.Run("unsuccessful login due to account locked by invalid codes", func(t *testing.T) {
+		mfaToken = login(t, lockedUser)
+		for range 2 {
+			_, _, err = authService.LoginMFA(context.Background(), dto.MFALoginRequestDto{
+				MFAToken: mfaToken,
+				Code:     "000000",
+			}, gofakeit.UUID(), dto.DeviceDto{})
+			assert.ErrorIs(t, err, ports.BadRequestError)
+		}
+
+		code, _ = totp.GenerateCode(secret, time.Now())
+		_, _, err = authService.LoginMFA(context.Background(), dto.MFALoginRequestDto{
+			MFAToken: mfaToken,
+			Code:     code,
+		}, gofakeit.UUID(), dto.DeviceDto{})
+		assert.ErrorIs(t, err, ports.TooManyRequestsError)
+	})
+	userRepo.AssertExpectations(t)
+	eventDispatcher.AssertExpectations(t)
+	tokenRepo.AssertExpectations(t)
+	verificationTokenRepo.AssertExpectations(t)
+	resetTokenRepo.AssertExpectations(t)
+	revokedTokenRepo.AssertExpectations(t)
+}
+
+// newTransactor runs functions without transaction, as on standalone database
+func newTransactor() *mocks.Transactor {
+	transactor := new(mocks.Transactor)
+	transactor.
+		On("WithinTransaction", mock.Anything, mock.Anything).
+		Return(func(ctx context.Context, fn func(context.Context) error) error {
+			return fn(ctx)
+		})
+	return transactor
+}
+
+func newIssuer() *jwt.Issuer {
+	return jwt.NewIssuer(jwt.Config{
+		SecretKey:                 "secret",
+		AccessTokenExp:            5 * time.Minute,
+		RefreshTokenExp:           14 * 24 * time.Hour,
+		EmailVerificationTokenExp: 24 * time.Hour,
+		PasswordResetTokenExp:     time.Hour,
+		MFATokenExp:               5 * time.Minute,
+		OAuthStateTokenExp:        10 * time.Minute,
+		IDTokenExp:                5 * time.Minute,
+		DataExportTokenExp:        time.Hour,
+		LegacyKeyEnabled:          true,
+	})
+}
+
+func newHasher() Hasher {
+	return BcryptHasher{Cost: bcrypt.DefaultCost}
+}
+
+func newCipher() *encryption.Cipher {
+	return encryption.NewCipher("secret")
 }
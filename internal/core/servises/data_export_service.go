@@ -0,0 +1,252 @@
+package servises
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports/dto"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/jwt"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/logging"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const dataExportPurpose = "data_export"
+
+// dataExportClaim is how long worker generates claimed export, export of stopped worker is claimed again afterwards
+const dataExportClaim = 5 * time.Minute
+
+type DataExportService struct {
+	baseURL        string
+	jwtIssuer      *jwt.Issuer
+	exportRepo     ports.DataExportRepository
+	userRepo       ports.UserRepository
+	tokenRepo      ports.RefreshTokenRepository
+	identityRepo   ports.IdentityRepository
+	credentialRepo ports.PasskeyCredentialRepository
+	log            logging.Logger
+}
+
+func NewDataExportService(baseURL string, jwtIssuer *jwt.Issuer, exportRepo ports.DataExportRepository, userRepo ports.UserRepository, tokenRepo ports.RefreshTokenRepository, identityRepo ports.IdentityRepository, credentialRepo ports.PasskeyCredentialRepository, log logging.Logger) ports.DataExportService {
+	return &DataExportService{
+		baseURL:        strings.TrimSuffix(baseURL, "/"),
+		jwtIssuer:      jwtIssuer,
+		exportRepo:     exportRepo,
+		userRepo:       userRepo,
+		tokenRepo:      tokenRepo,
+		identityRepo:   identityRepo,
+		credentialRepo: credentialRepo,
+		log:            log,
+	}
+}
+
+func (s *DataExportService) RequestDataExport(ctx context.Context, userID string) (dto.DataExportResponseDto, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return dto.DataExportResponseDto{}, fmt.Errorf("user not found: %w", ports.NotFoundError)
+	}
+
+	exportID, err := s.exportRepo.CreateDataExport(ctx, domain.DataExport{
+		User:   user.ID,
+		Status: domain.DataExportPending,
+	})
+	if err != nil {
+		s.log.Warnf("data export not created due to error: %v", err)
+		return dto.DataExportResponseDto{}, fmt.Errorf(`creating data export error: %w`, ports.InternalServerError)
+	}
+
+	// archive of large account takes a while, it is generated by worker and client polls GetDataExport until it is ready
+	return dto.DataExportResponseDto{
+		ID:        exportID,
+		Status:    domain.DataExportPending,
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+func (s *DataExportService) GetDataExport(ctx context.Context, userID string, exportID string) (dto.DataExportResponseDto, error) {
+	dataExport, err := s.exportRepo.GetDataExport(ctx, exportID)
+	if err != nil || dataExport.User.Hex() != userID {
+		return dto.DataExportResponseDto{}, fmt.Errorf("data export not found: %w", ports.NotFoundError)
+	}
+
+	dataExportResponseDto := dto.DataExportResponseDto{
+		ID:        exportID,
+		Status:    dataExport.Status,
+		CreatedAt: dataExport.CreatedAt,
+	}
+	if dataExport.Status != domain.DataExportReady {
+		return dataExportResponseDto, nil
+	}
+
+	downloadToken, err := s.jwtIssuer.GenerateDataExportJWT(
+		userID,
+		jwt.Claim{
+			Name:  "purpose",
+			Value: dataExportPurpose,
+		},
+		jwt.Claim{
+			Name:  "export",
+			Value: exportID,
+		},
+	)
+	if err != nil {
+		return dto.DataExportResponseDto{}, fmt.Errorf(`generating download token error: %w`, ports.InternalServerError)
+	}
+	dataExportResponseDto.DownloadURL = s.baseURL + "/api/v1/auth/export/download?token=" + url.QueryEscape(downloadToken)
+	return dataExportResponseDto, nil
+}
+
+func (s *DataExportService) DownloadDataExport(ctx context.Context, downloadToken string) ([]byte, error) {
+	claims, err := s.jwtIssuer.ParseJWT(downloadToken)
+	if err != nil || claims["purpose"] != dataExportPurpose {
+		return nil, fmt.Errorf("invalid download token: %w", ports.UnauthorizedError)
+	}
+	exportID, _ := claims["export"].(string)
+
+	dataExport, err := s.exportRepo.GetDataExport(ctx, exportID)
+	if err != nil || dataExport.User.Hex() != claims["sub"] || dataExport.Status != domain.DataExportReady {
+		return nil, fmt.Errorf("data export not found: %w", ports.NotFoundError)
+	}
+	return dataExport.Data, nil
+}
+
+func (s *DataExportService) RunDataExportWorker(ctx context.Context, interval time.Duration) {
+	for {
+		s.generatePendingDataExports(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// generatePendingDataExports generates exports one by one until none is pending, pending exports are kept in database,
+// so exports requested before restart are generated too
+func (s *DataExportService) generatePendingDataExports(ctx context.Context, now time.Time) {
+	for ctx.Err() == nil {
+		dataExport, claimed, err := s.exportRepo.ClaimPendingDataExport(ctx, now.Add(dataExportClaim))
+		if err != nil {
+			s.log.Warnf("data export not claimed due to error: %v", err)
+			return
+		}
+		if !claimed {
+			return
+		}
+		s.generateDataExport(ctx, dataExport)
+	}
+}
+
+func (s *DataExportService) generateDataExport(ctx context.Context, dataExport domain.DataExport) {
+	exportID := dataExport.ID.Hex()
+	user, err := s.userRepo.GetUserByID(ctx, dataExport.User.Hex())
+	if err == nil {
+		dataExport.Data, err = s.collectPersonalData(ctx, user)
+	}
+	if ctx.Err() != nil {
+		// export stays pending and is generated again once its claim expires
+		s.log.Warnf("data export '%s' interrupted by shutdown", exportID)
+		return
+	}
+
+	dataExport.Status = domain.DataExportReady
+	if err != nil {
+		s.log.Errorf("data export '%s' failed due to error: %v", exportID, err)
+		dataExport.Status = domain.DataExportFailed
+	}
+	_, err = s.exportRepo.UpdateDataExport(ctx, dataExport)
+	if err != nil {
+		s.log.Errorf("data export '%s' not saved due to error: %v", exportID, err)
+	}
+}
+
+func (s *DataExportService) collectPersonalData(ctx context.Context, user domain.User) ([]byte, error) {
+	userID := user.ID.Hex()
+	tokens, err := s.tokenRepo.GetUserRefreshTokens(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	rotatedTokens, err := s.tokenRepo.GetUserRotatedRefreshTokens(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	identities, err := s.identityRepo.GetUserIdentities(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	credentials, err := s.credentialRepo.GetUserPasskeyCredentials(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	archive := dto.DataExportArchiveDto{
+		ExportedAt: time.Now(),
+		Profile: dto.ProfileExportDto{
+			ID:            userID,
+			Nickname:      user.Nickname,
+			Email:         user.Email,
+			EmailVerified: user.EmailVerified,
+			HasPassword:   user.Password != "",
+			TOTPEnabled:   user.TOTPEnabled,
+			Banned:        user.Banned,
+			CreatedAt:     user.CreatedAt,
+			UpdatedAt:     user.UpdatedAt,
+			FailedLogins:  user.FailedLogins,
+		},
+		Sessions:     make([]dto.SessionExportDto, 0, len(tokens)),
+		LoginHistory: make([]dto.LoginExportDto, 0, len(rotatedTokens)),
+		Identities:   make([]dto.IdentityExportDto, 0, len(identities)),
+		Passkeys:     make([]dto.PasskeyExportDto, 0, len(credentials)),
+	}
+	if !user.NicknameChangedAt.IsZero() {
+		archive.Profile.NicknameChangedAt = &user.NicknameChangedAt
+	}
+	if !user.DeletedAt.IsZero() {
+		archive.Profile.DeletedAt = &user.DeletedAt
+	}
+	if !user.LastFailedLoginAt.IsZero() {
+		archive.Profile.LastFailedLoginAt = &user.LastFailedLoginAt
+	}
+	if !user.LockedUntil.IsZero() {
+		archive.Profile.LockedUntil = &user.LockedUntil
+	}
+	for _, token := range tokens {
+		archive.Sessions = append(archive.Sessions, dto.SessionExportDto{
+			ID:         sessionID(token),
+			UserAgent:  token.UserAgent,
+			IP:         token.IP,
+			StartedAt:  firstNonZero(token.StartedAt, token.CreatedAt),
+			LastUsedAt: firstNonZero(token.LastUsedAt, token.UpdatedAt),
+		})
+	}
+	for _, token := range rotatedTokens {
+		archive.LoginHistory = append(archive.LoginHistory, dto.LoginExportDto{
+			Session:   sessionID(token),
+			UserAgent: token.UserAgent,
+			IP:        token.IP,
+			IssuedAt:  token.CreatedAt,
+			RotatedAt: token.RotatedAt,
+		})
+	}
+	for _, identity := range identities {
+		archive.Identities = append(archive.Identities, dto.IdentityExportDto{
+			Provider:  identity.Provider,
+			Subject:   identity.Subject,
+			Email:     identity.Email,
+			CreatedAt: identity.CreatedAt,
+		})
+	}
+	for _, credential := range credentials {
+		archive.Passkeys = append(archive.Passkeys, dto.PasskeyExportDto{
+			CredentialID: base64.RawURLEncoding.EncodeToString(credential.CredentialID),
+			Transports:   credential.Transports,
+			SignCount:    credential.SignCount,
+			CreatedAt:    credential.CreatedAt,
+		})
+	}
+	return json.MarshalIndent(archive, "", "  ")
+}
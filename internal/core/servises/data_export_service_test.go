@@ -0,0 +1,168 @@
+package servises
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports/dto"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports/mocks"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/jwt"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/logging/nop"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestDataExport(t *testing.T) {
+	var log = nop.GetLogger()
+	jwtIssuer := newIssuer()
+	// mocks
+	exportRepo := new(mocks.DataExportRepository)
+	userRepo := new(mocks.UserRepository)
+	tokenRepo := new(mocks.RefreshTokenRepository)
+	identityRepo := new(mocks.IdentityRepository)
+	credentialRepo := new(mocks.PasskeyCredentialRepository)
+
+	user := domain.User{
+		Nickname: gofakeit.Username(),
+		Email:    gofakeit.Email(),
+		Password: "$2a$10$passwordhash",
+		// failed logins since the last successful one
+		FailedLogins:      2,
+		LastFailedLoginAt: time.Now(),
+	}
+	user.ID = primitive.NewObjectID()
+	strangerID := primitive.NewObjectID()
+
+	// in-memory storage behind repository mock
+	exports := map[string]domain.DataExport{}
+
+	userRepo.
+		On("GetUserByID", mock.Anything, user.ID.Hex()).
+		Return(user, nil)
+	exportRepo.
+		On("CreateDataExport", mock.Anything, mock.AnythingOfType("domain.DataExport")).
+		Return(func(_ context.Context, dataExport domain.DataExport) (string, error) {
+			dataExport.ID = primitive.NewObjectID()
+			exports[dataExport.ID.Hex()] = dataExport
+			return dataExport.ID.Hex(), nil
+		})
+	exportRepo.
+		On("GetDataExport", mock.Anything, mock.AnythingOfType("string")).
+		Return(func(_ context.Context, ID string) (domain.DataExport, error) {
+			dataExport, ok := exports[ID]
+			if !ok {
+				return dataExport, fmt.Errorf("")
+			}
+			return dataExport, nil
+		})
+	exportRepo.
+		On("UpdateDataExport", mock.Anything, mock.AnythingOfType("domain.DataExport")).
+		Return(func(_ context.Context, dataExport domain.DataExport) (domain.DataExport, error) {
+			exports[dataExport.ID.Hex()] = dataExport
+			return dataExport, nil
+		})
+	exportRepo.
+		On("ClaimPendingDataExport", mock.Anything, mock.Anything).
+		Return(func(_ context.Context, claimedUntil time.Time) (domain.DataExport, bool, error) {
+			for ID, dataExport := range exports {
+				if dataExport.Status == domain.DataExportPending && dataExport.ClaimedUntil.Before(time.Now()) {
+					dataExport.ClaimedUntil = claimedUntil
+					exports[ID] = dataExport
+					return dataExport, true, nil
+				}
+			}
+			return domain.DataExport{}, false, nil
+		})
+	tokenRepo.
+		On("GetUserRotatedRefreshTokens", mock.Anything, user.ID.Hex()).
+		Return([]domain.RefreshToken{
+			{User: user.ID, Family: "laptop-family", UserAgent: "Firefox", IP: "10.0.0.2", RotatedAt: time.Now()},
+		}, nil)
+	tokenRepo.
+		On("GetUserRefreshTokens", mock.Anything, user.ID.Hex()).
+		Return([]domain.RefreshToken{
+			{User: user.ID, Family: "laptop-family", UserAgent: "Firefox", IP: "10.0.0.1"},
+		}, nil)
+	identityRepo.
+		On("GetUserIdentities", mock.Anything, user.ID.Hex()).
+		Return([]domain.Identity{
+			{User: user.ID, Provider: "github", Subject: "42"},
+		}, nil)
+	credentialRepo.
+		On("GetUserPasskeyCredentials", mock.Anything, user.ID.Hex()).
+		Return([]domain.PasskeyCredential{}, nil)
+
+	// service
+	dataExportService := NewDataExportService(
+		"http://localhost:8090/", jwtIssuer,
+		exportRepo, userRepo, tokenRepo, identityRepo, credentialRepo,
+		log,
+	).(*DataExportService)
+
+	t.Run("successful data export", func(t *testing.T) {
+		dataExportResponseDto, err := dataExportService.RequestDataExport(context.Background(), user.ID.Hex())
+		assert.NoError(t, err)
+		assert.Equal(t, domain.DataExportPending, dataExportResponseDto.Status)
+
+		dataExportService.generatePendingDataExports(context.Background(), time.Now())
+		dataExportResponseDto, err = dataExportService.GetDataExport(context.Background(), user.ID.Hex(), dataExportResponseDto.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, domain.DataExportReady, dataExportResponseDto.Status)
+		downloadURL, err := url.Parse(dataExportResponseDto.DownloadURL)
+		assert.NoError(t, err)
+		assert.Equal(t, "/api/v1/auth/export/download", downloadURL.Path)
+
+		data, err := dataExportService.DownloadDataExport(context.Background(), downloadURL.Query().Get("token"))
+		assert.NoError(t, err)
+		var archive dto.DataExportArchiveDto
+		assert.NoError(t, json.Unmarshal(data, &archive))
+		assert.Equal(t, user.Nickname, archive.Profile.Nickname)
+		assert.True(t, archive.Profile.HasPassword)
+		assert.Len(t, archive.Sessions, 1)
+		assert.Equal(t, 2, archive.Profile.FailedLogins)
+		assert.Equal(t, "10.0.0.2", archive.LoginHistory[0].IP)
+		assert.Equal(t, "github", archive.Identities[0].Provider)
+		assert.NotContains(t, string(data), user.Password)
+	})
+	t.Run("successful generation of export interrupted by shutdown after restart", func(t *testing.T) {
+		dataExportResponseDto, err := dataExportService.RequestDataExport(context.Background(), user.ID.Hex())
+		assert.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		dataExportService.generateDataExport(ctx, exports[dataExportResponseDto.ID])
+		assert.Equal(t, domain.DataExportPending, exports[dataExportResponseDto.ID].Status)
+
+		// claim of stopped worker expires
+		dataExport := exports[dataExportResponseDto.ID]
+		dataExport.ClaimedUntil = time.Now().Add(-time.Second)
+		exports[dataExportResponseDto.ID] = dataExport
+		dataExportService.generatePendingDataExports(context.Background(), time.Now())
+		assert.Equal(t, domain.DataExportReady, exports[dataExportResponseDto.ID].Status)
+	})
+	t.Run("unsuccessful get of export of another user", func(t *testing.T) {
+		exportID, _ := exportRepo.CreateDataExport(context.Background(), domain.DataExport{User: user.ID, Status: domain.DataExportReady})
+		_, err := dataExportService.GetDataExport(context.Background(), strangerID.Hex(), exportID)
+		assert.Error(t, err)
+	})
+	t.Run("unsuccessful download due to token of another purpose", func(t *testing.T) {
+		resetToken, _ := jwtIssuer.GeneratePasswordResetJWT(
+			user.ID.Hex(),
+			jwt.Claim{Name: "purpose", Value: "password_reset"},
+			jwt.Claim{Name: "export", Value: primitive.NewObjectID().Hex()},
+		)
+		_, err := dataExportService.DownloadDataExport(context.Background(), resetToken)
+		assert.Error(t, err)
+	})
+	exportRepo.AssertExpectations(t)
+	userRepo.AssertExpectations(t)
+	tokenRepo.AssertExpectations(t)
+	identityRepo.AssertExpectations(t)
+	credentialRepo.AssertExpectations(t)
+}
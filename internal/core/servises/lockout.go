@@ -0,0 +1,127 @@
+package servises
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
+	"time"
+)
+
+// LockoutPolicy slows down password guessing of one account whatever addresses it comes from
+type LockoutPolicy struct {
+	// BaseDelay is wait after the first failed login, it doubles with every next failure up to MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// Threshold failed logins lock account for Duration, zero threshold disables lock
+	Threshold int
+	Duration  time.Duration
+	// Window is how long failed login is remembered
+	Window time.Duration
+}
+
+func (p LockoutPolicy) Enabled() bool {
+	return p.BaseDelay > 0 || p.Threshold > 0
+}
+
+// retryAfter is how long user has to wait before the next login, zero when login is allowed
+func (p LockoutPolicy) retryAfter(user domain.User, now time.Time) time.Duration {
+	if user.LockedUntil.After(now) {
+		return user.LockedUntil.Sub(now)
+	}
+	if p.BaseDelay <= 0 || user.FailedLogins == 0 || user.LastFailedLoginAt.Before(now.Add(-p.Window)) {
+		return 0
+	}
+	delay := p.BaseDelay
+	for i := 1; i < user.FailedLogins && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, p.MaxDelay)
+	return max(user.LastFailedLoginAt.Add(delay).Sub(now), 0)
+}
+
+func (s *AuthService) checkLockout(user domain.User, now time.Time) error {
+	retryAfter := s.lockoutPolicy.retryAfter(user, now)
+	if retryAfter <= 0 {
+		return nil
+	}
+	if user.LockedUntil.After(now) {
+		return ports.NewRateLimitError(
+			retryAfter,
+			fmt.Errorf("account is temporarily locked: %w", ports.TooManyRequestsError),
+		)
+	}
+	return ports.NewRateLimitError(
+		retryAfter,
+		fmt.Errorf("too many failed logins, try again later: %w", ports.TooManyRequestsError),
+	)
+}
+
+// recordFailedLogin counts failed login and locks account once threshold is reached
+func (s *AuthService) recordFailedLogin(ctx context.Context, user domain.User, now time.Time) {
+	if !s.lockoutPolicy.Enabled() {
+		return
+	}
+	user, err := s.userRepo.RecordFailedLogin(ctx, user.ID.Hex(), now, now.Add(-s.lockoutPolicy.Window))
+	if err != nil {
+		s.log.Warnf("failed login not recorded due to error: %v", err)
+		return
+	}
+	// account may be already locked by concurrent failed login
+	if s.lockoutPolicy.Threshold <= 0 || user.FailedLogins < s.lockoutPolicy.Threshold || user.LockedUntil.After(now) {
+		return
+	}
+
+	failedLogins := user.FailedLogins
+	locked := false
+	// account is not locked without event, so user is always notified about lock
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		user, locked, err = s.userRepo.LockUser(ctx, user.ID.Hex(), now, now.Add(s.lockoutPolicy.Duration))
+		if err != nil || !locked {
+			return err
+		}
+		return s.dispatchAccountLockedEvent(ctx, user, failedLogins)
+	})
+	if err != nil {
+		s.log.Warnf("account '%s' not locked due to error: %v", user.ID.Hex(), err)
+		return
+	}
+	if locked {
+		s.log.Infof("account '%s' locked after %d failed logins", user.ID.Hex(), failedLogins)
+	}
+}
+
+// resetFailedLogins is called after successful login
+func (s *AuthService) resetFailedLogins(ctx context.Context, user domain.User) domain.User {
+	if user.FailedLogins == 0 && user.LockedUntil.IsZero() {
+		return user
+	}
+	updatedUser, err := s.userRepo.ResetFailedLogins(ctx, user.ID.Hex())
+	if err != nil {
+		s.log.Warnf("failed logins not reset due to error: %v", err)
+		return user
+	}
+	return updatedUser
+}
+
+func (s *AuthService) dispatchAccountLockedEvent(ctx context.Context, user domain.User, failedLogins int) error {
+	body, err := json.Marshal(
+		map[string]interface{}{
+			"userID":       user.ID.Hex(),
+			"nickname":     user.Nickname,
+			"email":        user.Email,
+			"failedLogins": failedLogins,
+			"lockedUntil":  user.LockedUntil,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf(`error marshaling event body: %w`, ports.InternalServerError)
+	}
+	return s.eventDispatcher.Dispatch(ctx, domain.Event{
+		Exchange: ports.AccountLockedExchange,
+		Key:      user.ID.Hex(),
+		Body:     body,
+	})
+}
@@ -0,0 +1,224 @@
+package servises
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"fmt"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports/dto"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/digest"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/encryption"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/logging"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/totp"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	totpIssuer         = "Code Typing"
+	mfaPurpose         = "mfa"
+	recoveryCodesCount = 10
+)
+
+type MFAService struct {
+	cipher    *encryption.Cipher
+	userRepo  ports.UserRepository
+	tokenRepo ports.RefreshTokenRepository
+	log       logging.Logger
+}
+
+func NewMFAService(cipher *encryption.Cipher, userRepo ports.UserRepository, tokenRepo ports.RefreshTokenRepository, log logging.Logger) ports.MFAService {
+	return &MFAService{
+		cipher:    cipher,
+		userRepo:  userRepo,
+		tokenRepo: tokenRepo,
+		log:       log,
+	}
+}
+
+func (s *MFAService) EnrollTOTP(ctx context.Context, refreshToken string) (enrollResponseDto dto.TOTPEnrollResponseDto, err error) {
+	user, err := getUserByRefreshToken(ctx, s.userRepo, s.tokenRepo, refreshToken)
+	if err != nil {
+		return
+	}
+	if user.TOTPEnabled {
+		err = fmt.Errorf("two-factor authentication already enabled: %w", ports.BadRequestError)
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		err = fmt.Errorf(`generating totp secret error: %w`, ports.InternalServerError)
+		return
+	}
+	// secret stays pending until confirmed with the first code
+	encryptedSecret, err := s.cipher.Encrypt(secret)
+	if err != nil {
+		err = fmt.Errorf(`encrypting totp secret error: %w`, ports.InternalServerError)
+		return
+	}
+	err = s.userRepo.SetTOTPSecret(ctx, user.ID.Hex(), encryptedSecret)
+	if err != nil {
+		s.log.Warnf("user not updated due to error: %v", err)
+		err = fmt.Errorf(`updating user error: %w`, ports.InternalServerError)
+		return
+	}
+
+	return dto.TOTPEnrollResponseDto{
+		Secret: secret,
+		URI:    totp.URI(totpIssuer, user.Nickname, secret),
+	}, nil
+}
+
+func (s *MFAService) ConfirmTOTP(ctx context.Context, refreshToken string, code string) (recoveryCodes []string, err error) {
+	user, err := getUserByRefreshToken(ctx, s.userRepo, s.tokenRepo, refreshToken)
+	if err != nil {
+		return
+	}
+	if user.TOTPEnabled {
+		err = fmt.Errorf("two-factor authentication already enabled: %w", ports.BadRequestError)
+		return
+	}
+
+	secret, err := s.cipher.Decrypt(user.TOTPSecret)
+	if err != nil {
+		err = fmt.Errorf("totp enrollment not started: %w", ports.BadRequestError)
+		return
+	}
+	step, ok := totp.Step(code, secret, time.Now())
+	if ok {
+		ok, err = consumeTOTPStep(ctx, s.userRepo, user, step, s.log)
+		if err != nil {
+			return
+		}
+	}
+	if !ok {
+		err = fmt.Errorf("invalid two-factor code: %w", ports.BadRequestError)
+		return
+	}
+
+	recoveryCodes, hashedRecoveryCodes, err := generateRecoveryCodes()
+	if err != nil {
+		return
+	}
+	err = s.userRepo.EnableTOTP(ctx, user.ID.Hex(), hashedRecoveryCodes)
+	if err != nil {
+		s.log.Warnf("user not updated due to error: %v", err)
+		return nil, fmt.Errorf(`updating user error: %w`, ports.InternalServerError)
+	}
+	return
+}
+
+func (s *MFAService) DisableTOTP(ctx context.Context, refreshToken string, code string) (err error) {
+	user, err := getUserByRefreshToken(ctx, s.userRepo, s.tokenRepo, refreshToken)
+	if err != nil {
+		return
+	}
+	if !user.TOTPEnabled {
+		return fmt.Errorf("two-factor authentication not enabled: %w", ports.BadRequestError)
+	}
+	ok, err := verifySecondFactor(ctx, s.cipher, s.userRepo, user, code, s.log)
+	if err != nil {
+		return
+	}
+	if !ok {
+		return fmt.Errorf("invalid two-factor code: %w", ports.BadRequestError)
+	}
+
+	err = s.userRepo.DisableTOTP(ctx, user.ID.Hex())
+	if err != nil {
+		s.log.Warnf("two-factor authentication not disabled due to error: %v", err)
+		return fmt.Errorf(`disabling two-factor authentication error: %w`, ports.InternalServerError)
+	}
+	return nil
+}
+
+func (s *MFAService) RegenerateRecoveryCodes(ctx context.Context, refreshToken string, code string) (recoveryCodes []string, err error) {
+	user, err := getUserByRefreshToken(ctx, s.userRepo, s.tokenRepo, refreshToken)
+	if err != nil {
+		return
+	}
+	if !user.TOTPEnabled {
+		err = fmt.Errorf("two-factor authentication not enabled: %w", ports.BadRequestError)
+		return
+	}
+	ok, err := verifySecondFactor(ctx, s.cipher, s.userRepo, user, code, s.log)
+	if err != nil {
+		return
+	}
+	if !ok {
+		err = fmt.Errorf("invalid two-factor code: %w", ports.BadRequestError)
+		return
+	}
+
+	recoveryCodes, hashedRecoveryCodes, err := generateRecoveryCodes()
+	if err != nil {
+		return
+	}
+	err = s.userRepo.SetRecoveryCodes(ctx, user.ID.Hex(), hashedRecoveryCodes)
+	if err != nil {
+		s.log.Warnf("user not updated due to error: %v", err)
+		return nil, fmt.Errorf(`updating user error: %w`, ports.InternalServerError)
+	}
+	return
+}
+
+// verifySecondFactor accepts either current TOTP code or one of recovery codes,
+// step of matched TOTP code and matched recovery code are consumed by repository, so neither is used twice
+func verifySecondFactor(ctx context.Context, cipher *encryption.Cipher, userRepo ports.UserRepository, user domain.User, code string, log logging.Logger) (bool, error) {
+	secret, err := cipher.Decrypt(user.TOTPSecret)
+	if err == nil {
+		step, ok := totp.Step(code, secret, time.Now())
+		if ok {
+			return consumeTOTPStep(ctx, userRepo, user, step, log)
+		}
+	}
+
+	hashedCode := digest.SHA256(normalizeRecoveryCode(code))
+	if !slices.Contains(user.RecoveryCodes, hashedCode) {
+		return false, nil
+	}
+	consumed, err := userRepo.ConsumeRecoveryCode(ctx, user.ID.Hex(), hashedCode)
+	if err != nil {
+		log.Warnf("recovery code not consumed due to error: %v", err)
+		return false, fmt.Errorf(`consuming recovery code error: %w`, ports.InternalServerError)
+	}
+	return consumed, nil
+}
+
+// consumeTOTPStep accepts code of step once, false is returned when code of the step or a later one was already accepted
+func consumeTOTPStep(ctx context.Context, userRepo ports.UserRepository, user domain.User, step int64, log logging.Logger) (bool, error) {
+	consumed, err := userRepo.ConsumeTOTPStep(ctx, user.ID.Hex(), step)
+	if err != nil {
+		log.Warnf("totp step not consumed due to error: %v", err)
+		return false, fmt.Errorf(`consuming totp code error: %w`, ports.InternalServerError)
+	}
+	return consumed, nil
+}
+
+func generateRecoveryCodes() (recoveryCodes []string, hashedRecoveryCodes []string, err error) {
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	for range recoveryCodesCount {
+		raw := make([]byte, 5)
+		_, err = rand.Read(raw)
+		if err != nil {
+			err = fmt.Errorf(`generating recovery codes error: %w`, ports.InternalServerError)
+			return nil, nil, err
+		}
+		code := strings.ToLower(encoding.EncodeToString(raw))
+		code = code[:4] + "-" + code[4:]
+
+		recoveryCodes = append(recoveryCodes, code)
+		hashedRecoveryCodes = append(hashedRecoveryCodes, digest.SHA256(normalizeRecoveryCode(code)))
+	}
+	return
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(
+		strings.NewReplacer("-", "", " ", "").Replace(code),
+	)
+}
@@ -0,0 +1,176 @@
+package servises
+
+import (
+	"context"
+	"fmt"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports/mocks"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/logging/nop"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/totp"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"testing"
+	"time"
+)
+
+func TestEnrollTOTP(t *testing.T) {
+	var log = nop.GetLogger()
+	// mocks
+	userRepo := new(mocks.UserRepository)
+	tokenRepo := new(mocks.RefreshTokenRepository)
+
+	user := domain.User{
+		Nickname: gofakeit.Username(),
+		Email:    gofakeit.Email(),
+	}
+	user.ID = primitive.NewObjectID()
+	enabledUser := user
+	enabledUser.ID = primitive.NewObjectID()
+	enabledUser.TOTPEnabled = true
+
+	tokenRepo.
+		On("GetRefreshToken", mock.Anything, "refresh").
+		Return(domain.RefreshToken{User: user.ID}, nil)
+	tokenRepo.
+		On("GetRefreshToken", mock.Anything, "enabled_refresh").
+		Return(domain.RefreshToken{User: enabledUser.ID}, nil)
+	tokenRepo.
+		On("GetRefreshToken", mock.Anything, mock.AnythingOfType("string")).
+		Return(domain.RefreshToken{}, fmt.Errorf(""))
+	userRepo.
+		On("GetUserByID", mock.Anything, user.ID.Hex()).
+		Return(user, nil)
+	userRepo.
+		On("GetUserByID", mock.Anything, enabledUser.ID.Hex()).
+		Return(enabledUser, nil)
+	userRepo.
+		On("SetTOTPSecret", mock.Anything, user.ID.Hex(), mock.AnythingOfType("string")).
+		Return(nil)
+
+	// service
+	mfaService := NewMFAService(newCipher(), userRepo, tokenRepo, log)
+
+	t.Run("successful enrollment", func(t *testing.T) {
+		enrollResponseDto, err := mfaService.EnrollTOTP(context.Background(), "refresh")
+		assert.NoError(t, err)
+		assert.NotEmpty(t, enrollResponseDto.Secret)
+		assert.Contains(t, enrollResponseDto.URI, "otpauth://totp/")
+	})
+	t.Run("unsuccessful enrollment due to already enabled two-factor authentication", func(t *testing.T) {
+		_, err := mfaService.EnrollTOTP(context.Background(), "enabled_refresh")
+		assert.Error(t, err)
+	})
+	t.Run("unsuccessful enrollment due to invalid refresh token", func(t *testing.T) {
+		_, err := mfaService.EnrollTOTP(context.Background(), "invalid_refresh")
+		assert.Error(t, err)
+	})
+	userRepo.AssertExpectations(t)
+	tokenRepo.AssertExpectations(t)
+}
+
+func TestConfirmTOTP(t *testing.T) {
+	var log = nop.GetLogger()
+	// mocks
+	userRepo := new(mocks.UserRepository)
+	tokenRepo := new(mocks.RefreshTokenRepository)
+
+	secret, _ := totp.GenerateSecret()
+	encryptedSecret, _ := newCipher().Encrypt(secret)
+	user := domain.User{
+		Nickname:   gofakeit.Username(),
+		Email:      gofakeit.Email(),
+		TOTPSecret: encryptedSecret,
+	}
+	user.ID = primitive.NewObjectID()
+
+	tokenRepo.
+		On("GetRefreshToken", mock.Anything, "refresh").
+		Return(domain.RefreshToken{User: user.ID}, nil)
+	userRepo.
+		On("GetUserByID", mock.Anything, user.ID.Hex()).
+		Return(user, nil)
+	userRepo.
+		On("ConsumeTOTPStep", mock.Anything, user.ID.Hex(), mock.AnythingOfType("int64")).
+		Return(true, nil)
+	userRepo.
+		On("EnableTOTP", mock.Anything, user.ID.Hex(), mock.MatchedBy(func(hashedRecoveryCodes []string) bool {
+			return len(hashedRecoveryCodes) == 10
+		})).
+		Return(nil).
+		Once()
+
+	// service
+	mfaService := NewMFAService(newCipher(), userRepo, tokenRepo, log)
+
+	t.Run("successful confirmation", func(t *testing.T) {
+		code, _ := totp.GenerateCode(secret, time.Now())
+		recoveryCodes, err := mfaService.ConfirmTOTP(context.Background(), "refresh", code)
+		assert.NoError(t, err)
+		assert.Len(t, recoveryCodes, 10)
+	})
+	t.Run("unsuccessful confirmation due to invalid code", func(t *testing.T) {
+		_, err := mfaService.ConfirmTOTP(context.Background(), "refresh", "abcdef")
+		assert.Error(t, err)
+	})
+	userRepo.AssertExpectations(t)
+	tokenRepo.AssertExpectations(t)
+}
+
+func TestDisableTOTP(t *testing.T) {
+	var log = nop.GetLogger()
+	// mocks
+	userRepo := new(mocks.UserRepository)
+	tokenRepo := new(mocks.RefreshTokenRepository)
+
+	secret, _ := totp.GenerateSecret()
+	encryptedSecret, _ := newCipher().Encrypt(secret)
+	_, hashedRecoveryCodes, _ := generateRecoveryCodes()
+	user := domain.User{
+		Nickname:      gofakeit.Username(),
+		Email:         gofakeit.Email(),
+		TOTPEnabled:   true,
+		TOTPSecret:    encryptedSecret,
+		RecoveryCodes: hashedRecoveryCodes,
+	}
+	user.ID = primitive.NewObjectID()
+
+	tokenRepo.
+		On("GetRefreshToken", mock.Anything, "refresh").
+		Return(domain.RefreshToken{User: user.ID}, nil)
+	userRepo.
+		On("GetUserByID", mock.Anything, user.ID.Hex()).
+		Return(user, nil)
+	userRepo.
+		On("ConsumeTOTPStep", mock.Anything, user.ID.Hex(), mock.AnythingOfType("int64")).
+		Return(true, nil).
+		Once()
+	userRepo.
+		On("ConsumeTOTPStep", mock.Anything, user.ID.Hex(), mock.AnythingOfType("int64")).
+		Return(false, nil)
+	userRepo.
+		On("DisableTOTP", mock.Anything, user.ID.Hex()).
+		Return(nil).
+		Once()
+
+	// service
+	mfaService := NewMFAService(newCipher(), userRepo, tokenRepo, log)
+
+	t.Run("unsuccessful disabling due to invalid code", func(t *testing.T) {
+		err := mfaService.DisableTOTP(context.Background(), "refresh", "abcdef")
+		assert.Error(t, err)
+	})
+	t.Run("successful disabling", func(t *testing.T) {
+		code, _ := totp.GenerateCode(secret, time.Now())
+		err := mfaService.DisableTOTP(context.Background(), "refresh", code)
+		assert.NoError(t, err)
+	})
+	t.Run("unsuccessful disabling due to replayed code", func(t *testing.T) {
+		code, _ := totp.GenerateCode(secret, time.Now())
+		err := mfaService.DisableTOTP(context.Background(), "refresh", code)
+		assert.Error(t, err)
+	})
+	userRepo.AssertExpectations(t)
+	tokenRepo.AssertExpectations(t)
+}
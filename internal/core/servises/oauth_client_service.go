@@ -0,0 +1,107 @@
+package servises
+
+import (
+	"context"
+	"fmt"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports/dto"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/digest"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/logging"
+	"net/url"
+	"slices"
+)
+
+type OAuthClientService struct {
+	clientRepo ports.OAuthClientRepository
+	log        logging.Logger
+}
+
+func NewOAuthClientService(clientRepo ports.OAuthClientRepository, log logging.Logger) ports.OAuthClientService {
+	return &OAuthClientService{
+		clientRepo: clientRepo,
+		log:        log,
+	}
+}
+
+func (s *OAuthClientService) CreateOAuthClient(ctx context.Context, createOAuthClientRequestDto dto.CreateOAuthClientRequestDto) (clientResponseDto dto.OAuthClientResponseDto, err error) {
+	for _, redirectURI := range createOAuthClientRequestDto.RedirectURIs {
+		parsedURI, parseErr := url.Parse(redirectURI)
+		if parseErr != nil || !parsedURI.IsAbs() || parsedURI.Fragment != "" {
+			err = fmt.Errorf("redirect uri '%s' must be absolute and have no fragment: %w", redirectURI, ports.BadRequestError)
+			return
+		}
+	}
+	scopes := createOAuthClientRequestDto.Scopes
+	if len(scopes) == 0 {
+		scopes = supportedScopes
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(supportedScopes, scope) {
+			err = fmt.Errorf("scope '%s' is not supported: %w", scope, ports.BadRequestError)
+			return
+		}
+	}
+
+	client := domain.OAuthClient{
+		Name:         createOAuthClientRequestDto.Name,
+		RedirectURIs: createOAuthClientRequestDto.RedirectURIs,
+		Scopes:       scopes,
+	}
+	client.ClientID, err = randomToken()
+	if err != nil {
+		return
+	}
+	var clientSecret string
+	if !createOAuthClientRequestDto.Public {
+		clientSecret, err = randomToken()
+		if err != nil {
+			return
+		}
+		client.SecretHash = digest.SHA256(clientSecret)
+	}
+
+	_, err = s.clientRepo.CreateOAuthClient(ctx, client)
+	if err != nil {
+		s.log.Warnf("oauth client not saved due to error: %v", err)
+		err = fmt.Errorf(`saving oauth client error: %w`, ports.InternalServerError)
+		return
+	}
+
+	// secret is shown only once, only its hash is stored
+	clientResponseDto = mapOAuthClient(client)
+	clientResponseDto.ClientSecret = clientSecret
+	return
+}
+
+func (s *OAuthClientService) GetOAuthClients(ctx context.Context) ([]dto.OAuthClientResponseDto, error) {
+	clients, err := s.clientRepo.GetOAuthClients(ctx)
+	if err != nil {
+		s.log.Warnf("oauth clients not found due to error: %v", err)
+		return nil, fmt.Errorf(`getting oauth clients error: %w`, ports.InternalServerError)
+	}
+
+	clientResponseDtos := make([]dto.OAuthClientResponseDto, 0, len(clients))
+	for _, client := range clients {
+		clientResponseDtos = append(clientResponseDtos, mapOAuthClient(client))
+	}
+	return clientResponseDtos, nil
+}
+
+func (s *OAuthClientService) DeleteOAuthClient(ctx context.Context, clientID string) error {
+	err := s.clientRepo.DeleteOAuthClient(ctx, clientID)
+	if err != nil {
+		return fmt.Errorf("oauth client not found: %w", ports.NotFoundError)
+	}
+	return nil
+}
+
+func mapOAuthClient(client domain.OAuthClient) dto.OAuthClientResponseDto {
+	return dto.OAuthClientResponseDto{
+		ClientID:     client.ClientID,
+		Name:         client.Name,
+		RedirectURIs: client.RedirectURIs,
+		Scopes:       client.Scopes,
+		Public:       client.SecretHash == "",
+	}
+}
@@ -0,0 +1,236 @@
+package servises
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports/dto"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/jwt"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/logging"
+	"math/big"
+	"regexp"
+	"strings"
+)
+
+const (
+	oauthStatePurpose      = "oauth_state"
+	maxNicknameLength      = 20
+	nicknameSuffixAttempts = 5
+	defaultNickname        = "user"
+)
+
+var nicknameDisallowedChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+type OAuthService struct {
+	providers    map[string]ports.IdentityProvider
+	userRepo     ports.UserRepository
+	identityRepo ports.IdentityRepository
+	*sessionIssuer
+}
+
+func NewOAuthService(providers []ports.IdentityProvider, jwtIssuer *jwt.Issuer, userRepo ports.UserRepository, identityRepo ports.IdentityRepository, tokenRepo ports.RefreshTokenRepository, transactor ports.Transactor, resultsMigrator ports.EventDispatcher, log logging.Logger) ports.OAuthService {
+	providersByName := make(map[string]ports.IdentityProvider, len(providers))
+	for _, provider := range providers {
+		providersByName[provider.Name()] = provider
+	}
+	return &OAuthService{
+		providers:     providersByName,
+		userRepo:      userRepo,
+		identityRepo:  identityRepo,
+		sessionIssuer: newSessionIssuer(jwtIssuer, userRepo, tokenRepo, transactor, resultsMigrator, log),
+	}
+}
+
+func (s *OAuthService) BeginOAuthLogin(providerName string) (authURL string, stateToken string, err error) {
+	provider, err := s.getProvider(providerName)
+	if err != nil {
+		return
+	}
+
+	state, err := randomToken()
+	if err != nil {
+		return
+	}
+	codeVerifier, err := randomToken()
+	if err != nil {
+		return
+	}
+	nonce, err := randomToken()
+	if err != nil {
+		return
+	}
+
+	authURL, err = provider.AuthCodeURL(state, codeVerifier, nonce)
+	if err != nil {
+		s.log.Warnf("authorization url for provider '%s' not built due to error: %v", providerName, err)
+		err = fmt.Errorf(`starting oauth login error: %w`, ports.InternalServerError)
+		return
+	}
+
+	// state, PKCE verifier and nonce are kept by the browser in signed cookie until callback
+	stateToken, err = s.jwtIssuer.GenerateOAuthStateJWT(
+		provider.Name(),
+		jwt.Claim{Name: "purpose", Value: oauthStatePurpose},
+		jwt.Claim{Name: "state", Value: state},
+		jwt.Claim{Name: "code_verifier", Value: codeVerifier},
+		jwt.Claim{Name: "nonce", Value: nonce},
+	)
+	if err != nil {
+		err = fmt.Errorf(`generating oauth state error: %w`, ports.InternalServerError)
+		return
+	}
+	return
+}
+
+func (s *OAuthService) FinishOAuthLogin(ctx context.Context, oauthCallbackDto dto.OAuthCallbackDto, stateToken string, session string, device dto.DeviceDto) (access string, refresh string, err error) {
+	provider, err := s.getProvider(oauthCallbackDto.Provider)
+	if err != nil {
+		return
+	}
+
+	claims, err := s.jwtIssuer.ParseJWT(stateToken)
+	if err != nil || claims["purpose"] != oauthStatePurpose || claims["sub"] != provider.Name() {
+		err = fmt.Errorf("invalid oauth state: %w", ports.BadRequestError)
+		return
+	}
+	state, _ := claims["state"].(string)
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(oauthCallbackDto.State)) != 1 {
+		err = fmt.Errorf("oauth state mismatch: %w", ports.BadRequestError)
+		return
+	}
+	if oauthCallbackDto.Error != "" {
+		err = fmt.Errorf("authorization denied by provider with '%s': %w", oauthCallbackDto.Error, ports.UnauthorizedError)
+		return
+	}
+	if oauthCallbackDto.Code == "" {
+		err = fmt.Errorf("authorization code is missing: %w", ports.BadRequestError)
+		return
+	}
+
+	codeVerifier, _ := claims["code_verifier"].(string)
+	nonce, _ := claims["nonce"].(string)
+	profile, err := provider.Exchange(ctx, oauthCallbackDto.Code, codeVerifier, nonce)
+	if err != nil {
+		s.log.Warnf("oauth login with provider '%s' failed due to error: %v", provider.Name(), err)
+		err = fmt.Errorf("oauth login failed: %w", ports.UnauthorizedError)
+		return
+	}
+
+	user, err := s.getOrCreateUser(ctx, profile)
+	if err != nil {
+		return
+	}
+	return s.createSession(ctx, user, session, device)
+}
+
+func (s *OAuthService) getProvider(providerName string) (ports.IdentityProvider, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return nil, fmt.Errorf("identity provider '%s' not found: %w", providerName, ports.NotFoundError)
+	}
+	return provider, nil
+}
+
+func (s *OAuthService) getOrCreateUser(ctx context.Context, profile domain.ExternalProfile) (user domain.User, err error) {
+	identity, err := s.identityRepo.GetIdentity(ctx, profile.Provider, profile.Subject)
+	if err == nil {
+		user, err = s.userRepo.GetUserByID(ctx, identity.User.Hex())
+		if err != nil {
+			err = fmt.Errorf("user not found: %w", ports.NotFoundError)
+		}
+		return
+	}
+
+	if profile.Email != "" {
+		user, err = s.userRepo.GetUserByEmail(ctx, profile.Email)
+	}
+	if profile.Email != "" && err == nil {
+		// linking by email is safe only when both sides proved ownership of the address
+		if !profile.EmailVerified || !user.EmailVerified {
+			err = fmt.Errorf("account with this email already exists: %w", ports.BadRequestError)
+			return
+		}
+	} else {
+		user, err = s.provisionUser(ctx, profile)
+		if err != nil {
+			return
+		}
+	}
+
+	_, err = s.identityRepo.CreateIdentity(ctx, domain.Identity{
+		User:     user.ID,
+		Provider: profile.Provider,
+		Subject:  profile.Subject,
+		Email:    profile.Email,
+	})
+	if err != nil {
+		s.log.Warnf("identity not saved due to error: %v", err)
+		err = fmt.Errorf(`saving identity error: %w`, ports.InternalServerError)
+		return
+	}
+	return user, nil
+}
+
+func (s *OAuthService) provisionUser(ctx context.Context, profile domain.ExternalProfile) (user domain.User, err error) {
+	nickname, err := s.uniqueNickname(ctx, profile)
+	if err != nil {
+		return
+	}
+
+	user, err = s.userRepo.SaveUser(ctx, domain.User{
+		Nickname:      nickname,
+		Email:         profile.Email,
+		EmailVerified: profile.Email != "" && profile.EmailVerified,
+	})
+	if err != nil {
+		s.log.Warnf("user not saved due to error: %v", err)
+		err = fmt.Errorf(`saving user error: %w`, ports.InternalServerError)
+		return
+	}
+	return
+}
+
+func (s *OAuthService) uniqueNickname(ctx context.Context, profile domain.ExternalProfile) (string, error) {
+	base := nicknameBase(profile)
+	nickname := base
+	for range nicknameSuffixAttempts {
+		_, err := s.userRepo.GetUserByNickname(ctx, nickname)
+		if err != nil {
+			return nickname, nil
+		}
+
+		suffix, err := rand.Int(rand.Reader, big.NewInt(10000))
+		if err != nil {
+			return "", fmt.Errorf(`generating nickname error: %w`, ports.InternalServerError)
+		}
+		nickname = fmt.Sprintf("%s_%04d", base[:min(len(base), maxNicknameLength-5)], suffix.Int64())
+	}
+	return "", fmt.Errorf(`no free nickname for '%s': %w`, base, ports.InternalServerError)
+}
+
+func nicknameBase(profile domain.ExternalProfile) string {
+	candidates := []string{
+		profile.Nickname,
+		strings.Split(profile.Email, "@")[0],
+	}
+	for _, candidate := range candidates {
+		candidate = nicknameDisallowedChars.ReplaceAllString(candidate, "")
+		if candidate != "" {
+			return candidate[:min(len(candidate), maxNicknameLength)]
+		}
+	}
+	return defaultNickname
+}
+
+func randomToken() (string, error) {
+	raw := make([]byte, 32)
+	_, err := rand.Read(raw)
+	if err != nil {
+		return "", fmt.Errorf(`generating random token error: %w`, ports.InternalServerError)
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
@@ -0,0 +1,183 @@
+package servises
+
+import (
+	"context"
+	"fmt"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports/dto"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports/mocks"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/logging/nop"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"testing"
+)
+
+func TestOAuthLogin(t *testing.T) {
+	var log = nop.GetLogger()
+	jwtIssuer := newIssuer()
+	// mocks
+	provider := new(mocks.IdentityProvider)
+	userRepo := new(mocks.UserRepository)
+	identityRepo := new(mocks.IdentityRepository)
+	tokenRepo := new(mocks.RefreshTokenRepository)
+	eventDispatcher := new(mocks.EventDispatcher)
+
+	linkedUser := domain.User{Nickname: gofakeit.Username()}
+	linkedUser.ID = primitive.NewObjectID()
+	verifiedUser := domain.User{Email: "verified@example.com", EmailVerified: true}
+	verifiedUser.ID = primitive.NewObjectID()
+
+	provider.
+		On("Name").
+		Return("stand-in")
+	provider.
+		On("AuthCodeURL", mock.Anything, mock.Anything, mock.Anything).
+		Return("https://idp.example.com/authorize", nil)
+	provider.
+		On("Exchange", mock.Anything, "new", mock.Anything, mock.Anything).
+		Return(domain.ExternalProfile{Provider: "stand-in", Subject: "1", Email: "new@example.com", EmailVerified: true, Nickname: "taken nick!"}, nil)
+	provider.
+		On("Exchange", mock.Anything, "linked", mock.Anything, mock.Anything).
+		Return(domain.ExternalProfile{Provider: "stand-in", Subject: "2"}, nil)
+	provider.
+		On("Exchange", mock.Anything, "verified", mock.Anything, mock.Anything).
+		Return(domain.ExternalProfile{Provider: "stand-in", Subject: "3", Email: verifiedUser.Email, EmailVerified: true}, nil)
+	provider.
+		On("Exchange", mock.Anything, "unverified", mock.Anything, mock.Anything).
+		Return(domain.ExternalProfile{Provider: "stand-in", Subject: "4", Email: verifiedUser.Email}, nil)
+	provider.
+		On("Exchange", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(domain.ExternalProfile{}, fmt.Errorf(""))
+	identityRepo.
+		On("GetIdentity", mock.Anything, "stand-in", "2").
+		Return(domain.Identity{User: linkedUser.ID}, nil)
+	identityRepo.
+		On("GetIdentity", mock.Anything, "stand-in", mock.Anything).
+		Return(domain.Identity{}, fmt.Errorf(""))
+	identityRepo.
+		On("CreateIdentity", mock.Anything, mock.Anything).
+		Return(gofakeit.UUID(), nil)
+	userRepo.
+		On("GetUserByID", mock.Anything, linkedUser.ID.Hex()).
+		Return(linkedUser, nil)
+	userRepo.
+		On("GetUserByEmail", mock.Anything, verifiedUser.Email).
+		Return(verifiedUser, nil)
+	userRepo.
+		On("GetUserByEmail", mock.Anything, mock.Anything).
+		Return(domain.User{}, fmt.Errorf(""))
+	userRepo.
+		On("GetUserByNickname", mock.Anything, "takennick").
+		Return(domain.User{}, nil)
+	userRepo.
+		On("GetUserByNickname", mock.Anything, mock.Anything).
+		Return(domain.User{}, fmt.Errorf(""))
+	userRepo.
+		On("SaveUser", mock.Anything, mock.Anything).
+		Return(func(_ context.Context, user domain.User) (domain.User, error) {
+			user.ID = primitive.NewObjectID()
+			return user, nil
+		})
+	tokenRepo.
+		On("CreateRefreshToken", mock.Anything, mock.Anything).
+		Return(gofakeit.UUID(), nil)
+	eventDispatcher.
+		On(
+			"Dispatch",
+			mock.Anything,
+			mock.MatchedBy(func(event domain.Event) bool {
+				return event.Exchange == ports.AuthExchange
+			}),
+		).Return(nil)
+
+	// service
+	oauthService := NewOAuthService([]ports.IdentityProvider{provider}, jwtIssuer, userRepo, identityRepo, tokenRepo, newTransactor(), eventDispatcher, log)
+
+	begin := func(t *testing.T) (stateToken string, state string) {
+		authURL, stateToken, err := oauthService.BeginOAuthLogin("stand-in")
+		assert.NoError(t, err)
+		assert.NotEmpty(t, authURL)
+		claims, err := jwtIssuer.ParseJWT(stateToken)
+		assert.NoError(t, err)
+		return stateToken, claims["state"].(string)
+	}
+
+	t.Run("successful oauth login provisions new user", func(t *testing.T) {
+		stateToken, state := begin(t)
+		access, refresh, err := oauthService.FinishOAuthLogin(context.Background(), dto.OAuthCallbackDto{
+			Provider: "stand-in",
+			Code:     "new",
+			State:    state,
+		}, stateToken, gofakeit.UUID(), dto.DeviceDto{})
+		assert.NoError(t, err)
+		assert.NotEmpty(t, access)
+		assert.NotEmpty(t, refresh)
+		userRepo.AssertCalled(t, "SaveUser", mock.Anything, mock.MatchedBy(func(user domain.User) bool {
+			return user.Nickname != "takennick" && len(user.Nickname) == len("takennick_0000") && user.EmailVerified
+		}))
+		eventDispatcher.AssertNumberOfCalls(t, "Dispatch", 1)
+	})
+	t.Run("successful oauth login with linked identity", func(t *testing.T) {
+		stateToken, state := begin(t)
+		_, _, err := oauthService.FinishOAuthLogin(context.Background(), dto.OAuthCallbackDto{
+			Provider: "stand-in",
+			Code:     "linked",
+			State:    state,
+		}, stateToken, gofakeit.UUID(), dto.DeviceDto{})
+		assert.NoError(t, err)
+	})
+	t.Run("successful oauth login links account with same verified email", func(t *testing.T) {
+		stateToken, state := begin(t)
+		_, _, err := oauthService.FinishOAuthLogin(context.Background(), dto.OAuthCallbackDto{
+			Provider: "stand-in",
+			Code:     "verified",
+			State:    state,
+		}, stateToken, "", dto.DeviceDto{})
+		assert.NoError(t, err)
+		identityRepo.AssertCalled(t, "CreateIdentity", mock.Anything, domain.Identity{
+			User:     verifiedUser.ID,
+			Provider: "stand-in",
+			Subject:  "3",
+			Email:    verifiedUser.Email,
+		})
+	})
+	t.Run("unsuccessful oauth login due to unverified email of existing account", func(t *testing.T) {
+		stateToken, state := begin(t)
+		_, _, err := oauthService.FinishOAuthLogin(context.Background(), dto.OAuthCallbackDto{
+			Provider: "stand-in",
+			Code:     "unverified",
+			State:    state,
+		}, stateToken, "", dto.DeviceDto{})
+		assert.Error(t, err)
+	})
+	t.Run("unsuccessful oauth login due to state mismatch", func(t *testing.T) {
+		stateToken, _ := begin(t)
+		_, _, err := oauthService.FinishOAuthLogin(context.Background(), dto.OAuthCallbackDto{
+			Provider: "stand-in",
+			Code:     "new",
+			State:    "forged",
+		}, stateToken, "", dto.DeviceDto{})
+		assert.Error(t, err)
+	})
+	t.Run("unsuccessful oauth login due to failed code exchange", func(t *testing.T) {
+		stateToken, state := begin(t)
+		_, _, err := oauthService.FinishOAuthLogin(context.Background(), dto.OAuthCallbackDto{
+			Provider: "stand-in",
+			Code:     "invalid",
+			State:    state,
+		}, stateToken, "", dto.DeviceDto{})
+		assert.Error(t, err)
+	})
+	t.Run("unsuccessful oauth login due to unknown provider", func(t *testing.T) {
+		_, _, err := oauthService.BeginOAuthLogin("unknown")
+		assert.Error(t, err)
+	})
+	provider.AssertExpectations(t)
+	userRepo.AssertExpectations(t)
+	identityRepo.AssertExpectations(t)
+	tokenRepo.AssertExpectations(t)
+	eventDispatcher.AssertExpectations(t)
+}
@@ -0,0 +1,387 @@
+package servises
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports/dto"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/digest"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/jwt"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/logging"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	scopeOpenID             = "openid"
+	scopeProfile            = "profile"
+	scopeEmail              = "email"
+	authorizationCodeGrant  = "authorization_code"
+	codeResponseType        = "code"
+	codeChallengeMethodS256 = "S256"
+	accessTokenTypeHint     = "access_token"
+	refreshTokenTypeHint    = "refresh_token"
+)
+
+var supportedScopes = []string{scopeOpenID, scopeProfile, scopeEmail}
+
+type OIDCService struct {
+	issuer               string
+	authorizationCodeExp time.Duration
+	jwtIssuer            *jwt.Issuer
+	clientRepo           ports.OAuthClientRepository
+	codeRepo             ports.AuthorizationCodeRepository
+	userRepo             ports.UserRepository
+	tokenRepo            ports.RefreshTokenRepository
+	revocationService    ports.RevocationService
+	log                  logging.Logger
+}
+
+func NewOIDCService(issuer string, authorizationCodeExp time.Duration, jwtIssuer *jwt.Issuer, clientRepo ports.OAuthClientRepository, codeRepo ports.AuthorizationCodeRepository, userRepo ports.UserRepository, tokenRepo ports.RefreshTokenRepository, revocationService ports.RevocationService, log logging.Logger) ports.OIDCService {
+	return &OIDCService{
+		issuer:               strings.TrimSuffix(issuer, "/"),
+		authorizationCodeExp: authorizationCodeExp,
+		jwtIssuer:            jwtIssuer,
+		clientRepo:           clientRepo,
+		codeRepo:             codeRepo,
+		userRepo:             userRepo,
+		tokenRepo:            tokenRepo,
+		revocationService:    revocationService,
+		log:                  log,
+	}
+}
+
+func (s *OIDCService) GetOpenIDConfiguration() dto.OpenIDConfigurationDto {
+	return dto.OpenIDConfigurationDto{
+		Issuer:                            s.issuer,
+		AuthorizationEndpoint:             s.issuer + "/api/v1/oauth/authorize",
+		TokenEndpoint:                     s.issuer + "/api/v1/oauth/token",
+		UserInfoEndpoint:                  s.issuer + "/api/v1/oauth/userinfo",
+		JWKSURI:                           s.issuer + "/.well-known/jwks.json",
+		IntrospectionEndpoint:             s.issuer + "/api/v1/auth/introspect",
+		ResponseTypesSupported:            []string{codeResponseType},
+		GrantTypesSupported:               []string{authorizationCodeGrant},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{s.jwtIssuer.SigningAlgorithm()},
+		ScopesSupported:                   supportedScopes,
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{codeChallengeMethodS256},
+		ClaimsSupported:                   []string{"sub", "iss", "aud", "exp", "iat", "nonce", "nickname", "preferred_username", "email", "email_verified"},
+	}
+}
+
+func (s *OIDCService) GetJSONWebKeySet() jwt.JSONWebKeySet {
+	return s.jwtIssuer.PublicJWKS()
+}
+
+func (s *OIDCService) Authorize(ctx context.Context, refreshToken string, authorizationRequestDto dto.AuthorizationRequestDto) (redirectURL string, err error) {
+	client, err := s.clientRepo.GetOAuthClient(ctx, authorizationRequestDto.ClientID)
+	if err != nil {
+		err = ports.NewOAuthError("invalid_request", "unknown client", ports.BadRequestError)
+		return
+	}
+	redirectURI := authorizationRequestDto.RedirectURI
+	if redirectURI == "" && len(client.RedirectURIs) == 1 {
+		redirectURI = client.RedirectURIs[0]
+	}
+	// redirect URI is compared exactly, otherwise code may leak to attacker
+	if !slices.Contains(client.RedirectURIs, redirectURI) {
+		err = ports.NewOAuthError("invalid_request", "redirect uri is not registered for client", ports.BadRequestError)
+		return
+	}
+
+	// since now errors are returned to client by redirect
+	if authorizationRequestDto.ResponseType != codeResponseType {
+		return s.redirect(redirectURI, authorizationRequestDto.State, url.Values{
+			"error": {"unsupported_response_type"},
+		})
+	}
+	if authorizationRequestDto.CodeChallenge == "" || authorizationRequestDto.CodeChallengeMethod != codeChallengeMethodS256 {
+		return s.redirect(redirectURI, authorizationRequestDto.State, url.Values{
+			"error":             {"invalid_request"},
+			"error_description": {"PKCE with S256 code challenge is required"},
+		})
+	}
+	scopes := strings.Fields(authorizationRequestDto.Scope)
+	for _, scope := range scopes {
+		if !slices.Contains(supportedScopes, scope) || !slices.Contains(client.Scopes, scope) {
+			return s.redirect(redirectURI, authorizationRequestDto.State, url.Values{
+				"error":             {"invalid_scope"},
+				"error_description": {fmt.Sprintf("scope '%s' is not allowed", scope)},
+			})
+		}
+	}
+
+	user, err := getUserByRefreshToken(ctx, s.userRepo, s.tokenRepo, refreshToken)
+	if err != nil {
+		return
+	}
+
+	code, err := randomToken()
+	if err != nil {
+		return
+	}
+	_, err = s.codeRepo.CreateAuthorizationCode(ctx, domain.AuthorizationCode{
+		Code:          digest.SHA256(code),
+		Client:        client.ClientID,
+		User:          user.ID,
+		RedirectURI:   authorizationRequestDto.RedirectURI,
+		Scope:         strings.Join(scopes, " "),
+		CodeChallenge: authorizationRequestDto.CodeChallenge,
+		Nonce:         authorizationRequestDto.Nonce,
+	})
+	if err != nil {
+		s.log.Warnf("authorization code not saved due to error: %v", err)
+		err = fmt.Errorf(`saving authorization code error: %w`, ports.InternalServerError)
+		return
+	}
+	return s.redirect(redirectURI, authorizationRequestDto.State, url.Values{
+		"code": {code},
+	})
+}
+
+func (s *OIDCService) Token(ctx context.Context, tokenRequestDto dto.TokenRequestDto) (tokenResponseDto dto.TokenResponseDto, err error) {
+	if tokenRequestDto.GrantType != authorizationCodeGrant {
+		err = ports.NewOAuthError("unsupported_grant_type", "only authorization_code grant is supported", ports.BadRequestError)
+		return
+	}
+	client, err := s.authenticateClient(ctx, tokenRequestDto.ClientID, tokenRequestDto.ClientSecret)
+	if err != nil {
+		return
+	}
+
+	authorizationCode, err := s.codeRepo.TakeAuthorizationCode(ctx, digest.SHA256(tokenRequestDto.Code))
+	if err != nil {
+		err = ports.NewOAuthError("invalid_grant", "authorization code is invalid", ports.BadRequestError)
+		return
+	}
+	if authorizationCode.Client != client.ClientID ||
+		authorizationCode.RedirectURI != tokenRequestDto.RedirectURI ||
+		authorizationCode.CreatedAt.Add(s.authorizationCodeExp).Before(time.Now()) {
+		err = ports.NewOAuthError("invalid_grant", "authorization code is invalid", ports.BadRequestError)
+		return
+	}
+	challenge := sha256.Sum256([]byte(tokenRequestDto.CodeVerifier))
+	if subtle.ConstantTimeCompare(
+		[]byte(base64.RawURLEncoding.EncodeToString(challenge[:])),
+		[]byte(authorizationCode.CodeChallenge),
+	) != 1 {
+		err = ports.NewOAuthError("invalid_grant", "code verifier does not match code challenge", ports.BadRequestError)
+		return
+	}
+
+	// user may be banned or delete account after code is issued
+	user, err := s.userRepo.GetUserByID(ctx, authorizationCode.User.Hex())
+	if err != nil || !user.DeletedAt.IsZero() {
+		err = ports.NewOAuthError("invalid_grant", "user not found", ports.BadRequestError)
+		return
+	}
+	if user.Banned {
+		err = ports.NewOAuthError("invalid_grant", "user is banned", ports.BadRequestError)
+		return
+	}
+
+	// client token is not accepted by first-party API, only by userinfo and introspection endpoints
+	tokenResponseDto.AccessToken, err = s.jwtIssuer.GenerateClientAccessJWT(
+		user.ID.Hex(),
+		client.ClientID,
+		jwt.Claim{Name: "nickname", Value: user.Nickname},
+		jwt.Claim{Name: "email_verified", Value: user.EmailVerified},
+		jwt.Claim{Name: "scope", Value: authorizationCode.Scope},
+	)
+	if err != nil {
+		err = fmt.Errorf(`generating tokens error: %w`, ports.InternalServerError)
+		return
+	}
+	if slices.Contains(strings.Fields(authorizationCode.Scope), scopeOpenID) {
+		tokenResponseDto.IDToken, err = s.generateIDToken(user, authorizationCode)
+		if err != nil {
+			return
+		}
+	}
+	tokenResponseDto.TokenType = "Bearer"
+	tokenResponseDto.ExpiresIn = int(s.jwtIssuer.AccessTokenExp().Seconds())
+	tokenResponseDto.Scope = authorizationCode.Scope
+	return
+}
+
+func (s *OIDCService) UserInfo(ctx context.Context, accessToken string) (userInfoResponseDto dto.UserInfoResponseDto, err error) {
+	claims, err := s.jwtIssuer.ParseClientAccessJWT(accessToken)
+	if err != nil || s.revocationService.IsAccessTokenRevoked(claims) {
+		err = ports.NewOAuthError("invalid_token", "access token is invalid", ports.UnauthorizedError)
+		return
+	}
+	scope, _ := claims["scope"].(string)
+	scopes := strings.Fields(scope)
+	if !slices.Contains(scopes, scopeOpenID) {
+		err = ports.NewOAuthError("insufficient_scope", "openid scope is required", ports.ForbiddenError)
+		return
+	}
+
+	sub, _ := claims["sub"].(string)
+	user, err := s.userRepo.GetUserByID(ctx, sub)
+	if err != nil {
+		err = ports.NewOAuthError("invalid_token", "user not found", ports.UnauthorizedError)
+		return
+	}
+
+	userInfoResponseDto.Sub = user.ID.Hex()
+	if slices.Contains(scopes, scopeProfile) {
+		userInfoResponseDto.Nickname = user.Nickname
+		userInfoResponseDto.PreferredUsername = user.Nickname
+	}
+	if slices.Contains(scopes, scopeEmail) {
+		userInfoResponseDto.Email = user.Email
+		userInfoResponseDto.EmailVerified = &user.EmailVerified
+	}
+	return
+}
+
+func (s *OIDCService) Introspect(ctx context.Context, introspectionRequestDto dto.IntrospectionRequestDto) (introspectionResponseDto dto.IntrospectionResponseDto, err error) {
+	client, err := s.authenticateClient(ctx, introspectionRequestDto.ClientID, introspectionRequestDto.ClientSecret)
+	if err != nil {
+		return
+	}
+	if client.SecretHash == "" {
+		err = ports.NewOAuthError("invalid_client", "only confidential clients may introspect tokens", ports.UnauthorizedError)
+		return
+	}
+
+	// hint only defines which type is checked first
+	introspectors := []func(context.Context, string) (dto.IntrospectionResponseDto, bool){s.introspectAccessToken, s.introspectRefreshToken}
+	if introspectionRequestDto.TokenTypeHint == refreshTokenTypeHint {
+		slices.Reverse(introspectors)
+	}
+	for _, introspect := range introspectors {
+		if introspectionResponseDto, ok := introspect(ctx, introspectionRequestDto.Token); ok {
+			return introspectionResponseDto, nil
+		}
+	}
+	return dto.IntrospectionResponseDto{Active: false}, nil
+}
+
+func (s *OIDCService) introspectAccessToken(ctx context.Context, accessToken string) (dto.IntrospectionResponseDto, bool) {
+	claims, err := s.jwtIssuer.ParseAccessJWT(accessToken)
+	if err != nil {
+		claims, err = s.jwtIssuer.ParseClientAccessJWT(accessToken)
+	}
+	if err != nil || s.revocationService.IsAccessTokenRevoked(claims) {
+		return dto.IntrospectionResponseDto{}, false
+	}
+	sub, _ := claims["sub"].(string)
+	// tokens of deleted, pending deletion or banned users are not active anymore
+	if user, err := s.userRepo.GetUserByID(ctx, sub); err != nil || user.Banned || !user.DeletedAt.IsZero() {
+		return dto.IntrospectionResponseDto{}, false
+	}
+
+	introspectionResponseDto := dto.IntrospectionResponseDto{
+		Active:    true,
+		Sub:       sub,
+		TokenType: accessTokenTypeHint,
+		Exp:       numericClaim(claims, "exp"),
+		Iat:       numericClaim(claims, "iat"),
+	}
+	introspectionResponseDto.Nickname, _ = claims["nickname"].(string)
+	introspectionResponseDto.Scope, _ = claims["scope"].(string)
+	introspectionResponseDto.ClientID, _ = claims["client_id"].(string)
+	return introspectionResponseDto, true
+}
+
+func (s *OIDCService) introspectRefreshToken(ctx context.Context, refreshToken string) (dto.IntrospectionResponseDto, bool) {
+	claims, err := s.jwtIssuer.ParseJWT(refreshToken)
+	if err != nil {
+		return dto.IntrospectionResponseDto{}, false
+	}
+	// refresh token is active while it is stored, rotation and logout remove it
+	token, err := s.tokenRepo.GetRefreshToken(ctx, refreshToken)
+	if err != nil {
+		return dto.IntrospectionResponseDto{}, false
+	}
+	user, err := s.userRepo.GetUserByID(ctx, token.User.Hex())
+	if err != nil || user.Banned || !user.DeletedAt.IsZero() {
+		return dto.IntrospectionResponseDto{}, false
+	}
+
+	return dto.IntrospectionResponseDto{
+		Active:    true,
+		Sub:       user.ID.Hex(),
+		Nickname:  user.Nickname,
+		TokenType: refreshTokenTypeHint,
+		Exp:       numericClaim(claims, "exp"),
+		Iat:       numericClaim(claims, "iat"),
+	}, true
+}
+
+func (s *OIDCService) authenticateClient(ctx context.Context, clientID string, clientSecret string) (domain.OAuthClient, error) {
+	client, err := s.clientRepo.GetOAuthClient(ctx, clientID)
+	if err != nil {
+		return client, ports.NewOAuthError("invalid_client", "client authentication failed", ports.UnauthorizedError)
+	}
+	// public clients have no secret, their codes are protected by PKCE only
+	if client.SecretHash == "" {
+		return client, nil
+	}
+	if subtle.ConstantTimeCompare([]byte(client.SecretHash), []byte(digest.SHA256(clientSecret))) != 1 {
+		return client, ports.NewOAuthError("invalid_client", "client authentication failed", ports.UnauthorizedError)
+	}
+	return client, nil
+}
+
+func (s *OIDCService) generateIDToken(user domain.User, authorizationCode domain.AuthorizationCode) (string, error) {
+	scopes := strings.Fields(authorizationCode.Scope)
+	claims := []jwt.Claim{
+		{Name: "iss", Value: s.issuer},
+		{Name: "aud", Value: authorizationCode.Client},
+		{Name: "azp", Value: authorizationCode.Client},
+	}
+	if authorizationCode.Nonce != "" {
+		claims = append(claims, jwt.Claim{Name: "nonce", Value: authorizationCode.Nonce})
+	}
+	if slices.Contains(scopes, scopeProfile) {
+		claims = append(claims,
+			jwt.Claim{Name: "nickname", Value: user.Nickname},
+			jwt.Claim{Name: "preferred_username", Value: user.Nickname},
+		)
+	}
+	if slices.Contains(scopes, scopeEmail) {
+		claims = append(claims,
+			jwt.Claim{Name: "email", Value: user.Email},
+			jwt.Claim{Name: "email_verified", Value: user.EmailVerified},
+		)
+	}
+
+	idToken, err := s.jwtIssuer.GenerateIDJWT(user.ID.Hex(), claims...)
+	if err != nil {
+		return "", fmt.Errorf(`generating id token error: %w`, ports.InternalServerError)
+	}
+	return idToken, nil
+}
+
+func (s *OIDCService) redirect(redirectURI string, state string, params url.Values) (string, error) {
+	redirectURL, err := url.Parse(redirectURI)
+	if err != nil {
+		return "", fmt.Errorf(`parsing redirect uri error: %w`, ports.InternalServerError)
+	}
+	query := redirectURL.Query()
+	for name, values := range params {
+		query[name] = values
+	}
+	if state != "" {
+		query.Set("state", state)
+	}
+	// RFC 9207 issuer identification against mix-up attacks
+	query.Set("iss", s.issuer)
+	redirectURL.RawQuery = query.Encode()
+	return redirectURL.String(), nil
+}
+
+func numericClaim(claims map[string]interface{}, name string) int64 {
+	value, _ := claims[name].(float64)
+	return int64(value)
+}
@@ -0,0 +1,349 @@
+package servises
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports/dto"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports/mocks"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/digest"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/jwt"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/logging/nop"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestOIDCAuthorizationCodeFlow(t *testing.T) {
+	var log = nop.GetLogger()
+	jwtIssuer := newIssuer()
+	// mocks
+	clientRepo := new(mocks.OAuthClientRepository)
+	codeRepo := new(mocks.AuthorizationCodeRepository)
+	userRepo := new(mocks.UserRepository)
+	tokenRepo := new(mocks.RefreshTokenRepository)
+	revokedTokenRepo := new(mocks.RevokedAccessTokenRepository)
+
+	user := domain.User{
+		Nickname:      gofakeit.Username(),
+		Email:         gofakeit.Email(),
+		EmailVerified: true,
+	}
+	user.ID = primitive.NewObjectID()
+	client := domain.OAuthClient{
+		ClientID:     "results-service",
+		SecretHash:   digest.SHA256("secret"),
+		RedirectURIs: []string{"https://results.example.com/callback"},
+		Scopes:       []string{"openid", "profile", "email"},
+	}
+
+	// in-memory storage behind repository mock
+	codes := map[string]domain.AuthorizationCode{}
+
+	clientRepo.
+		On("GetOAuthClient", mock.Anything, client.ClientID).
+		Return(client, nil)
+	clientRepo.
+		On("GetOAuthClient", mock.Anything, mock.Anything).
+		Return(domain.OAuthClient{}, fmt.Errorf(""))
+	tokenRepo.
+		On("GetRefreshToken", mock.Anything, "refresh").
+		Return(domain.RefreshToken{User: user.ID}, nil)
+	tokenRepo.
+		On("GetRefreshToken", mock.Anything, mock.Anything).
+		Return(domain.RefreshToken{}, fmt.Errorf(""))
+	userRepo.
+		On("GetUserByID", mock.Anything, user.ID.Hex()).
+		Return(func(_ context.Context, ID string) (domain.User, error) {
+			return user, nil
+		})
+	codeRepo.
+		On("CreateAuthorizationCode", mock.Anything, mock.Anything).
+		Return(func(_ context.Context, code domain.AuthorizationCode) (string, error) {
+			code.CreatedAt = time.Now()
+			codes[code.Code] = code
+			return gofakeit.UUID(), nil
+		})
+	codeRepo.
+		On("TakeAuthorizationCode", mock.Anything, mock.AnythingOfType("string")).
+		Return(func(_ context.Context, hash string) (domain.AuthorizationCode, error) {
+			code, ok := codes[hash]
+			if !ok {
+				return code, fmt.Errorf("")
+			}
+			delete(codes, hash)
+			return code, nil
+		})
+
+	// service
+	revocationService := NewRevocationService(jwtIssuer, revokedTokenRepo, log)
+	oidcService := NewOIDCService("http://localhost:8090", time.Minute, jwtIssuer, clientRepo, codeRepo, userRepo, tokenRepo, revocationService, log)
+
+	verifier := "verifierverifierverifierverifierverifierveri"
+	challenge := sha256.Sum256([]byte(verifier))
+	authorizationRequestDto := dto.AuthorizationRequestDto{
+		ResponseType:        "code",
+		ClientID:            client.ClientID,
+		RedirectURI:         client.RedirectURIs[0],
+		Scope:               "openid profile email",
+		State:               "state",
+		Nonce:               "nonce",
+		CodeChallenge:       base64.RawURLEncoding.EncodeToString(challenge[:]),
+		CodeChallengeMethod: "S256",
+	}
+	authorize := func(t *testing.T) string {
+		redirectURL, err := oidcService.Authorize(context.Background(), "refresh", authorizationRequestDto)
+		assert.NoError(t, err)
+		parsedURL, _ := url.Parse(redirectURL)
+		assert.Equal(t, "state", parsedURL.Query().Get("state"))
+		return parsedURL.Query().Get("code")
+	}
+	tokenRequest := func(code string) dto.TokenRequestDto {
+		return dto.TokenRequestDto{
+			GrantType:    "authorization_code",
+			Code:         code,
+			RedirectURI:  client.RedirectURIs[0],
+			ClientID:     client.ClientID,
+			ClientSecret: "secret",
+			CodeVerifier: verifier,
+		}
+	}
+
+	t.Run("successful code exchange", func(t *testing.T) {
+		tokenResponseDto, err := oidcService.Token(context.Background(), tokenRequest(authorize(t)))
+		assert.NoError(t, err)
+		assert.NotEmpty(t, tokenResponseDto.AccessToken)
+		_, err = jwtIssuer.ParseAccessJWT(tokenResponseDto.AccessToken)
+		assert.Error(t, err, "client token must not be accepted by first-party API")
+
+		claims, err := jwtIssuer.ParseJWT(tokenResponseDto.IDToken)
+		assert.NoError(t, err)
+		assert.Equal(t, client.ClientID, claims["aud"])
+		assert.Equal(t, "nonce", claims["nonce"])
+		assert.Equal(t, user.Nickname, claims["nickname"])
+		assert.Equal(t, user.Email, claims["email"])
+	})
+	t.Run("successful userinfo", func(t *testing.T) {
+		tokenResponseDto, _ := oidcService.Token(context.Background(), tokenRequest(authorize(t)))
+
+		userInfoResponseDto, err := oidcService.UserInfo(context.Background(), tokenResponseDto.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, user.ID.Hex(), userInfoResponseDto.Sub)
+		assert.Equal(t, user.Email, userInfoResponseDto.Email)
+	})
+	t.Run("unsuccessful authorization due to unregistered redirect uri", func(t *testing.T) {
+		request := authorizationRequestDto
+		request.RedirectURI = "https://attacker.example.com/callback"
+		_, err := oidcService.Authorize(context.Background(), "refresh", request)
+		assert.Error(t, err)
+	})
+	t.Run("unsuccessful authorization due to missing pkce", func(t *testing.T) {
+		request := authorizationRequestDto
+		request.CodeChallenge = ""
+		redirectURL, err := oidcService.Authorize(context.Background(), "refresh", request)
+		assert.NoError(t, err)
+		parsedURL, _ := url.Parse(redirectURL)
+		assert.Equal(t, "invalid_request", parsedURL.Query().Get("error"))
+	})
+	t.Run("unsuccessful authorization due to not logged in user", func(t *testing.T) {
+		_, err := oidcService.Authorize(context.Background(), "", authorizationRequestDto)
+		assert.Error(t, err)
+	})
+	t.Run("unsuccessful code exchange due to wrong code verifier", func(t *testing.T) {
+		request := tokenRequest(authorize(t))
+		request.CodeVerifier = "anotheranotheranotheranotheranotheranotherano"
+		_, err := oidcService.Token(context.Background(), request)
+		assert.Error(t, err)
+	})
+	t.Run("unsuccessful code exchange due to wrong client secret", func(t *testing.T) {
+		request := tokenRequest(authorize(t))
+		request.ClientSecret = "guess"
+		_, err := oidcService.Token(context.Background(), request)
+		assert.Error(t, err)
+	})
+	t.Run("unsuccessful code exchange due to reused code", func(t *testing.T) {
+		code := authorize(t)
+		_, err := oidcService.Token(context.Background(), tokenRequest(code))
+		assert.NoError(t, err)
+
+		_, err = oidcService.Token(context.Background(), tokenRequest(code))
+		assert.Error(t, err)
+	})
+	t.Run("unsuccessful code exchange due to user banned after authorization", func(t *testing.T) {
+		code := authorize(t)
+		user.Banned = true
+		defer func() {
+			user.Banned = false
+		}()
+
+		_, err := oidcService.Token(context.Background(), tokenRequest(code))
+		assert.ErrorIs(t, err, ports.BadRequestError)
+	})
+	t.Run("unsuccessful code exchange due to account deleted after authorization", func(t *testing.T) {
+		code := authorize(t)
+		user.DeletedAt = time.Now()
+		defer func() {
+			user.DeletedAt = time.Time{}
+		}()
+
+		_, err := oidcService.Token(context.Background(), tokenRequest(code))
+		assert.ErrorIs(t, err, ports.BadRequestError)
+	})
+	clientRepo.AssertExpectations(t)
+	codeRepo.AssertExpectations(t)
+	userRepo.AssertExpectations(t)
+	tokenRepo.AssertExpectations(t)
+	revokedTokenRepo.AssertExpectations(t)
+}
+
+func TestIntrospection(t *testing.T) {
+	var log = nop.GetLogger()
+	jwtIssuer := newIssuer()
+	// mocks
+	clientRepo := new(mocks.OAuthClientRepository)
+	codeRepo := new(mocks.AuthorizationCodeRepository)
+	userRepo := new(mocks.UserRepository)
+	tokenRepo := new(mocks.RefreshTokenRepository)
+	revokedTokenRepo := new(mocks.RevokedAccessTokenRepository)
+
+	user := domain.User{
+		Nickname: gofakeit.Username(),
+		Email:    gofakeit.Email(),
+	}
+	user.ID = primitive.NewObjectID()
+	deletedUser := domain.User{
+		Nickname:  gofakeit.Username(),
+		Email:     gofakeit.Email(),
+		DeletedAt: time.Now(),
+	}
+	deletedUser.ID = primitive.NewObjectID()
+	client := domain.OAuthClient{
+		ClientID:   "results-service",
+		SecretHash: digest.SHA256("secret"),
+	}
+	publicClient := domain.OAuthClient{
+		ClientID: "spa",
+	}
+
+	accessToken, _ := jwtIssuer.GenerateAccessJWT(user.ID.Hex(), jwt.Claim{Name: "nickname", Value: user.Nickname})
+	refreshToken, _ := jwtIssuer.GenerateRefreshJWT(user.ID.Hex(), jwt.Claim{Name: "family", Value: "family"})
+	revokedRefreshToken, _ := jwtIssuer.GenerateRefreshJWT(user.ID.Hex(), jwt.Claim{Name: "family", Value: "revoked"})
+	strangerAccessToken, _ := jwtIssuer.GenerateAccessJWT(primitive.NewObjectID().Hex())
+	deletedUserAccessToken, _ := jwtIssuer.GenerateAccessJWT(deletedUser.ID.Hex())
+	revokedAccessToken, _ := jwtIssuer.GenerateAccessJWT(user.ID.Hex())
+	clientAccessToken, _ := jwtIssuer.GenerateClientAccessJWT(user.ID.Hex(), client.ClientID, jwt.Claim{Name: "scope", Value: "openid"})
+
+	clientRepo.
+		On("GetOAuthClient", mock.Anything, client.ClientID).
+		Return(client, nil)
+	clientRepo.
+		On("GetOAuthClient", mock.Anything, publicClient.ClientID).
+		Return(publicClient, nil)
+	userRepo.
+		On("GetUserByID", mock.Anything, user.ID.Hex()).
+		Return(user, nil)
+	userRepo.
+		On("GetUserByID", mock.Anything, deletedUser.ID.Hex()).
+		Return(deletedUser, nil)
+	userRepo.
+		On("GetUserByID", mock.Anything, mock.AnythingOfType("string")).
+		Return(domain.User{}, fmt.Errorf(""))
+	tokenRepo.
+		On("GetRefreshToken", mock.Anything, refreshToken).
+		Return(domain.RefreshToken{User: user.ID, Token: refreshToken}, nil)
+	tokenRepo.
+		On("GetRefreshToken", mock.Anything, mock.AnythingOfType("string")).
+		Return(domain.RefreshToken{}, fmt.Errorf(""))
+	revokedTokenRepo.
+		On("CreateRevokedAccessToken", mock.Anything, mock.AnythingOfType("domain.RevokedAccessToken")).
+		Return(primitive.NewObjectID().Hex(), nil)
+
+	// service
+	revocationService := NewRevocationService(jwtIssuer, revokedTokenRepo, log)
+	oidcService := NewOIDCService("http://localhost:8090", time.Minute, jwtIssuer, clientRepo, codeRepo, userRepo, tokenRepo, revocationService, log)
+
+	introspect := func(token string, hint string) (dto.IntrospectionResponseDto, error) {
+		return oidcService.Introspect(context.Background(), dto.IntrospectionRequestDto{
+			Token:         token,
+			TokenTypeHint: hint,
+			ClientID:      client.ClientID,
+			ClientSecret:  "secret",
+		})
+	}
+
+	t.Run("successful introspection of access token", func(t *testing.T) {
+		introspectionResponseDto, err := introspect(accessToken, "")
+		assert.NoError(t, err)
+		assert.True(t, introspectionResponseDto.Active)
+		assert.Equal(t, user.ID.Hex(), introspectionResponseDto.Sub)
+		assert.Equal(t, user.Nickname, introspectionResponseDto.Nickname)
+		assert.Equal(t, "access_token", introspectionResponseDto.TokenType)
+		assert.NotZero(t, introspectionResponseDto.Exp)
+	})
+	t.Run("successful introspection of client access token", func(t *testing.T) {
+		introspectionResponseDto, err := introspect(clientAccessToken, "")
+		assert.NoError(t, err)
+		assert.True(t, introspectionResponseDto.Active)
+		assert.Equal(t, client.ClientID, introspectionResponseDto.ClientID)
+		assert.Equal(t, "openid", introspectionResponseDto.Scope)
+	})
+	t.Run("successful introspection of refresh token", func(t *testing.T) {
+		introspectionResponseDto, err := introspect(refreshToken, "refresh_token")
+		assert.NoError(t, err)
+		assert.True(t, introspectionResponseDto.Active)
+		assert.Equal(t, "refresh_token", introspectionResponseDto.TokenType)
+	})
+	t.Run("successful introspection of revoked refresh token", func(t *testing.T) {
+		introspectionResponseDto, err := introspect(revokedRefreshToken, "refresh_token")
+		assert.NoError(t, err)
+		assert.False(t, introspectionResponseDto.Active)
+	})
+	t.Run("successful introspection of token of deleted user", func(t *testing.T) {
+		introspectionResponseDto, err := introspect(strangerAccessToken, "")
+		assert.NoError(t, err)
+		assert.False(t, introspectionResponseDto.Active)
+	})
+	t.Run("successful introspection of token of user pending deletion", func(t *testing.T) {
+		introspectionResponseDto, err := introspect(deletedUserAccessToken, "")
+		assert.NoError(t, err)
+		assert.False(t, introspectionResponseDto.Active)
+	})
+	t.Run("successful introspection of revoked access token", func(t *testing.T) {
+		err := revocationService.RevokeAccessToken(context.Background(), revokedAccessToken)
+		assert.NoError(t, err)
+
+		introspectionResponseDto, err := introspect(revokedAccessToken, "")
+		assert.NoError(t, err)
+		assert.False(t, introspectionResponseDto.Active)
+
+		introspectionResponseDto, err = introspect(accessToken, "")
+		assert.NoError(t, err)
+		assert.True(t, introspectionResponseDto.Active)
+	})
+	t.Run("unsuccessful introspection by public client", func(t *testing.T) {
+		_, err := oidcService.Introspect(context.Background(), dto.IntrospectionRequestDto{
+			Token:    accessToken,
+			ClientID: publicClient.ClientID,
+		})
+		assert.Error(t, err)
+	})
+	t.Run("unsuccessful introspection due to wrong client secret", func(t *testing.T) {
+		_, err := oidcService.Introspect(context.Background(), dto.IntrospectionRequestDto{
+			Token:        accessToken,
+			ClientID:     client.ClientID,
+			ClientSecret: "guess",
+		})
+		assert.Error(t, err)
+	})
+	clientRepo.AssertExpectations(t)
+	userRepo.AssertExpectations(t)
+	tokenRepo.AssertExpectations(t)
+	revokedTokenRepo.AssertExpectations(t)
+}
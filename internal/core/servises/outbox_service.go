@@ -0,0 +1,142 @@
+package servises
+
+import (
+	"context"
+	"fmt"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/logging"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// outboxLease is how long relay keeps publishing without renewing its lease, instance that stopped loses it afterwards
+const outboxLease = 30 * time.Second
+
+type OutboxService struct {
+	outboxRepo     ports.OutboxRepository
+	eventPublisher ports.EventPublisher
+	batchSize      int
+	// retryDelay is wait after the first failed publication, it doubles with every next failure up to maxRetryDelay
+	retryDelay    time.Duration
+	maxRetryDelay time.Duration
+	// maxAttempts failed publications mark event failed, so it does not hold back later events of its key forever
+	maxAttempts int
+	// owner identifies relay of this instance in lease
+	owner string
+	// now is read on every batch, so lease is renewed for time batch is actually relayed in
+	now func() time.Time
+	log logging.Logger
+}
+
+func NewOutboxService(batchSize int, retryDelay time.Duration, maxRetryDelay time.Duration, maxAttempts int, outboxRepo ports.OutboxRepository, eventPublisher ports.EventPublisher, log logging.Logger) ports.OutboxService {
+	return &OutboxService{
+		outboxRepo:     outboxRepo,
+		eventPublisher: eventPublisher,
+		batchSize:      batchSize,
+		retryDelay:     retryDelay,
+		maxRetryDelay:  maxRetryDelay,
+		maxAttempts:    maxAttempts,
+		owner:          primitive.NewObjectID().Hex(),
+		now:            time.Now,
+		log:            log,
+	}
+}
+
+func (s *OutboxService) Dispatch(ctx context.Context, event domain.Event) error {
+	_, err := s.outboxRepo.CreateOutboxEvent(ctx, domain.OutboxEvent{
+		Exchange: event.Exchange,
+		Key:      event.Key,
+		Body:     event.Body,
+	})
+	if err != nil {
+		s.log.Warnf("event not saved to outbox due to error: %v", err)
+		return fmt.Errorf(`saving event error: %w`, ports.InternalServerError)
+	}
+	return nil
+}
+
+func (s *OutboxService) RunOutboxRelay(ctx context.Context, interval time.Duration) {
+	for {
+		s.relayEvents(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// relayEvents publishes events whose retry is due, event is loaded only once earlier events of its exchange and key
+// are delivered or failed, so consumers get events of key in order. Events waiting for retry are not loaded at all,
+// so they do not take place of due events in batch. Next events of keys are loaded as long as batch makes progress
+func (s *OutboxService) relayEvents(ctx context.Context) {
+	for ctx.Err() == nil {
+		now := s.now()
+		acquired, err := s.outboxRepo.AcquireOutboxLease(ctx, s.owner, now.Add(outboxLease))
+		if err != nil {
+			s.log.Warnf("outbox lease not acquired due to error: %v", err)
+			return
+		}
+		if !acquired {
+			return
+		}
+
+		events, err := s.outboxRepo.GetDueOutboxEvents(ctx, now, s.batchSize)
+		if err != nil {
+			s.log.Warnf("outbox events not loaded due to error: %v", err)
+			return
+		}
+		progressed := false
+		for _, event := range events {
+			if ctx.Err() != nil {
+				return
+			}
+			if s.relayEvent(ctx, event, now) {
+				progressed = true
+			}
+		}
+		if !progressed {
+			return
+		}
+	}
+}
+
+// relayEvent publishes event, true is returned when event is delivered or failed for good, so next event of its key may go
+func (s *OutboxService) relayEvent(ctx context.Context, event domain.OutboxEvent, now time.Time) bool {
+	err := s.eventPublisher.Publish(ctx, domain.Event{
+		ID:       event.ID.Hex(),
+		Exchange: event.Exchange,
+		Key:      event.Key,
+		Body:     event.Body,
+	})
+	if err != nil {
+		event.Attempts++
+		event.NextAttemptAt = now.Add(s.backoff(event.Attempts))
+		event.LastError = err.Error()
+		if event.Attempts >= s.maxAttempts {
+			event.FailedAt = now
+			s.log.Errorf("outbox event '%s' of exchange '%s' failed after %d attempts and is not published again, due to error: %v", event.ID.Hex(), event.Exchange, event.Attempts, err)
+		} else {
+			s.log.Warnf("outbox event '%s' not published, attempt %d, due to error: %v", event.ID.Hex(), event.Attempts, err)
+		}
+	} else {
+		event.DeliveredAt = now
+	}
+
+	_, updateErr := s.outboxRepo.UpdateOutboxEvent(ctx, event)
+	if updateErr != nil {
+		// event is published again by the next run, consumers get it twice
+		s.log.Warnf("outbox event '%s' not updated due to error: %v", event.ID.Hex(), updateErr)
+		return false
+	}
+	return !event.DeliveredAt.IsZero() || !event.FailedAt.IsZero()
+}
+
+func (s *OutboxService) backoff(attempts int) time.Duration {
+	delay := s.retryDelay
+	for i := 1; i < attempts && delay < s.maxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, s.maxRetryDelay)
+}
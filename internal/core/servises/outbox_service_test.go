@@ -0,0 +1,228 @@
+package servises
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports/mocks"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/logging/nop"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"testing"
+	"time"
+)
+
+func TestOutboxRelay(t *testing.T) {
+	var log = nop.GetLogger()
+	// mocks
+	outboxRepo := new(mocks.OutboxRepository)
+	eventPublisher := new(mocks.EventPublisher)
+
+	// in-memory storage behind repository mock
+	var events []domain.OutboxEvent
+	leaseOwner := ""
+	var leases []time.Time
+	var published []string
+	brokerDown := false
+
+	outboxRepo.
+		On("CreateOutboxEvent", mock.Anything, mock.Anything).
+		Return(func(_ context.Context, event domain.OutboxEvent) (string, error) {
+			event.ID = primitive.NewObjectID()
+			events = append(events, event)
+			return event.ID.Hex(), nil
+		})
+	outboxRepo.
+		On("GetDueOutboxEvents", mock.Anything, mock.AnythingOfType("time.Time"), mock.AnythingOfType("int")).
+		Return(func(_ context.Context, now time.Time, limit int) ([]domain.OutboxEvent, error) {
+			// the oldest pending event of every key, events are stored in order they were created
+			var due []domain.OutboxEvent
+			pendingKeys := make(map[string]bool)
+			for _, event := range events {
+				if !event.DeliveredAt.IsZero() || !event.FailedAt.IsZero() {
+					continue
+				}
+				key := event.Exchange + ":" + event.Key
+				if event.Key == "" {
+					key = event.ID.Hex()
+				}
+				if pendingKeys[key] {
+					continue
+				}
+				pendingKeys[key] = true
+				if !event.NextAttemptAt.After(now) && len(due) < limit {
+					due = append(due, event)
+				}
+			}
+			return due, nil
+		})
+	outboxRepo.
+		On("UpdateOutboxEvent", mock.Anything, mock.Anything).
+		Return(func(_ context.Context, event domain.OutboxEvent) (domain.OutboxEvent, error) {
+			for i := range events {
+				if events[i].ID == event.ID {
+					events[i] = event
+				}
+			}
+			return event, nil
+		})
+	outboxRepo.
+		On("AcquireOutboxLease", mock.Anything, mock.AnythingOfType("string"), mock.Anything).
+		Return(func(_ context.Context, owner string, until time.Time) (bool, error) {
+			if leaseOwner != "" && leaseOwner != owner {
+				return false, nil
+			}
+			leaseOwner = owner
+			leases = append(leases, until)
+			return true, nil
+		})
+	eventPublisher.
+		On("Publish", mock.Anything, mock.Anything).
+		Return(func(_ context.Context, event domain.Event) error {
+			if brokerDown {
+				return fmt.Errorf("connection closed")
+			}
+			published = append(published, string(event.Body))
+			return nil
+		})
+
+	// service
+	outboxService := NewOutboxService(2, time.Second, time.Minute, 3, outboxRepo, eventPublisher, log).(*OutboxService)
+	dispatch := func(t *testing.T, key string, body string) {
+		err := outboxService.Dispatch(context.Background(), domain.Event{
+			Exchange: ports.AuthExchange,
+			Key:      key,
+			Body:     []byte(body),
+		})
+		assert.NoError(t, err)
+	}
+	relayAt := func(outboxService *OutboxService, now time.Time) {
+		outboxService.now = func() time.Time {
+			return now
+		}
+		outboxService.relayEvents(context.Background())
+	}
+
+	t.Run("successful relay of dispatched events", func(t *testing.T) {
+		dispatch(t, "user1", "login1")
+		dispatch(t, "user2", "login2")
+
+		relayAt(outboxService, time.Now())
+		assert.Equal(t, []string{"login1", "login2"}, published)
+		for _, event := range events {
+			assert.False(t, event.DeliveredAt.IsZero())
+		}
+	})
+	t.Run("successful retry of failed publication in order of key", func(t *testing.T) {
+		published = nil
+		now := time.Now()
+		brokerDown = true
+		dispatch(t, "user1", "first")
+		relayAt(outboxService, now)
+		assert.Empty(t, published)
+		assert.Equal(t, 1, events[len(events)-1].Attempts)
+
+		brokerDown = false
+		dispatch(t, "user1", "second")
+		dispatch(t, "user2", "other")
+		// later event of the same key waits for retry of the first one
+		relayAt(outboxService, now)
+		assert.Equal(t, []string{"other"}, published)
+
+		relayAt(outboxService, now.Add(time.Second))
+		assert.Equal(t, []string{"other", "first", "second"}, published)
+	})
+	t.Run("successful relay of another exchange while returned event waits for retry", func(t *testing.T) {
+		published = nil
+		now := time.Now()
+		brokerDown = true
+		dispatch(t, "user1", "returned")
+		relayAt(outboxService, now)
+
+		brokerDown = false
+		err := outboxService.Dispatch(context.Background(), domain.Event{
+			Exchange: ports.UserUpdatedExchange,
+			Key:      "user1",
+			Body:     []byte("updated"),
+		})
+		assert.NoError(t, err)
+		relayAt(outboxService, now)
+		assert.Equal(t, []string{"updated"}, published)
+
+		relayAt(outboxService, now.Add(time.Second))
+		assert.Equal(t, []string{"updated", "returned"}, published)
+	})
+	t.Run("successful relay of new events while batch of events waits for retry", func(t *testing.T) {
+		published = nil
+		now := time.Now()
+		brokerDown = true
+		dispatch(t, "user3", "waiting1")
+		dispatch(t, "user4", "waiting2")
+		relayAt(outboxService, now)
+
+		brokerDown = false
+		dispatch(t, "user5", "new")
+		relayAt(outboxService, now)
+		assert.Equal(t, []string{"new"}, published)
+
+		relayAt(outboxService, now.Add(time.Second))
+		assert.Equal(t, []string{"new", "waiting1", "waiting2"}, published)
+	})
+	t.Run("successful relay of later events of key after event failed for good", func(t *testing.T) {
+		published = nil
+		now := time.Now()
+		brokerDown = true
+		dispatch(t, "user6", "undeliverable")
+		for i := 0; i < 3; i++ {
+			relayAt(outboxService, now.Add(time.Duration(i)*time.Minute))
+		}
+		failed := events[len(events)-1]
+		assert.Equal(t, 3, failed.Attempts)
+		assert.False(t, failed.FailedAt.IsZero())
+
+		brokerDown = false
+		dispatch(t, "user6", "later")
+		relayAt(outboxService, now.Add(time.Hour))
+		assert.Equal(t, []string{"later"}, published)
+	})
+	t.Run("successful renewal of lease for every batch of long relay", func(t *testing.T) {
+		published, leases = nil, nil
+		dispatch(t, "user7", "batch1")
+		dispatch(t, "user8", "batch1")
+		dispatch(t, "user9", "batch2")
+
+		// every batch takes a minute
+		now := time.Now()
+		outboxService.now = func() time.Time {
+			now = now.Add(time.Minute)
+			return now
+		}
+		outboxService.relayEvents(context.Background())
+		assert.Len(t, published, 3)
+		assert.Len(t, leases, 3)
+		for i := 1; i < len(leases); i++ {
+			assert.Equal(t, time.Minute, leases[i].Sub(leases[i-1]))
+		}
+	})
+	t.Run("unsuccessful relay due to lease held by another instance", func(t *testing.T) {
+		published = nil
+		dispatch(t, "user1", "login")
+
+		anotherService := NewOutboxService(2, time.Second, time.Minute, 3, outboxRepo, eventPublisher, log).(*OutboxService)
+		relayAt(anotherService, time.Now())
+		assert.Empty(t, published)
+	})
+
+	outboxRepo.AssertExpectations(t)
+	eventPublisher.AssertExpectations(t)
+}
+
+func TestOutboxBackoff(t *testing.T) {
+	outboxService := NewOutboxService(10, time.Second, time.Minute, 3, nil, nil, nop.GetLogger()).(*OutboxService)
+
+	assert.Equal(t, time.Second, outboxService.backoff(1))
+	assert.Equal(t, 4*time.Second, outboxService.backoff(3))
+	assert.Equal(t, time.Minute, outboxService.backoff(10))
+}
@@ -0,0 +1,304 @@
+package servises
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports/dto"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/jwt"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/logging"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+type PasskeyService struct {
+	webAuthn       *webauthn.WebAuthn
+	userRepo       ports.UserRepository
+	credentialRepo ports.PasskeyCredentialRepository
+	sessionRepo    ports.PasskeySessionRepository
+	*sessionIssuer
+}
+
+func NewPasskeyService(webAuthn *webauthn.WebAuthn, jwtIssuer *jwt.Issuer, userRepo ports.UserRepository, credentialRepo ports.PasskeyCredentialRepository, sessionRepo ports.PasskeySessionRepository, tokenRepo ports.RefreshTokenRepository, transactor ports.Transactor, resultsMigrator ports.EventDispatcher, log logging.Logger) ports.PasskeyService {
+	return &PasskeyService{
+		webAuthn:       webAuthn,
+		userRepo:       userRepo,
+		credentialRepo: credentialRepo,
+		sessionRepo:    sessionRepo,
+		sessionIssuer:  newSessionIssuer(jwtIssuer, userRepo, tokenRepo, transactor, resultsMigrator, log),
+	}
+}
+
+func (s *PasskeyService) BeginPasskeyRegistration(ctx context.Context, refreshToken string) (*protocol.CredentialCreation, error) {
+	user, err := getUserByRefreshToken(ctx, s.userRepo, s.tokenRepo, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	webAuthnUser, err := s.getWebAuthnUser(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	requireResidentKey := true
+	creation, sessionData, err := s.webAuthn.BeginRegistration(
+		webAuthnUser,
+		webauthn.WithAuthenticatorSelection(protocol.AuthenticatorSelection{
+			RequireResidentKey: &requireResidentKey,
+			ResidentKey:        protocol.ResidentKeyRequirementRequired,
+			UserVerification:   protocol.VerificationRequired,
+		}),
+		webauthn.WithExclusions(
+			webauthn.Credentials(webAuthnUser.credentials).CredentialDescriptors(),
+		),
+	)
+	if err != nil {
+		s.log.Warnf("passkey registration not started due to error: %v", err)
+		return nil, fmt.Errorf(`starting passkey registration error: %w`, ports.InternalServerError)
+	}
+
+	err = s.saveSession(ctx, sessionData)
+	if err != nil {
+		return nil, err
+	}
+	return creation, nil
+}
+
+func (s *PasskeyService) FinishPasskeyRegistration(ctx context.Context, refreshToken string, credentialCreationResponse []byte) error {
+	user, err := getUserByRefreshToken(ctx, s.userRepo, s.tokenRepo, refreshToken)
+	if err != nil {
+		return err
+	}
+
+	parsedResponse, err := protocol.ParseCredentialCreationResponseBytes(credentialCreationResponse)
+	if err != nil {
+		return fmt.Errorf("invalid passkey registration response: %w", ports.BadRequestError)
+	}
+	sessionData, err := s.takeSession(ctx, parsedResponse.Response.CollectedClientData.Challenge)
+	if err != nil {
+		return err
+	}
+	if string(sessionData.UserID) != string(user.ID[:]) {
+		return fmt.Errorf("passkey registration was started by another user: %w", ports.BadRequestError)
+	}
+
+	webAuthnUser, err := s.getWebAuthnUser(ctx, user)
+	if err != nil {
+		return err
+	}
+	credential, err := s.webAuthn.CreateCredential(webAuthnUser, sessionData, parsedResponse)
+	if err != nil {
+		s.log.Warnf("passkey not registered due to error: %v", err)
+		return fmt.Errorf("passkey registration failed: %w", ports.BadRequestError)
+	}
+
+	transports := make([]string, 0, len(credential.Transport))
+	for _, transport := range credential.Transport {
+		transports = append(transports, string(transport))
+	}
+	_, err = s.credentialRepo.CreatePasskeyCredential(ctx, domain.PasskeyCredential{
+		User:            user.ID,
+		CredentialID:    credential.ID,
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		Transports:      transports,
+		Flags:           credentialFlags(credential.Flags),
+		AAGUID:          credential.Authenticator.AAGUID,
+		SignCount:       credential.Authenticator.SignCount,
+	})
+	if err != nil {
+		s.log.Warnf("passkey not saved due to error: %v", err)
+		return fmt.Errorf(`saving passkey error: %w`, ports.InternalServerError)
+	}
+	return nil
+}
+
+func (s *PasskeyService) BeginPasskeyLogin(ctx context.Context) (*protocol.CredentialAssertion, error) {
+	assertion, sessionData, err := s.webAuthn.BeginDiscoverableLogin(
+		webauthn.WithUserVerification(protocol.VerificationRequired),
+	)
+	if err != nil {
+		s.log.Warnf("passkey login not started due to error: %v", err)
+		return nil, fmt.Errorf(`starting passkey login error: %w`, ports.InternalServerError)
+	}
+
+	err = s.saveSession(ctx, sessionData)
+	if err != nil {
+		return nil, err
+	}
+	return assertion, nil
+}
+
+func (s *PasskeyService) FinishPasskeyLogin(ctx context.Context, credentialAssertionResponse []byte, session string, device dto.DeviceDto) (access string, refresh string, err error) {
+	parsedResponse, err := protocol.ParseCredentialRequestResponseBytes(credentialAssertionResponse)
+	if err != nil {
+		err = fmt.Errorf("invalid passkey login response: %w", ports.BadRequestError)
+		return
+	}
+	sessionData, err := s.takeSession(ctx, parsedResponse.Response.CollectedClientData.Challenge)
+	if err != nil {
+		return
+	}
+	if !sessionData.Expires.IsZero() && sessionData.Expires.Before(time.Now()) {
+		err = fmt.Errorf("passkey login expired: %w", ports.BadRequestError)
+		return
+	}
+
+	webAuthnUser, credential, err := s.webAuthn.ValidatePasskeyLogin(
+		func(rawID, userHandle []byte) (webauthn.User, error) {
+			return s.getWebAuthnUserByHandle(ctx, userHandle)
+		},
+		sessionData,
+		parsedResponse,
+	)
+	if err != nil {
+		s.log.Warnf("passkey login failed due to error: %v", err)
+		err = fmt.Errorf("passkey login failed: %w", ports.UnauthorizedError)
+		return
+	}
+	user := webAuthnUser.(*passkeyUser).user
+
+	// counter which did not grow means that private key may exist in several authenticators
+	if credential.Authenticator.CloneWarning {
+		s.log.Warnf("passkey sign count regression for user '%s', possibly cloned authenticator", user.ID.Hex())
+		err = fmt.Errorf("passkey sign count check failed: %w", ports.UnauthorizedError)
+		return
+	}
+	err = s.updateCredential(ctx, credential)
+	if err != nil {
+		return
+	}
+
+	return s.createSession(ctx, user, session, device)
+}
+
+func (s *PasskeyService) saveSession(ctx context.Context, sessionData *webauthn.SessionData) error {
+	data, err := json.Marshal(sessionData)
+	if err != nil {
+		return fmt.Errorf(`error marshaling passkey session: %w`, ports.InternalServerError)
+	}
+	_, err = s.sessionRepo.CreatePasskeySession(ctx, domain.PasskeySession{
+		Challenge: sessionData.Challenge,
+		Data:      data,
+	})
+	if err != nil {
+		s.log.Warnf("passkey session not saved due to error: %v", err)
+		return fmt.Errorf(`saving passkey session error: %w`, ports.InternalServerError)
+	}
+	return nil
+}
+
+func (s *PasskeyService) takeSession(ctx context.Context, challenge string) (sessionData webauthn.SessionData, err error) {
+	session, err := s.sessionRepo.TakePasskeySession(ctx, challenge)
+	if err != nil {
+		err = fmt.Errorf("passkey ceremony not found: %w", ports.BadRequestError)
+		return
+	}
+	err = json.Unmarshal(session.Data, &sessionData)
+	if err != nil {
+		err = fmt.Errorf(`error unmarshaling passkey session: %w`, ports.InternalServerError)
+	}
+	return
+}
+
+func (s *PasskeyService) updateCredential(ctx context.Context, credential *webauthn.Credential) error {
+	storedCredential, err := s.credentialRepo.GetPasskeyCredential(ctx, credential.ID)
+	if err != nil {
+		return fmt.Errorf("passkey not found: %w", ports.UnauthorizedError)
+	}
+	storedCredential.SignCount = credential.Authenticator.SignCount
+	storedCredential.Flags = credentialFlags(credential.Flags)
+
+	_, err = s.credentialRepo.UpdatePasskeyCredential(ctx, storedCredential)
+	if err != nil {
+		s.log.Warnf("passkey not updated due to error: %v", err)
+		return fmt.Errorf(`updating passkey error: %w`, ports.InternalServerError)
+	}
+	return nil
+}
+
+func (s *PasskeyService) getWebAuthnUserByHandle(ctx context.Context, userHandle []byte) (*passkeyUser, error) {
+	if len(userHandle) != len(primitive.ObjectID{}) {
+		return nil, fmt.Errorf("invalid user handle")
+	}
+	userID := primitive.ObjectID(userHandle)
+
+	user, err := s.userRepo.GetUserByID(ctx, userID.Hex())
+	if err != nil {
+		return nil, err
+	}
+	return s.getWebAuthnUser(ctx, user)
+}
+
+func (s *PasskeyService) getWebAuthnUser(ctx context.Context, user domain.User) (*passkeyUser, error) {
+	storedCredentials, err := s.credentialRepo.GetUserPasskeyCredentials(ctx, user.ID.Hex())
+	if err != nil {
+		s.log.Warnf("passkeys not found due to error: %v", err)
+		return nil, fmt.Errorf(`getting passkeys error: %w`, ports.InternalServerError)
+	}
+
+	credentials := make([]webauthn.Credential, 0, len(storedCredentials))
+	for _, storedCredential := range storedCredentials {
+		transports := make([]protocol.AuthenticatorTransport, 0, len(storedCredential.Transports))
+		for _, transport := range storedCredential.Transports {
+			transports = append(transports, protocol.AuthenticatorTransport(transport))
+		}
+		credentials = append(credentials, webauthn.Credential{
+			ID:              storedCredential.CredentialID,
+			PublicKey:       storedCredential.PublicKey,
+			AttestationType: storedCredential.AttestationType,
+			Transport:       transports,
+			Flags:           webauthn.NewCredentialFlags(protocol.AuthenticatorFlags(storedCredential.Flags)),
+			Authenticator: webauthn.Authenticator{
+				AAGUID:    storedCredential.AAGUID,
+				SignCount: storedCredential.SignCount,
+			},
+		})
+	}
+	return &passkeyUser{
+		user:        user,
+		credentials: credentials,
+	}, nil
+}
+
+func credentialFlags(flags webauthn.CredentialFlags) byte {
+	var raw protocol.AuthenticatorFlags
+	if flags.UserPresent {
+		raw |= protocol.FlagUserPresent
+	}
+	if flags.UserVerified {
+		raw |= protocol.FlagUserVerified
+	}
+	if flags.BackupEligible {
+		raw |= protocol.FlagBackupEligible
+	}
+	if flags.BackupState {
+		raw |= protocol.FlagBackupState
+	}
+	return byte(raw)
+}
+
+// passkeyUser adapts domain.User to webauthn.User
+type passkeyUser struct {
+	user        domain.User
+	credentials []webauthn.Credential
+}
+
+func (u *passkeyUser) WebAuthnID() []byte {
+	return u.user.ID[:]
+}
+
+func (u *passkeyUser) WebAuthnName() string {
+	return u.user.Nickname
+}
+
+func (u *passkeyUser) WebAuthnDisplayName() string {
+	return u.user.Nickname
+}
+
+func (u *passkeyUser) WebAuthnCredentials() []webauthn.Credential {
+	return u.credentials
+}
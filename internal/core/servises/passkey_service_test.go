@@ -0,0 +1,261 @@
+package servises
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports/dto"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports/mocks"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/logging/nop"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"testing"
+)
+
+const (
+	testRPID   = "localhost"
+	testOrigin = "http://localhost:3000"
+)
+
+// softAuthenticator emulates platform authenticator holding one ES256 discoverable credential
+type softAuthenticator struct {
+	credentialID []byte
+	key          *ecdsa.PrivateKey
+	userHandle   []byte
+	signCount    uint32
+}
+
+func newSoftAuthenticator() *softAuthenticator {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	credentialID := make([]byte, 16)
+	_, _ = rand.Read(credentialID)
+	return &softAuthenticator{
+		credentialID: credentialID,
+		key:          key,
+	}
+}
+
+func (a *softAuthenticator) create(creation *protocol.CredentialCreation) []byte {
+	a.userHandle = creation.Response.User.ID.(protocol.URLEncodedBase64)
+	clientDataJSON := a.clientData("webauthn.create", creation.Response.Challenge)
+
+	publicKey, _ := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{
+			KeyType:   int64(webauthncose.EllipticKey),
+			Algorithm: int64(webauthncose.AlgES256),
+		},
+		Curve:  1,
+		XCoord: a.key.PublicKey.X.FillBytes(make([]byte, 32)),
+		YCoord: a.key.PublicKey.Y.FillBytes(make([]byte, 32)),
+	})
+	attestedCredentialData := make([]byte, 16)
+	attestedCredentialData = binary.BigEndian.AppendUint16(attestedCredentialData, uint16(len(a.credentialID)))
+	attestedCredentialData = append(attestedCredentialData, a.credentialID...)
+	attestedCredentialData = append(attestedCredentialData, publicKey...)
+
+	authData := a.authData(protocol.FlagAttestedCredentialData)
+	authData = append(authData, attestedCredentialData...)
+	attestationObject, _ := webauthncbor.Marshal(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": authData,
+	})
+
+	return a.credential(map[string]string{
+		"clientDataJSON":    encode(clientDataJSON),
+		"attestationObject": encode(attestationObject),
+	})
+}
+
+func (a *softAuthenticator) get(assertion *protocol.CredentialAssertion) []byte {
+	clientDataJSON := a.clientData("webauthn.get", assertion.Response.Challenge)
+	authData := a.authData(0)
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signedData := sha256.Sum256(append(authData, clientDataHash[:]...))
+	signature, _ := ecdsa.SignASN1(rand.Reader, a.key, signedData[:])
+
+	return a.credential(map[string]string{
+		"clientDataJSON":    encode(clientDataJSON),
+		"authenticatorData": encode(authData),
+		"signature":         encode(signature),
+		"userHandle":        encode(a.userHandle),
+	})
+}
+
+func (a *softAuthenticator) clientData(ceremony string, challenge protocol.URLEncodedBase64) []byte {
+	clientDataJSON, _ := json.Marshal(map[string]any{
+		"type":      ceremony,
+		"challenge": challenge.String(),
+		"origin":    testOrigin,
+	})
+	return clientDataJSON
+}
+
+func (a *softAuthenticator) authData(flags protocol.AuthenticatorFlags) []byte {
+	rpIDHash := sha256.Sum256([]byte(testRPID))
+	authData := append(rpIDHash[:], byte(flags|protocol.FlagUserPresent|protocol.FlagUserVerified))
+	return binary.BigEndian.AppendUint32(authData, a.signCount)
+}
+
+func (a *softAuthenticator) credential(response map[string]string) []byte {
+	credential, _ := json.Marshal(map[string]any{
+		"id":       encode(a.credentialID),
+		"rawId":    encode(a.credentialID),
+		"type":     "public-key",
+		"response": response,
+	})
+	return credential
+}
+
+func encode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func TestPasskeyRegistrationAndLogin(t *testing.T) {
+	var log = nop.GetLogger()
+	jwtIssuer := newIssuer()
+	webAuthn, _ := webauthn.New(&webauthn.Config{
+		RPID:          testRPID,
+		RPDisplayName: "Code Typing",
+		RPOrigins:     []string{testOrigin},
+	})
+	// mocks
+	userRepo := new(mocks.UserRepository)
+	tokenRepo := new(mocks.RefreshTokenRepository)
+	credentialRepo := new(mocks.PasskeyCredentialRepository)
+	sessionRepo := new(mocks.PasskeySessionRepository)
+	eventDispatcher := new(mocks.EventDispatcher)
+
+	user := domain.User{
+		Nickname: gofakeit.Username(),
+		Email:    gofakeit.Email(),
+	}
+	user.ID = primitive.NewObjectID()
+
+	// in-memory storage behind repository mocks
+	sessions := map[string]domain.PasskeySession{}
+	var credentials []domain.PasskeyCredential
+
+	tokenRepo.
+		On("GetRefreshToken", mock.Anything, "refresh").
+		Return(domain.RefreshToken{User: user.ID}, nil)
+	tokenRepo.
+		On("CreateRefreshToken", mock.Anything, mock.Anything).
+		Return(gofakeit.UUID(), nil)
+	userRepo.
+		On("GetUserByID", mock.Anything, user.ID.Hex()).
+		Return(user, nil)
+	sessionRepo.
+		On("CreatePasskeySession", mock.Anything, mock.Anything).
+		Return(func(_ context.Context, session domain.PasskeySession) (string, error) {
+			sessions[session.Challenge] = session
+			return gofakeit.UUID(), nil
+		})
+	sessionRepo.
+		On("TakePasskeySession", mock.Anything, mock.AnythingOfType("string")).
+		Return(func(_ context.Context, challenge string) (domain.PasskeySession, error) {
+			session, ok := sessions[challenge]
+			if !ok {
+				return session, fmt.Errorf("")
+			}
+			delete(sessions, challenge)
+			return session, nil
+		})
+	credentialRepo.
+		On("GetUserPasskeyCredentials", mock.Anything, user.ID.Hex()).
+		Return(func(context.Context, string) ([]domain.PasskeyCredential, error) {
+			return credentials, nil
+		})
+	credentialRepo.
+		On("CreatePasskeyCredential", mock.Anything, mock.Anything).
+		Return(func(_ context.Context, credential domain.PasskeyCredential) (string, error) {
+			credentials = append(credentials, credential)
+			return gofakeit.UUID(), nil
+		})
+	credentialRepo.
+		On("GetPasskeyCredential", mock.Anything, mock.Anything).
+		Return(func(context.Context, []byte) (domain.PasskeyCredential, error) {
+			return credentials[0], nil
+		})
+	credentialRepo.
+		On("UpdatePasskeyCredential", mock.Anything, mock.Anything).
+		Return(func(_ context.Context, credential domain.PasskeyCredential) (domain.PasskeyCredential, error) {
+			credentials[0] = credential
+			return credential, nil
+		})
+	eventDispatcher.
+		On(
+			"Dispatch",
+			mock.Anything,
+			mock.Anything,
+		).Return(nil)
+
+	// service
+	passkeyService := NewPasskeyService(webAuthn, jwtIssuer, userRepo, credentialRepo, sessionRepo, tokenRepo, newTransactor(), eventDispatcher, log)
+	authenticator := newSoftAuthenticator()
+
+	t.Run("successful passkey registration", func(t *testing.T) {
+		creation, err := passkeyService.BeginPasskeyRegistration(context.Background(), "refresh")
+		assert.NoError(t, err)
+
+		err = passkeyService.FinishPasskeyRegistration(context.Background(), "refresh", authenticator.create(creation))
+		assert.NoError(t, err)
+		assert.Len(t, credentials, 1)
+	})
+	t.Run("successful passkey login", func(t *testing.T) {
+		authenticator.signCount = 1
+		assertion, err := passkeyService.BeginPasskeyLogin(context.Background())
+		assert.NoError(t, err)
+
+		access, refresh, err := passkeyService.FinishPasskeyLogin(context.Background(), authenticator.get(assertion), gofakeit.UUID(), dto.DeviceDto{})
+		assert.NoError(t, err)
+		assert.NotEmpty(t, access)
+		assert.NotEmpty(t, refresh)
+		assert.Equal(t, uint32(1), credentials[0].SignCount)
+	})
+	t.Run("unsuccessful passkey login due to replayed assertion", func(t *testing.T) {
+		authenticator.signCount = 2
+		assertion, _ := passkeyService.BeginPasskeyLogin(context.Background())
+		response := authenticator.get(assertion)
+		_, _, err := passkeyService.FinishPasskeyLogin(context.Background(), response, gofakeit.UUID(), dto.DeviceDto{})
+		assert.NoError(t, err)
+
+		_, _, err = passkeyService.FinishPasskeyLogin(context.Background(), response, gofakeit.UUID(), dto.DeviceDto{})
+		assert.Error(t, err)
+	})
+	t.Run("unsuccessful passkey login due to sign count regression", func(t *testing.T) {
+		authenticator.signCount = 1
+		assertion, _ := passkeyService.BeginPasskeyLogin(context.Background())
+
+		_, _, err := passkeyService.FinishPasskeyLogin(context.Background(), authenticator.get(assertion), gofakeit.UUID(), dto.DeviceDto{})
+		assert.Error(t, err)
+	})
+	t.Run("unsuccessful passkey login due to foreign key", func(t *testing.T) {
+		stranger := newSoftAuthenticator()
+		stranger.credentialID = authenticator.credentialID
+		stranger.userHandle = authenticator.userHandle
+		stranger.signCount = 10
+		assertion, _ := passkeyService.BeginPasskeyLogin(context.Background())
+
+		_, _, err := passkeyService.FinishPasskeyLogin(context.Background(), stranger.get(assertion), gofakeit.UUID(), dto.DeviceDto{})
+		assert.Error(t, err)
+	})
+	userRepo.AssertExpectations(t)
+	tokenRepo.AssertExpectations(t)
+	credentialRepo.AssertExpectations(t)
+	sessionRepo.AssertExpectations(t)
+}
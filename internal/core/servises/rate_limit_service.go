@@ -0,0 +1,41 @@
+package servises
+
+import (
+	"context"
+	"fmt"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/logging"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/ratelimit"
+)
+
+type RateLimitService struct {
+	store ports.RateLimitStore
+	log   logging.Logger
+}
+
+func NewRateLimitService(store ports.RateLimitStore, log logging.Logger) ports.RateLimitService {
+	return &RateLimitService{
+		store: store,
+		log:   log,
+	}
+}
+
+func (s *RateLimitService) Allow(ctx context.Context, key string, policy ratelimit.Policy) error {
+	if !policy.Enabled() {
+		return nil
+	}
+	retryAfter, err := s.store.Take(ctx, key, policy)
+	if err != nil {
+		// unavailable store must not lock everyone out of login
+		s.log.Warnf("rate limit of '%s' not checked due to error: %v", key, err)
+		return nil
+	}
+	if retryAfter > 0 {
+		s.log.Debugf("rate limit of '%s' exceeded", key)
+		return ports.NewRateLimitError(
+			retryAfter,
+			fmt.Errorf("too many attempts, try again later: %w", ports.TooManyRequestsError),
+		)
+	}
+	return nil
+}
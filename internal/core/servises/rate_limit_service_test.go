@@ -0,0 +1,61 @@
+package servises
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports/mocks"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/logging/nop"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/ratelimit"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	var log = nop.GetLogger()
+	policy := ratelimit.Policy{Capacity: 2, Period: time.Minute}
+	// mocks
+	store := new(mocks.RateLimitStore)
+
+	// in-memory storage behind store mock
+	buckets := map[string]ratelimit.Bucket{}
+	now := time.Now()
+
+	store.
+		On("Take", mock.Anything, "login:account:broken", policy).
+		Return(time.Duration(0), fmt.Errorf("")).
+		Once()
+	store.
+		On("Take", mock.Anything, "login:account:bob", policy).
+		Return(func(_ context.Context, key string, policy ratelimit.Policy) (time.Duration, error) {
+			var retryAfter time.Duration
+			buckets[key], retryAfter = policy.Take(buckets[key], now)
+			return retryAfter, nil
+		})
+
+	// service
+	rateLimitService := NewRateLimitService(store, log)
+
+	t.Run("successful requests within capacity", func(t *testing.T) {
+		for i := 0; i < policy.Capacity; i++ {
+			assert.NoError(t, rateLimitService.Allow(context.Background(), "login:account:bob", policy))
+		}
+	})
+	t.Run("unsuccessful request due to empty bucket", func(t *testing.T) {
+		err := rateLimitService.Allow(context.Background(), "login:account:bob", policy)
+		assert.ErrorIs(t, err, ports.TooManyRequestsError)
+		var rateLimitError *ports.RateLimitError
+		assert.True(t, errors.As(err, &rateLimitError))
+		assert.Equal(t, 30*time.Second, rateLimitError.RetryAfter)
+	})
+	t.Run("successful request when store is unavailable", func(t *testing.T) {
+		assert.NoError(t, rateLimitService.Allow(context.Background(), "login:account:broken", policy))
+	})
+	t.Run("successful request with disabled policy", func(t *testing.T) {
+		assert.NoError(t, rateLimitService.Allow(context.Background(), "login:account:bob", ratelimit.Policy{}))
+	})
+	store.AssertExpectations(t)
+}
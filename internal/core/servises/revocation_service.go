@@ -0,0 +1,157 @@
+package servises
+
+import (
+	"context"
+	"fmt"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/jwt"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/logging"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"sync"
+	"time"
+)
+
+// RevocationService keeps revocations of access tokens in memory, so token validation does not query database,
+// revocations live no longer than access tokens themselves
+type RevocationService struct {
+	jwtIssuer        *jwt.Issuer
+	revokedTokenRepo ports.RevokedAccessTokenRepository
+	log              logging.Logger
+	mu               sync.RWMutex
+	// revokedTokens maps jti to time when token expires anyway
+	revokedTokens map[string]time.Time
+	// revokedUsers maps user to second before which all tokens of user are revoked
+	revokedUsers map[string]time.Time
+}
+
+func NewRevocationService(jwtIssuer *jwt.Issuer, revokedTokenRepo ports.RevokedAccessTokenRepository, log logging.Logger) ports.RevocationService {
+	return &RevocationService{
+		jwtIssuer:        jwtIssuer,
+		revokedTokenRepo: revokedTokenRepo,
+		log:              log,
+		revokedTokens:    make(map[string]time.Time),
+		revokedUsers:     make(map[string]time.Time),
+	}
+}
+
+func (s *RevocationService) RevokeAccessToken(ctx context.Context, accessToken string) error {
+	claims, err := s.jwtIssuer.ParseAccessJWT(accessToken)
+	if err != nil {
+		return fmt.Errorf("invalid access token: %w", ports.BadRequestError)
+	}
+	jti, _ := claims["jti"].(string)
+	sub, _ := claims["sub"].(string)
+	userID, err := primitive.ObjectIDFromHex(sub)
+	if jti == "" || err != nil {
+		return fmt.Errorf("access token can not be revoked: %w", ports.BadRequestError)
+	}
+
+	revokedToken := domain.RevokedAccessToken{
+		JTI:  jti,
+		User: userID,
+	}
+	_, err = s.revokedTokenRepo.CreateRevokedAccessToken(ctx, revokedToken)
+	if err != nil {
+		s.log.Warnf("access token not revoked due to error: %v", err)
+		return fmt.Errorf(`revoking access token error: %w`, ports.InternalServerError)
+	}
+	s.cacheRevocation(revokedToken, time.Now())
+	return nil
+}
+
+func (s *RevocationService) RevokeUserAccessTokens(ctx context.Context, userID string) error {
+	user, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return fmt.Errorf("user not found: %w", ports.NotFoundError)
+	}
+
+	revokedToken := domain.RevokedAccessToken{
+		User: user,
+	}
+	now := time.Now()
+	_, err = s.revokedTokenRepo.CreateRevokedAccessToken(ctx, revokedToken)
+	if err != nil {
+		s.log.Warnf("access tokens of user '%s' not revoked due to error: %v", userID, err)
+		return fmt.Errorf(`revoking access tokens error: %w`, ports.InternalServerError)
+	}
+	s.cacheRevocation(revokedToken, now)
+	return nil
+}
+
+func (s *RevocationService) IsAccessTokenRevoked(claims map[string]interface{}) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if jti, ok := claims["jti"].(string); ok {
+		if _, ok = s.revokedTokens[jti]; ok {
+			return true
+		}
+	}
+	if sub, ok := claims["sub"].(string); ok {
+		if revokedAt, ok := s.revokedUsers[sub]; ok {
+			return numericClaim(claims, "iat") < revokedAt.Unix()
+		}
+	}
+	return false
+}
+
+func (s *RevocationService) RunRevocationSync(ctx context.Context, interval time.Duration) {
+	for {
+		s.syncRevocations(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// syncRevocations loads revocations which may still deny unexpired tokens, including ones made by other instances
+func (s *RevocationService) syncRevocations(ctx context.Context) {
+	revokedTokens, err := s.revokedTokenRepo.GetRevokedAccessTokens(
+		ctx,
+		time.Now().Add(-s.jwtIssuer.AccessTokenExp()),
+	)
+	if err != nil {
+		s.log.Warnf("revoked access tokens not loaded due to error: %v", err)
+		return
+	}
+	for _, revokedToken := range revokedTokens {
+		s.cacheRevocation(revokedToken, revokedToken.CreatedAt)
+	}
+	s.pruneRevocations(time.Now())
+}
+
+func (s *RevocationService) cacheRevocation(revokedToken domain.RevokedAccessToken, revokedAt time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if revokedToken.JTI != "" {
+		s.revokedTokens[revokedToken.JTI] = revokedAt.Add(s.jwtIssuer.AccessTokenExp())
+		return
+	}
+	// iat has second precision, token issued in the second of revocation is kept valid,
+	// so login right after password change or restored account is not denied
+	userID := revokedToken.User.Hex()
+	revokedAt = revokedAt.Truncate(time.Second)
+	if revokedAt.After(s.revokedUsers[userID]) {
+		s.revokedUsers[userID] = revokedAt
+	}
+}
+
+func (s *RevocationService) pruneRevocations(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for jti, expiresAt := range s.revokedTokens {
+		if now.After(expiresAt) {
+			delete(s.revokedTokens, jti)
+		}
+	}
+	for userID, revokedAt := range s.revokedUsers {
+		if now.After(revokedAt.Add(s.jwtIssuer.AccessTokenExp())) {
+			delete(s.revokedUsers, userID)
+		}
+	}
+}
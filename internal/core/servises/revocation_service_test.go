@@ -0,0 +1,100 @@
+package servises
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports/mocks"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/logging/nop"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"testing"
+	"time"
+)
+
+func TestAccessTokenRevocation(t *testing.T) {
+	var log = nop.GetLogger()
+	jwtIssuer := newIssuer()
+	// mocks
+	revokedTokenRepo := new(mocks.RevokedAccessTokenRepository)
+
+	userID := primitive.NewObjectID()
+	anotherUserID := primitive.NewObjectID()
+	parsed := func(sub string) map[string]interface{} {
+		accessToken, _ := jwtIssuer.GenerateAccessJWT(sub)
+		claims, _ := jwtIssuer.ParseAccessJWT(accessToken)
+		return claims
+	}
+	// issuedBefore returns claims of token issued a second before now, iat has second precision
+	issuedBefore := func(sub string) map[string]interface{} {
+		claims := parsed(sub)
+		claims["iat"] = float64(time.Now().Add(-time.Second).Unix())
+		return claims
+	}
+
+	// in-memory storage behind repository mock, shared by two instances of service
+	var revokedTokens []domain.RevokedAccessToken
+	revokedTokenRepo.
+		On("CreateRevokedAccessToken", mock.Anything, mock.AnythingOfType("domain.RevokedAccessToken")).
+		Return(func(_ context.Context, revokedToken domain.RevokedAccessToken) (string, error) {
+			revokedToken.ID = primitive.NewObjectID()
+			revokedToken.CreatedAt = time.Now()
+			revokedTokens = append(revokedTokens, revokedToken)
+			return revokedToken.ID.Hex(), nil
+		})
+	revokedTokenRepo.
+		On("GetRevokedAccessTokens", mock.Anything, mock.AnythingOfType("time.Time")).
+		Return(func(_ context.Context, since time.Time) ([]domain.RevokedAccessToken, error) {
+			return revokedTokens, nil
+		})
+
+	// service
+	revocationService := NewRevocationService(jwtIssuer, revokedTokenRepo, log)
+	anotherInstance := NewRevocationService(jwtIssuer, revokedTokenRepo, log).(*RevocationService)
+
+	t.Run("successful single token revocation", func(t *testing.T) {
+		accessToken, _ := jwtIssuer.GenerateAccessJWT(userID.Hex())
+		err := revocationService.RevokeAccessToken(context.Background(), accessToken)
+		assert.NoError(t, err)
+
+		claims, _ := jwtIssuer.ParseAccessJWT(accessToken)
+		assert.True(t, revocationService.IsAccessTokenRevoked(claims))
+		assert.False(t, revocationService.IsAccessTokenRevoked(parsed(userID.Hex())))
+	})
+	t.Run("successful revocation of all tokens of user", func(t *testing.T) {
+		claims := issuedBefore(anotherUserID.Hex())
+		err := revocationService.RevokeUserAccessTokens(context.Background(), anotherUserID.Hex())
+		assert.NoError(t, err)
+
+		assert.True(t, revocationService.IsAccessTokenRevoked(claims))
+		assert.False(t, revocationService.IsAccessTokenRevoked(parsed(userID.Hex())))
+	})
+	t.Run("successful validation of token issued right after revocation of all tokens of user", func(t *testing.T) {
+		assert.False(t, revocationService.IsAccessTokenRevoked(parsed(anotherUserID.Hex())))
+	})
+	t.Run("successful sync of revocations made by another instance", func(t *testing.T) {
+		claims := issuedBefore(anotherUserID.Hex())
+		assert.False(t, anotherInstance.IsAccessTokenRevoked(claims))
+
+		anotherInstance.syncRevocations(context.Background())
+		assert.True(t, anotherInstance.IsAccessTokenRevoked(claims))
+	})
+	t.Run("successful pruning of expired revocations", func(t *testing.T) {
+		claims := parsed(anotherUserID.Hex())
+		anotherInstance.pruneRevocations(time.Now().Add(2 * anotherInstance.jwtIssuer.AccessTokenExp()))
+		assert.False(t, anotherInstance.IsAccessTokenRevoked(claims))
+	})
+	t.Run("unsuccessful revocation of invalid token", func(t *testing.T) {
+		err := revocationService.RevokeAccessToken(context.Background(), "invalid_token")
+		assert.Error(t, err)
+
+		refreshToken, _ := jwtIssuer.GenerateRefreshJWT(userID.Hex())
+		err = revocationService.RevokeAccessToken(context.Background(), refreshToken)
+		assert.Error(t, err)
+	})
+	t.Run("unsuccessful user revocation due to invalid id", func(t *testing.T) {
+		err := revocationService.RevokeUserAccessTokens(context.Background(), "not an id")
+		assert.Error(t, err)
+	})
+	revokedTokenRepo.AssertExpectations(t)
+}
@@ -0,0 +1,196 @@
+package servises
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports/dto"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/jwt"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/logging"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// sessionIssuer starts sessions for already authenticated users, shared by all login methods
+type sessionIssuer struct {
+	jwtIssuer       *jwt.Issuer
+	userRepo        ports.UserRepository
+	tokenRepo       ports.RefreshTokenRepository
+	transactor      ports.Transactor
+	eventDispatcher ports.EventDispatcher
+	log             logging.Logger
+}
+
+func newSessionIssuer(jwtIssuer *jwt.Issuer, userRepo ports.UserRepository, tokenRepo ports.RefreshTokenRepository, transactor ports.Transactor, eventDispatcher ports.EventDispatcher, log logging.Logger) *sessionIssuer {
+	return &sessionIssuer{
+		jwtIssuer:       jwtIssuer,
+		userRepo:        userRepo,
+		tokenRepo:       tokenRepo,
+		transactor:      transactor,
+		eventDispatcher: eventDispatcher,
+		log:             log,
+	}
+}
+
+func (i *sessionIssuer) createSession(ctx context.Context, user domain.User, session string, device dto.DeviceDto) (access string, refresh string, err error) {
+	if user.Banned {
+		err = fmt.Errorf("user is banned: %w", ports.ForbiddenError)
+		return
+	}
+	family := primitive.NewObjectID().Hex()
+	now := time.Now()
+	access, refresh, err = i.generateTokens(user, family, 0, now)
+	if err != nil {
+		return
+	}
+
+	// session is not started unless results of anonymous session are going to be migrated
+	err = i.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		// login during grace period cancels account deletion
+		if !user.DeletedAt.IsZero() {
+			err := i.userRepo.RestoreUser(ctx, user.ID.Hex())
+			if err != nil {
+				i.log.Warnf("user not restored due to error: %v", err)
+				return fmt.Errorf(`restoring user error: %w`, ports.InternalServerError)
+			}
+		}
+
+		_, err := i.tokenRepo.CreateRefreshToken(ctx, domain.RefreshToken{
+			User:       user.ID,
+			Token:      refresh,
+			Family:     family,
+			UserAgent:  device.UserAgent,
+			IP:         device.IP,
+			StartedAt:  now,
+			LastUsedAt: now,
+		})
+		if err != nil {
+			return fmt.Errorf(`creating refresh token error: %w`, ports.InternalServerError)
+		}
+		return i.dispatchAuthEvent(ctx, session, user)
+	})
+	if err != nil {
+		return "", "", err
+	}
+	return
+}
+
+// generateTokens issues access token and refresh token of given family generation, so every rotated token is unique,
+// access token keeps time of login the family started with, so refresh does not count as authentication
+func (i *sessionIssuer) generateTokens(user domain.User, family string, generation int, authTime time.Time) (accessToken string, refreshToken string, err error) {
+	accessToken, err = i.jwtIssuer.GenerateAccessJWT(
+		user.ID.Hex(),
+		jwt.Claim{
+			Name:  "nickname",
+			Value: user.Nickname,
+		},
+		jwt.Claim{
+			Name:  "email_verified",
+			Value: user.EmailVerified,
+		},
+		jwt.Claim{
+			Name:  "auth_time",
+			Value: authTime.Unix(),
+		},
+	)
+	if err != nil {
+		err = fmt.Errorf(`generating tokens error: %w`, ports.InternalServerError)
+		return
+	}
+	refreshToken, err = i.jwtIssuer.GenerateRefreshJWT(
+		user.ID.Hex(),
+		jwt.Claim{
+			Name:  "family",
+			Value: family,
+		},
+		jwt.Claim{
+			Name:  "generation",
+			Value: generation,
+		},
+	)
+	if err != nil {
+		err = fmt.Errorf(`generating tokens error: %w`, ports.InternalServerError)
+		return
+	}
+	return
+}
+
+func (i *sessionIssuer) dispatchAuthEvent(ctx context.Context, session string, user domain.User) error {
+	if session == "" {
+		return nil
+	}
+
+	body, err := json.Marshal(
+		map[string]interface{}{
+			"session": session,
+			"userID":  user.ID.Hex(),
+		},
+	)
+	if err != nil {
+		return fmt.Errorf(`error marshaling event body: %w`, ports.InternalServerError)
+	}
+
+	return i.eventDispatcher.Dispatch(ctx, domain.Event{
+		Exchange: ports.AuthExchange,
+		Key:      user.ID.Hex(),
+		Body:     body,
+	})
+}
+
+// revokeReusedToken revokes the whole family when rotated refresh token is presented again,
+// either the legitimate client or the one who stole the token holds the current token of the family
+func (i *sessionIssuer) revokeReusedToken(ctx context.Context, refreshToken string) bool {
+	token, err := i.tokenRepo.GetRotatedRefreshToken(ctx, refreshToken)
+	if err != nil {
+		return false
+	}
+
+	i.log.Warnf(
+		"security: reuse of rotated refresh token detected for user '%s', revoking token family '%s' after generation %d",
+		token.User.Hex(), token.Family, token.Generation,
+	)
+	// family is not revoked without event, so security notification is not lost
+	err = i.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		err := i.tokenRepo.DeleteRefreshTokenFamily(ctx, token.Family)
+		if err != nil {
+			return err
+		}
+		return i.dispatchTokenReuseEvent(ctx, token)
+	})
+	if err != nil {
+		i.log.Errorf("refresh token family '%s' not revoked due to error: %v", token.Family, err)
+	}
+	return true
+}
+
+func (i *sessionIssuer) dispatchTokenReuseEvent(ctx context.Context, token domain.RefreshToken) error {
+	body, err := json.Marshal(
+		map[string]interface{}{
+			"userID":     token.User.Hex(),
+			"family":     token.Family,
+			"generation": token.Generation,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf(`error marshaling event body: %w`, ports.InternalServerError)
+	}
+	return i.eventDispatcher.Dispatch(ctx, domain.Event{
+		Exchange: ports.TokenReuseExchange,
+		Key:      token.User.Hex(),
+		Body:     body,
+	})
+}
+
+func getUserByRefreshToken(ctx context.Context, userRepo ports.UserRepository, tokenRepo ports.RefreshTokenRepository, refreshToken string) (domain.User, error) {
+	token, err := tokenRepo.GetRefreshToken(ctx, refreshToken)
+	if err != nil {
+		return domain.User{}, fmt.Errorf("refresh token not found: %w", ports.UnauthorizedError)
+	}
+	user, err := userRepo.GetUserByID(ctx, token.User.Hex())
+	if err != nil {
+		return domain.User{}, fmt.Errorf("user not found: %w", ports.NotFoundError)
+	}
+	return user, nil
+}
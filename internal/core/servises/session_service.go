@@ -0,0 +1,114 @@
+package servises
+
+import (
+	"context"
+	"fmt"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports/dto"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/logging"
+	"sort"
+	"time"
+)
+
+type SessionService struct {
+	tokenRepo ports.RefreshTokenRepository
+	log       logging.Logger
+}
+
+func NewSessionService(tokenRepo ports.RefreshTokenRepository, log logging.Logger) ports.SessionService {
+	return &SessionService{
+		tokenRepo: tokenRepo,
+		log:       log,
+	}
+}
+
+func (s *SessionService) GetSessions(ctx context.Context, refreshToken string) ([]dto.SessionResponseDto, error) {
+	currentToken, err := s.tokenRepo.GetRefreshToken(ctx, refreshToken)
+	if err != nil {
+		return nil, fmt.Errorf("refresh token not found: %w", ports.UnauthorizedError)
+	}
+	tokens, err := s.tokenRepo.GetUserRefreshTokens(ctx, currentToken.User.Hex())
+	if err != nil {
+		s.log.Warnf("refresh tokens not found due to error: %v", err)
+		return nil, fmt.Errorf(`getting sessions error: %w`, ports.InternalServerError)
+	}
+
+	sessionResponseDtos := make([]dto.SessionResponseDto, 0, len(tokens))
+	for _, token := range tokens {
+		sessionResponseDtos = append(sessionResponseDtos, dto.SessionResponseDto{
+			ID:         sessionID(token),
+			UserAgent:  token.UserAgent,
+			IP:         token.IP,
+			CreatedAt:  firstNonZero(token.StartedAt, token.CreatedAt),
+			LastUsedAt: firstNonZero(token.LastUsedAt, token.UpdatedAt),
+			Current:    token.Token == currentToken.Token,
+		})
+	}
+	sort.Slice(sessionResponseDtos, func(i, j int) bool {
+		return sessionResponseDtos[i].LastUsedAt.After(sessionResponseDtos[j].LastUsedAt)
+	})
+	return sessionResponseDtos, nil
+}
+
+func (s *SessionService) RevokeSession(ctx context.Context, refreshToken string, sessionIDToRevoke string) error {
+	currentToken, err := s.tokenRepo.GetRefreshToken(ctx, refreshToken)
+	if err != nil {
+		return fmt.Errorf("refresh token not found: %w", ports.UnauthorizedError)
+	}
+	tokens, err := s.tokenRepo.GetUserRefreshTokens(ctx, currentToken.User.Hex())
+	if err != nil {
+		s.log.Warnf("refresh tokens not found due to error: %v", err)
+		return fmt.Errorf(`getting sessions error: %w`, ports.InternalServerError)
+	}
+
+	// only sessions of the same user may be revoked
+	for _, token := range tokens {
+		if sessionID(token) != sessionIDToRevoke {
+			continue
+		}
+		if token.Family == "" {
+			err = s.tokenRepo.DeleteRefreshToken(ctx, token.Token)
+		} else {
+			err = s.tokenRepo.DeleteRefreshTokenFamily(ctx, token.Family)
+		}
+		if err != nil {
+			s.log.Warnf("session '%s' not revoked due to error: %v", sessionIDToRevoke, err)
+			return fmt.Errorf(`revoking session error: %w`, ports.InternalServerError)
+		}
+		return nil
+	}
+	return fmt.Errorf("session '%s' not found: %w", sessionIDToRevoke, ports.NotFoundError)
+}
+
+func (s *SessionService) RevokeOtherSessions(ctx context.Context, refreshToken string) error {
+	currentToken, err := s.tokenRepo.GetRefreshToken(ctx, refreshToken)
+	if err != nil {
+		return fmt.Errorf("refresh token not found: %w", ports.UnauthorizedError)
+	}
+	if currentToken.Family == "" {
+		return fmt.Errorf("session must be refreshed first: %w", ports.BadRequestError)
+	}
+
+	err = s.tokenRepo.DeleteOtherUserRefreshTokens(ctx, currentToken.User.Hex(), currentToken.Family)
+	if err != nil {
+		s.log.Warnf("other sessions not revoked due to error: %v", err)
+		return fmt.Errorf(`revoking sessions error: %w`, ports.InternalServerError)
+	}
+	return nil
+}
+
+// sessionID is token family, tokens issued before families appeared are sessions on their own
+func sessionID(token domain.RefreshToken) string {
+	if token.Family == "" {
+		return token.ID.Hex()
+	}
+	return token.Family
+}
+
+func firstNonZero(t time.Time, fallback time.Time) time.Time {
+	if t.IsZero() {
+		return fallback
+	}
+	return t
+}
@@ -0,0 +1,119 @@
+package servises
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports/mocks"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/logging/nop"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"testing"
+	"time"
+)
+
+func TestSessions(t *testing.T) {
+	var log = nop.GetLogger()
+	// mocks
+	tokenRepo := new(mocks.RefreshTokenRepository)
+
+	userID := primitive.NewObjectID()
+	strangerID := primitive.NewObjectID()
+
+	// in-memory storage behind repository mock
+	var tokens []domain.RefreshToken
+	reset := func() {
+		tokens = []domain.RefreshToken{
+			{User: userID, Token: "laptop", Family: "laptop-family", UserAgent: "Firefox", IP: "10.0.0.1", LastUsedAt: time.Now()},
+			{User: userID, Token: "phone", Family: "phone-family", UserAgent: "Safari", IP: "10.0.0.2", LastUsedAt: time.Now().Add(-time.Hour)},
+			{User: strangerID, Token: "stranger", Family: "stranger-family"},
+		}
+	}
+	reset()
+
+	tokenRepo.
+		On("GetRefreshToken", mock.Anything, mock.AnythingOfType("string")).
+		Return(func(_ context.Context, refreshToken string) (domain.RefreshToken, error) {
+			for _, token := range tokens {
+				if token.Token == refreshToken {
+					return token, nil
+				}
+			}
+			return domain.RefreshToken{}, fmt.Errorf("")
+		})
+	tokenRepo.
+		On("GetUserRefreshTokens", mock.Anything, mock.AnythingOfType("string")).
+		Return(func(_ context.Context, ID string) ([]domain.RefreshToken, error) {
+			var userTokens []domain.RefreshToken
+			for _, token := range tokens {
+				if token.User.Hex() == ID {
+					userTokens = append(userTokens, token)
+				}
+			}
+			return userTokens, nil
+		})
+	tokenRepo.
+		On("DeleteRefreshTokenFamily", mock.Anything, mock.AnythingOfType("string")).
+		Return(func(_ context.Context, family string) error {
+			var kept []domain.RefreshToken
+			for _, token := range tokens {
+				if token.Family != family {
+					kept = append(kept, token)
+				}
+			}
+			tokens = kept
+			return nil
+		})
+	tokenRepo.
+		On("DeleteOtherUserRefreshTokens", mock.Anything, userID.Hex(), mock.AnythingOfType("string")).
+		Return(func(_ context.Context, ID string, exceptFamily string) error {
+			var kept []domain.RefreshToken
+			for _, token := range tokens {
+				if token.User.Hex() != ID || token.Family == exceptFamily {
+					kept = append(kept, token)
+				}
+			}
+			tokens = kept
+			return nil
+		})
+
+	// service
+	sessionService := NewSessionService(tokenRepo, log)
+
+	t.Run("successful sessions listing", func(t *testing.T) {
+		sessionResponseDtos, err := sessionService.GetSessions(context.Background(), "phone")
+		assert.NoError(t, err)
+		assert.Len(t, sessionResponseDtos, 2)
+		assert.Equal(t, "laptop-family", sessionResponseDtos[0].ID)
+		assert.False(t, sessionResponseDtos[0].Current)
+		assert.Equal(t, "Safari", sessionResponseDtos[1].UserAgent)
+		assert.True(t, sessionResponseDtos[1].Current)
+	})
+	t.Run("successful session revocation", func(t *testing.T) {
+		defer reset()
+		err := sessionService.RevokeSession(context.Background(), "phone", "laptop-family")
+		assert.NoError(t, err)
+
+		_, err = sessionService.GetSessions(context.Background(), "laptop")
+		assert.Error(t, err)
+	})
+	t.Run("unsuccessful revocation of session of another user", func(t *testing.T) {
+		err := sessionService.RevokeSession(context.Background(), "phone", "stranger-family")
+		assert.Error(t, err)
+
+		_, err = sessionService.GetSessions(context.Background(), "stranger")
+		assert.NoError(t, err)
+	})
+	t.Run("successful revocation of other sessions", func(t *testing.T) {
+		defer reset()
+		err := sessionService.RevokeOtherSessions(context.Background(), "phone")
+		assert.NoError(t, err)
+
+		sessionResponseDtos, _ := sessionService.GetSessions(context.Background(), "phone")
+		assert.Len(t, sessionResponseDtos, 1)
+		_, err = sessionService.GetSessions(context.Background(), "stranger")
+		assert.NoError(t, err)
+	})
+	tokenRepo.AssertExpectations(t)
+}
@@ -0,0 +1,292 @@
+package servises
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports/dto"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/encryption"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/jwt"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/logging"
+	"sort"
+	"time"
+)
+
+type SigningKeyService struct {
+	jwtIssuer *jwt.Issuer
+	cipher    *encryption.Cipher
+	keyRepo   ports.SigningKeyRepository
+	algorithm string
+	// zero disables scheduled rotation, keys are rotated by admin only
+	rotationInterval time.Duration
+	log              logging.Logger
+}
+
+func NewSigningKeyService(algorithm string, rotationInterval time.Duration, jwtIssuer *jwt.Issuer, cipher *encryption.Cipher, keyRepo ports.SigningKeyRepository, log logging.Logger) ports.SigningKeyService {
+	return &SigningKeyService{
+		jwtIssuer:        jwtIssuer,
+		cipher:           cipher,
+		keyRepo:          keyRepo,
+		algorithm:        algorithm,
+		rotationInterval: rotationInterval,
+		log:              log,
+	}
+}
+
+func (s *SigningKeyService) LoadSigningKeys(ctx context.Context) error {
+	storedKeys, err := s.keyRepo.GetSigningKeys(ctx)
+	if err != nil {
+		return err
+	}
+	if len(storedKeys) == 0 {
+		return nil
+	}
+
+	// newest active key signs, previous one may still be active for a moment of concurrent rotation
+	sort.SliceStable(storedKeys, func(i, j int) bool {
+		return storedKeys[i].ActivatedAt.After(storedKeys[j].ActivatedAt)
+	})
+	keys := make([]*jwt.Key, 0, len(storedKeys))
+	for _, storedKey := range storedKeys {
+		if storedKey.State == string(jwt.KeyRetired) {
+			continue
+		}
+		key, err := s.decodeSigningKey(storedKey)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+	}
+	s.jwtIssuer.SetKeys(keys...)
+	return nil
+}
+
+func (s *SigningKeyService) RotateSigningKeys(ctx context.Context) (signingKeyResponseDto dto.SigningKeyResponseDto, err error) {
+	storedKeys, err := s.keyRepo.GetSigningKeys(ctx)
+	if err != nil {
+		s.log.Warnf("signing keys not found due to error: %v", err)
+		err = fmt.Errorf(`getting signing keys error: %w`, ports.InternalServerError)
+		return
+	}
+	now := time.Now()
+	if len(storedKeys) == 0 {
+		// configured keys are imported, so tokens they signed stay valid after rotation
+		for _, key := range s.jwtIssuer.Keys() {
+			storedKey, importErr := s.saveKey(ctx, key, now)
+			if importErr != nil {
+				err = importErr
+				return
+			}
+			storedKeys = append(storedKeys, storedKey)
+		}
+	}
+
+	// key published during previous rotation is promoted, so consumers already know it
+	next, ok := nextSigningKey(storedKeys)
+	if !ok {
+		_, err = s.createNextKey(ctx)
+		if err != nil {
+			return
+		}
+		// instances rotating at the same time all promote the oldest of created keys
+		storedKeys, err = s.keyRepo.GetSigningKeys(ctx)
+		if err != nil {
+			s.log.Warnf("signing keys not found due to error: %v", err)
+			err = fmt.Errorf(`getting signing keys error: %w`, ports.InternalServerError)
+			return
+		}
+		next, _ = nextSigningKey(storedKeys)
+	}
+	err = s.keyRepo.ActivateSigningKey(ctx, next.KeyID, now)
+	if err != nil {
+		s.log.Warnf("signing key not activated due to error: %v", err)
+		err = fmt.Errorf(`signing keys are being rotated by another instance: %w`, ports.BadRequestError)
+		return
+	}
+	err = s.keyRepo.DeactivateSigningKeys(ctx, next.KeyID, now.Add(s.jwtIssuer.MaxTokenExp()))
+	if err != nil {
+		s.log.Errorf("previous signing keys not deactivated due to error: %v", err)
+		err = fmt.Errorf(`rotating signing keys error: %w`, ports.InternalServerError)
+		return
+	}
+	s.log.Infof("signing key '%s' activated", next.KeyID)
+
+	storedKeys, err = s.keyRepo.GetSigningKeys(ctx)
+	if err == nil {
+		if _, ok = nextSigningKey(storedKeys); !ok {
+			_, err = s.createNextKey(ctx)
+		}
+	}
+	if err != nil {
+		// rotation itself succeeded, next key is created during the following one
+		s.log.Warnf("next signing key not published due to error: %v", err)
+	}
+	err = s.LoadSigningKeys(ctx)
+	if err != nil {
+		s.log.Errorf("signing keys not loaded due to error: %v", err)
+		err = fmt.Errorf(`loading signing keys error: %w`, ports.InternalServerError)
+		return
+	}
+
+	next.State, next.ActivatedAt = string(jwt.KeyActive), now
+	return mapSigningKey(next), nil
+}
+
+func (s *SigningKeyService) GetSigningKeys(ctx context.Context) ([]dto.SigningKeyResponseDto, error) {
+	storedKeys, err := s.keyRepo.GetSigningKeys(ctx)
+	if err != nil {
+		s.log.Warnf("signing keys not found due to error: %v", err)
+		return nil, fmt.Errorf(`getting signing keys error: %w`, ports.InternalServerError)
+	}
+
+	signingKeyResponseDtos := make([]dto.SigningKeyResponseDto, 0, len(storedKeys))
+	for _, storedKey := range storedKeys {
+		signingKeyResponseDtos = append(signingKeyResponseDtos, mapSigningKey(storedKey))
+	}
+	return signingKeyResponseDtos, nil
+}
+
+func (s *SigningKeyService) RunKeyRotation(ctx context.Context, interval time.Duration) {
+	for {
+		s.maintainSigningKeys(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+func (s *SigningKeyService) maintainSigningKeys(ctx context.Context, now time.Time) {
+	err := s.keyRepo.RetireSigningKeys(ctx, now)
+	if err != nil {
+		s.log.Warnf("signing keys not retired due to error: %v", err)
+	}
+	if s.rotationRequired(ctx, now) {
+		_, err = s.RotateSigningKeys(ctx)
+		if err != nil {
+			s.log.Warnf("scheduled signing key rotation failed due to error: %v", err)
+		}
+	}
+	err = s.LoadSigningKeys(ctx)
+	if err != nil {
+		s.log.Errorf("signing keys not loaded due to error: %v", err)
+	}
+}
+
+func (s *SigningKeyService) rotationRequired(ctx context.Context, now time.Time) bool {
+	if s.rotationInterval <= 0 {
+		return false
+	}
+	storedKeys, err := s.keyRepo.GetSigningKeys(ctx)
+	if err != nil {
+		s.log.Warnf("signing keys not found due to error: %v", err)
+		return false
+	}
+	for _, storedKey := range storedKeys {
+		if storedKey.State == string(jwt.KeyActive) && now.Sub(storedKey.ActivatedAt) < s.rotationInterval {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *SigningKeyService) createNextKey(ctx context.Context) (domain.SigningKey, error) {
+	key, err := jwt.GenerateKey(s.algorithm)
+	if err != nil {
+		s.log.Errorf("signing key not generated due to error: %v", err)
+		return domain.SigningKey{}, fmt.Errorf(`generating signing key error: %w`, ports.InternalServerError)
+	}
+	key.State = jwt.KeyVerifyOnly
+	return s.saveKey(ctx, key, time.Time{})
+}
+
+func (s *SigningKeyService) saveKey(ctx context.Context, key *jwt.Key, now time.Time) (storedKey domain.SigningKey, err error) {
+	storedKey = domain.SigningKey{
+		KeyID:     key.ID,
+		Algorithm: key.Method.Alg(),
+		State:     string(key.State),
+	}
+	switch key.State {
+	case jwt.KeyActive:
+		storedKey.ActivatedAt = now
+	case jwt.KeyVerifyOnly:
+		// imported verify-only keys are kept for the longest token lifetime, generated ones wait for promotion
+		if !now.IsZero() {
+			storedKey.RetireAt = now.Add(s.jwtIssuer.MaxTokenExp())
+		}
+	}
+	storedKey.PublicKey, err = key.MarshalPublicKey()
+	if err == nil && key.HasPrivateKey() {
+		var privateKey []byte
+		privateKey, err = key.MarshalPrivateKey()
+		if err == nil {
+			storedKey.PrivateKey, err = s.cipher.Encrypt(base64.StdEncoding.EncodeToString(privateKey))
+		}
+	}
+	if err != nil {
+		s.log.Errorf("signing key not encoded due to error: %v", err)
+		err = fmt.Errorf(`encoding signing key error: %w`, ports.InternalServerError)
+		return
+	}
+
+	_, err = s.keyRepo.CreateSigningKey(ctx, storedKey)
+	if err != nil {
+		s.log.Warnf("signing key not saved due to error: %v", err)
+		err = fmt.Errorf(`saving signing key error: %w`, ports.InternalServerError)
+		return
+	}
+	return storedKey, nil
+}
+
+// nextSigningKey returns the oldest key which has never been active, concurrent rotations choose the same one
+func nextSigningKey(storedKeys []domain.SigningKey) (next domain.SigningKey, ok bool) {
+	for _, storedKey := range storedKeys {
+		if storedKey.State != string(jwt.KeyVerifyOnly) || !storedKey.ActivatedAt.IsZero() ||
+			!storedKey.RetireAt.IsZero() || storedKey.PrivateKey == "" {
+			continue
+		}
+		if !ok || storedKey.ID.Hex() < next.ID.Hex() {
+			next, ok = storedKey, true
+		}
+	}
+	return
+}
+
+func (s *SigningKeyService) decodeSigningKey(storedKey domain.SigningKey) (*jwt.Key, error) {
+	der := storedKey.PublicKey
+	if storedKey.PrivateKey != "" {
+		privateKey, err := s.cipher.Decrypt(storedKey.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("signing key '%s' not decrypted due to error: %v", storedKey.KeyID, err)
+		}
+		der, err = base64.StdEncoding.DecodeString(privateKey)
+		if err != nil {
+			return nil, fmt.Errorf("signing key '%s' is malformed", storedKey.KeyID)
+		}
+	}
+	key, err := jwt.ParseKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("signing key '%s' not parsed due to error: %v", storedKey.KeyID, err)
+	}
+	key.ID, key.State = storedKey.KeyID, jwt.KeyState(storedKey.State)
+	return key, nil
+}
+
+func mapSigningKey(storedKey domain.SigningKey) dto.SigningKeyResponseDto {
+	signingKeyResponseDto := dto.SigningKeyResponseDto{
+		KeyID:     storedKey.KeyID,
+		Algorithm: storedKey.Algorithm,
+		State:     storedKey.State,
+		CreatedAt: storedKey.CreatedAt,
+	}
+	if !storedKey.ActivatedAt.IsZero() {
+		signingKeyResponseDto.ActivatedAt = &storedKey.ActivatedAt
+	}
+	if !storedKey.RetireAt.IsZero() {
+		signingKeyResponseDto.RetireAt = &storedKey.RetireAt
+	}
+	return signingKeyResponseDto
+}
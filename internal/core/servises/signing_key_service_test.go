@@ -0,0 +1,145 @@
+package servises
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports/mocks"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/jwt"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/logging/nop"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSigningKeyRotation(t *testing.T) {
+	var log = nop.GetLogger()
+	jwtIssuer := newIssuer()
+	// mocks
+	keyRepo := new(mocks.SigningKeyRepository)
+
+	// in-memory storage behind repository mock
+	var keys []domain.SigningKey
+
+	keyRepo.
+		On("GetSigningKeys", mock.Anything).
+		Return(func(_ context.Context) ([]domain.SigningKey, error) {
+			return append([]domain.SigningKey{}, keys...), nil
+		})
+	keyRepo.
+		On("CreateSigningKey", mock.Anything, mock.Anything).
+		Return(func(_ context.Context, key domain.SigningKey) (string, error) {
+			key.ID = primitive.NewObjectID()
+			key.CreatedAt = time.Now()
+			keys = append(keys, key)
+			return key.ID.Hex(), nil
+		})
+	keyRepo.
+		On("ActivateSigningKey", mock.Anything, mock.AnythingOfType("string"), mock.Anything).
+		Return(func(_ context.Context, keyID string, activatedAt time.Time) error {
+			for i := range keys {
+				if keys[i].KeyID == keyID && keys[i].ActivatedAt.IsZero() {
+					keys[i].State, keys[i].ActivatedAt = string(jwt.KeyActive), activatedAt
+					return nil
+				}
+			}
+			return fmt.Errorf("")
+		})
+	keyRepo.
+		On("DeactivateSigningKeys", mock.Anything, mock.AnythingOfType("string"), mock.Anything).
+		Return(func(_ context.Context, exceptKeyID string, retireAt time.Time) error {
+			for i := range keys {
+				if keys[i].KeyID != exceptKeyID && keys[i].State == string(jwt.KeyActive) {
+					keys[i].State, keys[i].RetireAt = string(jwt.KeyVerifyOnly), retireAt
+				}
+			}
+			return nil
+		})
+	keyRepo.
+		On("RetireSigningKeys", mock.Anything, mock.Anything).
+		Return(func(_ context.Context, now time.Time) error {
+			for i := range keys {
+				if keys[i].State == string(jwt.KeyVerifyOnly) && !keys[i].RetireAt.IsZero() && !keys[i].RetireAt.After(now) {
+					keys[i].State, keys[i].PrivateKey = string(jwt.KeyRetired), ""
+				}
+			}
+			return nil
+		})
+
+	keyIDOf := func(t *testing.T, token string) string {
+		header, err := base64.RawURLEncoding.DecodeString(strings.Split(token, ".")[0])
+		assert.NoError(t, err)
+		var parsedHeader struct {
+			KeyID string `json:"kid"`
+		}
+		assert.NoError(t, json.Unmarshal(header, &parsedHeader))
+		return parsedHeader.KeyID
+	}
+
+	// service
+	keyService := NewSigningKeyService("EdDSA", 2*jwtIssuer.MaxTokenExp(), jwtIssuer, newCipher(), keyRepo, log).(*SigningKeyService)
+
+	var firstToken string
+	var firstKeyID string
+	t.Run("successful scheduled first rotation", func(t *testing.T) {
+		keyService.maintainSigningKeys(context.Background(), time.Now())
+
+		signingKeyResponseDtos, err := keyService.GetSigningKeys(context.Background())
+		assert.NoError(t, err)
+		assert.Len(t, signingKeyResponseDtos, 2)
+		for _, signingKeyResponseDto := range signingKeyResponseDtos {
+			if signingKeyResponseDto.State == string(jwt.KeyActive) {
+				firstKeyID = signingKeyResponseDto.KeyID
+			}
+		}
+
+		firstToken, err = jwtIssuer.GenerateAccessJWT("user")
+		assert.NoError(t, err)
+		assert.Equal(t, firstKeyID, keyIDOf(t, firstToken))
+		// next key is published before it signs anything
+		assert.Len(t, jwtIssuer.PublicJWKS().Keys, 2)
+	})
+	t.Run("successful rotation to previously published key", func(t *testing.T) {
+		nextKeyID := ""
+		for _, key := range jwtIssuer.PublicJWKS().Keys {
+			if key.KeyID != firstKeyID {
+				nextKeyID = key.KeyID
+			}
+		}
+
+		signingKeyResponseDto, err := keyService.RotateSigningKeys(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, nextKeyID, signingKeyResponseDto.KeyID)
+
+		token, _ := jwtIssuer.GenerateAccessJWT("user")
+		assert.Equal(t, nextKeyID, keyIDOf(t, token))
+		_, err = jwtIssuer.ParseJWT(firstToken)
+		assert.NoError(t, err)
+	})
+	t.Run("successful load of keys stored by another instance", func(t *testing.T) {
+		jwtIssuer.SetKeys()
+
+		err := keyService.LoadSigningKeys(context.Background())
+		assert.NoError(t, err)
+		_, err = jwtIssuer.ParseJWT(firstToken)
+		assert.NoError(t, err)
+		assert.Len(t, jwtIssuer.PublicJWKS().Keys, 3)
+	})
+	t.Run("successful retirement of key after its tokens expired", func(t *testing.T) {
+		keyService.maintainSigningKeys(context.Background(), time.Now().Add(jwtIssuer.MaxTokenExp()))
+
+		_, err := jwtIssuer.ParseJWT(firstToken)
+		assert.Error(t, err)
+		assert.Len(t, jwtIssuer.PublicJWKS().Keys, 2)
+
+		signingKeyResponseDtos, err := keyService.GetSigningKeys(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, string(jwt.KeyRetired), signingKeyResponseDtos[0].State)
+	})
+	keyRepo.AssertExpectations(t)
+}
@@ -0,0 +1,305 @@
+package servises
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports/dto"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/digest"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/jwt"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/logging"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/password"
+	"time"
+)
+
+const emailChangePurpose = "email_change"
+
+type UserService struct {
+	nicknameChangeCooldown time.Duration
+	jwtIssuer              *jwt.Issuer
+	hasher                 password.Hasher
+	userRepo               ports.UserRepository
+	tokenRepo              ports.RefreshTokenRepository
+	changeTokenRepo        ports.EmailChangeTokenRepository
+	breachedPasswords      ports.BreachedPasswordChecker
+	revocationService      ports.RevocationService
+	transactor             ports.Transactor
+	eventDispatcher        ports.EventDispatcher
+	log                    logging.Logger
+}
+
+func NewUserService(nicknameChangeCooldown time.Duration, jwtIssuer *jwt.Issuer, hasher password.Hasher, userRepo ports.UserRepository, tokenRepo ports.RefreshTokenRepository, changeTokenRepo ports.EmailChangeTokenRepository, breachedPasswords ports.BreachedPasswordChecker, revocationService ports.RevocationService, transactor ports.Transactor, eventDispatcher ports.EventDispatcher, log logging.Logger) ports.UserService {
+	return &UserService{
+		nicknameChangeCooldown: nicknameChangeCooldown,
+		jwtIssuer:              jwtIssuer,
+		hasher:                 hasher,
+		userRepo:               userRepo,
+		tokenRepo:              tokenRepo,
+		changeTokenRepo:        changeTokenRepo,
+		breachedPasswords:      breachedPasswords,
+		revocationService:      revocationService,
+		transactor:             transactor,
+		eventDispatcher:        eventDispatcher,
+		log:                    log,
+	}
+}
+
+func (s *UserService) GetUser(ctx context.Context, userID string) (dto.UserResponseDto, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return dto.UserResponseDto{}, fmt.Errorf("user not found: %w", ports.NotFoundError)
+	}
+	return mapUser(user), nil
+}
+
+func (s *UserService) ChangeNickname(ctx context.Context, userID string, nickname string) (dto.UserResponseDto, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return dto.UserResponseDto{}, fmt.Errorf("user not found: %w", ports.NotFoundError)
+	}
+	if user.Nickname == nickname {
+		return mapUser(user), nil
+	}
+	if nextChange := user.NicknameChangedAt.Add(s.nicknameChangeCooldown); time.Now().Before(nextChange) {
+		return dto.UserResponseDto{}, fmt.Errorf(
+			"nickname can be changed again after %s: %w", nextChange.Format(time.RFC3339), ports.BadRequestError,
+		)
+	}
+	if _, err = s.userRepo.GetUserByNickname(ctx, nickname); err == nil {
+		return dto.UserResponseDto{}, fmt.Errorf("nickname already picked: %w", ports.BadRequestError)
+	}
+
+	user, err = s.updateUser(ctx, func(ctx context.Context) (domain.User, error) {
+		return s.userRepo.ChangeNickname(ctx, userID, nickname, time.Now())
+	})
+	if err != nil {
+		return dto.UserResponseDto{}, err
+	}
+	return mapUser(user), nil
+}
+
+func (s *UserService) ChangePassword(ctx context.Context, userID string, refreshToken string, changePasswordRequestDto dto.ChangePasswordRequestDto) error {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("user not found: %w", ports.NotFoundError)
+	}
+	err = password.VerifyPassword(user.Password, changePasswordRequestDto.CurrentPassword)
+	if err != nil {
+		return fmt.Errorf("current password does not match: %w", ports.BadRequestError)
+	}
+	err = checkBreachedPassword(s.breachedPasswords, changePasswordRequestDto.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	hashedPassword, err := s.hasher.Hash(changePasswordRequestDto.NewPassword)
+	if err != nil {
+		return fmt.Errorf(`hashing password error: %w`, ports.InternalServerError)
+	}
+	err = s.userRepo.SetPassword(ctx, userID, hashedPassword)
+	if err != nil {
+		s.log.Warnf("user not updated due to error: %v", err)
+		return fmt.Errorf(`updating user error: %w`, ports.InternalServerError)
+	}
+
+	// session the password is changed from stays, every other one ends
+	var currentFamily string
+	if token, err := s.tokenRepo.GetRefreshToken(ctx, refreshToken); err == nil && token.User == user.ID {
+		currentFamily = token.Family
+	}
+	err = s.tokenRepo.DeleteOtherUserRefreshTokens(ctx, userID, currentFamily)
+	if err != nil {
+		s.log.Warnf("other sessions not revoked due to error: %v", err)
+	}
+	// access tokens are not bound to session, current session gets new one by refresh
+	err = s.revocationService.RevokeUserAccessTokens(ctx, userID)
+	if err != nil {
+		s.log.Warnf("access tokens revoke error: %v", err)
+	}
+	return nil
+}
+
+func (s *UserService) ChangeEmail(ctx context.Context, userID string, email string) error {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("user not found: %w", ports.NotFoundError)
+	}
+	if user.Email == email {
+		return fmt.Errorf("email is not changed: %w", ports.BadRequestError)
+	}
+	if _, err = s.userRepo.GetUserByEmail(ctx, email); err == nil {
+		return fmt.Errorf("account with this email already exists: %w", ports.BadRequestError)
+	}
+
+	confirmationToken, err := s.jwtIssuer.GenerateEmailVerificationJWT(
+		user.ID.Hex(),
+		jwt.Claim{
+			Name:  "purpose",
+			Value: emailChangePurpose,
+		},
+		jwt.Claim{
+			Name:  "email",
+			Value: email,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf(`generating confirmation token error: %w`, ports.InternalServerError)
+	}
+
+	// only the last requested change may be confirmed, verification of registered email is not affected
+	err = s.changeTokenRepo.DeleteUserEmailChangeTokens(ctx, user.ID.Hex())
+	if err != nil {
+		s.log.Warnf("email change tokens delete error: %v", err)
+	}
+	_, err = s.changeTokenRepo.CreateEmailChangeToken(ctx, domain.EmailChangeToken{
+		User:  user.ID,
+		Email: email,
+		Token: digest.SHA256(confirmationToken),
+	})
+	if err != nil {
+		return fmt.Errorf(`creating confirmation token error: %w`, ports.InternalServerError)
+	}
+
+	body, err := json.Marshal(
+		map[string]interface{}{
+			"userID":   user.ID.Hex(),
+			"nickname": user.Nickname,
+			"email":    email,
+			"token":    confirmationToken,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf(`error marshaling event body: %w`, ports.InternalServerError)
+	}
+
+	return s.eventDispatcher.Dispatch(ctx, domain.Event{
+		Exchange: ports.EmailChangeExchange,
+		Key:      user.ID.Hex(),
+		Body:     body,
+	})
+}
+
+func (s *UserService) ConfirmEmailChange(ctx context.Context, confirmationToken string) (dto.UserResponseDto, error) {
+	claims, err := s.jwtIssuer.ParseJWT(confirmationToken)
+	if err != nil || claims["purpose"] != emailChangePurpose {
+		return dto.UserResponseDto{}, fmt.Errorf("invalid confirmation token: %w", ports.BadRequestError)
+	}
+
+	token, err := s.changeTokenRepo.TakeEmailChangeToken(
+		ctx,
+		digest.SHA256(confirmationToken),
+	)
+	if err != nil || token.User.Hex() != claims["sub"] {
+		return dto.UserResponseDto{}, fmt.Errorf("confirmation token not found: %w", ports.BadRequestError)
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, token.User.Hex())
+	if err != nil {
+		return dto.UserResponseDto{}, fmt.Errorf("user not found: %w", ports.NotFoundError)
+	}
+	// address could be taken while confirmation letter was on its way
+	if _, err = s.userRepo.GetUserByEmail(ctx, token.Email); err == nil {
+		return dto.UserResponseDto{}, fmt.Errorf("account with this email already exists: %w", ports.BadRequestError)
+	}
+
+	user, err = s.updateUser(ctx, func(ctx context.Context) (domain.User, error) {
+		return s.userRepo.ChangeEmail(ctx, user.ID.Hex(), token.Email)
+	})
+	if err != nil {
+		return dto.UserResponseDto{}, err
+	}
+	return mapUser(user), nil
+}
+
+// updateUser saves nickname or email change together with event, so services storing them are not left with stale ones
+func (s *UserService) updateUser(ctx context.Context, update func(ctx context.Context) (domain.User, error)) (user domain.User, err error) {
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		user, err = update(ctx)
+		if err != nil {
+			s.log.Warnf("user not updated due to error: %v", err)
+			return fmt.Errorf(`updating user error: %w`, ports.InternalServerError)
+		}
+		return s.dispatchUserUpdatedEvent(ctx, user)
+	})
+	return user, err
+}
+
+// dispatchUserUpdatedEvent lets services which store nickname or email of user refresh them
+func (s *UserService) dispatchUserUpdatedEvent(ctx context.Context, user domain.User) error {
+	body, err := json.Marshal(
+		map[string]interface{}{
+			"userID":   user.ID.Hex(),
+			"nickname": user.Nickname,
+			"email":    user.Email,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf(`error marshaling event body: %w`, ports.InternalServerError)
+	}
+
+	return s.eventDispatcher.Dispatch(ctx, domain.Event{
+		Exchange: ports.UserUpdatedExchange,
+		Key:      user.ID.Hex(),
+		Body:     body,
+	})
+}
+
+func (s *UserService) BanUser(ctx context.Context, userID string) error {
+	err := s.setBanned(ctx, userID, true)
+	if err != nil {
+		return err
+	}
+
+	err = s.tokenRepo.DeleteUserRefreshTokens(ctx, userID)
+	if err != nil {
+		s.log.Warnf("refresh tokens of user '%s' not deleted due to error: %v", userID, err)
+		return fmt.Errorf(`deleting refresh tokens error: %w`, ports.InternalServerError)
+	}
+	return s.revocationService.RevokeUserAccessTokens(ctx, userID)
+}
+
+func (s *UserService) UnbanUser(ctx context.Context, userID string) error {
+	return s.setBanned(ctx, userID, false)
+}
+
+func (s *UserService) setBanned(ctx context.Context, userID string, banned bool) error {
+	_, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("user not found: %w", ports.NotFoundError)
+	}
+
+	err = s.userRepo.SetBanned(ctx, userID, banned)
+	if err != nil {
+		s.log.Warnf("user not updated due to error: %v", err)
+		return fmt.Errorf(`updating user error: %w`, ports.InternalServerError)
+	}
+	return nil
+}
+
+func (s *UserService) UnlockUser(ctx context.Context, userID string) error {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("user not found: %w", ports.NotFoundError)
+	}
+
+	_, err = s.userRepo.ResetFailedLogins(ctx, user.ID.Hex())
+	if err != nil {
+		s.log.Warnf("failed logins not reset due to error: %v", err)
+		return fmt.Errorf(`updating user error: %w`, ports.InternalServerError)
+	}
+	return nil
+}
+
+func mapUser(user domain.User) dto.UserResponseDto {
+	return dto.UserResponseDto{
+		ID:            user.ID.Hex(),
+		Nickname:      user.Nickname,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified,
+		TOTPEnabled:   user.TOTPEnabled,
+		CreatedAt:     user.CreatedAt,
+	}
+}
@@ -0,0 +1,422 @@
+package servises
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/domain"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports/dto"
+	"github.com/ttodoshi/code-typing-auth-service/internal/core/ports/mocks"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/digest"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/jwt"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/logging/nop"
+	. "github.com/ttodoshi/code-typing-auth-service/pkg/password"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"testing"
+	"time"
+)
+
+func TestGetUser(t *testing.T) {
+	var log = nop.GetLogger()
+	jwtIssuer := newIssuer()
+	// mocks
+	userRepo := new(mocks.UserRepository)
+
+	user := domain.User{
+		Nickname: gofakeit.Username(),
+		Email:    gofakeit.Email(),
+		Password: "hash",
+	}
+	user.ID = primitive.NewObjectID()
+
+	userRepo.
+		On("GetUserByID", mock.Anything, user.ID.Hex()).
+		Return(user, nil)
+	userRepo.
+		On("GetUserByID", mock.Anything, mock.AnythingOfType("string")).
+		Return(domain.User{}, fmt.Errorf(""))
+
+	// service
+	userService := NewUserService(
+		time.Hour,
+		jwtIssuer, newHasher(), userRepo, new(mocks.RefreshTokenRepository), new(mocks.EmailChangeTokenRepository),
+		nil, NewRevocationService(jwtIssuer, new(mocks.RevokedAccessTokenRepository), log), newTransactor(), new(mocks.EventDispatcher),
+		log,
+	)
+
+	t.Run("successful get user", func(t *testing.T) {
+		userResponseDto, err := userService.GetUser(context.Background(), user.ID.Hex())
+		assert.NoError(t, err)
+		assert.Equal(t, user.ID.Hex(), userResponseDto.ID)
+		assert.Equal(t, user.Nickname, userResponseDto.Nickname)
+	})
+	t.Run("unsuccessful get user due to unknown id", func(t *testing.T) {
+		_, err := userService.GetUser(context.Background(), primitive.NewObjectID().Hex())
+		assert.Error(t, err)
+	})
+	userRepo.AssertExpectations(t)
+}
+
+func TestBanUser(t *testing.T) {
+	var log = nop.GetLogger()
+	jwtIssuer := newIssuer()
+	// mocks
+	userRepo := new(mocks.UserRepository)
+	tokenRepo := new(mocks.RefreshTokenRepository)
+	revokedTokenRepo := new(mocks.RevokedAccessTokenRepository)
+
+	user := domain.User{
+		Nickname: gofakeit.Username(),
+		Email:    gofakeit.Email(),
+	}
+	user.ID = primitive.NewObjectID()
+	accessToken, _ := jwtIssuer.GenerateAccessJWT(user.ID.Hex())
+
+	userRepo.
+		On("GetUserByID", mock.Anything, user.ID.Hex()).
+		Return(func(_ context.Context, ID string) (domain.User, error) {
+			return user, nil
+		})
+	userRepo.
+		On("GetUserByID", mock.Anything, mock.AnythingOfType("string")).
+		Return(domain.User{}, fmt.Errorf(""))
+	userRepo.
+		On("SetBanned", mock.Anything, user.ID.Hex(), mock.AnythingOfType("bool")).
+		Return(func(_ context.Context, _ string, banned bool) error {
+			user.Banned = banned
+			return nil
+		})
+	tokenRepo.
+		On("DeleteUserRefreshTokens", mock.Anything, user.ID.Hex()).
+		Return(nil)
+	revokedTokenRepo.
+		On("CreateRevokedAccessToken", mock.Anything, domain.RevokedAccessToken{User: user.ID}).
+		Return(primitive.NewObjectID().Hex(), nil)
+
+	// service
+	revocationService := NewRevocationService(jwtIssuer, revokedTokenRepo, log)
+	userService := NewUserService(
+		time.Hour,
+		jwtIssuer, newHasher(), userRepo, tokenRepo, new(mocks.EmailChangeTokenRepository),
+		nil, revocationService, newTransactor(), new(mocks.EventDispatcher),
+		log,
+	)
+
+	t.Run("successful ban", func(t *testing.T) {
+		err := userService.BanUser(context.Background(), user.ID.Hex())
+		assert.NoError(t, err)
+		assert.True(t, user.Banned)
+
+		claims, err := jwtIssuer.ParseAccessJWT(accessToken)
+		assert.NoError(t, err)
+		// token issued in the second of revocation is kept valid
+		claims["iat"] = float64(time.Now().Add(-time.Minute).Unix())
+		assert.True(t, revocationService.IsAccessTokenRevoked(claims))
+	})
+	t.Run("successful unban", func(t *testing.T) {
+		err := userService.UnbanUser(context.Background(), user.ID.Hex())
+		assert.NoError(t, err)
+		assert.False(t, user.Banned)
+	})
+	t.Run("unsuccessful ban due to unknown id", func(t *testing.T) {
+		err := userService.BanUser(context.Background(), primitive.NewObjectID().Hex())
+		assert.Error(t, err)
+	})
+	userRepo.AssertExpectations(t)
+	tokenRepo.AssertExpectations(t)
+	revokedTokenRepo.AssertExpectations(t)
+}
+
+func TestChangeNickname(t *testing.T) {
+	var log = nop.GetLogger()
+	jwtIssuer := newIssuer()
+	// mocks
+	userRepo := new(mocks.UserRepository)
+	eventDispatcher := new(mocks.EventDispatcher)
+
+	user := domain.User{
+		Nickname: gofakeit.Username(),
+		Email:    gofakeit.Email(),
+	}
+	user.ID = primitive.NewObjectID()
+	pickedNickname := gofakeit.Username()
+
+	userRepo.
+		On("GetUserByID", mock.Anything, user.ID.Hex()).
+		Return(func(_ context.Context, ID string) (domain.User, error) {
+			return user, nil
+		})
+	userRepo.
+		On("GetUserByNickname", mock.Anything, pickedNickname).
+		Return(domain.User{Nickname: pickedNickname}, nil)
+	userRepo.
+		On("GetUserByNickname", mock.Anything, mock.AnythingOfType("string")).
+		Return(domain.User{}, fmt.Errorf(""))
+	userRepo.
+		On("ChangeNickname", mock.Anything, user.ID.Hex(), mock.AnythingOfType("string"), mock.AnythingOfType("time.Time")).
+		Return(func(_ context.Context, _ string, nickname string, changedAt time.Time) (domain.User, error) {
+			user.Nickname = nickname
+			user.NicknameChangedAt = changedAt
+			return user, nil
+		})
+	eventDispatcher.
+		On("Dispatch", mock.Anything, mock.Anything).
+		Return(fmt.Errorf("outbox is unavailable")).
+		Once()
+	eventDispatcher.
+		On("Dispatch", mock.Anything, mock.MatchedBy(func(event domain.Event) bool {
+			return event.Exchange == ports.UserUpdatedExchange
+		})).
+		Return(nil).
+		Once()
+
+	// service
+	userService := NewUserService(
+		time.Hour,
+		jwtIssuer, newHasher(), userRepo, new(mocks.RefreshTokenRepository), new(mocks.EmailChangeTokenRepository),
+		nil, NewRevocationService(jwtIssuer, new(mocks.RevokedAccessTokenRepository), log), newTransactor(), eventDispatcher,
+		log,
+	)
+
+	t.Run("unsuccessful nickname change due to picked nickname", func(t *testing.T) {
+		_, err := userService.ChangeNickname(context.Background(), user.ID.Hex(), pickedNickname)
+		assert.Error(t, err)
+	})
+	t.Run("unsuccessful nickname change due to event not saved", func(t *testing.T) {
+		savedUser := user
+		_, err := userService.ChangeNickname(context.Background(), user.ID.Hex(), "lost_nickname")
+		assert.Error(t, err)
+		// update is rolled back together with event by database
+		user = savedUser
+	})
+	t.Run("successful nickname change", func(t *testing.T) {
+		userResponseDto, err := userService.ChangeNickname(context.Background(), user.ID.Hex(), "new_nickname")
+		assert.NoError(t, err)
+		assert.Equal(t, "new_nickname", userResponseDto.Nickname)
+		assert.False(t, user.NicknameChangedAt.IsZero())
+	})
+	t.Run("unsuccessful nickname change due to cooldown", func(t *testing.T) {
+		_, err := userService.ChangeNickname(context.Background(), user.ID.Hex(), "another_nickname")
+		assert.Error(t, err)
+		assert.Equal(t, "new_nickname", user.Nickname)
+	})
+	userRepo.AssertExpectations(t)
+	eventDispatcher.AssertExpectations(t)
+}
+
+func TestChangePassword(t *testing.T) {
+	var log = nop.GetLogger()
+	jwtIssuer := newIssuer()
+	// mocks
+	userRepo := new(mocks.UserRepository)
+	tokenRepo := new(mocks.RefreshTokenRepository)
+	breachedPasswords := new(mocks.BreachedPasswordChecker)
+	revokedTokenRepo := new(mocks.RevokedAccessTokenRepository)
+
+	currentPassword := gofakeit.Password(true, true, true, true, false, 8)
+	newPassword := gofakeit.Password(true, true, true, true, false, 8)
+	hashPassword, err := HashPassword(currentPassword)
+	assert.NoError(t, err)
+	user := domain.User{
+		Nickname: gofakeit.Username(),
+		Email:    gofakeit.Email(),
+		Password: hashPassword,
+	}
+	user.ID = primitive.NewObjectID()
+
+	userRepo.
+		On("GetUserByID", mock.Anything, user.ID.Hex()).
+		Return(func(_ context.Context, ID string) (domain.User, error) {
+			return user, nil
+		})
+	userRepo.
+		On("SetPassword", mock.Anything, user.ID.Hex(), mock.AnythingOfType("string")).
+		Return(func(_ context.Context, _ string, password string) error {
+			user.Password = password
+			return nil
+		})
+	tokenRepo.
+		On("GetRefreshToken", mock.Anything, "current").
+		Return(domain.RefreshToken{User: user.ID, Token: "current", Family: "current-family"}, nil)
+	tokenRepo.
+		On("DeleteOtherUserRefreshTokens", mock.Anything, user.ID.Hex(), "current-family").
+		Return(nil)
+	breachedPasswords.
+		On("IsBreached", "password123").
+		Return(true)
+	breachedPasswords.
+		On("IsBreached", newPassword).
+		Return(false)
+	revokedTokenRepo.
+		On("CreateRevokedAccessToken", mock.Anything, mock.MatchedBy(func(token domain.RevokedAccessToken) bool {
+			return token.User == user.ID && token.JTI == ""
+		})).
+		Return(primitive.NewObjectID().Hex(), nil).
+		Once()
+
+	// service
+	revocationService := NewRevocationService(jwtIssuer, revokedTokenRepo, log)
+	userService := NewUserService(
+		time.Hour,
+		jwtIssuer, newHasher(), userRepo, tokenRepo, new(mocks.EmailChangeTokenRepository),
+		breachedPasswords, revocationService, newTransactor(), new(mocks.EventDispatcher),
+		log,
+	)
+	accessToken, _ := jwtIssuer.GenerateAccessJWT(user.ID.Hex())
+
+	t.Run("unsuccessful password change due to wrong current password", func(t *testing.T) {
+		err = userService.ChangePassword(context.Background(), user.ID.Hex(), "current", dto.ChangePasswordRequestDto{
+			CurrentPassword: newPassword,
+			NewPassword:     newPassword,
+		})
+		assert.Error(t, err)
+	})
+	t.Run("unsuccessful password change due to breached password", func(t *testing.T) {
+		err = userService.ChangePassword(context.Background(), user.ID.Hex(), "current", dto.ChangePasswordRequestDto{
+			CurrentPassword: currentPassword,
+			NewPassword:     "password123",
+		})
+		assert.ErrorIs(t, err, ports.BadRequestError)
+	})
+	t.Run("successful password change", func(t *testing.T) {
+		err = userService.ChangePassword(context.Background(), user.ID.Hex(), "current", dto.ChangePasswordRequestDto{
+			CurrentPassword: currentPassword,
+			NewPassword:     newPassword,
+		})
+		assert.NoError(t, err)
+		assert.NoError(t, VerifyPassword(user.Password, newPassword))
+
+		claims, err := jwtIssuer.ParseAccessJWT(accessToken)
+		assert.NoError(t, err)
+		// token issued in the second of revocation is kept valid
+		claims["iat"] = float64(time.Now().Add(-time.Minute).Unix())
+		assert.True(t, revocationService.IsAccessTokenRevoked(claims))
+	})
+	userRepo.AssertExpectations(t)
+	tokenRepo.AssertExpectations(t)
+	breachedPasswords.AssertExpectations(t)
+	revokedTokenRepo.AssertExpectations(t)
+}
+
+func TestChangeEmail(t *testing.T) {
+	var log = nop.GetLogger()
+	jwtIssuer := newIssuer()
+	// mocks
+	userRepo := new(mocks.UserRepository)
+	changeTokenRepo := new(mocks.EmailChangeTokenRepository)
+	eventDispatcher := new(mocks.EventDispatcher)
+
+	user := domain.User{
+		Nickname:      gofakeit.Username(),
+		Email:         gofakeit.Email(),
+		EmailVerified: true,
+	}
+	user.ID = primitive.NewObjectID()
+	takenEmail := gofakeit.Email()
+	newEmail := gofakeit.Email()
+
+	// in-memory storage behind repository mocks
+	tokens := map[string]domain.EmailChangeToken{}
+	var confirmationToken string
+
+	userRepo.
+		On("GetUserByID", mock.Anything, user.ID.Hex()).
+		Return(func(_ context.Context, ID string) (domain.User, error) {
+			return user, nil
+		})
+	userRepo.
+		On("GetUserByEmail", mock.Anything, takenEmail).
+		Return(domain.User{Email: takenEmail}, nil)
+	userRepo.
+		On("GetUserByEmail", mock.Anything, mock.AnythingOfType("string")).
+		Return(domain.User{}, fmt.Errorf(""))
+	userRepo.
+		On("ChangeEmail", mock.Anything, user.ID.Hex(), mock.AnythingOfType("string")).
+		Return(func(_ context.Context, _ string, email string) (domain.User, error) {
+			user.Email = email
+			user.EmailVerified = true
+			return user, nil
+		})
+	changeTokenRepo.
+		On("DeleteUserEmailChangeTokens", mock.Anything, user.ID.Hex()).
+		Return(func(_ context.Context, ID string) error {
+			tokens = map[string]domain.EmailChangeToken{}
+			return nil
+		})
+	changeTokenRepo.
+		On("CreateEmailChangeToken", mock.Anything, mock.AnythingOfType("domain.EmailChangeToken")).
+		Return(func(_ context.Context, token domain.EmailChangeToken) (string, error) {
+			tokens[token.Token] = token
+			return gofakeit.UUID(), nil
+		})
+	changeTokenRepo.
+		On("TakeEmailChangeToken", mock.Anything, mock.AnythingOfType("string")).
+		Return(func(_ context.Context, hash string) (domain.EmailChangeToken, error) {
+			token, ok := tokens[hash]
+			if !ok {
+				return token, fmt.Errorf("")
+			}
+			delete(tokens, hash)
+			return token, nil
+		})
+	eventDispatcher.
+		On("Dispatch", mock.Anything, mock.MatchedBy(func(event domain.Event) bool {
+			return event.Exchange == ports.EmailChangeExchange
+		})).
+		Run(func(args mock.Arguments) {
+			var body map[string]string
+			_ = json.Unmarshal(args.Get(1).(domain.Event).Body, &body)
+			confirmationToken = body["token"]
+		}).
+		Return(nil)
+	eventDispatcher.
+		On("Dispatch", mock.Anything, mock.MatchedBy(func(event domain.Event) bool {
+			return event.Exchange == ports.UserUpdatedExchange
+		})).
+		Return(nil).
+		Once()
+
+	// service
+	userService := NewUserService(
+		time.Hour,
+		jwtIssuer, newHasher(), userRepo, new(mocks.RefreshTokenRepository), changeTokenRepo,
+		nil, NewRevocationService(jwtIssuer, new(mocks.RevokedAccessTokenRepository), log), newTransactor(), eventDispatcher,
+		log,
+	)
+
+	t.Run("unsuccessful email change due to taken email", func(t *testing.T) {
+		err := userService.ChangeEmail(context.Background(), user.ID.Hex(), takenEmail)
+		assert.Error(t, err)
+	})
+	t.Run("successful email change", func(t *testing.T) {
+		err := userService.ChangeEmail(context.Background(), user.ID.Hex(), newEmail)
+		assert.NoError(t, err)
+		assert.NotEqual(t, newEmail, user.Email)
+
+		userResponseDto, err := userService.ConfirmEmailChange(context.Background(), confirmationToken)
+		assert.NoError(t, err)
+		assert.Equal(t, newEmail, userResponseDto.Email)
+		assert.True(t, userResponseDto.EmailVerified)
+	})
+	t.Run("unsuccessful confirmation due to used token", func(t *testing.T) {
+		_, err := userService.ConfirmEmailChange(context.Background(), confirmationToken)
+		assert.Error(t, err)
+	})
+	t.Run("unsuccessful confirmation due to token of another purpose", func(t *testing.T) {
+		verificationToken, _ := jwtIssuer.GenerateEmailVerificationJWT(
+			user.ID.Hex(),
+			jwt.Claim{Name: "purpose", Value: "email_verification"},
+		)
+		tokens[digest.SHA256(verificationToken)] = domain.EmailChangeToken{User: user.ID, Email: takenEmail}
+
+		_, err := userService.ConfirmEmailChange(context.Background(), verificationToken)
+		assert.Error(t, err)
+	})
+	userRepo.AssertExpectations(t)
+	changeTokenRepo.AssertExpectations(t)
+	eventDispatcher.AssertExpectations(t)
+}
@@ -0,0 +1,118 @@
+package breach
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+// magic starts every set file, its last byte is format version
+var magic = []byte("BRPWSET1")
+
+const headerSize = 16
+
+// Set is sorted list of 64-bit SHA-1 prefixes of breached passwords.
+// Chance that password is falsely reported as breached is about size of set / 2^64
+type Set struct {
+	prefixes []uint64
+}
+
+func NewSet(passwords ...string) *Set {
+	set := &Set{}
+	for _, password := range passwords {
+		set.prefixes = append(set.prefixes, prefix(password))
+	}
+	set.normalize()
+	return set
+}
+
+// Build reads plain password list with one password per line
+func Build(r io.Reader) (*Set, error) {
+	set := &Set{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		password := strings.TrimSuffix(scanner.Text(), "\r")
+		if password == "" {
+			continue
+		}
+		set.prefixes = append(set.prefixes, prefix(password))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read password list: %w", err)
+	}
+	set.normalize()
+	return set, nil
+}
+
+// Load reads set file written by WriteTo
+func Load(path string) (*Set, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < headerSize || !bytes.Equal(data[:len(magic)], magic) {
+		return nil, fmt.Errorf("'%s' is not breached password set", path)
+	}
+	count := binary.BigEndian.Uint64(data[len(magic):headerSize])
+	if uint64(len(data)-headerSize) != count*8 {
+		return nil, fmt.Errorf("breached password set '%s' is truncated", path)
+	}
+
+	set := &Set{prefixes: make([]uint64, count)}
+	for i := range set.prefixes {
+		set.prefixes[i] = binary.BigEndian.Uint64(data[headerSize+i*8:])
+	}
+	if !slices.IsSorted(set.prefixes) {
+		return nil, fmt.Errorf("breached password set '%s' is not sorted", path)
+	}
+	return set, nil
+}
+
+func (s *Set) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	header := make([]byte, headerSize)
+	copy(header, magic)
+	binary.BigEndian.PutUint64(header[len(magic):], uint64(len(s.prefixes)))
+	n, err := bw.Write(header)
+	written := int64(n)
+	if err != nil {
+		return written, err
+	}
+
+	entry := make([]byte, 8)
+	for _, p := range s.prefixes {
+		binary.BigEndian.PutUint64(entry, p)
+		n, err = bw.Write(entry)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, bw.Flush()
+}
+
+func (s *Set) Len() int {
+	return len(s.prefixes)
+}
+
+// IsBreached reports whether password is in set
+func (s *Set) IsBreached(password string) bool {
+	_, found := slices.BinarySearch(s.prefixes, prefix(password))
+	return found
+}
+
+func (s *Set) normalize() {
+	slices.Sort(s.prefixes)
+	s.prefixes = slices.Compact(s.prefixes)
+}
+
+func prefix(password string) uint64 {
+	sum := sha1.Sum([]byte(password))
+	return binary.BigEndian.Uint64(sum[:8])
+}
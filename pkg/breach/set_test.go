@@ -0,0 +1,55 @@
+package breach
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuildAndLoad(t *testing.T) {
+	set, err := Build(strings.NewReader("123456\r\npassword\n\nqwerty\npassword\n"))
+	if err != nil {
+		t.Fatalf("Build returned an error: %v", err)
+	}
+	if set.Len() != 3 {
+		t.Errorf("Build kept %d passwords, expected 3 unique ones", set.Len())
+	}
+
+	var buf bytes.Buffer
+	_, err = set.WriteTo(&buf)
+	if err != nil {
+		t.Fatalf("WriteTo returned an error: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "breached.bin")
+	err = os.WriteFile(path, buf.Bytes(), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load returned an error: %v", err)
+	}
+	for _, password := range []string{"123456", "password", "qwerty"} {
+		if !loaded.IsBreached(password) {
+			t.Errorf("IsBreached(%q) = false, expected true", password)
+		}
+	}
+	if loaded.IsBreached("correct horse battery staple") {
+		t.Error("IsBreached of password not in list = true")
+	}
+}
+
+func TestLoadInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "passwords.txt")
+	err := os.WriteFile(path, []byte("123456\npassword\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Load(path)
+	if err == nil {
+		t.Error("Load of plain password list returned no error")
+	}
+}
@@ -1,38 +1,212 @@
 package broker
 
 import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
 	amqp "github.com/rabbitmq/amqp091-go"
 	"log"
-	"os"
 	"sync"
+	"time"
 )
 
-var conn *amqp.Connection
-var channel *amqp.Channel
-var once sync.Once
+var (
+	ErrNotConnected = errors.New("RabbitMQ is not connected")
+	ErrNacked       = errors.New("message is rejected by RabbitMQ")
+	ErrReturned     = errors.New("message is not routed to any queue")
+)
 
-func InitMessageBroker() *amqp.Channel {
-	once.Do(func() {
-		var err error
-		conn, err = amqp.Dial(os.Getenv("RABBITMQ_URL"))
-		if err != nil {
-			log.Fatal("failed to create RabbitMQ connection")
-		}
-		channel, err = conn.Channel()
+// Metrics are exposed by expvar:
+// awaiting_confirm is number of messages published but not confirmed yet,
+// unconfirmed counts messages whose confirm was not received before timeout or connection loss
+var Metrics = expvar.NewMap("rabbitmq")
+
+const (
+	minReconnectDelay = time.Second
+	maxReconnectDelay = 30 * time.Second
+)
+
+// Publisher keeps connection to RabbitMQ and publishes messages with confirms,
+// lost connection is re-established with doubling delay and topology is declared again on new channel
+type Publisher struct {
+	url string
+	// topology declares exchanges on every new channel
+	topology func(channel *amqp.Channel) error
+	// mu guards channel and serializes publications, so returned message is matched with confirm of its publication
+	mu      sync.Mutex
+	conn    *amqp.Connection
+	channel *amqp.Channel
+	returns chan amqp.Return
+	closed  chan struct{}
+	stopped chan struct{}
+}
+
+// NewPublisher connects in background, messages are not published until connection is established
+func NewPublisher(url string, topology func(channel *amqp.Channel) error) *Publisher {
+	p := &Publisher{
+		url:      url,
+		topology: topology,
+		closed:   make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	go p.run()
+	return p
+}
+
+func (p *Publisher) run() {
+	defer close(p.stopped)
+
+	delay := minReconnectDelay
+	for {
+		lost, err := p.connect()
 		if err != nil {
-			log.Fatal("failed to create RabbitMQ channel")
+			log.Printf("RabbitMQ not connected, next attempt in %s, due to: %v", delay, err)
+			select {
+			case <-p.closed:
+				return
+			case <-time.After(delay):
+			}
+			delay = min(delay*2, maxReconnectDelay)
+			continue
 		}
+		delay = minReconnectDelay
 		log.Print("RabbitMQ connection initialized")
-	})
-	return channel
+
+		select {
+		case <-p.closed:
+			return
+		case err = <-lost:
+			Metrics.Add("reconnects", 1)
+			log.Printf("RabbitMQ connection lost due to: %v", err)
+			p.disconnect()
+		}
+	}
+}
+
+// connect opens connection and channel in confirm mode, returned channel gets error once either of them is closed
+func (p *Publisher) connect() (<-chan *amqp.Error, error) {
+	conn, err := amqp.Dial(p.url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create RabbitMQ connection: %w", err)
+	}
+	channel, err := conn.Channel()
+	if err == nil {
+		err = channel.Confirm(false)
+	}
+	if err == nil {
+		err = p.topology(channel)
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("failed to create RabbitMQ channel: %w", err)
+	}
+
+	lost := make(chan *amqp.Error, 2)
+	forward := func(closed chan *amqp.Error) {
+		err, ok := <-closed
+		if !ok {
+			err = amqp.ErrClosed
+		}
+		lost <- err
+	}
+	go forward(conn.NotifyClose(make(chan *amqp.Error, 1)))
+	go forward(channel.NotifyClose(make(chan *amqp.Error, 1)))
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.conn, p.channel = conn, channel
+	// at most one message is returned per publication, buffer keeps connection reader from blocking
+	p.returns = channel.NotifyReturn(make(chan amqp.Return, 16))
+	return lost, nil
 }
 
-func Close() {
-	var err error
-	err = conn.Close()
-	err = channel.Close()
+func (p *Publisher) disconnect() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.conn != nil {
+		// channel may be closed alone, connection is recreated together with it
+		_ = p.conn.Close()
+	}
+	p.conn, p.channel, p.returns = nil, nil, nil
+}
+
+// Publish publishes mandatory message and waits for its confirm until ctx is done,
+// message without queue to route it to is reported by ErrReturned
+func (p *Publisher) Publish(ctx context.Context, exchange string, key string, msg amqp.Publishing) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.channel == nil {
+		return ErrNotConnected
+	}
+	p.dropReturns()
+
+	Metrics.Add("awaiting_confirm", 1)
+	defer Metrics.Add("awaiting_confirm", -1)
+	confirmation, err := p.channel.PublishWithDeferredConfirmWithContext(ctx, exchange, key, true, false, msg)
+	if err != nil {
+		return fmt.Errorf("failed to publish message: %w", err)
+	}
+	acked, err := confirmation.WaitContext(ctx)
 	if err != nil {
-		log.Fatal("failed to close RabbitMQ connection")
+		Metrics.Add("unconfirmed", 1)
+		return fmt.Errorf("message not confirmed: %w", err)
+	}
+	if !acked {
+		// pending confirms are released without ack when channel is closed
+		if p.channel.IsClosed() {
+			Metrics.Add("unconfirmed", 1)
+			return fmt.Errorf("message not confirmed: %w", ErrNotConnected)
+		}
+		Metrics.Add("nacked", 1)
+		return ErrNacked
+	}
+
+	// server sends return before confirm of the same message
+	select {
+	case returned := <-p.returns:
+		Metrics.Add("returned", 1)
+		return fmt.Errorf("%w: %s", ErrReturned, returned.ReplyText)
+	default:
+	}
+	Metrics.Add("confirmed", 1)
+	return nil
+}
+
+// dropReturns discards returns of publications which were not waited for
+func (p *Publisher) dropReturns() {
+	for {
+		select {
+		case <-p.returns:
+		default:
+			return
+		}
+	}
+}
+
+// Close stops reconnection and closes channel before connection, so messages published on it are not lost
+func (p *Publisher) Close() error {
+	close(p.closed)
+	<-p.stopped
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.conn == nil {
+		return nil
+	}
+	err := p.channel.Close()
+	if err != nil && !errors.Is(err, amqp.ErrClosed) {
+		return fmt.Errorf("failed to close RabbitMQ channel: %w", err)
+	}
+	err = p.conn.Close()
+	if err != nil && !errors.Is(err, amqp.ErrClosed) {
+		return fmt.Errorf("failed to close RabbitMQ connection: %w", err)
 	}
+	p.conn, p.channel, p.returns = nil, nil, nil
 	log.Print("RabbitMQ connection closed")
+	return nil
 }
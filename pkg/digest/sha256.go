@@ -0,0 +1,13 @@
+package digest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// SHA256 returns hex encoded SHA-256 digest of the value.
+// Used for storing high-entropy tokens which must not be kept in plain text
+func SHA256(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
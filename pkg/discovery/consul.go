@@ -4,17 +4,29 @@ import (
 	"fmt"
 	"github.com/hashicorp/consul/api"
 	"log"
-	"os"
-	"strconv"
-	"strings"
 )
 
-func InitServiceDiscovery() {
+type Config struct {
+	// Address of consul agent
+	Address        string
+	ServiceName    string
+	ServiceAddress string
+	ServicePort    int
+	Tags           []string
+}
+
+// Registration is service registered in consul by Register
+type Registration struct {
+	agent     *api.Agent
+	serviceID string
+}
+
+func Register(cfg Config) *Registration {
 	log.Print("initializing consul client")
 
 	consulClient, err := api.NewClient(
 		&api.Config{
-			Address: os.Getenv("CONSUL_HOST"),
+			Address: cfg.Address,
 		},
 	)
 	if err != nil {
@@ -22,28 +34,37 @@ func InitServiceDiscovery() {
 	}
 
 	log.Print("register service in consul")
-	agent := consulClient.Agent()
-	parsedPort, err := strconv.Atoi(os.Getenv("PORT"))
-	if err != nil {
-		log.Fatal("port parse error")
+	registration := &Registration{
+		agent:     consulClient.Agent(),
+		serviceID: cfg.ServiceName,
 	}
-
-	tags := strings.Split(os.Getenv("CONSUL_TAGS"), ",")
 	service := &api.AgentServiceRegistration{
-		Name:    os.Getenv("CONSUL_SERVICE_NAME"),
-		Port:    parsedPort,
-		Address: os.Getenv("CONSUL_SERVICE_ADDRESS"),
-		Tags:    tags,
+		ID:      registration.serviceID,
+		Name:    cfg.ServiceName,
+		Port:    cfg.ServicePort,
+		Address: cfg.ServiceAddress,
+		Tags:    cfg.Tags,
 		Check: &api.AgentServiceCheck{
-			HTTP:                           fmt.Sprintf("http://%s:%d/health", os.Getenv("CONSUL_SERVICE_ADDRESS"), parsedPort),
+			HTTP:                           fmt.Sprintf("http://%s:%d/health", cfg.ServiceAddress, cfg.ServicePort),
 			Interval:                       "10s",
 			Timeout:                        "2s",
 			DeregisterCriticalServiceAfter: "1m",
 		},
 	}
-	err = agent.ServiceRegister(service)
+	err = registration.agent.ServiceRegister(service)
 	if err != nil {
 		log.Fatalf("error while service registration due to error '%s'", err)
 	}
 	log.Print("service registered in consul")
+	return registration
+}
+
+// Deregister removes service from consul, so traffic is not routed to stopping instance
+func (r *Registration) Deregister() error {
+	err := r.agent.ServiceDeregister(r.serviceID)
+	if err != nil {
+		return fmt.Errorf("error while service deregistration: %w", err)
+	}
+	log.Print("service deregistered from consul")
+	return nil
 }
@@ -0,0 +1,58 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+)
+
+type Cipher struct {
+	key [sha256.Size]byte
+}
+
+// NewCipher derives AES-256 key from secret
+func NewCipher(secret string) *Cipher {
+	return &Cipher{key: sha256.Sum256([]byte(secret))}
+}
+
+// Encrypt seals plaintext with AES-256-GCM, nonce is prepended to the result
+func (c *Cipher) Encrypt(plaintext string) (string, error) {
+	gcm, err := c.newGCM()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return "", fmt.Errorf("could not generate nonce %w", err)
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (c *Cipher) Decrypt(ciphertext string) (string, error) {
+	gcm, err := c.newGCM()
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil || len(sealed) < gcm.NonceSize() {
+		return "", fmt.Errorf("malformed ciphertext")
+	}
+	plaintext, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("could not decrypt %w", err)
+	}
+	return string(plaintext), nil
+}
+
+func (c *Cipher) newGCM() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(c.key[:])
+	if err != nil {
+		return nil, fmt.Errorf("could not create cipher %w", err)
+	}
+	return cipher.NewGCM(block)
+}
@@ -1,13 +0,0 @@
-package env
-
-import (
-	"github.com/joho/godotenv"
-	"log"
-)
-
-func LoadEnvVariables() {
-	err := godotenv.Load()
-	if err != nil {
-		log.Print("no .env file found")
-	}
-}
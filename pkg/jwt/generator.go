@@ -1,26 +1,67 @@
 package jwt
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"github.com/golang-jwt/jwt/v5"
-	"os"
-	"strconv"
+	"sync"
 	"time"
 )
 
-var (
-	secretKey          = []byte(os.Getenv("SECRET_KEY"))
-	AccessTokenExp, _  = strconv.Atoi(os.Getenv("ACCESS_TOKEN_EXP"))
-	RefreshTokenExp, _ = strconv.Atoi(os.Getenv("REFRESH_TOKEN_EXP"))
-)
+type Config struct {
+	SecretKey                 string
+	Issuer                    string
+	Audience                  string
+	AccessTokenExp            time.Duration
+	RefreshTokenExp           time.Duration
+	EmailVerificationTokenExp time.Duration
+	PasswordResetTokenExp     time.Duration
+	MFATokenExp               time.Duration
+	OAuthStateTokenExp        time.Duration
+	IDTokenExp                time.Duration
+	DataExportTokenExp        time.Duration
+	// LegacyKeyEnabled keeps tokens signed with SecretKey valid, it is turned off once they expired after migration to asymmetric keys
+	LegacyKeyEnabled bool
+}
+
+// Issuer generates and parses tokens of the service, it is shared by all services, so keys set by rotation apply to all of them
+type Issuer struct {
+	cfg Config
+	// legacyKey is HS256 key derived from SecretKey, nil when legacy key is disabled
+	legacyKey *Key
+	keysMu    sync.RWMutex
+	// signing falls back to legacy key until asymmetric key is set
+	activeKey        *Key
+	verificationKeys map[string]*Key
+}
+
+func NewIssuer(cfg Config) *Issuer {
+	issuer := &Issuer{
+		cfg:              cfg,
+		verificationKeys: map[string]*Key{},
+	}
+	if cfg.LegacyKeyEnabled {
+		issuer.legacyKey = secretKeyHS256([]byte(cfg.SecretKey))
+	}
+	return issuer
+}
+
+// AccessTokenExp is lifetime of access tokens, their revocations are kept this long
+func (i *Issuer) AccessTokenExp() time.Duration {
+	return i.cfg.AccessTokenExp
+}
+
+// accessTokenType is typ header of access tokens, so no other token of the service is accepted instead of them
+const accessTokenType = "at+jwt"
 
 type Claim struct {
 	Name  string
 	Value interface{}
 }
 
-func GenerateAccessJWT(sub string, claims ...Claim) (accessToken string, err error) {
-	accessToken, err = generateJWT(sub, AccessTokenExp, claims...)
+func (i *Issuer) GenerateAccessJWT(sub string, claims ...Claim) (accessToken string, err error) {
+	accessToken, err = i.generateAccessJWT(i.cfg.Audience, sub, claims...)
 
 	if err != nil {
 		err = fmt.Errorf("access jwt generation error due to: %s", err.Error())
@@ -29,8 +70,33 @@ func GenerateAccessJWT(sub string, claims ...Claim) (accessToken string, err err
 	return
 }
 
-func GenerateRefreshJWT(sub string, claims ...Claim) (refreshToken string, err error) {
-	refreshToken, err = generateJWT(sub, RefreshTokenExp, claims...)
+// GenerateClientAccessJWT issues access token to OAuth client, its audience is the client,
+// so it is not accepted by ParseAccessJWT instead of first-party token
+func (i *Issuer) GenerateClientAccessJWT(sub string, clientID string, claims ...Claim) (accessToken string, err error) {
+	claims = append([]Claim{{Name: "client_id", Value: clientID}}, claims...)
+	accessToken, err = i.generateAccessJWT(clientID, sub, claims...)
+
+	if err != nil {
+		err = fmt.Errorf("client access jwt generation error due to: %s", err.Error())
+		return
+	}
+	return
+}
+
+func (i *Issuer) generateAccessJWT(audience string, sub string, claims ...Claim) (string, error) {
+	jti, err := generateJTI()
+	if err != nil {
+		return "", err
+	}
+	claims = append([]Claim{{Name: "jti", Value: jti}}, claims...)
+	if audience != "" {
+		claims = append([]Claim{{Name: "aud", Value: audience}}, claims...)
+	}
+	return i.generateTypedJWT(accessTokenType, sub, i.cfg.AccessTokenExp, claims...)
+}
+
+func (i *Issuer) GenerateRefreshJWT(sub string, claims ...Claim) (refreshToken string, err error) {
+	refreshToken, err = i.generateJWT(sub, i.cfg.RefreshTokenExp, claims...)
 
 	if err != nil {
 		err = fmt.Errorf("refresh jwt generation error due to: %s", err.Error())
@@ -39,17 +105,104 @@ func GenerateRefreshJWT(sub string, claims ...Claim) (refreshToken string, err e
 	return
 }
 
-func generateJWT(sub string, exp int, claims ...Claim) (jwtToken string, err error) {
-	token := jwt.New(jwt.SigningMethodHS256)
+func (i *Issuer) GenerateEmailVerificationJWT(sub string, claims ...Claim) (verificationToken string, err error) {
+	verificationToken, err = i.generateJWT(sub, i.cfg.EmailVerificationTokenExp, claims...)
+
+	if err != nil {
+		err = fmt.Errorf("email verification jwt generation error due to: %s", err.Error())
+		return
+	}
+	return
+}
+
+func (i *Issuer) GeneratePasswordResetJWT(sub string, claims ...Claim) (resetToken string, err error) {
+	resetToken, err = i.generateJWT(sub, i.cfg.PasswordResetTokenExp, claims...)
+
+	if err != nil {
+		err = fmt.Errorf("password reset jwt generation error due to: %s", err.Error())
+		return
+	}
+	return
+}
+
+func (i *Issuer) GenerateMFAJWT(sub string, claims ...Claim) (mfaToken string, err error) {
+	mfaToken, err = i.generateJWT(sub, i.cfg.MFATokenExp, claims...)
+
+	if err != nil {
+		err = fmt.Errorf("mfa jwt generation error due to: %s", err.Error())
+		return
+	}
+	return
+}
+
+func (i *Issuer) GenerateOAuthStateJWT(sub string, claims ...Claim) (stateToken string, err error) {
+	stateToken, err = i.generateJWT(sub, i.cfg.OAuthStateTokenExp, claims...)
+
+	if err != nil {
+		err = fmt.Errorf("oauth state jwt generation error due to: %s", err.Error())
+		return
+	}
+	return
+}
+
+func (i *Issuer) GenerateIDJWT(sub string, claims ...Claim) (idToken string, err error) {
+	idToken, err = i.generateJWT(sub, i.cfg.IDTokenExp, claims...)
+
+	if err != nil {
+		err = fmt.Errorf("id jwt generation error due to: %s", err.Error())
+		return
+	}
+	return
+}
+
+func (i *Issuer) GenerateDataExportJWT(sub string, claims ...Claim) (downloadToken string, err error) {
+	downloadToken, err = i.generateJWT(sub, i.cfg.DataExportTokenExp, claims...)
+
+	if err != nil {
+		err = fmt.Errorf("data export jwt generation error due to: %s", err.Error())
+		return
+	}
+	return
+}
+
+func (i *Issuer) generateJWT(sub string, exp time.Duration, claims ...Claim) (jwtToken string, err error) {
+	return i.generateTypedJWT("", sub, exp, claims...)
+}
+
+func (i *Issuer) generateTypedJWT(typ string, sub string, exp time.Duration, claims ...Claim) (jwtToken string, err error) {
+	key := i.currentSigningKey()
+	if key == nil {
+		return "", fmt.Errorf("no signing key")
+	}
+	token := jwt.New(key.Method)
+	token.Header["kid"] = key.ID
+	if typ != "" {
+		token.Header["typ"] = typ
+	}
 	tokenClaims := token.Claims.(jwt.MapClaims)
 
+	now := time.Now().Unix()
 	tokenClaims["sub"] = sub
+	if i.cfg.Issuer != "" {
+		tokenClaims["iss"] = i.cfg.Issuer
+	}
 	for _, claim := range claims {
 		tokenClaims[claim.Name] = claim.Value
 	}
-	tokenClaims["iat"] = time.Now().Unix()
-	tokenClaims["exp"] = time.Now().Unix() + int64(exp)
+	tokenClaims["iat"] = now
+	tokenClaims["nbf"] = now
+	tokenClaims["exp"] = now + int64(exp.Seconds())
 
-	jwtToken, err = token.SignedString(secretKey)
+	jwtToken, err = token.SignedString(key.signingKey)
 	return
 }
+
+// generateJTI returns random identifier used to revoke single access token
+func generateJTI() (string, error) {
+	raw := make([]byte, 16)
+	_, err := rand.Read(raw)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
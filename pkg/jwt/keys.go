@@ -0,0 +1,340 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// KeyState defines what key is used for during rotation
+type KeyState string
+
+const (
+	// KeyActive signs new tokens
+	KeyActive KeyState = "active"
+	// KeyVerifyOnly is published and accepted for verification only, e.g. previous key until its tokens expire
+	KeyVerifyOnly KeyState = "verify-only"
+	// KeyRetired is neither published nor accepted
+	KeyRetired KeyState = "retired"
+)
+
+// Key is a signing or verification key identified by kid header of tokens
+type Key struct {
+	ID     string
+	Method jwt.SigningMethod
+	State  KeyState
+	// signing key is nil for verify-only keys
+	signingKey      interface{}
+	verificationKey interface{}
+}
+
+// JSONWebKey is public part of asymmetric key in RFC 7517 format
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+}
+
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// InitSigningKeys loads private key from privateKeyFile for signing and
+// keys from verificationKeyFiles which are accepted only for verification
+func (i *Issuer) InitSigningKeys(privateKeyFile string, keyID string, verificationKeyFiles []string) error {
+	var keys []*Key
+	if path := privateKeyFile; path != "" {
+		key, err := LoadKeyFile(path)
+		if err != nil {
+			return err
+		}
+		if key.signingKey == nil {
+			return fmt.Errorf("file '%s' has no private key", path)
+		}
+		if keyID != "" {
+			key.ID = keyID
+		}
+		keys = append(keys, key)
+	}
+	for _, path := range verificationKeyFiles {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		key, err := LoadKeyFile(path)
+		if err != nil {
+			return err
+		}
+		key.signingKey = nil
+		keys = append(keys, key)
+	}
+	if len(keys) > 0 {
+		i.SetKeys(keys...)
+	}
+	return nil
+}
+
+// SetKeys replaces known keys, the first active key with private part becomes the signing one, retired keys are dropped
+func (i *Issuer) SetKeys(keys ...*Key) {
+	i.keysMu.Lock()
+	defer i.keysMu.Unlock()
+
+	i.activeKey = nil
+	i.verificationKeys = map[string]*Key{}
+	for _, key := range keys {
+		if key.State == KeyRetired {
+			continue
+		}
+		if i.activeKey == nil && key.State == KeyActive && key.signingKey != nil {
+			i.activeKey = key
+		}
+		i.verificationKeys[key.ID] = key
+	}
+}
+
+// Keys returns known asymmetric keys, the signing one first
+func (i *Issuer) Keys() []*Key {
+	i.keysMu.RLock()
+	defer i.keysMu.RUnlock()
+
+	var keys []*Key
+	if i.activeKey != nil {
+		keys = append(keys, i.activeKey)
+	}
+	for _, key := range i.verificationKeys {
+		if key != i.activeKey {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// GenerateKey creates new private key for RS256 or EdDSA algorithm
+func GenerateKey(alg string) (*Key, error) {
+	var privateKey interface{}
+	var err error
+	switch alg {
+	case jwt.SigningMethodRS256.Alg():
+		privateKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	case jwt.SigningMethodEdDSA.Alg():
+		_, privateKey, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm '%s'", alg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("key generation error: %v", err)
+	}
+	return NewKey(privateKey)
+}
+
+// ParseKey reads DER encoded PKCS#8 private or PKIX public key
+func ParseKey(der []byte) (*Key, error) {
+	parsedKey, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		parsedKey, err = x509.ParsePKIXPublicKey(der)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing key error: %v", err)
+	}
+	return NewKey(parsedKey)
+}
+
+// MaxTokenExp returns lifetime of the longest living token, key must stay verifiable this long after it stops signing
+func (i *Issuer) MaxTokenExp() time.Duration {
+	return max(
+		i.cfg.AccessTokenExp, i.cfg.RefreshTokenExp, i.cfg.EmailVerificationTokenExp, i.cfg.PasswordResetTokenExp,
+		i.cfg.MFATokenExp, i.cfg.OAuthStateTokenExp, i.cfg.IDTokenExp, i.cfg.DataExportTokenExp,
+	)
+}
+
+// LoadKeyFile reads RSA or Ed25519 key in PEM format, private keys may be PKCS#1 or PKCS#8, public keys PKIX
+func LoadKeyFile(path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading key file '%s' error: %v", path, err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("file '%s' has no PEM block", path)
+	}
+
+	var parsedKey interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsedKey, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsedKey, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsedKey, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		err = fmt.Errorf("unsupported PEM block '%s'", block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing key file '%s' error: %v", path, err)
+	}
+	return NewKey(parsedKey)
+}
+
+// NewKey wraps RSA or Ed25519 key, kid is RFC 7638 thumbprint of public key
+func NewKey(parsedKey interface{}) (*Key, error) {
+	key := &Key{}
+	switch k := parsedKey.(type) {
+	case *rsa.PrivateKey:
+		key.Method, key.signingKey, key.verificationKey = jwt.SigningMethodRS256, k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.Method, key.verificationKey = jwt.SigningMethodRS256, k
+	case ed25519.PrivateKey:
+		key.Method, key.signingKey, key.verificationKey = jwt.SigningMethodEdDSA, k, k.Public()
+	case ed25519.PublicKey:
+		key.Method, key.verificationKey = jwt.SigningMethodEdDSA, k
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsedKey)
+	}
+
+	thumbprint, err := key.thumbprint()
+	if err != nil {
+		return nil, err
+	}
+	key.ID = thumbprint
+	key.State = KeyVerifyOnly
+	if key.signingKey != nil {
+		key.State = KeyActive
+	}
+	return key, nil
+}
+
+// PublicJWKS returns public keys of all known asymmetric keys
+func (i *Issuer) PublicJWKS() JSONWebKeySet {
+	i.keysMu.RLock()
+	defer i.keysMu.RUnlock()
+
+	jwks := JSONWebKeySet{Keys: []JSONWebKey{}}
+	for _, key := range i.verificationKeys {
+		jwks.Keys = append(jwks.Keys, key.JSONWebKey())
+	}
+	sort.Slice(jwks.Keys, func(i, j int) bool {
+		return jwks.Keys[i].KeyID < jwks.Keys[j].KeyID
+	})
+	return jwks
+}
+
+// SigningAlgorithm returns alg header of newly issued tokens
+func (i *Issuer) SigningAlgorithm() string {
+	key := i.currentSigningKey()
+	if key == nil {
+		return ""
+	}
+	return key.Method.Alg()
+}
+
+// MarshalPrivateKey returns DER encoded PKCS#8 private key
+func (k *Key) MarshalPrivateKey() ([]byte, error) {
+	if k.signingKey == nil {
+		return nil, fmt.Errorf("key '%s' has no private part", k.ID)
+	}
+	return x509.MarshalPKCS8PrivateKey(k.signingKey)
+}
+
+// MarshalPublicKey returns DER encoded PKIX public key
+func (k *Key) MarshalPublicKey() ([]byte, error) {
+	return x509.MarshalPKIXPublicKey(k.verificationKey)
+}
+
+func (k *Key) HasPrivateKey() bool {
+	return k.signingKey != nil
+}
+
+func (k *Key) JSONWebKey() JSONWebKey {
+	jwk := JSONWebKey{
+		KeyID:     k.ID,
+		Use:       "sig",
+		Algorithm: k.Method.Alg(),
+	}
+	switch publicKey := k.verificationKey.(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(publicKey)
+	}
+	return jwk
+}
+
+func (k *Key) thumbprint() (string, error) {
+	jwk := k.JSONWebKey()
+	// members in lexicographic order as required by RFC 7638
+	var members interface{}
+	if jwk.KeyType == "RSA" {
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.KeyType, jwk.N}
+	} else {
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Curve, jwk.KeyType, jwk.X}
+	}
+	data, err := json.Marshal(members)
+	if err != nil {
+		return "", fmt.Errorf("key thumbprint error: %v", err)
+	}
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+func (i *Issuer) currentSigningKey() *Key {
+	i.keysMu.RLock()
+	defer i.keysMu.RUnlock()
+
+	if i.activeKey != nil {
+		return i.activeKey
+	}
+	return i.legacyKey
+}
+
+func (i *Issuer) verificationKey(keyID string) (*Key, bool) {
+	i.keysMu.RLock()
+	defer i.keysMu.RUnlock()
+
+	if key, ok := i.verificationKeys[keyID]; ok {
+		return key, true
+	}
+	// tokens issued before key ids appeared are signed with SECRET_KEY
+	if i.legacyKey != nil && (keyID == "" || keyID == i.legacyKey.ID) {
+		return i.legacyKey, true
+	}
+	return nil, false
+}
+
+func secretKeyHS256(secretKey []byte) *Key {
+	// kid must not disclose anything about secret, so it is derived through separate hash
+	sum := sha256.Sum256(append([]byte("kid:"), secretKey...))
+	return &Key{
+		ID:              base64.RawURLEncoding.EncodeToString(sum[:12]),
+		Method:          jwt.SigningMethodHS256,
+		signingKey:      secretKey,
+		verificationKey: secretKey,
+	}
+}
@@ -0,0 +1,198 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeKeyFile(t *testing.T, key interface{}) string {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "key.pem")
+	err = os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600)
+	assert.NoError(t, err)
+	return path
+}
+
+func newTestIssuer(legacyKeyEnabled bool) *Issuer {
+	return NewIssuer(Config{
+		SecretKey:        "secret",
+		AccessTokenExp:   5 * time.Minute,
+		RefreshTokenExp:  time.Hour,
+		LegacyKeyEnabled: legacyKeyEnabled,
+	})
+}
+
+func headerOf(t *testing.T, token string) map[string]interface{} {
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+	assert.NoError(t, err)
+	return parsed.Header
+}
+
+func TestAsymmetricSigning(t *testing.T) {
+	issuer := newTestIssuer(true)
+
+	_, edPrivateKey, _ := ed25519.GenerateKey(rand.Reader)
+	rsaPrivateKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	legacyToken, err := issuer.GenerateAccessJWT("user")
+	assert.NoError(t, err)
+
+	t.Run("successful EdDSA signing with key from PEM file", func(t *testing.T) {
+		err := issuer.InitSigningKeys(writeKeyFile(t, edPrivateKey), "", nil)
+		assert.NoError(t, err)
+
+		token, err := issuer.GenerateAccessJWT("user")
+		assert.NoError(t, err)
+		header := headerOf(t, token)
+		assert.Equal(t, "EdDSA", header["alg"])
+		assert.NotEmpty(t, header["kid"])
+
+		claims, err := issuer.ParseJWT(token)
+		assert.NoError(t, err)
+		assert.Equal(t, "user", claims["sub"])
+
+		jwks := issuer.PublicJWKS()
+		assert.Len(t, jwks.Keys, 1)
+		assert.Equal(t, header["kid"], jwks.Keys[0].KeyID)
+		assert.Equal(t, "OKP", jwks.Keys[0].KeyType)
+	})
+	t.Run("successful RS256 signing with previous key kept for verification", func(t *testing.T) {
+		edToken, _ := issuer.GenerateAccessJWT("user")
+		err := issuer.InitSigningKeys(writeKeyFile(t, rsaPrivateKey), "", []string{writeKeyFile(t, edPrivateKey)})
+		assert.NoError(t, err)
+
+		token, err := issuer.GenerateAccessJWT("user")
+		assert.NoError(t, err)
+		assert.Equal(t, "RS256", headerOf(t, token)["alg"])
+
+		_, err = issuer.ParseJWT(token)
+		assert.NoError(t, err)
+		_, err = issuer.ParseJWT(edToken)
+		assert.NoError(t, err)
+		assert.Len(t, issuer.PublicJWKS().Keys, 2)
+	})
+	t.Run("successful verification of token issued with secret key", func(t *testing.T) {
+		_, err := issuer.ParseJWT(legacyToken)
+		assert.NoError(t, err)
+	})
+	t.Run("unsuccessful verification due to unknown key", func(t *testing.T) {
+		_, otherKey, _ := ed25519.GenerateKey(rand.Reader)
+		key, _ := NewKey(otherKey)
+		token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, jwt.MapClaims{"sub": "user", "exp": 9999999999})
+		token.Header["kid"] = key.ID
+		signedToken, _ := token.SignedString(otherKey)
+
+		_, err := issuer.ParseJWT(signedToken)
+		assert.Error(t, err)
+	})
+	t.Run("unsuccessful verification due to algorithm not bound to key", func(t *testing.T) {
+		rsaKey, _ := NewKey(rsaPrivateKey)
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "user", "exp": 9999999999})
+		token.Header["kid"] = rsaKey.ID
+		signedToken, _ := token.SignedString(x509.MarshalPKCS1PublicKey(&rsaPrivateKey.PublicKey))
+
+		_, err := issuer.ParseJWT(signedToken)
+		assert.Error(t, err)
+	})
+}
+
+func TestKeyStates(t *testing.T) {
+	issuer := newTestIssuer(true)
+
+	previousKey, _ := GenerateKey("EdDSA")
+	currentKey, _ := GenerateKey("RS256")
+	nextKey, _ := GenerateKey("EdDSA")
+
+	issuer.SetKeys(previousKey)
+	previousToken, _ := issuer.GenerateAccessJWT("user")
+
+	t.Run("successful signing with active key only", func(t *testing.T) {
+		previousKey.State = KeyVerifyOnly
+		nextKey.State = KeyVerifyOnly
+		issuer.SetKeys(nextKey, currentKey, previousKey)
+
+		token, err := issuer.GenerateAccessJWT("user")
+		assert.NoError(t, err)
+		assert.Equal(t, currentKey.ID, headerOf(t, token)["kid"])
+		assert.Len(t, issuer.PublicJWKS().Keys, 3)
+	})
+	t.Run("successful verification with verify-only key", func(t *testing.T) {
+		_, err := issuer.ParseJWT(previousToken)
+		assert.NoError(t, err)
+	})
+	t.Run("successful key restore from DER", func(t *testing.T) {
+		der, err := currentKey.MarshalPrivateKey()
+		assert.NoError(t, err)
+		restoredKey, err := ParseKey(der)
+		assert.NoError(t, err)
+		assert.Equal(t, currentKey.ID, restoredKey.ID)
+		assert.True(t, restoredKey.HasPrivateKey())
+
+		der, err = currentKey.MarshalPublicKey()
+		assert.NoError(t, err)
+		restoredKey, err = ParseKey(der)
+		assert.NoError(t, err)
+		assert.Equal(t, currentKey.ID, restoredKey.ID)
+		assert.False(t, restoredKey.HasPrivateKey())
+	})
+	t.Run("unsuccessful verification with retired key", func(t *testing.T) {
+		previousKey.State = KeyRetired
+		issuer.SetKeys(currentKey, previousKey)
+
+		_, err := issuer.ParseJWT(previousToken)
+		assert.Error(t, err)
+		assert.Len(t, issuer.PublicJWKS().Keys, 1)
+	})
+}
+
+func TestLegacyKeyDisabled(t *testing.T) {
+	legacyToken, err := newTestIssuer(true).GenerateAccessJWT("user")
+	assert.NoError(t, err)
+	issuer := newTestIssuer(false)
+
+	t.Run("unsuccessful verification of token issued with secret key", func(t *testing.T) {
+		_, err := issuer.ParseJWT(legacyToken)
+		assert.Error(t, err)
+	})
+	t.Run("unsuccessful verification of token without kid", func(t *testing.T) {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "user", "exp": 9999999999})
+		signedToken, err := token.SignedString([]byte("secret"))
+		assert.NoError(t, err)
+
+		_, err = issuer.ParseJWT(signedToken)
+		assert.Error(t, err)
+	})
+	t.Run("unsuccessful signing without asymmetric key", func(t *testing.T) {
+		_, err := issuer.GenerateAccessJWT("user")
+		assert.Error(t, err)
+	})
+	t.Run("successful signing and verification with asymmetric key", func(t *testing.T) {
+		key, _ := GenerateKey("EdDSA")
+		issuer.SetKeys(key)
+
+		token, err := issuer.GenerateAccessJWT("user")
+		assert.NoError(t, err)
+		_, err = issuer.ParseJWT(token)
+		assert.NoError(t, err)
+	})
+}
+
+func TestMaxTokenExp(t *testing.T) {
+	issuer := NewIssuer(Config{
+		AccessTokenExp:     5 * time.Minute,
+		RefreshTokenExp:    time.Hour,
+		DataExportTokenExp: 2 * time.Hour,
+	})
+
+	assert.Equal(t, 2*time.Hour, issuer.MaxTokenExp())
+}
@@ -0,0 +1,93 @@
+package jwt
+
+import (
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"slices"
+)
+
+// ParseJWT verifies signature, expiration and not before time of the token and returns its claims
+func (i *Issuer) ParseJWT(jwtToken string) (claims map[string]interface{}, err error) {
+	token, err := i.parse(jwtToken)
+	if err != nil {
+		err = fmt.Errorf("jwt parsing error due to: %s", err.Error())
+		return
+	}
+	return token.Claims.(jwt.MapClaims), nil
+}
+
+// ParseAccessJWT additionally checks that token is an access token issued for Audience by Issuer,
+// tokens of OAuth clients are not accepted
+func (i *Issuer) ParseAccessJWT(accessToken string) (claims map[string]interface{}, err error) {
+	var options []jwt.ParserOption
+	if i.cfg.Audience != "" {
+		options = append(options, jwt.WithAudience(i.cfg.Audience))
+	}
+	claims, err = i.parseAccessToken(accessToken, options...)
+	if err == nil && claims["client_id"] != nil {
+		err = fmt.Errorf("token is issued to OAuth client")
+	}
+	if err != nil {
+		err = fmt.Errorf("access jwt parsing error due to: %s", err.Error())
+		return
+	}
+	return
+}
+
+// ParseClientAccessJWT checks that token is an access token issued by Issuer to OAuth client named by its audience
+func (i *Issuer) ParseClientAccessJWT(accessToken string) (claims map[string]interface{}, err error) {
+	claims, err = i.parseAccessToken(accessToken)
+	if err == nil {
+		clientID, _ := claims["client_id"].(string)
+		audience, _ := jwt.MapClaims(claims).GetAudience()
+		if clientID == "" || !slices.Contains(audience, clientID) {
+			err = fmt.Errorf("token is not issued to OAuth client")
+		}
+	}
+	if err != nil {
+		err = fmt.Errorf("client access jwt parsing error due to: %s", err.Error())
+		return
+	}
+	return
+}
+
+func (i *Issuer) parseAccessToken(accessToken string, options ...jwt.ParserOption) (map[string]interface{}, error) {
+	if i.cfg.Issuer != "" {
+		options = append(options, jwt.WithIssuer(i.cfg.Issuer))
+	}
+	token, err := i.parse(accessToken, options...)
+	if err != nil {
+		return nil, err
+	}
+	if token.Header["typ"] != accessTokenType {
+		return nil, fmt.Errorf("token is not an access token")
+	}
+	return token.Claims.(jwt.MapClaims), nil
+}
+
+func (i *Issuer) parse(jwtToken string, options ...jwt.ParserOption) (*jwt.Token, error) {
+	options = append(options,
+		jwt.WithValidMethods([]string{
+			jwt.SigningMethodHS256.Alg(),
+			jwt.SigningMethodRS256.Alg(),
+			jwt.SigningMethodEdDSA.Alg(),
+		}),
+		jwt.WithExpirationRequired(),
+	)
+	return jwt.Parse(
+		jwtToken,
+		func(token *jwt.Token) (interface{}, error) {
+			keyID, _ := token.Header["kid"].(string)
+			key, ok := i.verificationKey(keyID)
+			if !ok {
+				return nil, fmt.Errorf("unknown key '%s'", keyID)
+			}
+			// algorithm is bound to the key, so token can not choose how it is verified
+			if token.Method.Alg() != key.Method.Alg() {
+				return nil, fmt.Errorf("unexpected signing method '%s'", token.Method.Alg())
+			}
+			return key.verificationKey, nil
+		},
+		options...,
+	)
+}
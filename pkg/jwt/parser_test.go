@@ -0,0 +1,102 @@
+package jwt
+
+import (
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func newAudienceIssuer(audience string) *Issuer {
+	return NewIssuer(Config{
+		SecretKey:        "secret",
+		Issuer:           "http://localhost:8090",
+		Audience:         audience,
+		AccessTokenExp:   5 * time.Minute,
+		RefreshTokenExp:  time.Hour,
+		LegacyKeyEnabled: true,
+	})
+}
+
+func TestParseAccessJWT(t *testing.T) {
+	issuer := newAudienceIssuer("code-typing")
+
+	signedWith := func(claims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		token.Header["typ"] = accessTokenType
+		signedToken, _ := token.SignedString([]byte("secret"))
+		return signedToken
+	}
+	claimsWith := func(name string, value interface{}) jwt.MapClaims {
+		claims := jwt.MapClaims{
+			"sub": "user",
+			"iss": "http://localhost:8090",
+			"aud": "code-typing",
+			"nbf": time.Now().Unix(),
+			"exp": time.Now().Add(time.Minute).Unix(),
+		}
+		claims[name] = value
+		return claims
+	}
+
+	t.Run("successful access token validation", func(t *testing.T) {
+		accessToken, err := issuer.GenerateAccessJWT("user")
+		assert.NoError(t, err)
+
+		claims, err := issuer.ParseAccessJWT(accessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, "user", claims["sub"])
+		assert.Equal(t, "http://localhost:8090", claims["iss"])
+		assert.NotEmpty(t, claims["jti"])
+	})
+	t.Run("unsuccessful validation of refresh token", func(t *testing.T) {
+		refreshToken, _ := issuer.GenerateRefreshJWT("user")
+
+		_, err := issuer.ParseAccessJWT(refreshToken)
+		assert.Error(t, err)
+	})
+	t.Run("unsuccessful validation due to another issuer", func(t *testing.T) {
+		_, err := issuer.ParseAccessJWT(signedWith(claimsWith("iss", "https://attacker.example.com")))
+		assert.Error(t, err)
+	})
+	t.Run("unsuccessful validation due to another audience", func(t *testing.T) {
+		_, err := issuer.ParseAccessJWT(signedWith(claimsWith("aud", "results-service")))
+		assert.Error(t, err)
+	})
+	t.Run("unsuccessful validation of not yet valid token", func(t *testing.T) {
+		_, err := issuer.ParseAccessJWT(signedWith(claimsWith("nbf", time.Now().Add(time.Minute).Unix())))
+		assert.Error(t, err)
+	})
+	t.Run("unsuccessful validation of expired token", func(t *testing.T) {
+		_, err := issuer.ParseAccessJWT(signedWith(claimsWith("exp", time.Now().Add(-time.Minute).Unix())))
+		assert.Error(t, err)
+	})
+}
+
+func TestParseClientAccessJWT(t *testing.T) {
+	issuer := newAudienceIssuer("code-typing")
+
+	clientToken, err := issuer.GenerateClientAccessJWT("user", "client", Claim{Name: "scope", Value: "openid"})
+	assert.NoError(t, err)
+
+	t.Run("successful client access token validation", func(t *testing.T) {
+		claims, err := issuer.ParseClientAccessJWT(clientToken)
+		assert.NoError(t, err)
+		assert.Equal(t, "client", claims["client_id"])
+		assert.Equal(t, "client", claims["aud"])
+	})
+	t.Run("unsuccessful validation of client token as first-party token", func(t *testing.T) {
+		_, err := issuer.ParseAccessJWT(clientToken)
+		assert.Error(t, err)
+	})
+	t.Run("unsuccessful validation of client token as first-party token without audience", func(t *testing.T) {
+		_, err := newAudienceIssuer("").ParseAccessJWT(clientToken)
+		assert.Error(t, err)
+	})
+	t.Run("unsuccessful validation of first-party token as client token", func(t *testing.T) {
+		accessToken, _ := issuer.GenerateAccessJWT("user")
+
+		_, err := issuer.ParseClientAccessJWT(accessToken)
+		assert.Error(t, err)
+	})
+}
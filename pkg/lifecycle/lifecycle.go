@@ -0,0 +1,116 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/logging"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Hook releases resources of subsystem, it must return once ctx is done
+type Hook func(ctx context.Context) error
+
+type hook struct {
+	name string
+	fn   Hook
+}
+
+// Lifecycle stops subsystems in reverse order of registration, so every subsystem stops before ones it depends on
+type Lifecycle struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	tasks    sync.WaitGroup
+	mu       sync.Mutex
+	hooks    []hook
+	shutdown chan struct{}
+	once     sync.Once
+	log      logging.Logger
+}
+
+func New(log logging.Logger) *Lifecycle {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Lifecycle{
+		ctx:      ctx,
+		cancel:   cancel,
+		shutdown: make(chan struct{}),
+		log:      log,
+	}
+}
+
+// Context is cancelled when shutdown begins
+func (l *Lifecycle) Context() context.Context {
+	return l.ctx
+}
+
+// Go runs background task, Stop waits for it to return after Context is cancelled
+func (l *Lifecycle) Go(task func(ctx context.Context)) {
+	l.tasks.Add(1)
+	go func() {
+		defer l.tasks.Done()
+		task(l.ctx)
+	}()
+}
+
+func (l *Lifecycle) OnStop(name string, fn Hook) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.hooks = append(l.hooks, hook{name: name, fn: fn})
+}
+
+// Shutdown makes Wait return without signal, e.g. when server failed
+func (l *Lifecycle) Shutdown() {
+	l.once.Do(func() {
+		close(l.shutdown)
+	})
+}
+
+// Wait blocks until SIGINT or SIGTERM is received or Shutdown is called
+func (l *Lifecycle) Wait() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	select {
+	case sig := <-signals:
+		l.log.Infof("received %s signal", sig)
+	case <-l.shutdown:
+	}
+}
+
+// Stop cancels Context, waits for background tasks and runs hooks, all within timeout.
+// Failed hook does not prevent the rest from running, errors of all of them are returned
+func (l *Lifecycle) Stop(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var errs []error
+	l.cancel()
+	done := make(chan struct{})
+	go func() {
+		l.tasks.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		errs = append(errs, fmt.Errorf("background tasks not stopped: %w", ctx.Err()))
+	}
+
+	l.mu.Lock()
+	hooks := l.hooks
+	l.mu.Unlock()
+	for i := len(hooks) - 1; i >= 0; i-- {
+		l.log.Infof("stopping %s", hooks[i].name)
+		err := hooks[i].fn(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s not stopped: %w", hooks[i].name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
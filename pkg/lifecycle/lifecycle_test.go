@@ -0,0 +1,62 @@
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/ttodoshi/code-typing-auth-service/pkg/logging/nop"
+	"testing"
+	"time"
+)
+
+func TestStop(t *testing.T) {
+	var log = nop.GetLogger()
+
+	t.Run("successful stop in reverse order after background tasks", func(t *testing.T) {
+		lc := New(log)
+		var stopped []string
+		lc.Go(func(ctx context.Context) {
+			<-ctx.Done()
+			stopped = append(stopped, "task")
+		})
+		lc.OnStop("database", func(ctx context.Context) error {
+			stopped = append(stopped, "database")
+			return nil
+		})
+		lc.OnStop("server", func(ctx context.Context) error {
+			stopped = append(stopped, "server")
+			return nil
+		})
+
+		lc.Shutdown()
+		lc.Wait()
+		err := lc.Stop(time.Second)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"task", "server", "database"}, stopped)
+	})
+	t.Run("unsuccessful stop due to failed hook does not skip the rest", func(t *testing.T) {
+		lc := New(log)
+		databaseStopped := false
+		lc.OnStop("database", func(ctx context.Context) error {
+			databaseStopped = true
+			return nil
+		})
+		lc.OnStop("broker", func(ctx context.Context) error {
+			return fmt.Errorf("connection lost")
+		})
+
+		err := lc.Stop(time.Second)
+		assert.ErrorContains(t, err, "broker not stopped: connection lost")
+		assert.True(t, databaseStopped)
+	})
+	t.Run("unsuccessful stop due to hook exceeding timeout", func(t *testing.T) {
+		lc := New(log)
+		lc.OnStop("server", func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+
+		err := lc.Stop(10 * time.Millisecond)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
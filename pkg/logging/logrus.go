@@ -49,7 +49,16 @@ func Init() {
 		LogLevels: logrus.AllLevels,
 	})
 
-	switch os.Getenv("LOG_LEVEL") {
+	l.SetLevel(logrus.DebugLevel)
+
+	e = logrus.NewEntry(l)
+}
+
+// SetLevel changes level of logger returned by GetLogger, unknown level is treated as debug
+func SetLevel(level string) {
+	once.Do(Init)
+	l := e.Logger
+	switch level {
 	case Trace:
 		l.SetLevel(logrus.TraceLevel)
 	case Debug:
@@ -63,8 +72,6 @@ func Init() {
 	default:
 		l.SetLevel(logrus.DebugLevel)
 	}
-
-	e = logrus.NewEntry(l)
 }
 
 type writerHook struct {
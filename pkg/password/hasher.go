@@ -1,19 +1,129 @@
 package password
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
+	"strings"
 )
 
-func HashPassword(password string) (string, error) {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+// Hasher creates hashes which encode algorithm and parameters, so any of them is verified by VerifyPassword
+type Hasher interface {
+	Hash(password string) (string, error)
+	// NeedsRehash reports whether hash was made by another algorithm or with other parameters
+	NeedsRehash(hashedPassword string) bool
+}
+
+var ErrMismatchedPassword = errors.New("password does not match")
 
+// HashPassword hashes password with bcrypt of default cost, services hash passwords with configured Hasher
+func HashPassword(password string) (string, error) {
+	hashedPassword, err := BcryptHasher{Cost: bcrypt.DefaultCost}.Hash(password)
 	if err != nil {
 		return "", fmt.Errorf("could not hash password %w", err)
 	}
-	return string(hashedPassword), nil
+	return hashedPassword, nil
 }
 
 func VerifyPassword(hashedPassword string, candidatePassword string) error {
+	if strings.HasPrefix(hashedPassword, argon2idPrefix) {
+		return verifyArgon2id(hashedPassword, candidatePassword)
+	}
 	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(candidatePassword))
 }
+
+type BcryptHasher struct {
+	Cost int
+}
+
+func (h BcryptHasher) Hash(password string) (string, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashedPassword), nil
+}
+
+func (h BcryptHasher) NeedsRehash(hashedPassword string) bool {
+	cost, err := bcrypt.Cost([]byte(hashedPassword))
+	return err != nil || cost != h.Cost
+}
+
+const argon2idPrefix = "$argon2id$"
+
+// Argon2idHasher encodes hashes in PHC string format: $argon2id$v=19$m=<KiB>,t=<iterations>,p=<parallelism>$<salt>$<key>
+type Argon2idHasher struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+type argon2idHash struct {
+	Argon2idHasher
+	salt []byte
+	key  []byte
+}
+
+func (h Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.SaltLength)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.Iterations, h.Memory, h.Parallelism, h.KeyLength)
+	return fmt.Sprintf(
+		"%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idPrefix, argon2.Version, h.Memory, h.Iterations, h.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (h Argon2idHasher) NeedsRehash(hashedPassword string) bool {
+	hash, err := parseArgon2id(hashedPassword)
+	return err != nil || hash.Argon2idHasher != h
+}
+
+func verifyArgon2id(hashedPassword string, candidatePassword string) error {
+	hash, err := parseArgon2id(hashedPassword)
+	if err != nil {
+		return err
+	}
+	key := argon2.IDKey([]byte(candidatePassword), hash.salt, hash.Iterations, hash.Memory, hash.Parallelism, hash.KeyLength)
+	if subtle.ConstantTimeCompare(key, hash.key) != 1 {
+		return ErrMismatchedPassword
+	}
+	return nil
+}
+
+func parseArgon2id(hashedPassword string) (hash argon2idHash, err error) {
+	parts := strings.Split(hashedPassword, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return hash, errors.New("hash is not argon2id hash")
+	}
+	var version int
+	_, err = fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil || version != argon2.Version {
+		return hash, fmt.Errorf("unsupported argon2id version '%s'", parts[2])
+	}
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &hash.Memory, &hash.Iterations, &hash.Parallelism)
+	if err != nil {
+		return hash, fmt.Errorf("invalid argon2id parameters '%s'", parts[3])
+	}
+	hash.salt, err = base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return hash, errors.New("invalid argon2id salt")
+	}
+	hash.key, err = base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(hash.key) == 0 {
+		return hash, errors.New("invalid argon2id key")
+	}
+	hash.SaltLength = uint32(len(hash.salt))
+	hash.KeyLength = uint32(len(hash.key))
+	return hash, nil
+}
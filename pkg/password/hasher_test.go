@@ -3,6 +3,7 @@ package password
 import (
 	"github.com/brianvoe/gofakeit/v6"
 	"golang.org/x/crypto/bcrypt"
+	"strings"
 	"testing"
 )
 
@@ -28,3 +29,48 @@ func TestVerifyPassword(t *testing.T) {
 		t.Errorf("VerifyPassword returned an error: %v", err)
 	}
 }
+
+func TestArgon2idHasher(t *testing.T) {
+	hasher := Argon2idHasher{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	password := gofakeit.Password(true, true, true, true, false, 8)
+	hashedPassword, err := hasher.Hash(password)
+	if err != nil {
+		t.Fatalf("Hash returned an error: %v", err)
+	}
+	if !strings.HasPrefix(hashedPassword, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Errorf("hash %q is not in PHC format", hashedPassword)
+	}
+
+	err = VerifyPassword(hashedPassword, password)
+	if err != nil {
+		t.Errorf("VerifyPassword returned an error: %v", err)
+	}
+	err = VerifyPassword(hashedPassword, "invalid_password")
+	if err == nil {
+		t.Error("VerifyPassword of invalid password returned no error")
+	}
+	if hasher.NeedsRehash(hashedPassword) {
+		t.Error("NeedsRehash of hash with current parameters = true")
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	argon2idHasher := Argon2idHasher{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	bcryptHash, _ := BcryptHasher{Cost: bcrypt.MinCost}.Hash("password")
+	argon2idHash, _ := argon2idHasher.Hash("password")
+
+	if !argon2idHasher.NeedsRehash(bcryptHash) {
+		t.Error("argon2id NeedsRehash of bcrypt hash = false")
+	}
+	stronger := argon2idHasher
+	stronger.Iterations = 2
+	if !stronger.NeedsRehash(argon2idHash) {
+		t.Error("argon2id NeedsRehash of hash with fewer iterations = false")
+	}
+	if !(BcryptHasher{Cost: bcrypt.DefaultCost}).NeedsRehash(bcryptHash) {
+		t.Error("bcrypt NeedsRehash of hash with lower cost = false")
+	}
+	if (BcryptHasher{Cost: bcrypt.MinCost}).NeedsRehash(bcryptHash) {
+		t.Error("bcrypt NeedsRehash of hash with current cost = true")
+	}
+}
@@ -0,0 +1,85 @@
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Policy allows bursts of Capacity requests, tokens are refilled evenly so the bucket is full again after Period
+type Policy struct {
+	Capacity int
+	Period   time.Duration
+}
+
+// ParsePolicy reads policy written as "<capacity>/<period in seconds>", empty string disables limiting
+func ParsePolicy(policy string) (Policy, error) {
+	if policy == "" {
+		return Policy{}, nil
+	}
+	capacity, period, ok := strings.Cut(policy, "/")
+	if !ok {
+		return Policy{}, fmt.Errorf("rate limit policy '%s' must be written as capacity/seconds", policy)
+	}
+	c, err := strconv.Atoi(capacity)
+	if err != nil || c <= 0 {
+		return Policy{}, fmt.Errorf("invalid capacity of rate limit policy '%s'", policy)
+	}
+	p, err := strconv.Atoi(period)
+	if err != nil || p <= 0 {
+		return Policy{}, fmt.Errorf("invalid period of rate limit policy '%s'", policy)
+	}
+	return Policy{
+		Capacity: c,
+		Period:   time.Duration(p) * time.Second,
+	}, nil
+}
+
+// UnmarshalText reads policy in ParsePolicy format, so policy may be loaded from configuration
+func (p *Policy) UnmarshalText(text []byte) (err error) {
+	*p, err = ParsePolicy(string(text))
+	return
+}
+
+func (p Policy) String() string {
+	if !p.Enabled() {
+		return ""
+	}
+	return fmt.Sprintf("%d/%d", p.Capacity, int(p.Period.Seconds()))
+}
+
+func (p Policy) Enabled() bool {
+	return p.Capacity > 0 && p.Period > 0
+}
+
+// Bucket is state of one limited key, zero bucket is full
+type Bucket struct {
+	Tokens    float64
+	UpdatedAt time.Time
+}
+
+// Take refills bucket for time passed since its update and takes one token,
+// retryAfter is positive when bucket is empty and request must be rejected
+func (p Policy) Take(bucket Bucket, now time.Time) (updated Bucket, retryAfter time.Duration) {
+	tokens := float64(p.Capacity)
+	if !bucket.UpdatedAt.IsZero() {
+		// clocks of replicas sharing bucket may differ, time never goes back for bucket
+		elapsed := max(now.Sub(bucket.UpdatedAt), 0)
+		tokens = min(tokens, bucket.Tokens+float64(elapsed)/float64(p.refillInterval()))
+	}
+	if tokens < 1 {
+		retryAfter = time.Duration((1 - tokens) * float64(p.refillInterval()))
+		return Bucket{Tokens: tokens, UpdatedAt: now}, retryAfter
+	}
+	return Bucket{Tokens: tokens - 1, UpdatedAt: now}, 0
+}
+
+// FullAt is time when bucket is full again, so it can be forgotten
+func (p Policy) FullAt(bucket Bucket) time.Time {
+	return bucket.UpdatedAt.Add(time.Duration((float64(p.Capacity) - bucket.Tokens) * float64(p.refillInterval())))
+}
+
+func (p Policy) refillInterval() time.Duration {
+	return p.Period / time.Duration(p.Capacity)
+}
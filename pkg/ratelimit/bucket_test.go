@@ -0,0 +1,47 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTake(t *testing.T) {
+	policy := Policy{Capacity: 3, Period: time.Minute}
+	now := time.Now()
+
+	var bucket Bucket
+	var retryAfter time.Duration
+	for i := 0; i < policy.Capacity; i++ {
+		bucket, retryAfter = policy.Take(bucket, now)
+		if retryAfter != 0 {
+			t.Fatalf("request %d of burst rejected", i+1)
+		}
+	}
+	bucket, retryAfter = policy.Take(bucket, now)
+	if retryAfter != 20*time.Second {
+		t.Errorf("Take of empty bucket retryAfter = %s, expected 20s", retryAfter)
+	}
+	_, retryAfter = policy.Take(bucket, now.Add(20*time.Second))
+	if retryAfter != 0 {
+		t.Errorf("Take after refill of one token rejected, retryAfter = %s", retryAfter)
+	}
+	if fullAt := policy.FullAt(bucket); !fullAt.Equal(now.Add(time.Minute)) {
+		t.Errorf("FullAt = %s, expected %s", fullAt, now.Add(time.Minute))
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	policy, err := ParsePolicy("5/60")
+	if err != nil || policy.Capacity != 5 || policy.Period != time.Minute {
+		t.Errorf("ParsePolicy(\"5/60\") = %v, %v", policy, err)
+	}
+	policy, err = ParsePolicy("")
+	if err != nil || policy.Enabled() {
+		t.Errorf("ParsePolicy(\"\") = %v, %v, expected disabled policy", policy, err)
+	}
+	for _, invalid := range []string{"5", "0/60", "5/0", "five/60"} {
+		if _, err = ParsePolicy(invalid); err == nil {
+			t.Errorf("ParsePolicy(%q) returned no error", invalid)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package totp
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+func TestGenerateCode(t *testing.T) {
+	// RFC 6238 test vectors for SHA1
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).
+		EncodeToString([]byte("12345678901234567890"))
+	vectors := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+	for unix, expected := range vectors {
+		code, err := GenerateCode(secret, time.Unix(unix, 0))
+		if err != nil {
+			t.Errorf("GenerateCode returned an error: %v", err)
+		}
+		if code != expected {
+			t.Errorf("GenerateCode at %d = %s, expected %s", unix, code, expected)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Errorf("GenerateSecret returned an error: %v", err)
+	}
+	now := time.Now()
+
+	code, _ := GenerateCode(secret, now.Add(-period*time.Second))
+	if !Validate(code, secret, now) {
+		t.Errorf("code from previous period is not valid")
+	}
+	code, _ = GenerateCode(secret, now.Add(-3*period*time.Second))
+	if Validate(code, secret, now) {
+		t.Errorf("outdated code is valid")
+	}
+	if Validate("abc", secret, now) {
+		t.Errorf("malformed code is valid")
+	}
+}
+
+func TestStep(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Errorf("GenerateSecret returned an error: %v", err)
+	}
+	now := time.Now()
+
+	code, _ := GenerateCode(secret, now.Add(-period*time.Second))
+	step, ok := Step(code, secret, now)
+	if !ok || step != now.Unix()/period-1 {
+		t.Errorf("Step of code from previous period = %d, %v, expected %d", step, ok, now.Unix()/period-1)
+	}
+	if _, ok = Step("abc", secret, now); ok {
+		t.Errorf("malformed code has step")
+	}
+}